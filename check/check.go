@@ -0,0 +1,563 @@
+// Package check finds and repairs problems in the references between the
+// objects of a Gramps database, like the Check and Repair tool of Gramps.
+package check
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+// The kinds of problems that are found.
+type Kind int
+
+const (
+	// A link to an object that does not exist.
+	DanglingLink Kind = iota
+	// A childof or parentin of a person that does not match the childrefs,
+	// father or mother of the family, or the reverse.
+	FamilyLink
+	// Several objects with the same handle.
+	DuplicateHandle
+	// Several objects of the same kind with the same Gramps ID.
+	DuplicateID
+	// A family with no father, mother or children.
+	EmptyFamily
+	// A name of a person with no parts.
+	EmptyName
+	// A citation without a source, or with a source that does not exist.
+	MissingSource
+	// A media object whose file does not exist.
+	MissingMedia
+	// A person who is their own ancestor.
+	PedigreeLoop
+	// A father or mother of a family who is also a child in it.
+	ParentIsChild
+	// A person born before one of their birth parents.
+	ChildBeforeParent
+)
+
+func (k Kind) String() string {
+	switch k {
+	case DanglingLink:
+		return "dangling link"
+	case FamilyLink:
+		return "family link"
+	case DuplicateHandle:
+		return "duplicate handle"
+	case DuplicateID:
+		return "duplicate ID"
+	case EmptyFamily:
+		return "empty family"
+	case EmptyName:
+		return "empty name"
+	case MissingSource:
+		return "missing source"
+	case MissingMedia:
+		return "missing media"
+	case PedigreeLoop:
+		return "pedigree loop"
+	case ParentIsChild:
+		return "parent is child"
+	case ChildBeforeParent:
+		return "child before parent"
+	}
+	return "unknown"
+}
+
+// A Problem found in a Database.
+type Problem struct {
+	Kind Kind
+	// The object with the problem, e.g. the person with a dangling link.
+	Handle string
+	ID     string
+	// A description of the problem.
+	Message string
+	// Whether the problem was repaired.
+	Fixed bool
+}
+
+func (p Problem) String() string {
+	s := p.Message
+	if p.Fixed {
+		s += " (fixed)"
+	}
+	return s
+}
+
+// Options controlling how a Database is checked.
+type Options struct {
+	// Repair the problems the way Gramps does: links to missing persons and
+	// families are removed, as are links to them that a family or person
+	// does not agree with; other links to missing objects get placeholder
+	// objects; links that a family or person lacks are added; duplicate IDs
+	// are replaced; empty families and empty alternate names are removed.
+	// Duplicate handles, missing media files and impossible pedigrees are
+	// only reported.
+	Repair bool
+	// The directory relative paths of media files are found in when the
+	// Header has no absolute media path, normally the directory of the file.
+	Dir string
+}
+
+// The kinds of objects that the links with these names refer to, when links
+// to missing objects are repaired by creating placeholders.
+var placeholderKinds = map[string]string{
+	"eventref":    "event",
+	"place":       "placeobj",
+	"placeref":    "placeobj",
+	"citationref": "citation",
+	"sourceref":   "source",
+	"noteref":     "note",
+	"objref":      "object",
+	"reporef":     "repository",
+	"tagref":      "tag",
+}
+
+type checker struct {
+	db       *xml.Database
+	opts     Options
+	problems []Problem
+	// The note linked from placeholder objects, created with the first.
+	explanation *xml.Note
+}
+
+// Check db for problems, repairing them if opts.Repair is set. The problems
+// found are returned, in the order they were found.
+func Check(db *xml.Database, opts Options) []Problem {
+	c := &checker{db: db, opts: opts}
+	db.Reindex()
+	c.duplicateHandles()
+	c.duplicateIDs()
+	c.danglingLinks()
+	c.familyLinks()
+	c.emptyFamilies()
+	c.emptyNames()
+	c.missingSources()
+	c.missingMedia()
+	c.pedigree()
+	if opts.Repair {
+		db.Reindex()
+	}
+	return c.problems
+}
+
+// Describe an object for messages, e.g. person I0001.
+func describe(obj xml.DBObj) string {
+	name := xml.ElementName(obj)
+	if name == "placeobj" {
+		name = "place"
+	}
+	if id := idOf(obj); id != "" {
+		return name + " " + id
+	}
+	if tag, ok := obj.(*xml.Tag); ok && tag.Name != "" {
+		return name + " " + tag.Name
+	}
+	return name + " " + obj.GetHandle()
+}
+
+func idOf(obj xml.DBObj) string {
+	if o, ok := obj.(interface {
+		GetID() string
+	}); ok {
+		return o.GetID()
+	}
+	return ""
+}
+
+func (c *checker) report(kind Kind, obj xml.DBObj, fixed bool, format string, args ...interface{}) {
+	p := Problem{Kind: kind, Message: fmt.Sprintf(format, args...), Fixed: fixed}
+	if obj != nil {
+		p.Handle, p.ID = obj.GetHandle(), idOf(obj)
+	}
+	c.problems = append(c.problems, p)
+}
+
+func (c *checker) duplicateHandles() {
+	seen := make(map[string]xml.DBObj)
+	for _, obj := range c.db.DBObjs() {
+		h := obj.GetHandle()
+		if first, ok := seen[h]; ok {
+			c.report(DuplicateHandle, obj, false, "%s has the handle %s of %s",
+				describe(obj), h, describe(first))
+			continue
+		}
+		seen[h] = obj
+	}
+}
+
+func (c *checker) duplicateIDs() {
+	seen := make(map[string]bool)
+	var dups []xml.DBObj
+	for _, obj := range c.db.DBObjs() {
+		id := idOf(obj)
+		if id == "" {
+			continue
+		}
+		key := xml.ElementName(obj) + " " + id
+		if seen[key] {
+			dups = append(dups, obj)
+		}
+		seen[key] = true
+	}
+
+	for _, obj := range dups {
+		old := describe(obj)
+		if !c.opts.Repair {
+			c.report(DuplicateID, obj, false, "%s is used by several objects", old)
+			continue
+		}
+		if err := c.db.Renumber(obj); err != nil {
+			c.report(DuplicateID, obj, false, "%s is used by several objects: %s", old, err)
+			continue
+		}
+		c.report(DuplicateID, obj, true, "%s is used by several objects; changed to %s",
+			old, idOf(obj))
+	}
+}
+
+func (c *checker) danglingLinks() {
+	var dangling []xml.Reference
+	for _, ref := range c.db.Links() {
+		if c.db.ByHandle(ref.HLink(c.db)) == nil {
+			dangling = append(dangling, ref)
+		}
+	}
+
+	for _, ref := range dangling {
+		h := ref.HLink(c.db)
+		from := "database"
+		if ref.From != nil {
+			from = describe(ref.From)
+		}
+		parts := strings.Split(ref.Path, ".")
+		last := parts[len(parts)-1]
+		kind := DanglingLink
+		if ref.Path == "citation.sourceref" {
+			kind = MissingSource
+		}
+		msg := fmt.Sprintf("%s has a %s link to missing object %s", from, ref.Path, h)
+		if !c.opts.Repair {
+			c.report(kind, ref.From, false, "%s", msg)
+			continue
+		}
+
+		if name, ok := placeholderKinds[last]; ok {
+			// Other links to the same object may have been repaired already.
+			if c.db.ByHandle(h) == nil {
+				if err := c.placeholder(name, h); err != nil {
+					c.report(kind, ref.From, false, "%s: %s", msg, err)
+					continue
+				}
+			}
+			c.report(kind, ref.From, true, "%s; created a placeholder", msg)
+			continue
+		}
+		if err := c.db.Unlink(ref); err != nil {
+			c.report(kind, ref.From, false, "%s: %s", msg, err)
+			continue
+		}
+		c.report(kind, ref.From, true, "%s; removed the link", msg)
+	}
+}
+
+// Get links to the note explaining placeholder objects, creating it first.
+func (c *checker) explain() ([]*xml.GenericLink, error) {
+	if c.explanation == nil {
+		note := &xml.Note{Type: "General",
+			Text: "Objects referenced by this note were referenced but missing from the database."}
+		if err := c.db.Add(note); err != nil {
+			return nil, err
+		}
+		c.explanation = note
+	}
+	return []*xml.GenericLink{{HLink: c.explanation.Handle}}, nil
+}
+
+// Create a placeholder object of the given element name with handle h, as
+// Gramps does for links to missing objects.
+func (c *checker) placeholder(name, h string) error {
+	noteRefs, err := c.explain()
+	if err != nil {
+		return err
+	}
+	unknown := "Unknown"
+
+	var obj xml.DBObj
+	switch name {
+	case "event":
+		e := &xml.Event{Type: &unknown, NoteRefs: noteRefs}
+		e.Handle = h
+		obj = e
+	case "placeobj":
+		p := &xml.PlaceObj{Type: "Unknown", PTitle: &unknown,
+			PNames: []*xml.PName{{Value: unknown}}, NoteRefs: noteRefs}
+		if c.db.Version() == "1.5.0" {
+			p.Type, p.PNames = "", nil
+		}
+		p.Handle = h
+		obj = p
+	case "citation":
+		cite := &xml.Citation{NoteRefs: noteRefs}
+		cite.Handle = h
+		source, err := c.newSource()
+		if err != nil {
+			return err
+		}
+		cite.SourceRef.HLink = source.Handle
+		obj = cite
+	case "source":
+		s := &xml.Source{STitle: &unknown, NoteRefs: noteRefs}
+		s.Handle = h
+		obj = s
+	case "note":
+		n := &xml.Note{Type: "General", Text: unknown}
+		n.Handle = h
+		obj = n
+	case "object":
+		o := &xml.Object{File: xml.File{Description: unknown}, NoteRefs: noteRefs}
+		o.Handle = h
+		obj = o
+	case "repository":
+		r := &xml.Repository{RName: unknown, Type: "Unknown", NoteRefs: noteRefs}
+		r.Handle = h
+		obj = r
+	case "tag":
+		obj = &xml.Tag{Handle: h, Name: unknown + " " + h, Color: "#000000000000",
+			Priority: "0"}
+	}
+	return c.db.Add(obj)
+}
+
+// Create a new placeholder source.
+func (c *checker) newSource() (*xml.Source, error) {
+	noteRefs, err := c.explain()
+	if err != nil {
+		return nil, err
+	}
+	unknown := "Unknown"
+	s := &xml.Source{STitle: &unknown, NoteRefs: noteRefs}
+	return s, c.db.Add(s)
+}
+
+func hasLink(links []*xml.GenericLink, h string) bool {
+	for _, l := range links {
+		if l.HLink == h {
+			return true
+		}
+	}
+	return false
+}
+
+func removeLinks(links []*xml.GenericLink, h string) []*xml.GenericLink {
+	var kept []*xml.GenericLink
+	for _, l := range links {
+		if l.HLink != h {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+func isParent(f *xml.Family, h string) bool {
+	return f.Father != nil && f.Father.HLink == h || f.Mother != nil && f.Mother.HLink == h
+}
+
+func isChild(f *xml.Family, h string) bool {
+	for _, ref := range f.ChildRefs {
+		if ref.HLink == h {
+			return true
+		}
+	}
+	return false
+}
+
+// Make the families of people and the members of families agree, the way
+// Gramps does: a person is added to the families that list them and removed
+// from those that do not.
+func (c *checker) familyLinks() {
+	for _, f := range c.db.Families {
+		var parents []*xml.GenericLink
+		if f.Father != nil {
+			parents = append(parents, f.Father)
+		}
+		if f.Mother != nil {
+			parents = append(parents, f.Mother)
+		}
+		for _, l := range parents {
+			p := c.db.PersonByHandle(l.HLink)
+			if p == nil || hasLink(p.ParentIns, f.Handle) {
+				continue
+			}
+			if c.opts.Repair {
+				p.ParentIns = append(p.ParentIns, &xml.GenericLink{HLink: f.Handle})
+			}
+			c.report(FamilyLink, p, c.opts.Repair, "%s is a parent in %s but has no parentin",
+				describe(p), describe(f))
+		}
+		for _, ref := range f.ChildRefs {
+			p := c.db.PersonByHandle(ref.HLink)
+			if p == nil || hasLink(p.ChildOfs, f.Handle) {
+				continue
+			}
+			if c.opts.Repair {
+				p.ChildOfs = append(p.ChildOfs, &xml.GenericLink{HLink: f.Handle})
+			}
+			c.report(FamilyLink, p, c.opts.Repair, "%s is a child in %s but has no childof",
+				describe(p), describe(f))
+		}
+	}
+
+	for _, p := range c.db.People.Persons {
+		for _, l := range p.ParentIns {
+			f := c.db.FamilyByHandle(l.HLink)
+			if f == nil || isParent(f, p.Handle) {
+				continue
+			}
+			c.report(FamilyLink, p, c.opts.Repair, "%s has a parentin %s, which has other parents",
+				describe(p), describe(f))
+			if c.opts.Repair {
+				p.ParentIns = removeLinks(p.ParentIns, f.Handle)
+			}
+		}
+		for _, l := range p.ChildOfs {
+			f := c.db.FamilyByHandle(l.HLink)
+			if f == nil || isChild(f, p.Handle) {
+				continue
+			}
+			c.report(FamilyLink, p, c.opts.Repair, "%s has a childof %s, which does not list them",
+				describe(p), describe(f))
+			if c.opts.Repair {
+				p.ChildOfs = removeLinks(p.ChildOfs, f.Handle)
+			}
+		}
+	}
+}
+
+func (c *checker) emptyFamilies() {
+	var empty []*xml.Family
+	for _, f := range c.db.Families {
+		if f.Father == nil && f.Mother == nil && len(f.ChildRefs) == 0 {
+			empty = append(empty, f)
+		}
+	}
+	if len(empty) > 0 && c.opts.Repair {
+		// Links may have been changed by the repairs so far.
+		c.db.Reindex()
+	}
+	for _, f := range empty {
+		if !c.opts.Repair {
+			c.report(EmptyFamily, f, false, "%s has no members", describe(f))
+			continue
+		}
+		// Check all the links first so that none are removed if the family
+		// cannot be.
+		refs := c.db.Referrers(f.Handle)
+		var err error
+		for _, ref := range refs {
+			if err = c.db.CanUnlink(ref); err != nil {
+				break
+			}
+		}
+		for i := 0; i < len(refs) && err == nil; i++ {
+			err = c.db.Unlink(refs[i])
+		}
+		if err == nil {
+			_, err = c.db.Remove(f.Handle)
+		}
+		if err != nil {
+			c.report(EmptyFamily, f, false, "%s has no members: %s", describe(f), err)
+			continue
+		}
+		c.report(EmptyFamily, f, true, "%s has no members; removed it", describe(f))
+	}
+}
+
+func isEmpty(n *xml.Name) bool {
+	for _, s := range []*string{n.First, n.Call, n.Suffix, n.Title, n.Nick, n.FamilyNick} {
+		if s != nil && strings.TrimSpace(*s) != "" {
+			return false
+		}
+	}
+	for _, s := range n.Surnames {
+		if strings.TrimSpace(s.Value) != "" || s.Prefix != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Report empty names. Empty alternate names are removed when repairing; the
+// only name of a person is kept.
+func (c *checker) emptyNames() {
+	for _, p := range c.db.People.Persons {
+		var kept []*xml.Name
+		for i, n := range p.Names {
+			if !isEmpty(n) {
+				kept = append(kept, n)
+				continue
+			}
+			last := len(kept) == 0 && i == len(p.Names)-1
+			fixed := c.opts.Repair && !last
+			c.report(EmptyName, p, fixed, "%s has an empty name", describe(p))
+			if !fixed {
+				kept = append(kept, n)
+			}
+		}
+		if c.opts.Repair {
+			p.Names = kept
+		}
+	}
+}
+
+func (c *checker) missingSources() {
+	for _, cite := range c.db.Citations {
+		if cite.SourceRef.HLink != "" {
+			// Links to missing sources are dangling links.
+			continue
+		}
+		if !c.opts.Repair {
+			c.report(MissingSource, cite, false, "%s has no source", describe(cite))
+			continue
+		}
+		source, err := c.newSource()
+		if err != nil {
+			c.report(MissingSource, cite, false, "%s has no source: %s", describe(cite), err)
+			continue
+		}
+		cite.SourceRef.HLink = source.Handle
+		c.report(MissingSource, cite, true, "%s has no source; linked it to a placeholder",
+			describe(cite))
+	}
+}
+
+// Get the directory relative paths of media files are found in.
+func (c *checker) mediaDir() string {
+	if mp := c.db.Header.MediaPath; mp != nil && *mp != "" {
+		if filepath.IsAbs(*mp) {
+			return *mp
+		}
+		return filepath.Join(c.opts.Dir, *mp)
+	}
+	return c.opts.Dir
+}
+
+func (c *checker) missingMedia() {
+	dir := c.mediaDir()
+	for _, o := range c.db.Objects {
+		path := o.File.Src
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			c.report(MissingMedia, o, false, "%s refers to missing file %s", describe(o), path)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package check
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+func link(h string) *xml.GenericLink {
+	return &xml.GenericLink{HLink: h}
+}
+
+func person(h, id, first string) *xml.Person {
+	p := &xml.Person{Names: []*xml.Name{{First: &first}}}
+	p.Handle, p.ID = h, id
+	return p
+}
+
+// A small database with one of each problem.
+func brokenDatabase() *xml.Database {
+	db := &xml.Database{}
+	empty := ""
+
+	father := person("_father", "I0000", "John")
+	father.ParentIns = []*xml.GenericLink{link("_family")}
+	// No childof for _family.
+	child := person("_child", "I0001", "Jane")
+	child.Names = append(child.Names, &xml.Name{First: &empty})
+	// Has a childof for a family that does not list them and a dangling
+	// eventref.
+	other := person("_other", "I0002", "Joe")
+	other.ChildOfs = []*xml.GenericLink{link("_family")}
+	other.EventRefs = []*xml.EventRef{{}}
+	other.EventRefs[0].HLink = "_missingevent"
+	dup := person("_dup", "I0001", "Jim")
+	db.People.Persons = []*xml.Person{father, child, other, dup}
+
+	family := &xml.Family{Father: link("_father"), Mother: link("_missingmother"),
+		ChildRefs: []*xml.ChildRef{{}}}
+	family.ChildRefs[0].HLink = "_child"
+	family.Handle, family.ID = "_family", "F0000"
+	emptyFamily := &xml.Family{}
+	emptyFamily.Handle, emptyFamily.ID = "_empty", "F0001"
+	db.Families = []*xml.Family{family, emptyFamily}
+
+	citation := &xml.Citation{}
+	citation.Handle, citation.ID = "_citation", "C0000"
+	db.Citations = []*xml.Citation{citation}
+
+	object := &xml.Object{File: xml.File{Src: "missing.jpg"}}
+	object.Handle, object.ID = "_object", "O0000"
+	db.Objects = []*xml.Object{object}
+	return db
+}
+
+func kinds(problems []Problem) map[Kind]int {
+	counts := make(map[Kind]int)
+	for _, p := range problems {
+		counts[p.Kind]++
+	}
+	return counts
+}
+
+func TestCheck(t *testing.T) {
+	db := brokenDatabase()
+	problems := Check(db, Options{})
+	want := map[Kind]int{DuplicateID: 1, DanglingLink: 2, FamilyLink: 2, EmptyFamily: 1,
+		EmptyName: 1, MissingSource: 1, MissingMedia: 1}
+	got := kinds(problems)
+	for k, n := range want {
+		if got[k] != n {
+			t.Errorf("Got %d %s problems, want %d", got[k], k, n)
+		}
+	}
+	for _, p := range problems {
+		if p.Fixed {
+			t.Errorf("Problem fixed without repairing: %s", p)
+		}
+		if p.Kind == DuplicateID && p.ID != "I0001" {
+			t.Errorf("Got duplicate ID %s, want I0001", p.ID)
+		}
+	}
+	if len(db.Families) != 2 || len(db.People.Persons[1].Names) != 2 {
+		t.Error("Database was changed without repairing")
+	}
+}
+
+func TestRepair(t *testing.T) {
+	db := brokenDatabase()
+	for _, p := range Check(db, Options{Repair: true}) {
+		if !p.Fixed && p.Kind != MissingMedia {
+			t.Errorf("Problem not fixed: %s", p)
+		}
+	}
+
+	father := db.PersonByHandle("_father")
+	child := db.PersonByHandle("_child")
+	other := db.PersonByHandle("_other")
+	family := db.FamilyByHandle("_family")
+	if len(child.ChildOfs) != 1 || child.ChildOfs[0].HLink != "_family" {
+		t.Error("Child was not added to the family")
+	}
+	if len(other.ChildOfs) != 0 {
+		t.Error("Childof that the family does not agree with was kept")
+	}
+	if family.Mother != nil || family.Father.HLink != father.Handle {
+		t.Error("Link to missing mother was not removed")
+	}
+	if db.FamilyByHandle("_empty") != nil {
+		t.Error("Empty family was not removed")
+	}
+	if len(child.Names) != 1 {
+		t.Error("Empty name was not removed")
+	}
+	if e := db.EventByHandle("_missingevent"); e == nil || len(e.NoteRefs) != 1 {
+		t.Error("No placeholder event was created")
+	}
+	if dup := db.PersonByHandle("_dup"); dup.ID == "I0001" || db.PersonByID(dup.ID) != dup {
+		t.Errorf("Duplicate ID was changed to %s", dup.ID)
+	}
+	if s := db.SourceByHandle(db.CitationByID("C0000").SourceRef.HLink); s == nil {
+		t.Error("Citation was not given a source")
+	}
+
+	problems := Check(db, Options{})
+	if got := kinds(problems); len(problems) != 1 || got[MissingMedia] != 1 {
+		t.Errorf("Got problems %v after repairing", problems)
+	}
+}
+
+// An empty family whose links cannot be removed is reported as not repaired.
+func TestEmptyFamilyNotRemoved(t *testing.T) {
+	db := &xml.Database{}
+	family := &xml.Family{}
+	family.Handle, family.ID = "_empty", "F0000"
+	db.Families = []*xml.Family{family}
+	citation := &xml.Citation{SourceRef: xml.GenericLink{HLink: "_empty"}}
+	citation.Handle, citation.ID = "_citation", "C0000"
+	db.Citations = []*xml.Citation{citation}
+	// The person's link comes first and could be removed.
+	sealed := &xml.LDSOrd{Type: "sealed_to_parents", SealedTo: &xml.GenericLink{HLink: "_empty"}}
+	person := &xml.Person{Gender: "U", LDSOrds: []*xml.LDSOrd{sealed}}
+	person.Handle, person.ID = "_person", "I0000"
+	db.People.Persons = []*xml.Person{person}
+
+	for _, p := range Check(db, Options{Repair: true}) {
+		if p.Kind == EmptyFamily && p.Fixed {
+			t.Errorf("Reported as repaired: %s", p)
+		}
+	}
+	if db.FamilyByHandle("_empty") == nil {
+		t.Error("Family was removed")
+	}
+	if sealed.SealedTo == nil || citation.SourceRef.HLink != "_empty" {
+		t.Error("Links to the family were removed")
+	}
+}
+
+func TestMediaPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "media"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "media", "missing.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	db := brokenDatabase()
+	mediaPath := "media"
+	db.Header.MediaPath = &mediaPath
+	if got := kinds(Check(db, Options{Dir: dir})); got[MissingMedia] != 0 {
+		t.Error("Media file relative to the media path was not found")
+	}
+	mediaPath = filepath.Join(dir, "media")
+	if got := kinds(Check(db, Options{})); got[MissingMedia] != 0 {
+		t.Error("Media file in absolute media path was not found")
+	}
+}
+
+func TestExample(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "xml", "testdata", "example-1.5.0.gramps"))
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer f.Close()
+	db, err := xml.Parse(f)
+	if err != nil {
+		t.Fatalf("Failed to parse example: %s", err)
+	}
+	for _, p := range Check(db, Options{}) {
+		// Rufus Blanco was born in the year 20, before his parents.
+		if p.Kind != MissingMedia && !(p.Kind == ChildBeforeParent && p.ID == "I0599") {
+			t.Errorf("Unexpected problem in example: %s", p)
+		}
+	}
+}
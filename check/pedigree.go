@@ -0,0 +1,231 @@
+package check
+
+import (
+	"strings"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+// A Loop is a cycle in the pedigree of a Database: each person is a child in
+// the family at the same index of a parent that is the next person, and the
+// last person a child of the first.
+type Loop struct {
+	People   []*xml.Person
+	Families []*xml.Family
+}
+
+func (l Loop) String() string {
+	var parts []string
+	for i, p := range l.People {
+		parts = append(parts, describe(p), describe(l.Families[i]))
+	}
+	if len(l.People) > 0 {
+		parts = append(parts, describe(l.People[0]))
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// A parent of a person and the family they are parent and child in.
+type parentEdge struct {
+	family *xml.Family
+	parent *xml.Person
+}
+
+// Get the relation of the child with handle h to the father or mother of f.
+func childRel(f *xml.Family, h string, father bool) string {
+	for _, ref := range f.ChildRefs {
+		if ref.HLink == h {
+			if father {
+				return ref.FRel
+			}
+			return ref.MRel
+		}
+	}
+	return ""
+}
+
+// Get the parents of p other than p itself, in the families p is a child in.
+// If birthOnly is set, adoptive, foster and step parents are left out.
+func parentEdges(db *xml.Database, p *xml.Person, birthOnly bool) []parentEdge {
+	var edges []parentEdge
+	for _, l := range p.ChildOfs {
+		f := db.FamilyByHandle(l.HLink)
+		if f == nil {
+			continue
+		}
+		for i, parent := range []*xml.GenericLink{f.Father, f.Mother} {
+			if parent == nil || parent.HLink == p.Handle {
+				continue
+			}
+			if rel := childRel(f, p.Handle, i == 0); birthOnly && rel != "" && rel != "Birth" {
+				continue
+			}
+			if q := db.PersonByHandle(parent.HLink); q != nil {
+				edges = append(edges, parentEdge{f, q})
+			}
+		}
+	}
+	return edges
+}
+
+// Find the people of db who are their own ancestors, through the families
+// they are children in. Each loop is reported once, starting with the person
+// of it found first; a parent in their own family is not a loop but a
+// ParentIsChild problem.
+func Loops(db *xml.Database) []Loop {
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := map[*xml.Person]int{}
+	var stack []*xml.Person
+	var families []*xml.Family
+	var loops []Loop
+	var visit func(p *xml.Person)
+	visit = func(p *xml.Person) {
+		state[p] = onStack
+		stack = append(stack, p)
+		for _, e := range parentEdges(db, p, false) {
+			families = append(families, e.family)
+			switch state[e.parent] {
+			case unvisited:
+				visit(e.parent)
+			case onStack:
+				start := len(stack) - 1
+				for stack[start] != e.parent {
+					start--
+				}
+				loops = append(loops, Loop{
+					People:   append([]*xml.Person{}, stack[start:]...),
+					Families: append([]*xml.Family{}, families[start:]...),
+				})
+			}
+			families = families[:len(families)-1]
+		}
+		stack = stack[:len(stack)-1]
+		state[p] = done
+	}
+	for _, p := range db.People.Persons {
+		if state[p] == unvisited {
+			visit(p)
+		}
+	}
+	return loops
+}
+
+// Report the people who are their own ancestors, parents who are children in
+// their own family, and children born before a parent. These are not
+// repaired.
+func (c *checker) pedigree() {
+	for _, l := range Loops(c.db) {
+		c.report(PedigreeLoop, l.People[0], false, "%s is their own ancestor: %s",
+			describe(l.People[0]), l)
+	}
+	for _, f := range c.db.Families {
+		for _, l := range []*xml.GenericLink{f.Father, f.Mother} {
+			if l == nil || !isChild(f, l.HLink) {
+				continue
+			}
+			if p := c.db.PersonByHandle(l.HLink); p != nil {
+				c.report(ParentIsChild, p, false, "%s is both a parent and a child in %s",
+					describe(p), describe(f))
+			}
+		}
+	}
+	for _, p := range c.db.People.Persons {
+		birth, ok := birthJDN(c.db, p)
+		if !ok {
+			continue
+		}
+		for _, e := range parentEdges(c.db, p, true) {
+			if parentBirth, ok := birthJDN(c.db, e.parent); ok && birth < parentBirth {
+				c.report(ChildBeforeParent, p, false, "%s was born before their parent %s in %s",
+					describe(p), describe(e.parent), describe(e.family))
+			}
+		}
+	}
+}
+
+// Get the JDN of the birth of p, or of a fallback for it.
+func birthJDN(db *xml.Database, p *xml.Person) (int, bool) {
+	e, _ := p.BirthOrFallback(db)
+	if e == nil {
+		return 0, false
+	}
+	d, err := e.GetDate()
+	if err != nil || d.SortValue() == 0 {
+		return 0, false
+	}
+	return d.JDN(), true
+}
+
+// The pedigree collapse of a person in one generation of ancestors.
+type Generation struct {
+	// The number of ancestors a person has in the generation without
+	// pedigree collapse: 1 for the person, 2 for the parents and so on.
+	Possible uint64
+	// The number of places in the generation taken by known ancestors, and
+	// the number of different people among them.
+	Known, Distinct uint64
+	// The number of places taken by ancestors who take another place in the
+	// generation or in a generation before: Known less those found for the
+	// first time.
+	Implex uint64
+}
+
+// The pedigree collapse of a person: ancestors who are reached through
+// several lines of descent.
+type Collapse struct {
+	Generations []Generation
+	// The ancestors who take more than one place, in the order they are
+	// first repeated.
+	Repeated []*xml.Person
+	// The number of places of each ancestor and of the person.
+	Places map[*xml.Person]uint64
+}
+
+// The most generations Pedigree looks at, which keeps the number of places
+// in a generation in a uint64.
+const maxGenerations = 64
+
+// Find the pedigree collapse of p in up to generations generations, with p
+// as the first, or 64 if generations is 0. Parents are the birth parents in
+// all families p is a child in. People who are their own ancestors take a
+// place in every generation after the loop.
+func Pedigree(db *xml.Database, p *xml.Person, generations int) *Collapse {
+	if generations <= 0 || generations > maxGenerations {
+		generations = maxGenerations
+	}
+	c := &Collapse{Places: map[*xml.Person]uint64{}}
+	// The number of places of each person in the generation, in order.
+	gen := map[*xml.Person]uint64{p: 1}
+	order := []*xml.Person{p}
+	for i := 0; i < generations && len(order) > 0; i++ {
+		g := Generation{Possible: 1 << uint(i), Distinct: uint64(len(order))}
+		next := map[*xml.Person]uint64{}
+		var nextOrder []*xml.Person
+		for _, q := range order {
+			n := gen[q]
+			g.Known += n
+			if c.Places[q] > 0 {
+				g.Implex += n
+			} else {
+				g.Implex += n - 1
+			}
+			if c.Places[q] < 2 && c.Places[q]+n >= 2 {
+				c.Repeated = append(c.Repeated, q)
+			}
+			c.Places[q] += n
+			for _, e := range parentEdges(db, q, true) {
+				if next[e.parent] == 0 {
+					nextOrder = append(nextOrder, e.parent)
+				}
+				next[e.parent] += n
+			}
+		}
+		c.Generations = append(c.Generations, g)
+		gen, order = next, nextOrder
+	}
+	return c
+}
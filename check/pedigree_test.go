@@ -0,0 +1,140 @@
+package check
+
+import (
+	"strings"
+	"testing"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+// Add a family of father and mother, which may be nil, with children to db.
+func addFamily(db *xml.Database, h string, father, mother *xml.Person, children ...*xml.Person) *xml.Family {
+	f := &xml.Family{}
+	f.Handle, f.ID = h, "F"+h
+	if father != nil {
+		f.Father = link(father.Handle)
+		father.ParentIns = append(father.ParentIns, link(h))
+	}
+	if mother != nil {
+		f.Mother = link(mother.Handle)
+		mother.ParentIns = append(mother.ParentIns, link(h))
+	}
+	for _, c := range children {
+		ref := &xml.ChildRef{}
+		ref.HLink = c.Handle
+		f.ChildRefs = append(f.ChildRefs, ref)
+		c.ChildOfs = append(c.ChildOfs, link(h))
+	}
+	db.Families = append(db.Families, f)
+	return f
+}
+
+// Add people with handles to db.
+func addPeople(db *xml.Database, handles ...string) []*xml.Person {
+	var people []*xml.Person
+	for _, h := range handles {
+		p := person(h, "I"+h, h)
+		db.People.Persons = append(db.People.Persons, p)
+		people = append(people, p)
+	}
+	return people
+}
+
+// Give p a birth event on date.
+func addBirth(t *testing.T, db *xml.Database, p *xml.Person, date string) {
+	birth := "Birth"
+	e := &xml.Event{Type: &birth}
+	e.SetDateString(date)
+	if err := db.Add(e); err != nil {
+		t.Fatal(err)
+	}
+	ref := &xml.EventRef{Role: "Primary"}
+	ref.HLink = e.Handle
+	p.EventRefs = append(p.EventRefs, ref)
+}
+
+func TestPedigreeProblems(t *testing.T) {
+	db := &xml.Database{}
+	people := addPeople(db, "a", "b", "c", "d", "e", "g")
+	a, b, c, d, e, g := people[0], people[1], people[2], people[3], people[4], people[5]
+	f1 := addFamily(db, "1", b, nil, a)
+	f2 := addFamily(db, "2", nil, c, b)
+	f3 := addFamily(db, "3", a, nil, c)
+	addFamily(db, "4", d, nil, d)
+	addFamily(db, "5", e, nil, g)
+	addBirth(t, db, e, "1900")
+	addBirth(t, db, g, "1850")
+	db.Reindex()
+
+	loops := Loops(db)
+	if len(loops) != 1 {
+		t.Fatalf("Got %d loops", len(loops))
+	}
+	l := loops[0]
+	if len(l.People) != 3 || l.People[0] != a || l.People[1] != b || l.People[2] != c ||
+		l.Families[0] != f1 || l.Families[1] != f2 || l.Families[2] != f3 {
+		t.Errorf("Got loop %s", l)
+	}
+	want := "person Ia -> family F1 -> person Ib -> family F2 -> person Ic -> family F3 -> person Ia"
+	if s := l.String(); s != want {
+		t.Errorf("Got %q, want %q", s, want)
+	}
+
+	got := kinds(Check(db, Options{}))
+	for k, n := range map[Kind]int{PedigreeLoop: 1, ParentIsChild: 1, ChildBeforeParent: 1} {
+		if got[k] != n {
+			t.Errorf("Got %d %s problems, want %d", got[k], k, n)
+		}
+	}
+}
+
+func TestPedigreeCollapse(t *testing.T) {
+	db := &xml.Database{}
+	people := addPeople(db, "k", "p1", "p2", "q1", "q2", "g1", "g2", "adoptive")
+	k, p1, p2, q1, q2, g1, g2, adoptive := people[0], people[1], people[2], people[3],
+		people[4], people[5], people[6], people[7]
+	// The parents of k are first cousins.
+	addFamily(db, "1", p1, p2, k)
+	addFamily(db, "2", q1, nil, p1)
+	addFamily(db, "3", nil, q2, p2)
+	addFamily(db, "4", g1, g2, q1, q2)
+	f := addFamily(db, "5", adoptive, nil, q2)
+	f.ChildRefs[0].FRel = "Adopted"
+	db.Reindex()
+
+	c := Pedigree(db, k, 0)
+	want := []Generation{
+		{Possible: 1, Known: 1, Distinct: 1},
+		{Possible: 2, Known: 2, Distinct: 2},
+		{Possible: 4, Known: 2, Distinct: 2},
+		{Possible: 8, Known: 4, Distinct: 2, Implex: 2},
+	}
+	if len(c.Generations) != len(want) {
+		t.Fatalf("Got %d generations: %+v", len(c.Generations), c.Generations)
+	}
+	for i, g := range want {
+		if c.Generations[i] != g {
+			t.Errorf("Generation %d: got %+v, want %+v", i+1, c.Generations[i], g)
+		}
+	}
+	if len(c.Repeated) != 2 || c.Repeated[0] != g1 || c.Repeated[1] != g2 || c.Places[g1] != 2 {
+		t.Errorf("Got repeated ancestors %v", c.Repeated)
+	}
+	if c.Places[adoptive] != 0 {
+		t.Error("Adoptive parent counted as an ancestor")
+	}
+	if n := len(Pedigree(db, k, 2).Generations); n != 2 {
+		t.Errorf("Got %d generations, want 2", n)
+	}
+
+	// A person who is their own ancestor is counted again in each generation.
+	addFamily(db, "6", k, nil, g1)
+	db.Reindex()
+	c = Pedigree(db, k, 8)
+	if len(c.Generations) != 8 || c.Generations[4].Implex != 2 {
+		t.Errorf("Got %+v", c.Generations)
+	}
+	if !strings.Contains(Loops(db)[0].String(), "person Ik") {
+		t.Errorf("Got loops %v", Loops(db))
+	}
+}
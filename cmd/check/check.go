@@ -0,0 +1,46 @@
+package main
+
+import "code.google.com/p/gogramps/check"
+import "code.google.com/p/gogramps/xml"
+import "flag"
+import "fmt"
+import "os"
+import "path/filepath"
+
+var inFilename = flag.String("in", "", "The name of the gramps file to check")
+var outFilename = flag.String("out", "", "The name of the gramps file to write with the problems repaired (default: do not repair)")
+
+func main() {
+	f, err := os.Open(*inFilename)
+	if err != nil {
+		fmt.Println("Could not read file: ", err)
+		os.Exit(2)
+	}
+	db, err := xml.Parse(f)
+	f.Close()
+	if err != nil {
+		fmt.Println("Could not parse XML: ", err)
+		os.Exit(2)
+	}
+
+	opts := check.Options{Repair: *outFilename != "", Dir: filepath.Dir(*inFilename)}
+	problems := check.Check(db, opts)
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", p.Kind, p)
+	}
+
+	if opts.Repair {
+		if err = db.Serialize(*outFilename); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		return
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	flag.Parse()
+}
@@ -0,0 +1,16 @@
+/*
+Check looks for problems in the references between the objects of a gramps XML
+file, like the Check and Repair tool of Gramps: links to missing objects,
+people and families that disagree about who is in the family, duplicate handles
+and IDs, families without members, empty names, citations without a source and
+media files that cannot be found.
+
+Every problem is printed. Given -out, the problems are repaired the way Gramps
+repairs them and the result is written to a new file. Otherwise the exit status
+is 1 if there were any problems.
+
+Example:
+check -in=foo.gramps
+check -in=foo.gramps -out=fixed.gramps
+*/
+package documentation
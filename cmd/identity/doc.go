@@ -5,12 +5,19 @@ This can be used as an example program for writing a program that
 modifies the parsed XML before re-serializing it.
 
 It can also be used to verify that the parsing is working correctly.  Given a
-gramps export, running identity on the file, loading it into Gramps and
-re-exporting it should generate an identical file to the first export.
-Unfortunately, the load into Gramps is necessary because Gramps canonicalizes
-the ordering of elements and attributes.
+gramps export, running identity on the file should generate a file whose
+uncompressed contents are identical to the export, as the output is formatted
+the same way Gramps formats it.
+
+The input may be compressed with gzip, bzip2 or xz, or not compressed at all.
+Uncompressed input is written uncompressed; otherwise the output is compressed
+with gzip, as Gramps writes it.
+
+Passing -version migrates the file to another version of the XML format, e.g.
+to normalize old exports to the latest format.
 
 Example:
 identity -in=foo.gramps -out=bar.gramps
+identity -in=old.gramps -out=new.gramps -version=1.7.1
 */
 package documentation
@@ -1,5 +1,6 @@
 package main
 
+import "bufio"
 import "code.google.com/p/gogramps/xml"
 import "flag"
 import "fmt"
@@ -7,6 +8,8 @@ import "os"
 
 var inFilename = flag.String("in", "", "The name of the gramps file to read")
 var outFilename = flag.String("out", "", "The name of the gramps file to write")
+var strict = flag.Bool("strict", true, "Fail on elements that are not parsed instead of keeping them")
+var version = flag.String("version", "", "The XML version to write (default: the version read)")
 
 func main() {
 	f, err := os.Open(*inFilename)
@@ -14,12 +17,38 @@ func main() {
 		fmt.Println("Could not read file: ", err)
 		return
 	}
-	db, err := xml.Parse(f)
-	if err != nil {
+	defer f.Close()
+	// ParseWith decompresses the file; its compression is only detected here.
+	in := bufio.NewReader(f)
+	compression := xml.DetectCompression(in)
+	db, err := xml.ParseWith(in, xml.ParseOptions{Strict: *strict})
+	if uerr, ok := err.(*xml.UnparsedError); ok && !*strict {
+		for _, field := range uerr.Fields {
+			fmt.Printf("Warning: line %d: unparsed %s in %s\n", field.Line, field.Path, field.ID)
+		}
+	} else if err != nil {
 		fmt.Println("Could not parse XML: ", err)
 		return
 	}
-	if err = db.Serialize(*outFilename); err != nil {
+	if *version != "" {
+		if err = xml.Migrate(db, *version); err != nil {
+			fmt.Println("Could not migrate: ", err)
+			return
+		}
+	}
+	// Uncompressed input is written uncompressed; anything else is written
+	// with gzip, as Gramps does.
+	out, err := os.Create(*outFilename)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err = db.Write(out, xml.WriteOptions{Uncompressed: compression == xml.None}); err != nil {
+		out.Close()
+		fmt.Println(err)
+		return
+	}
+	if err = out.Close(); err != nil {
 		fmt.Println(err)
 		return
 	}
@@ -0,0 +1,174 @@
+package gedcom
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The characters of ANSEL (ANSI Z39.47) from 0xA1, with the extensions of
+// GEDCOM. Zero means undefined.
+var anselSpacing = [0x100 - 0xA1]rune{
+	0xA1 - 0xA1: 'Ł', 0xA2 - 0xA1: 'Ø', 0xA3 - 0xA1: 'Đ', 0xA4 - 0xA1: 'Þ', 0xA5 - 0xA1: 'Æ',
+	0xA6 - 0xA1: 'Œ', 0xA7 - 0xA1: 'ʹ', 0xA8 - 0xA1: '·', 0xA9 - 0xA1: '♭', 0xAA - 0xA1: '®',
+	0xAB - 0xA1: '±', 0xAC - 0xA1: 'Ơ', 0xAD - 0xA1: 'Ư', 0xAE - 0xA1: 'ʼ', 0xB0 - 0xA1: 'ʻ',
+	0xB1 - 0xA1: 'ł', 0xB2 - 0xA1: 'ø', 0xB3 - 0xA1: 'đ', 0xB4 - 0xA1: 'þ', 0xB5 - 0xA1: 'æ',
+	0xB6 - 0xA1: 'œ', 0xB7 - 0xA1: 'ʺ', 0xB8 - 0xA1: 'ı', 0xB9 - 0xA1: '£', 0xBA - 0xA1: 'ð',
+	0xBC - 0xA1: 'ơ', 0xBD - 0xA1: 'ư', 0xBE - 0xA1: '□', 0xBF - 0xA1: '■', 0xC0 - 0xA1: '°',
+	0xC1 - 0xA1: 'ℓ', 0xC2 - 0xA1: '℗', 0xC3 - 0xA1: '©', 0xC4 - 0xA1: '♯', 0xC5 - 0xA1: '¿',
+	0xC6 - 0xA1: '¡', 0xC7 - 0xA1: 'ß', 0xC8 - 0xA1: '€', 0xCF - 0xA1: 'ß',
+
+	// Combining diacritics, which precede the character they modify in ANSEL.
+	0xE0 - 0xA1: 0x0309, 0xE1 - 0xA1: 0x0300, 0xE2 - 0xA1: 0x0301, 0xE3 - 0xA1: 0x0302,
+	0xE4 - 0xA1: 0x0303, 0xE5 - 0xA1: 0x0304, 0xE6 - 0xA1: 0x0306, 0xE7 - 0xA1: 0x0307,
+	0xE8 - 0xA1: 0x0308, 0xE9 - 0xA1: 0x030C, 0xEA - 0xA1: 0x030A, 0xEB - 0xA1: 0xFE20,
+	0xEC - 0xA1: 0xFE21, 0xED - 0xA1: 0x0315, 0xEE - 0xA1: 0x030B, 0xEF - 0xA1: 0x0310,
+	0xF0 - 0xA1: 0x0327, 0xF1 - 0xA1: 0x0328, 0xF2 - 0xA1: 0x0323, 0xF3 - 0xA1: 0x0324,
+	0xF4 - 0xA1: 0x0325, 0xF5 - 0xA1: 0x0333, 0xF6 - 0xA1: 0x0332, 0xF7 - 0xA1: 0x0326,
+	0xF8 - 0xA1: 0x031C, 0xF9 - 0xA1: 0x032E, 0xFA - 0xA1: 0xFE22, 0xFB - 0xA1: 0xFE23,
+	0xFE - 0xA1: 0x0313,
+}
+
+// The precomposed characters of letters with combining diacritics: the
+// letters, and the characters they make with the diacritic.
+var compositions = map[rune][2]string{
+	0x0300: {"AEIOUaeiou", "ÀÈÌÒÙàèìòù"},
+	0x0301: {"AEIOUYaeiouyCcNnSsZzLlRrGg", "ÁÉÍÓÚÝáéíóúýĆćŃńŚśŹźĹĺŔŕǴǵ"},
+	0x0302: {"AEIOUaeiouCcGgHhJjSsWwYy", "ÂÊÎÔÛâêîôûĈĉĜĝĤĥĴĵŜŝŴŵŶŷ"},
+	0x0303: {"ANOanoIiUu", "ÃÑÕãñõĨĩŨũ"},
+	0x0304: {"AEIOUaeiou", "ĀĒĪŌŪāēīōū"},
+	0x0306: {"AaGgUuEeIiOo", "ĂăĞğŬŭĔĕĬĭŎŏ"},
+	0x0307: {"CcEeGgIZz", "ĊċĖėĠġİŻż"},
+	0x0308: {"AEIOUaeiouyY", "ÄËÏÖÜäëïöüÿŸ"},
+	0x030A: {"AaUu", "ÅåŮů"},
+	0x030B: {"OoUu", "ŐőŰű"},
+	0x030C: {"CcDdEeNnRrSsTtZz", "ČčĎďĚěŇňŘřŠšŤťŽž"},
+	0x0327: {"CcSsTtGgKkLlNnRr", "ÇçŞşŢţĢģĶķĻļŅņŖŗ"},
+	0x0328: {"AaEeIiUu", "ĄąĘęĮįŲų"},
+}
+
+// Get the character base makes with the combining diacritic mark, or 0 if
+// there is no such character.
+func compose(base, mark rune) rune {
+	c, ok := compositions[mark]
+	if !ok {
+		return 0
+	}
+	i := strings.IndexRune(c[0], base)
+	if i < 0 {
+		return 0
+	}
+	composed := []rune(c[1])
+	return composed[utf8.RuneCountInString(c[0][:i])]
+}
+
+// Decode ANSEL. Diacritics are moved after the character they modify, and
+// combined with it where Unicode has a precomposed character.
+func decodeANSEL(b []byte) string {
+	var out []rune
+	var marks []rune
+	for _, c := range b {
+		var r rune
+		switch {
+		case c < 0x80:
+			r = rune(c)
+		case c == 0x88 || c == 0x89:
+			// Non-sorting character sequence delimiters.
+			continue
+		case c == 0x8D:
+			r = 0x200D
+		case c == 0x8E:
+			r = 0x200C
+		case c >= 0xA1 && anselSpacing[c-0xA1] != 0:
+			r = anselSpacing[c-0xA1]
+		default:
+			r = utf8.RuneError
+		}
+		if c >= 0xE0 && r != utf8.RuneError {
+			marks = append(marks, r)
+			continue
+		}
+		var rest []rune
+		for _, m := range marks {
+			if composed := compose(r, m); composed != 0 && rest == nil {
+				r = composed
+			} else {
+				rest = append(rest, m)
+			}
+		}
+		marks = marks[:0]
+		out = append(append(out, r), rest...)
+	}
+	return string(append(out, marks...))
+}
+
+// The characters of Windows-1252 from 0x80 to 0x9F. The rest are those of
+// ISO 8859-1.
+var cp1252 = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+func decodeCP1252(b []byte) string {
+	out := make([]rune, len(b))
+	for i, c := range b {
+		if c >= 0x80 && c < 0xA0 {
+			out[i] = cp1252[c-0x80]
+		} else {
+			out[i] = rune(c)
+		}
+	}
+	return string(out)
+}
+
+func decodeUTF16(b []byte, bigEndian bool) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			u[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+var charPattern = regexp.MustCompile(`(?m)^\s*1\s+CHAR\s+(\S+)`)
+
+// Decode a GEDCOM file into UTF-8. The encoding is detected from a byte order
+// mark, the zero bytes of UTF-16, or else the CHAR of the header. Returns a
+// message if the encoding was not what the file declared.
+func decode(b []byte) (text string, warning string) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		return string(b[3:]), ""
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return decodeUTF16(b[2:], false), ""
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return decodeUTF16(b[2:], true), ""
+	case len(b) >= 2 && b[0] != 0 && b[1] == 0:
+		return decodeUTF16(b, false), ""
+	case len(b) >= 2 && b[0] == 0 && b[1] != 0:
+		return decodeUTF16(b, true), ""
+	}
+
+	charset := ""
+	if m := charPattern.FindSubmatch(b); m != nil {
+		charset = strings.ToUpper(string(m[1]))
+	}
+	switch charset {
+	case "ANSEL":
+		return decodeANSEL(b), ""
+	case "ANSI", "CP1252", "WINDOWS-1252", "ISO-8859-1", "ISO8859-1", "LATIN1":
+		return decodeCP1252(b), ""
+	}
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	if charset == "" {
+		charset = "no character set"
+	}
+	return decodeCP1252(b), "file with " + charset + " is not UTF-8; read as Windows-1252"
+}
@@ -0,0 +1,38 @@
+package gedcom
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+func TestDecode(t *testing.T) {
+	utf16le := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune("0 HEAD\nŁódź")) {
+		utf16le = append(utf16le, byte(u), byte(u>>8))
+	}
+	var utf16be []byte
+	for _, u := range utf16.Encode([]rune("0 HEAD\nŁódź")) {
+		utf16be = append(utf16be, byte(u>>8), byte(u))
+	}
+	for _, c := range []struct {
+		name, in, want string
+		warning        bool
+	}{
+		{"ANSEL", "1 CHAR ANSEL\nZieli\xE2nski \xA1\xE2od\xE2z", "1 CHAR ANSEL\nZieliński Łódź", false},
+		{"ANSEL without precomposed", "1 CHAR ANSEL\n\xF2a\xE8\xE2u", "1 CHAR ANSEL\na\u0323\u00fc\u0301", false},
+		{"CP1252", "1 CHAR ANSI\n\x80 caf\xE9", "1 CHAR ANSI\n€ café", false},
+		{"UTF-8", "1 CHAR UTF-8\ncafé", "1 CHAR UTF-8\ncafé", false},
+		{"UTF-8 BOM", "\xEF\xBB\xBF1 CHAR UTF-8\ncafé", "1 CHAR UTF-8\ncafé", false},
+		{"UTF-16LE", string(utf16le), "0 HEAD\nŁódź", false},
+		{"UTF-16BE", string(utf16be), "0 HEAD\nŁódź", false},
+		{"not UTF-8", "1 CHAR UTF-8\ncaf\xE9", "1 CHAR UTF-8\ncafé", true},
+	} {
+		got, warning := decode([]byte(c.in))
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+		if (warning != "") != c.warning {
+			t.Errorf("%s: got warning %q", c.name, warning)
+		}
+	}
+}
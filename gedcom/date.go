@@ -0,0 +1,278 @@
+package gedcom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+// The months of the calendars of GEDCOM. The Hebrew year starts with Tishri
+// and has Adar I and Adar II, as in Gramps.
+var months = map[xml.Calendar][]string{
+	xml.Gregorian: {"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"},
+	xml.Julian:    {"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"},
+	xml.Hebrew: {"TSH", "CSH", "KSL", "TVT", "SHV", "ADR", "ADS", "NSN", "IYR", "SVN", "TMZ",
+		"AAV", "ELL"},
+	xml.FrenchRepublican: {"VEND", "BRUM", "FRIM", "NIVO", "PLUV", "VENT", "GERM", "FLOR",
+		"PRAI", "MESS", "THER", "FRUC", "COMP"},
+}
+
+// The calendar escapes of GEDCOM 5.5.1.
+var calendarEscapes = map[xml.Calendar]string{
+	xml.Julian:           "@#DJULIAN@ ",
+	xml.Hebrew:           "@#DHEBREW@ ",
+	xml.FrenchRepublican: "@#DFRENCH R@ ",
+}
+
+// The calendar keywords of GEDCOM 7.0.
+var calendarKeywords = map[xml.Calendar]string{
+	xml.Julian:           "JULIAN ",
+	xml.Hebrew:           "HEBREW ",
+	xml.FrenchRepublican: "FRENCH_R ",
+}
+
+// The GEDCOM keywords of modifiers of a single date.
+var modifierKeywords = map[xml.Modifier]string{
+	xml.ModBefore: "BEF ",
+	xml.ModAfter:  "AFT ",
+	xml.ModAbout:  "ABT ",
+}
+
+// Write v of calendar cal as a GEDCOM date, e.g. "@#DJULIAN@ 4 MAR 1749/50",
+// or "" if it has no year. GEDCOM 7.0 dates have a calendar keyword instead
+// of an escape, BCE instead of B.C., and no dual years.
+func formatYMD(cal xml.Calendar, v xml.YMD, dual, seven bool) string {
+	if v.Year == 0 {
+		return ""
+	}
+	year := v.Year
+	if year < 0 {
+		year = -year
+	}
+	s := fmt.Sprint(year)
+	if dual && !seven {
+		// The year of a dual dated Date is the later one.
+		s = fmt.Sprintf("%d/%02d", year-1, year%100)
+	}
+	if v.Year < 0 && seven {
+		s += " BCE"
+	} else if v.Year < 0 {
+		s += " B.C."
+	}
+	if v.Month > 0 && v.Month <= len(months[cal]) {
+		s = months[cal][v.Month-1] + " " + s
+		if v.Day > 0 {
+			s = fmt.Sprint(v.Day) + " " + s
+		}
+	}
+	if seven {
+		return calendarKeywords[cal] + s
+	}
+	return calendarEscapes[cal] + s
+}
+
+// Get the GEDCOM 5.5.1 or 7.0 value of d, and what could not be written of
+// it, or "" if d was written exactly. Dates in calendars GEDCOM lacks are
+// converted to the Gregorian calendar, and dates without a year are written
+// as date phrases in parentheses.
+func formatDate(d xml.Date, seven bool) (value, loss string) {
+	if d.Modifier == xml.ModTextOnly {
+		return "(" + d.Text + ")", ""
+	}
+	if d.IsEmpty() {
+		return "", ""
+	}
+	var losses []string
+	if _, ok := months[d.Calendar]; !ok {
+		losses = append(losses, fmt.Sprintf("%s date converted to Gregorian", d.Calendar))
+		d = d.In(xml.Gregorian)
+	}
+	if d.NewYear != "" {
+		losses = append(losses, fmt.Sprintf("new year %s left out", d.NewYear))
+	}
+	if d.DualDated && seven {
+		losses = append(losses, "dual year left out")
+	}
+	start := formatYMD(d.Calendar, d.Start, d.DualDated, seven)
+	stop := formatYMD(d.Calendar, d.Stop, d.DualDated, seven)
+	if start == "" || (stop == "" && (d.Modifier == xml.ModRange || d.Modifier == xml.ModSpan)) {
+		return "(" + xml.DefaultDateDisplayer.Display(d) + ")", "date without a year written as text"
+	}
+
+	switch d.Modifier {
+	case xml.ModRange:
+		value = "BET " + start + " AND " + stop
+	case xml.ModSpan:
+		value = "FROM " + start + " TO " + stop
+	default:
+		value = modifierKeywords[d.Modifier] + start
+	}
+	switch {
+	case d.Quality == xml.QualityRegular:
+	case d.Modifier != xml.ModNone:
+		losses = append(losses, "quality of a qualified date left out")
+	case d.Quality == xml.QualityEstimated:
+		value = "EST " + value
+	case d.Quality == xml.QualityCalculated:
+		value = "CAL " + value
+	}
+
+	return value, strings.Join(losses, "; ")
+}
+
+// The calendars of the calendar escapes of GEDCOM 5.5.1, which are written
+// without their spaces here, and of the calendar keywords of GEDCOM 7.0.
+var escapeCalendars = map[string]xml.Calendar{
+	"@#DGREGORIAN@": xml.Gregorian,
+	"@#DJULIAN@":    xml.Julian,
+	"@#DHEBREW@":    xml.Hebrew,
+	"@#DFRENCHR@":   xml.FrenchRepublican,
+	"GREGORIAN":     xml.Gregorian,
+	"JULIAN":        xml.Julian,
+	"HEBREW":        xml.Hebrew,
+	"FRENCH_R":      xml.FrenchRepublican,
+}
+
+// Parse a date of GEDCOM without a modifier: a calendar escape or keyword, a
+// day and month, a year with the last digits of the dual year after a slash,
+// and B.C.
+func parseYMD(words []string) (cal xml.Calendar, v xml.YMD, dual, ok bool) {
+	if len(words) > 0 {
+		c, known := escapeCalendars[words[0]]
+		if !known && strings.HasPrefix(words[0], "@#D") {
+			return
+		}
+		if known {
+			cal = c
+			words = words[1:]
+		}
+	}
+	bc := false
+	if n := len(words); n > 0 {
+		switch words[n-1] {
+		case "B.C.", "BC", "B.C", "BCE", "(B.C.)":
+			bc = true
+			words = words[:n-1]
+		}
+	}
+	if len(words) == 0 || len(words) > 3 {
+		return cal, v, false, false
+	}
+
+	year := words[len(words)-1]
+	if i := strings.Index(year, "/"); i > 0 {
+		dual = true
+		year = year[:i]
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil || y <= 0 {
+		return cal, v, false, false
+	}
+	if dual {
+		// As in Gramps, the year of a dual dated date is the later one.
+		y++
+	}
+	v.Year = y
+	if bc {
+		v.Year = -y
+	}
+	if len(words) >= 2 {
+		for i, m := range months[cal] {
+			if m == words[len(words)-2] {
+				v.Month = i + 1
+			}
+		}
+		if v.Month == 0 {
+			return cal, v, false, false
+		}
+	}
+	if len(words) == 3 {
+		d, err := strconv.Atoi(words[0])
+		if err != nil || d < 1 || d > 31 {
+			return cal, v, false, false
+		}
+		v.Day = d
+	}
+	return cal, v, dual, true
+}
+
+// Parse a GEDCOM date value. A date that cannot be parsed is returned as text
+// with ok false. The phrase of an interpreted date is left out, and FROM and
+// TO dates are read as after and before.
+func parseDate(s string) (d xml.Date, ok bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		return xml.Date{Modifier: xml.ModTextOnly, Text: s[1 : len(s)-1]}, true
+	}
+	text := xml.Date{Modifier: xml.ModTextOnly, Text: s}
+	u := strings.ToUpper(strings.Replace(s, "@#DFRENCH R@", "@#DFRENCHR@", -1))
+	if i := strings.Index(u, "("); i > 0 && strings.HasPrefix(u, "INT ") {
+		u = u[4:i]
+	}
+	words := strings.Fields(u)
+	if len(words) == 0 {
+		return xml.Date{}, true
+	}
+
+	switch words[0] {
+	case "EST":
+		d.Quality = xml.QualityEstimated
+		words = words[1:]
+	case "CAL":
+		d.Quality = xml.QualityCalculated
+		words = words[1:]
+	}
+	var start, stop []string
+	if len(words) > 0 {
+		switch words[0] {
+		case "ABT", "ABOUT", "CIRCA":
+			d.Modifier = xml.ModAbout
+			start = words[1:]
+		case "BEF", "BEFORE", "TO":
+			d.Modifier = xml.ModBefore
+			start = words[1:]
+		case "AFT", "AFTER":
+			d.Modifier = xml.ModAfter
+			start = words[1:]
+		case "BET", "FROM":
+			start = words[1:]
+			sep := "AND"
+			d.Modifier = xml.ModRange
+			if words[0] == "FROM" {
+				sep = "TO"
+				d.Modifier = xml.ModSpan
+			}
+			for i, w := range start {
+				if w == sep {
+					start, stop = start[:i], start[i+1:]
+					break
+				}
+			}
+			if stop == nil {
+				if d.Modifier == xml.ModRange {
+					return text, false
+				}
+				d.Modifier = xml.ModAfter
+			}
+		default:
+			start = words
+		}
+	}
+
+	var dual1, dual2 bool
+	d.Calendar, d.Start, dual1, ok = parseYMD(start)
+	if !ok {
+		return text, false
+	}
+	if stop != nil {
+		var cal xml.Calendar
+		cal, d.Stop, dual2, ok = parseYMD(stop)
+		if !ok || cal != d.Calendar {
+			return text, false
+		}
+	}
+	d.DualDated = dual1 || dual2
+	return d, true
+}
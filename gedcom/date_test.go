@@ -0,0 +1,117 @@
+package gedcom
+
+import (
+	"testing"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+func TestFormatDate(t *testing.T) {
+	for _, c := range []struct {
+		date  xml.Date
+		value string
+		loss  bool
+	}{
+		{xml.Date{Start: ymd(1897, 3, 12)}, "12 MAR 1897", false},
+		{xml.Date{Start: ymd(1897, 3, 0)}, "MAR 1897", false},
+		{xml.Date{Modifier: xml.ModBefore, Start: ymd(1897, 0, 0)}, "BEF 1897", false},
+		{xml.Date{Modifier: xml.ModRange, Start: ymd(1889, 0, 0), Stop: ymd(2019, 0, 0)},
+			"BET 1889 AND 2019", false},
+		{xml.Date{Modifier: xml.ModSpan, Start: ymd(1889, 0, 0), Stop: ymd(2019, 0, 0)},
+			"FROM 1889 TO 2019", false},
+		{xml.Date{Quality: xml.QualityEstimated, Start: ymd(1850, 0, 0)}, "EST 1850", false},
+		{xml.Date{Quality: xml.QualityCalculated, Modifier: xml.ModAbout, Start: ymd(1850, 0, 0)},
+			"ABT 1850", true},
+		{xml.Date{Calendar: xml.Julian, Start: ymd(1750, 3, 4), DualDated: true},
+			"@#DJULIAN@ 4 MAR 1749/50", false},
+		{xml.Date{Calendar: xml.Hebrew, Start: ymd(5700, 1, 0)}, "@#DHEBREW@ TSH 5700", false},
+		{xml.Date{Start: ymd(-44, 3, 15)}, "15 MAR 44 B.C.", false},
+		{xml.Date{Calendar: xml.Julian, Start: ymd(1700, 0, 0), NewYear: "Mar25"},
+			"@#DJULIAN@ 1700", true},
+		{xml.Date{Start: ymd(0, 3, 12)}, "(", true},
+		{xml.Date{Modifier: xml.ModTextOnly, Text: "in the spring"}, "(in the spring)", false},
+		{xml.Date{}, "", false},
+	} {
+		value, loss := formatDate(c.date, false)
+		if c.value == "(" {
+			if len(value) == 0 || value[0] != '(' {
+				t.Errorf("%v: got %q, want a date phrase", c.date, value)
+			}
+		} else if value != c.value {
+			t.Errorf("%v: got %q, want %q", c.date, value, c.value)
+		}
+		if (loss != "") != c.loss {
+			t.Errorf("%v: got loss %q", c.date, loss)
+		}
+	}
+
+	// Dates in other calendars are converted.
+	d := xml.Date{Calendar: xml.Islamic, Start: ymd(1400, 1, 1)}
+	if value, loss := formatDate(d, false); value != "21 NOV 1979" || loss == "" {
+		t.Errorf("Got %q, %q", value, loss)
+	}
+}
+
+func TestFormatDateSeven(t *testing.T) {
+	for _, c := range []struct {
+		date  xml.Date
+		value string
+		loss  bool
+	}{
+		{xml.Date{Start: ymd(1897, 3, 12)}, "12 MAR 1897", false},
+		{xml.Date{Calendar: xml.Julian, Start: ymd(1750, 3, 4), DualDated: true}, "JULIAN 4 MAR 1750", true},
+		{xml.Date{Calendar: xml.FrenchRepublican, Start: ymd(3, 1, 1)}, "FRENCH_R 1 VEND 3", false},
+		{xml.Date{Start: ymd(-44, 3, 15)}, "15 MAR 44 BCE", false},
+	} {
+		value, loss := formatDate(c.date, true)
+		if value != c.value || (loss != "") != c.loss {
+			t.Errorf("%v: got %q, %q, want %q", c.date, value, loss, c.value)
+		}
+		if d, ok := parseDate(value); !ok || d.Start != c.date.Start || d.Calendar != c.date.Calendar {
+			t.Errorf("%s: read back as %+v", value, d)
+		}
+	}
+}
+
+func ymd(year, month, day int) xml.YMD {
+	return xml.YMD{Year: year, Month: month, Day: day}
+}
+
+func TestParseDate(t *testing.T) {
+	for _, c := range []struct {
+		value string
+		date  xml.Date
+		ok    bool
+	}{
+		{"12 MAR 1897", xml.Date{Start: ymd(1897, 3, 12)}, true},
+		{"abt Mar 1897", xml.Date{Modifier: xml.ModAbout, Start: ymd(1897, 3, 0)}, true},
+		{"EST 1850", xml.Date{Quality: xml.QualityEstimated, Start: ymd(1850, 0, 0)}, true},
+		{"CAL AFT 1850", xml.Date{Quality: xml.QualityCalculated, Modifier: xml.ModAfter, Start: ymd(1850, 0, 0)}, true},
+		{"BET 1889 AND JUN 2019", xml.Date{Modifier: xml.ModRange, Start: ymd(1889, 0, 0), Stop: ymd(2019, 6, 0)}, true},
+		{"FROM 1889 TO 2019", xml.Date{Modifier: xml.ModSpan, Start: ymd(1889, 0, 0), Stop: ymd(2019, 0, 0)}, true},
+		{"FROM 1889", xml.Date{Modifier: xml.ModAfter, Start: ymd(1889, 0, 0)}, true},
+		{"TO 1889", xml.Date{Modifier: xml.ModBefore, Start: ymd(1889, 0, 0)}, true},
+		{"@#DJULIAN@ 4 MAR 1749/50", xml.Date{Calendar: xml.Julian, Start: ymd(1750, 3, 4), DualDated: true}, true},
+		{"@#DFRENCH R@ 1 VEND 3", xml.Date{Calendar: xml.FrenchRepublican, Start: ymd(3, 1, 1)}, true},
+		{"15 MAR 44 B.C.", xml.Date{Start: ymd(-44, 3, 15)}, true},
+		{"INT 1850 (about when the war ended)", xml.Date{Start: ymd(1850, 0, 0)}, true},
+		{"(in the spring)", xml.Date{Modifier: xml.ModTextOnly, Text: "in the spring"}, true},
+		{"the spring of 1850", xml.Date{Modifier: xml.ModTextOnly, Text: "the spring of 1850"}, false},
+		{"BET 1889", xml.Date{Modifier: xml.ModTextOnly, Text: "BET 1889"}, false},
+		{"@#DROMAN@ 1850", xml.Date{Modifier: xml.ModTextOnly, Text: "@#DROMAN@ 1850"}, false},
+		{"32 JAN 1850", xml.Date{Modifier: xml.ModTextOnly, Text: "32 JAN 1850"}, false},
+	} {
+		d, ok := parseDate(c.value)
+		if d != c.date || ok != c.ok {
+			t.Errorf("%s: got %+v, %t, want %+v, %t", c.value, d, ok, c.date, c.ok)
+		}
+	}
+
+	// Written dates are read back.
+	for _, value := range []string{"BEF 12 MAR 1897", "EST 1850", "@#DHEBREW@ TSH 5700", "FROM 1889 TO 2019"} {
+		d, ok := parseDate(value)
+		if got, loss := formatDate(d, false); !ok || got != value || loss != "" {
+			t.Errorf("%s: got %q, %q", value, got, loss)
+		}
+	}
+}
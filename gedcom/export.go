@@ -0,0 +1,1017 @@
+package gedcom
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+// The versions of GEDCOM that can be exported.
+const (
+	Version551 = "5.5.1"
+	Version70  = "7.0"
+)
+
+// Options controlling how a Database is exported.
+type ExportOptions struct {
+	// The name of the exporting program in the header: GOGRAMPS unless set.
+	Source string
+	// The time of the export in the header: now unless set.
+	Date time.Time
+	// The version of GEDCOM written: Version551 unless set.
+	Version string
+	// The directory of media files with relative paths, for ExportGEDZIP:
+	// the media path of the database unless set.
+	MediaPath string
+}
+
+// The xref of the submitter, who is the researcher of the database.
+const submitter = "SUBM"
+
+type exporter struct {
+	db       *xml.Database
+	lw       *lineWriter
+	opts     ExportOptions
+	xrefs    map[string]string
+	warnings []Warning
+	// Whether GEDCOM 7.0 is written, and the paths media files are written
+	// with by object handle, if not their own.
+	seven bool
+	files map[string]string
+}
+
+// Export db to w as GEDCOM 5.5.1 or 7.0 in UTF-8, with the Gramps IDs of
+// objects as their xrefs. Returns what could not be represented in GEDCOM.
+func Export(w io.Writer, db *xml.Database, opts ExportOptions) ([]Warning, error) {
+	return export(w, db, opts, nil)
+}
+
+func export(w io.Writer, db *xml.Database, opts ExportOptions, files map[string]string) ([]Warning, error) {
+	if opts.Source == "" {
+		opts.Source = "GOGRAMPS"
+	}
+	if opts.Date.IsZero() {
+		opts.Date = time.Now()
+	}
+	if opts.Version == "" {
+		opts.Version = Version551
+	}
+	if opts.Version != Version551 && opts.Version != Version70 {
+		return nil, fmt.Errorf("Unsupported GEDCOM version: %q", opts.Version)
+	}
+	seven := opts.Version == Version70
+	e := &exporter{db: db, lw: newLineWriter(w, seven), opts: opts, seven: seven, files: files}
+	e.assignXrefs()
+	e.header()
+	for _, p := range db.People.Persons {
+		e.person(p)
+	}
+	for _, f := range db.Families {
+		e.family(f)
+	}
+	for _, s := range db.Sources {
+		e.source(s)
+	}
+	for _, r := range db.Repositories {
+		e.repository(r)
+	}
+	for _, o := range db.Objects {
+		e.object(o)
+	}
+	for _, n := range db.Notes {
+		e.note(n)
+	}
+	e.lw.line(0, "", "TRLR", "")
+	return e.warnings, e.lw.flush()
+}
+
+// Get the ID of obj, or its handle if it has none.
+func idOf(obj xml.DBObj) string {
+	if o, ok := obj.(interface {
+		GetID() string
+	}); ok && o.GetID() != "" {
+		return o.GetID()
+	}
+	return obj.GetHandle()
+}
+
+func (e *exporter) warn(obj xml.DBObj, format string, args ...interface{}) {
+	w := Warning{Message: fmt.Sprintf(format, args...)}
+	if obj != nil {
+		w.ID = idOf(obj)
+	}
+	e.warnings = append(e.warnings, w)
+}
+
+// The longest xref GEDCOM allows, without the at signs.
+const maxXref = 20
+
+// Get id with the characters GEDCOM does not allow in xrefs left out.
+// GEDCOM 7.0 does not allow lower case letters.
+func cleanXref(id string, seven bool) string {
+	var b []byte
+	for i := 0; i < len(id) && len(b) < maxXref; i++ {
+		c := id[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' && !seven || c >= '0' && c <= '9' || c == '_' {
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// The first letters of generated xrefs, by the element names of objects.
+var xrefPrefixes = map[string]string{
+	"person": "I", "family": "F", "source": "S", "repository": "R", "object": "O", "note": "N",
+}
+
+// Give the objects of records xrefs: their IDs when they can be, or else the
+// first letter of the record and a number.
+func (e *exporter) assignXrefs() {
+	e.xrefs = map[string]string{}
+	used := map[string]bool{submitter: true}
+	var objs []xml.DBObj
+	for _, p := range e.db.People.Persons {
+		objs = append(objs, p)
+	}
+	for _, f := range e.db.Families {
+		objs = append(objs, f)
+	}
+	for _, s := range e.db.Sources {
+		objs = append(objs, s)
+	}
+	for _, r := range e.db.Repositories {
+		objs = append(objs, r)
+	}
+	for _, o := range e.db.Objects {
+		objs = append(objs, o)
+	}
+	for _, n := range e.db.Notes {
+		objs = append(objs, n)
+	}
+	var later []xml.DBObj
+	for _, obj := range objs {
+		id := idOf(obj)
+		if x := cleanXref(id, e.seven); x != "" && x == id && !used[x] && x != "VOID" {
+			e.xrefs[obj.GetHandle()] = x
+			used[x] = true
+		} else {
+			later = append(later, obj)
+		}
+	}
+	n := 0
+	for _, obj := range later {
+		prefix := xrefPrefixes[xml.ElementName(obj)]
+		x := ""
+		for x == "" || used[x] {
+			n++
+			x = prefix + "X" + strconv.Itoa(n)
+		}
+		e.xrefs[obj.GetHandle()] = x
+		used[x] = true
+		e.warn(obj, "ID cannot be an xref; written as %s", x)
+	}
+}
+
+// Get the xref of the object with handle h, or "" if it is not exported.
+func (e *exporter) xref(h string) string {
+	return e.xrefs[h]
+}
+
+func (e *exporter) header() {
+	lw := e.lw
+	lw.line(0, "", "HEAD", "")
+	if e.seven {
+		lw.line(1, "", "GEDC", "")
+		lw.line(2, "", "VERS", Version70)
+		lw.line(1, "", "SCHMA", "")
+		for _, tag := range extensionTags {
+			lw.line(2, "", "TAG", tag+" "+schemaURI+tag)
+		}
+	}
+	lw.line(1, "", "SOUR", e.opts.Source)
+	lw.line(2, "", "NAME", "gogramps")
+	lw.line(1, "", "DATE", strings.ToUpper(e.opts.Date.Format("2 Jan 2006")))
+	lw.line(2, "", "TIME", e.opts.Date.Format("15:04:05"))
+	lw.pointer(1, "SUBM", submitter)
+	if !e.seven {
+		lw.line(1, "", "GEDC", "")
+		lw.line(2, "", "VERS", Version551)
+		lw.line(2, "", "FORM", "LINEAGE-LINKED")
+		lw.line(1, "", "CHAR", "UTF-8")
+	}
+
+	lw.line(0, submitter, "SUBM", "")
+	r := e.db.Header.Researcher
+	if r == nil || str(r.ResName) == "" {
+		lw.line(1, "", "NAME", "Not Provided")
+		return
+	}
+	lw.line(1, "", "NAME", str(r.ResName))
+	e.address(1, &xml.Address{Street: r.ResAddr, Locality: r.ResLocality, City: r.ResCity,
+		State: r.ResState, Country: r.ResCountry, Postal: r.ResPostal, Phone: r.ResPhone})
+	if email := str(r.ResEMail); email != "" {
+		lw.line(1, "", "EMAIL", email)
+	}
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Get a keyword as the version written has it: in capitals in GEDCOM 7.0.
+func (e *exporter) keyword(k string) string {
+	if e.seven {
+		return strings.ToUpper(k)
+	}
+	return k
+}
+
+// Write a line with the keyword of value in keywords, or nothing if it is "".
+// Other values are written as they are, or as OTHER with a phrase in GEDCOM
+// 7.0.
+func (e *exporter) enum(level int, tag, value string, keywords map[string]string) {
+	k, ok := keywords[value]
+	switch {
+	case ok && k == "":
+	case ok:
+		e.lw.line(level, "", tag, e.keyword(k))
+	case e.seven:
+		e.lw.line(level, "", tag, "OTHER")
+		e.lw.line(level+1, "", "PHRASE", value)
+	default:
+		e.lw.line(level, "", tag, value)
+	}
+}
+
+// Write the change time of a record, a Unix time.
+func (e *exporter) change(change string) {
+	secs, err := strconv.ParseInt(change, 10, 64)
+	if err != nil || secs == 0 {
+		return
+	}
+	t := time.Unix(secs, 0).UTC()
+	e.lw.line(1, "", "CHAN", "")
+	e.lw.line(2, "", "DATE", strings.ToUpper(t.Format("2 Jan 2006")))
+	e.lw.line(3, "", "TIME", t.Format("15:04:05"))
+}
+
+// Write a DATE line for the date of v, an object with a date.
+func (e *exporter) date(level int, obj xml.DBObj, v interface {
+	GetDate() (xml.Date, error)
+	GetDateString() string
+}) {
+	d, err := v.GetDate()
+	var value, loss string
+	if err != nil {
+		e.warn(obj, "invalid date %q written as text", v.GetDateString())
+		value = "(" + v.GetDateString() + ")"
+	} else if value, loss = formatDate(d, e.seven); value == "" {
+		return
+	}
+	if loss != "" {
+		e.warn(obj, "date %s: %s", xml.DefaultDateDisplayer.Display(d), loss)
+	}
+	switch {
+	case !strings.HasPrefix(value, "("):
+		e.lw.raw(level, "", "DATE", value)
+	case e.seven:
+		// Date phrases are written without a date.
+		e.lw.line(level, "", "DATE", "")
+		e.lw.line(level+1, "", "PHRASE", value[1:len(value)-1])
+	default:
+		e.lw.line(level, "", "DATE", value)
+	}
+}
+
+// Get the tag of note records and links to them: SNOTE in GEDCOM 7.0.
+func (e *exporter) noteTag() string {
+	if e.seven {
+		return "SNOTE"
+	}
+	return "NOTE"
+}
+
+// Write links to notes.
+func (e *exporter) notes(level int, refs []*xml.GenericLink) {
+	for _, ref := range refs {
+		if x := e.xref(ref.HLink); x != "" {
+			e.lw.pointer(level, e.noteTag(), x)
+		}
+	}
+}
+
+// Write links to media objects.
+func (e *exporter) objRefs(level int, obj xml.DBObj, refs []*xml.ObjRef) {
+	for _, ref := range refs {
+		x := e.xref(ref.HLink)
+		if x == "" {
+			continue
+		}
+		e.lw.pointer(level, "OBJE", x)
+		if ref.Region != nil {
+			e.warn(obj, "region of media object %s left out", x)
+		}
+		if len(ref.Attributes) > 0 || len(ref.CitationRefs) > 0 || len(ref.NoteRefs) > 0 {
+			e.warn(obj, "attributes, citations and notes of the link to media object %s left out", x)
+		}
+	}
+}
+
+// The GEDCOM certainty assessments of the confidence levels of Gramps, from
+// very low to very high. Normal is not written.
+var quality = []string{"0", "1", "", "2", "3"}
+
+// Write citations as source citations.
+func (e *exporter) citations(level int, obj xml.DBObj, refs []*xml.GenericLink) {
+	for _, ref := range refs {
+		c := e.db.CitationByHandle(ref.HLink)
+		if c == nil {
+			continue
+		}
+		x := e.xref(c.SourceRef.HLink)
+		if x == "" {
+			e.warn(obj, "citation %s without a source left out", idOf(c))
+			continue
+		}
+		e.lw.pointer(level, "SOUR", x)
+		if page := str(c.Page); page != "" {
+			e.lw.text(level+1, "", "PAGE", page)
+		}
+		if c.GetDateString() != "" {
+			e.lw.line(level+1, "", "DATA", "")
+			e.date(level+2, c, c)
+		}
+		if conf, err := strconv.Atoi(str(c.Confidence)); err == nil && conf >= 0 &&
+			conf < len(quality) && quality[conf] != "" {
+			e.lw.line(level+1, "", "QUAY", quality[conf])
+		}
+		e.notes(level+1, c.NoteRefs)
+		e.objRefs(level+1, c, c.ObjRefs)
+		if len(c.DataItems) > 0 || len(c.SrcAttributes) > 0 || len(c.Attributes) > 0 {
+			e.warn(c, "attributes of citation left out")
+		}
+	}
+}
+
+// Write an address structure: ADDR with its parts, and PHON.
+func (e *exporter) address(level int, a *xml.Address) {
+	var lines []string
+	for _, s := range []*string{a.Street, a.Locality} {
+		if str(s) != "" {
+			lines = append(lines, str(s))
+		}
+	}
+	var city []string
+	for _, s := range []*string{a.City, a.County, a.State, a.Postal} {
+		if str(s) != "" {
+			city = append(city, str(s))
+		}
+	}
+	if len(city) > 0 {
+		lines = append(lines, strings.Join(city, ", "))
+	}
+	if str(a.Country) != "" {
+		lines = append(lines, str(a.Country))
+	}
+	if len(lines) > 0 {
+		e.lw.text(level, "", "ADDR", strings.Join(lines, "\n"))
+		for _, part := range []struct {
+			tag   string
+			value *string
+		}{
+			{"ADR1", a.Street}, {"ADR2", a.Locality}, {"CITY", a.City}, {"STAE", a.State},
+			{"POST", a.Postal}, {"CTRY", a.Country},
+		} {
+			if str(part.value) != "" {
+				e.lw.line(level+1, "", part.tag, str(part.value))
+			}
+		}
+	}
+	if phone := str(a.Phone); phone != "" {
+		e.lw.line(level, "", "PHON", phone)
+	}
+}
+
+// Get the name of a place, with the names of the places enclosing it: its
+// title if it has one, or else its name and those of the places it is in.
+func (e *exporter) placeName(p *xml.PlaceObj) string {
+	if t := str(p.PTitle); t != "" {
+		return t
+	}
+	var names []string
+	seen := map[*xml.PlaceObj]bool{}
+	for q := p; q != nil && !seen[q]; {
+		seen[q] = true
+		if len(q.PNames) > 0 {
+			names = append(names, q.PNames[0].Value)
+		}
+		if len(q.PlaceRefs) == 0 {
+			break
+		}
+		q = e.db.PlaceByHandle(q.PlaceRefs[0].HLink)
+	}
+	if len(names) == 0 && len(p.Locations) > 0 {
+		l := p.Locations[0]
+		for _, s := range []string{l.Street, l.Locality, l.City, l.Parish, l.County, l.State, l.Country} {
+			if s != "" {
+				names = append(names, s)
+			}
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Get a coordinate in degrees as GEDCOM writes it, e.g. N50.5 or W3.2, from a
+// coordinate of Gramps.
+func coordinate(s string, positive, negative string) (string, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return "", false
+	}
+	if v < 0 {
+		return negative + strconv.FormatFloat(-v, 'f', -1, 64), true
+	}
+	return positive + strconv.FormatFloat(v, 'f', -1, 64), true
+}
+
+// Write a place structure for the place linked to.
+func (e *exporter) place(level int, obj xml.DBObj, l *xml.GenericLink) {
+	if l == nil {
+		return
+	}
+	p := e.db.PlaceByHandle(l.HLink)
+	if p == nil {
+		return
+	}
+	e.lw.line(level, "", "PLAC", e.placeName(p))
+	if p.Coord != nil {
+		lat, ok1 := coordinate(p.Coord.Lat, "N", "S")
+		long, ok2 := coordinate(p.Coord.Long, "E", "W")
+		if ok1 && ok2 {
+			e.lw.line(level+1, "", "MAP", "")
+			e.lw.line(level+2, "", "LATI", lat)
+			e.lw.line(level+2, "", "LONG", long)
+		} else {
+			e.warn(p, "coordinates %s, %s are not decimal degrees; left out", p.Coord.Lat, p.Coord.Long)
+		}
+	}
+	e.notes(level+1, p.NoteRefs)
+	if len(p.CitationRefs) > 0 || len(p.ObjRefs) > 0 || len(p.URLs) > 0 {
+		e.warn(p, "citations, media and URLs of place left out")
+	}
+}
+
+// Write the details of an event: its date, place, attributes, notes,
+// citations and media. ref may be nil.
+func (e *exporter) eventDetail(level int, ev *xml.Event, ref *xml.EventRef) {
+	e.date(level, ev, ev)
+	e.place(level, ev, ev.Place)
+	var attrs []*xml.Attribute
+	attrs = append(attrs, ev.Attributes...)
+	var refNotes []*xml.GenericLink
+	if ref != nil {
+		attrs = append(attrs, ref.Attributes...)
+		refNotes = ref.NoteRefs
+	}
+	for _, a := range attrs {
+		if tag, ok := eventAttributeTags[a.Type]; ok {
+			e.lw.line(level, "", tag, a.Value)
+		} else {
+			e.warn(ev, "attribute %s of event left out", a.Type)
+		}
+	}
+	if e.seven {
+		e.associations(level, ev)
+	}
+	e.notes(level, ev.NoteRefs)
+	e.notes(level, refNotes)
+	e.citations(level, ev, ev.CitationRefs)
+	e.objRefs(level, ev, ev.ObjRefs)
+	if ev.Priv != 0 {
+		e.lw.line(level, "", "RESN", e.keyword("privacy"))
+	}
+}
+
+// Write the people with roles other than the primary one in ev as the
+// associations of GEDCOM 7.0 events.
+func (e *exporter) associations(level int, ev *xml.Event) {
+	for _, ref := range e.db.Referrers(ev.Handle) {
+		p, ok := ref.From.(*xml.Person)
+		er, ok2 := ref.Link.(*xml.EventRef)
+		if !ok || !ok2 || er.Role == "Primary" || er.Role == "" || e.xref(p.Handle) == "" {
+			continue
+		}
+		e.lw.pointer(level, "ASSO", e.xref(p.Handle))
+		e.enum(level+1, "ROLE", er.Role, roleTags)
+		e.notes(level+1, er.NoteRefs)
+	}
+}
+
+// Write an event with tags, the GEDCOM tags of event types of an individual or
+// a family.
+func (e *exporter) event(level int, ev *xml.Event, ref *xml.EventRef, tags map[string]string) {
+	typ := str(ev.Type)
+	descr := str(ev.Description)
+	tag, ok := tags[typ]
+	switch {
+	case !ok:
+		e.lw.line(level, "", "EVEN", descr)
+		e.lw.line(level+1, "", "TYPE", typ)
+	case factTags[tag]:
+		e.lw.line(level, "", tag, descr)
+	default:
+		value := ""
+		if ev.GetDateString() == "" && ev.Place == nil {
+			value = "Y"
+		}
+		e.lw.line(level, "", tag, value)
+		if descr != "" {
+			e.lw.line(level+1, "", "TYPE", descr)
+		}
+	}
+	e.eventDetail(level+1, ev, ref)
+}
+
+// Get whether ev is written as the event of a family or of a person other
+// than p, with the associations of the people in it.
+func (e *exporter) owned(ev *xml.Event, p *xml.Person) bool {
+	if e.principal(ev, p) != nil {
+		return true
+	}
+	for _, ref := range e.db.Referrers(ev.Handle) {
+		if _, ok := ref.From.(*xml.Family); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the person with the primary role in ev, other than p.
+func (e *exporter) principal(ev *xml.Event, p *xml.Person) *xml.Person {
+	for _, ref := range e.db.Referrers(ev.Handle) {
+		q, ok := ref.From.(*xml.Person)
+		if !ok || q == p {
+			continue
+		}
+		if er, ok := ref.Link.(*xml.EventRef); ok && (er.Role == "Primary" || er.Role == "") {
+			return q
+		}
+	}
+	return nil
+}
+
+// Write the name of a person.
+func (e *exporter) name(p *xml.Person, n *xml.Name) {
+	lw := e.lw
+	var surnames []string
+	for _, s := range n.Surnames {
+		if full := strings.TrimSpace(s.Prefix + " " + s.Value); full != "" {
+			surnames = append(surnames, full)
+		}
+	}
+	value := strings.TrimSpace(str(n.First) + " /" + strings.Join(surnames, " ") + "/ " + str(n.Suffix))
+	lw.line(1, "", "NAME", value)
+	e.enum(2, "TYPE", n.Type, nameTypes)
+	primary := n.PrimarySurname()
+	for _, part := range []struct {
+		tag, value string
+	}{
+		{"NPFX", str(n.Title)},
+		{"GIVN", str(n.First)},
+		{"NICK", str(n.Nick)},
+		{"_RUFNAME", str(n.Call)},
+	} {
+		if part.value != "" {
+			lw.line(2, "", part.tag, part.value)
+		}
+	}
+	if primary != nil && primary.Prefix != "" {
+		lw.line(2, "", "SPFX", primary.Prefix)
+	}
+	var values []string
+	for _, s := range n.Surnames {
+		if s.Value != "" {
+			values = append(values, s.Value)
+		}
+	}
+	if len(values) > 0 {
+		lw.line(2, "", "SURN", strings.Join(values, ","))
+	}
+	if str(n.Suffix) != "" {
+		lw.line(2, "", "NSFX", str(n.Suffix))
+	}
+	e.notes(2, n.NoteRefs)
+	e.citations(2, p, n.CitationRefs)
+	if n.GetDateString() != "" {
+		e.warn(p, "date of name %s left out", n)
+	}
+	if str(n.FamilyNick) != "" {
+		e.warn(p, "family nickname %s left out", str(n.FamilyNick))
+	}
+}
+
+// Write an LDS ordinance.
+func (e *exporter) ldsOrd(level int, obj xml.DBObj, o *xml.LDSOrd) {
+	tag, ok := ldsTags[o.Type]
+	if !ok {
+		e.warn(obj, "LDS ordinance %s left out", o.Type)
+		return
+	}
+	e.lw.line(level, "", tag, "")
+	e.date(level+1, obj, o)
+	if o.Temple != nil {
+		e.lw.line(level+1, "", "TEMP", o.Temple.Val)
+	}
+	e.place(level+1, obj, o.Place)
+	if o.Status != nil {
+		e.lw.line(level+1, "", "STAT", strings.ToUpper(o.Status.Val))
+	}
+	if o.SealedTo != nil {
+		if x := e.xref(o.SealedTo.HLink); x != "" {
+			e.lw.pointer(level+1, "FAMC", x)
+		}
+	}
+	e.notes(level+1, o.NoteRefs)
+	e.citations(level+1, obj, o.CitationRefs)
+}
+
+// Write the pedigree of p in f, from its relations to the father and mother.
+func (e *exporter) pedigree(p *xml.Person, f *xml.Family) {
+	for _, ref := range f.ChildRefs {
+		if ref.HLink != p.Handle {
+			continue
+		}
+		rel := ref.FRel
+		if rel == "" || rel == "Birth" {
+			rel = ref.MRel
+		}
+		if _, ok := pedigrees[rel]; !ok && !e.seven {
+			e.warn(p, "relation %s to the parents in family %s written as birth", rel, idOf(f))
+			return
+		}
+		if ref.FRel != ref.MRel && ref.FRel != "" && ref.MRel != "" {
+			e.warn(p, "different relations to the father and mother in family %s", idOf(f))
+		}
+		e.enum(2, "PEDI", rel, pedigrees)
+		return
+	}
+}
+
+func (e *exporter) person(p *xml.Person) {
+	lw := e.lw
+	lw.line(0, e.xref(p.Handle), "INDI", "")
+	if p.Priv != 0 {
+		lw.line(1, "", "RESN", e.keyword("privacy"))
+	}
+	for _, n := range p.Names {
+		e.name(p, n)
+	}
+	switch p.Gender {
+	case "M", "F":
+		lw.line(1, "", "SEX", p.Gender)
+	default:
+		lw.line(1, "", "SEX", "U")
+	}
+	for _, ref := range p.EventRefs {
+		ev := e.db.EventByHandle(ref.HLink)
+		if ev == nil {
+			continue
+		}
+		if ref.Role == "Primary" || ref.Role == "" {
+			e.event(1, ev, ref, personEventTags)
+			continue
+		}
+		if e.seven && e.owned(ev, p) {
+			continue
+		}
+		if q := e.principal(ev, p); q != nil {
+			lw.pointer(1, "ASSO", e.xref(q.Handle))
+			lw.line(2, "", "RELA", ref.Role+" of "+str(ev.Type))
+			e.notes(2, ref.NoteRefs)
+			continue
+		}
+		e.warn(p, "event %s with role %s and no principal written as the person's own", idOf(ev), ref.Role)
+		e.event(1, ev, ref, personEventTags)
+	}
+	for _, a := range p.Attributes {
+		e.attribute(1, p, a, attributeTags)
+	}
+	for _, a := range p.Addresses {
+		lw.line(1, "", "RESI", "")
+		e.date(2, p, a)
+		e.address(2, a)
+		e.notes(2, a.NoteRefs)
+		e.citations(2, p, a.CitationRefs)
+	}
+	for _, o := range p.LDSOrds {
+		e.ldsOrd(1, p, o)
+	}
+	for _, l := range p.ChildOfs {
+		f := e.db.FamilyByHandle(l.HLink)
+		if f == nil {
+			continue
+		}
+		lw.pointer(1, "FAMC", e.xref(f.Handle))
+		e.pedigree(p, f)
+	}
+	for _, l := range p.ParentIns {
+		if x := e.xref(l.HLink); x != "" {
+			lw.pointer(1, "FAMS", x)
+		}
+	}
+	for _, ref := range p.PersonRefs {
+		if x := e.xref(ref.HLink); x != "" {
+			lw.pointer(1, "ASSO", x)
+			if e.seven {
+				e.enum(2, "ROLE", ref.Rel, roleTags)
+			} else {
+				lw.line(2, "", "RELA", ref.Rel)
+			}
+			e.notes(2, ref.NoteRefs)
+			e.citations(2, p, ref.CitationRefs)
+		}
+	}
+	for _, u := range p.URLs {
+		lw.line(1, "", "WWW", u.HRef)
+	}
+	e.notes(1, p.NoteRefs)
+	e.citations(1, p, p.CitationRefs)
+	e.objRefs(1, p, p.ObjRefs)
+	if len(p.TagRefs) > 0 {
+		e.warn(p, "tags left out")
+	}
+	e.change(p.Change)
+}
+
+// Get the type of an external identifier of GEDCOM 7.0 kept in an attribute
+// of type typ: EXID, or EXID: and the URI of the type. Returns ok false for
+// other attributes.
+func exidType(typ string) (uri string, ok bool) {
+	if typ == "EXID" {
+		return "", true
+	}
+	if strings.HasPrefix(typ, "EXID:") {
+		return typ[len("EXID:"):], true
+	}
+	return "", false
+}
+
+// Write an attribute of type typ as an external identifier, in GEDCOM 7.0.
+// Returns whether it was written.
+func (e *exporter) exid(level int, typ, value string) bool {
+	uri, ok := exidType(typ)
+	if !ok || !e.seven {
+		return false
+	}
+	e.lw.line(level, "", "EXID", value)
+	if uri != "" {
+		e.lw.line(level+1, "", "TYPE", uri)
+	}
+	return true
+}
+
+// Write an attribute of type NO, whose value is an event type that did not
+// happen, as a negative assertion of GEDCOM 7.0. Returns whether it was
+// written.
+func (e *exporter) negative(level int, obj xml.DBObj, a *xml.Attribute) bool {
+	tags := personEventTags
+	if _, ok := obj.(*xml.Family); ok {
+		tags = familyEventTags
+	}
+	tag, ok := tags[a.Value]
+	if !ok || a.Type != "NO" || !e.seven {
+		return false
+	}
+	e.lw.line(level, "", "NO", tag)
+	e.notes(level+1, a.NoteRefs)
+	e.citations(level+1, obj, a.CitationRefs)
+	return true
+}
+
+// Write an attribute as the fact with its tag in tags, or else as FACT.
+// External identifiers and negative assertions are written as such in
+// GEDCOM 7.0.
+func (e *exporter) attribute(level int, obj xml.DBObj, a *xml.Attribute, tags map[string]string) {
+	if e.negative(level, obj, a) {
+		return
+	}
+	if e.exid(level, a.Type, a.Value) {
+		if len(a.NoteRefs) > 0 || len(a.CitationRefs) > 0 {
+			e.warn(obj, "notes and citations of external identifier %s left out", a.Value)
+		}
+		return
+	}
+	if tag, ok := tags[a.Type]; ok {
+		e.lw.line(level, "", tag, a.Value)
+	} else {
+		e.lw.line(level, "", "FACT", a.Value)
+		e.lw.line(level+1, "", "TYPE", a.Type)
+	}
+	e.notes(level+1, a.NoteRefs)
+	e.citations(level+1, obj, a.CitationRefs)
+}
+
+func (e *exporter) family(f *xml.Family) {
+	lw := e.lw
+	lw.line(0, e.xref(f.Handle), "FAM", "")
+	if f.Priv != 0 {
+		lw.line(1, "", "RESN", e.keyword("privacy"))
+	}
+	if f.Rel != nil && f.Rel.Type != "" && f.Rel.Type != "Married" && f.Rel.Type != "Unknown" {
+		e.warn(f, "relationship type %s left out", f.Rel.Type)
+	}
+	if f.Father != nil {
+		if x := e.xref(f.Father.HLink); x != "" {
+			lw.pointer(1, "HUSB", x)
+		}
+	}
+	if f.Mother != nil {
+		if x := e.xref(f.Mother.HLink); x != "" {
+			lw.pointer(1, "WIFE", x)
+		}
+	}
+	for _, ref := range f.ChildRefs {
+		if x := e.xref(ref.HLink); x != "" {
+			lw.pointer(1, "CHIL", x)
+		}
+	}
+	for _, ref := range f.EventRefs {
+		if ev := e.db.EventByHandle(ref.HLink); ev != nil {
+			e.event(1, ev, ref, familyEventTags)
+		}
+	}
+	for _, a := range f.Attributes {
+		_, exid := exidType(a.Type)
+		if a.Type == "Number of Children" || e.seven && (exid || a.Type == "NO") {
+			e.attribute(1, f, a, attributeTags)
+		} else {
+			e.warn(f, "attribute %s left out", a.Type)
+		}
+	}
+	for _, o := range f.LDSOrds {
+		e.ldsOrd(1, f, o)
+	}
+	e.notes(1, f.NoteRefs)
+	e.citations(1, f, f.CitationRefs)
+	e.objRefs(1, f, f.ObjRefs)
+	if len(f.TagRefs) > 0 {
+		e.warn(f, "tags left out")
+	}
+	e.change(f.Change)
+}
+
+func (e *exporter) source(s *xml.Source) {
+	lw := e.lw
+	lw.line(0, e.xref(s.Handle), "SOUR", "")
+	for _, part := range []struct {
+		tag   string
+		value *string
+	}{
+		{"AUTH", s.SAuthor}, {"TITL", s.STitle}, {"ABBR", s.SAbbrev}, {"PUBL", s.SPubInfo},
+		{"TEXT", s.SourceText},
+	} {
+		if str(part.value) != "" {
+			lw.text(1, "", part.tag, str(part.value))
+		}
+	}
+	for _, ref := range s.RepoRefs {
+		x := e.xref(ref.HLink)
+		if x == "" {
+			continue
+		}
+		lw.pointer(1, "REPO", x)
+		if ref.CallNo != "" || ref.Medium != "" {
+			lw.line(2, "", "CALN", ref.CallNo)
+			if e.seven {
+				e.enum(3, "MEDI", ref.Medium, sourceMedia)
+			} else if ref.Medium != "" {
+				lw.line(3, "", "MEDI", ref.Medium)
+			}
+		}
+	}
+	e.notes(1, s.NoteRefs)
+	e.objRefs(1, s, s.ObjRefs)
+	left := len(s.DataItems) > 0 || len(s.Attributes) > 0
+	for _, a := range s.SrcAttributes {
+		left = !e.exid(1, a.Type, a.Value) || left
+	}
+	if left {
+		e.warn(s, "attributes of source left out")
+	}
+	e.change(s.Change)
+}
+
+func (e *exporter) repository(r *xml.Repository) {
+	lw := e.lw
+	lw.line(0, e.xref(r.Handle), "REPO", "")
+	lw.line(1, "", "NAME", r.RName)
+	if len(r.Addresses) > 0 {
+		e.address(1, r.Addresses[0])
+		if len(r.Addresses) > 1 {
+			e.warn(r, "addresses after the first left out")
+		}
+	}
+	if r.URL != nil {
+		lw.line(1, "", "WWW", r.URL.HRef)
+	}
+	e.notes(1, r.NoteRefs)
+	e.change(r.Change)
+}
+
+// Get the GEDCOM multimedia format of a file: its extension, or the subtype
+// of its MIME type.
+func mediaFormat(f xml.File) string {
+	if ext := strings.TrimPrefix(path.Ext(f.Src), "."); ext != "" {
+		return strings.ToLower(ext)
+	}
+	if t, _, err := mime.ParseMediaType(f.Mime); err == nil {
+		if i := strings.Index(t, "/"); i >= 0 {
+			return t[i+1:]
+		}
+	}
+	return ""
+}
+
+// Get the MIME type of a file: its own, or else that of its extension.
+func mediaType(f xml.File) string {
+	if strings.Contains(f.Mime, "/") {
+		return f.Mime
+	}
+	if t := mime.TypeByExtension(path.Ext(f.Src)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Get whether s is a URL rather than a file path. Windows drive letters are
+// not schemes.
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && len(u.Scheme) > 1
+}
+
+// Get the URI of a file as GEDCOM 7.0 writes it: a relative path, a file URL
+// for an absolute path, or a URL as it is.
+func fileURI(src string) string {
+	if isURL(src) {
+		return src
+	}
+	p := strings.Replace(src, "\\", "/", -1)
+	if path.IsAbs(p) {
+		return (&url.URL{Scheme: "file", Path: p}).String()
+	}
+	if len(p) > 1 && p[1] == ':' {
+		return (&url.URL{Scheme: "file", Path: "/" + p}).String()
+	}
+	return (&url.URL{Path: p}).String()
+}
+
+func (e *exporter) object(o *xml.Object) {
+	lw := e.lw
+	lw.line(0, e.xref(o.Handle), "OBJE", "")
+	src, ok := e.files[o.Handle]
+	if !ok {
+		src = o.File.Src
+	}
+	switch {
+	case e.seven:
+		lw.line(1, "", "FILE", fileURI(src))
+		lw.line(2, "", "FORM", mediaType(o.File))
+	default:
+		lw.line(1, "", "FILE", src)
+		if format := mediaFormat(o.File); format != "" {
+			lw.line(2, "", "FORM", format)
+		}
+	}
+	if o.File.Description != "" {
+		lw.line(2, "", "TITL", o.File.Description)
+	}
+	e.notes(1, o.NoteRefs)
+	e.citations(1, o, o.CitationRefs)
+	left := o.GetDateString() != ""
+	for _, a := range o.Attributes {
+		left = !e.exid(1, a.Type, a.Value) || left
+	}
+	if left {
+		e.warn(o, "date and attributes of media object left out")
+	}
+	e.change(o.Change)
+}
+
+func (e *exporter) note(n *xml.Note) {
+	e.lw.text(0, e.xref(n.Handle), e.noteTag(), n.Text)
+	if len(n.Styles) > 0 {
+		e.warn(n, "formatting of note left out")
+	}
+	e.change(n.Change)
+}
@@ -0,0 +1,231 @@
+package gedcom
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+func add(t *testing.T, db *xml.Database, obj xml.DBObj) {
+	if err := db.Add(obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A small database with one of most of the things GEDCOM records.
+func exampleDatabase(t *testing.T) *xml.Database {
+	db := &xml.Database{}
+	db.Header.Researcher = &xml.Researcher{ResName: strp("Ann Researcher"), ResEMail: strp("ann@example.com")}
+
+	note := &xml.Note{Text: "A note\nwith two lines"}
+	add(t, db, note)
+	repo := &xml.Repository{RName: "Archive"}
+	add(t, db, repo)
+	src := &xml.Source{STitle: strp("Parish register"), SAuthor: strp("Parish")}
+	ref := &xml.RepoRef{CallNo: "PR 12", Medium: "Book"}
+	ref.HLink = repo.Handle
+	src.RepoRefs = append(src.RepoRefs, ref)
+	add(t, db, src)
+	cit := &xml.Citation{Page: strp("p. 3"), Confidence: strp("4")}
+	cit.SourceRef.HLink = src.Handle
+	add(t, db, cit)
+
+	place := &xml.PlaceObj{PNames: []*xml.PName{{Value: "Leeds"}}, Coord: &xml.Coord{Lat: "53.8", Long: "-1.55"}}
+	add(t, db, place)
+	england := &xml.PlaceObj{PNames: []*xml.PName{{Value: "England"}}}
+	add(t, db, england)
+	pref := &xml.PlaceRef{}
+	pref.HLink = england.Handle
+	place.PlaceRefs = append(place.PlaceRefs, pref)
+
+	birth := &xml.Event{Type: strp("Birth"), Place: link(place.Handle)}
+	birth.SetDateString("abt 1850-03-12")
+	birth.CitationRefs = append(birth.CitationRefs, link(cit.Handle))
+	birth.Attributes = append(birth.Attributes, &xml.Attribute{Type: "Age", Value: "0"},
+		&xml.Attribute{Type: "Weather", Value: "Rain"})
+	add(t, db, birth)
+	graduation := &xml.Event{Type: strp("Graduation")}
+	add(t, db, graduation)
+	custom := &xml.Event{Type: strp("Knighthood"), Description: strp("Order of the Bath")}
+	custom.SetDateString("1890")
+	add(t, db, custom)
+	marriage := &xml.Event{Type: strp("Marriage")}
+	marriage.SetDateString("bet 1875-06 and 1876")
+	add(t, db, marriage)
+
+	john := &xml.Person{Gender: "M", Names: []*xml.Name{{
+		Type: "Birth Name", First: strp("John"), Call: strp("Jack"), Title: strp("Sir"),
+		Surnames: []*xml.Surname{{Prefix: "van", Value: "Smith"}},
+	}}}
+	john.ID = "I@1"
+	for _, ev := range []*xml.Event{birth, graduation, custom} {
+		r := &xml.EventRef{Role: "Primary"}
+		r.HLink = ev.Handle
+		john.EventRefs = append(john.EventRefs, r)
+	}
+	john.Attributes = append(john.Attributes, &xml.Attribute{Type: "Nickname", Value: "Jack"})
+	john.NoteRefs = append(john.NoteRefs, link(note.Handle))
+	add(t, db, john)
+
+	mary := &xml.Person{Gender: "F", Names: []*xml.Name{{First: strp("Mary")}}}
+	r := &xml.EventRef{Role: "Witness"}
+	r.HLink = birth.Handle
+	mary.EventRefs = append(mary.EventRefs, r)
+	add(t, db, mary)
+
+	child := &xml.Person{Gender: "U"}
+	add(t, db, child)
+
+	f := &xml.Family{Father: link(john.Handle), Mother: link(mary.Handle), Rel: &xml.Rel{Type: "Civil Union"}}
+	cref := &xml.ChildRef{FRel: "Adopted", MRel: "Adopted"}
+	cref.HLink = child.Handle
+	f.ChildRefs = append(f.ChildRefs, cref)
+	add(t, db, f)
+	john.ParentIns = append(john.ParentIns, link(f.Handle))
+	mary.ParentIns = append(mary.ParentIns, link(f.Handle))
+	child.ChildOfs = append(child.ChildOfs, link(f.Handle))
+	r = &xml.EventRef{Role: "Family"}
+	r.HLink = marriage.Handle
+	f.EventRefs = append(f.EventRefs, r)
+
+	db.Reindex()
+	return db
+}
+
+func TestExport(t *testing.T) {
+	db := exampleDatabase(t)
+	var buf bytes.Buffer
+	date := time.Date(2013, 5, 4, 12, 30, 0, 0, time.UTC)
+	warnings, err := Export(&buf, db, ExportOptions{Date: date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"0 HEAD\n1 SOUR GOGRAMPS\n",
+		"1 DATE 4 MAY 2013\n2 TIME 12:30:00\n",
+		"1 CHAR UTF-8\n",
+		"0 @SUBM@ SUBM\n1 NAME Ann Researcher\n1 EMAIL ann@@example.com\n",
+		"0 @IX1@ INDI\n1 NAME John /van Smith/\n2 TYPE birth\n2 NPFX Sir\n2 GIVN John\n" +
+			"2 _RUFNAME Jack\n2 SPFX van\n2 SURN Smith\n1 SEX M\n",
+		"1 BIRT\n2 DATE ABT 12 MAR 1850\n2 PLAC Leeds, England\n3 MAP\n4 LATI N53.8\n4 LONG W1.55\n" +
+			"2 AGE 0\n2 SOUR @S0000@\n3 PAGE p. 3\n3 QUAY 3\n",
+		"1 GRAD Y\n",
+		"1 EVEN Order of the Bath\n2 TYPE Knighthood\n2 DATE 1890\n",
+		"1 FACT Jack\n2 TYPE Nickname\n",
+		"1 FAMS @F0000@\n1 NOTE @N0000@\n",
+		"1 ASSO @IX1@\n2 RELA Witness of Birth\n",
+		"1 FAMC @F0000@\n2 PEDI adopted\n",
+		"0 @F0000@ FAM\n1 HUSB @IX1@\n1 WIFE @I0000@\n1 CHIL @I0001@\n" +
+			"1 MARR\n2 DATE BET JUN 1875 AND 1876\n",
+		"0 @S0000@ SOUR\n1 AUTH Parish\n1 TITL Parish register\n1 REPO @R0000@\n2 CALN PR 12\n3 MEDI Book\n",
+		"0 @R0000@ REPO\n1 NAME Archive\n",
+		"0 @N0000@ NOTE A note\n1 CONT with two lines\n",
+		"0 TRLR\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Missing\n%s", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(out)
+	}
+
+	var messages []string
+	for _, w := range warnings {
+		messages = append(messages, w.String())
+	}
+	for _, want := range []string{
+		"I@1: ID cannot be an xref; written as IX1",
+		"E0000: attribute Weather of event left out",
+		"F0000: relationship type Civil Union left out",
+	} {
+		found := false
+		for _, m := range messages {
+			found = found || m == want
+		}
+		if !found {
+			t.Errorf("Missing warning %q in %q", want, messages)
+		}
+	}
+}
+
+func TestExportSeven(t *testing.T) {
+	db := exampleDatabase(t)
+	john, mary := db.People.Persons[0], db.People.Persons[1]
+	john.Attributes = append(john.Attributes, &xml.Attribute{Type: "EXID:https://www.familysearch.org/", Value: "ABCD-123"},
+		&xml.Attribute{Type: "NO", Value: "Baptism"})
+	db.EventByHandle(john.EventRefs[1].HLink).SetDate(xml.Date{Modifier: xml.ModTextOnly, Text: "in the spring"})
+	db.EventByHandle(john.EventRefs[2].HLink).SetDate(xml.Date{Calendar: xml.Julian, Start: ymd(1890, 0, 0)})
+	ref := &xml.PersonRef{Rel: "Godfather"}
+	ref.HLink = john.Handle
+	mary.PersonRefs = append(mary.PersonRefs, ref)
+	db.Notes[0].Text = "@home\nwith @ inside"
+
+	var buf bytes.Buffer
+	date := time.Date(2013, 5, 4, 12, 30, 0, 0, time.UTC)
+	if _, err := Export(&buf, db, ExportOptions{Date: date, Version: Version70}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"0 HEAD\n1 GEDC\n2 VERS 7.0\n1 SCHMA\n2 TAG _RUFNAME https://code.google.com/p/gogramps/gedcom/_RUFNAME\n" +
+			"1 SOUR GOGRAMPS\n",
+		"1 EMAIL ann@example.com\n",
+		"0 @IX1@ INDI\n1 NAME John /van Smith/\n2 TYPE BIRTH\n",
+		"2 AGE 0\n2 ASSO @I0000@\n3 ROLE WITN\n2 SOUR @S0000@\n",
+		"1 GRAD\n2 DATE\n3 PHRASE in the spring\n",
+		"1 EVEN Order of the Bath\n2 TYPE Knighthood\n2 DATE JULIAN 1890\n",
+		"1 EXID ABCD-123\n2 TYPE https://www.familysearch.org/\n1 NO BAPM\n",
+		"1 SNOTE @N0000@\n",
+		"1 ASSO @IX1@\n2 ROLE OTHER\n3 PHRASE Godfather\n",
+		"1 FAMC @F0000@\n2 PEDI ADOPTED\n",
+		"2 CALN PR 12\n3 MEDI BOOK\n",
+		"0 @N0000@ SNOTE @@home\n1 CONT with @ inside\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Missing\n%s", want)
+		}
+	}
+	for _, unwanted := range []string{"CHAR", "RELA", "CONC", "@@example"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Got %s", unwanted)
+		}
+	}
+	if t.Failed() {
+		t.Log(out)
+	}
+
+	if _, err := Export(&buf, db, ExportOptions{Version: "5.5"}); err == nil {
+		t.Error("Exported an unknown version")
+	}
+}
+
+func TestExportExample(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "xml", "testdata", "example-1.5.0.gramps"))
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer f.Close()
+	db, err := xml.Parse(f)
+	if err != nil {
+		t.Fatalf("Failed to parse example: %s", err)
+	}
+	var buf bytes.Buffer
+	if _, err := Export(&buf, db, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), " INDI\n"); n != len(db.People.Persons) {
+		t.Errorf("Got %d individuals, want %d", n, len(db.People.Persons))
+	}
+	for i, l := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if len(l) > 255 {
+			t.Errorf("Line %d is %d bytes long", i+1, len(l))
+		}
+	}
+}
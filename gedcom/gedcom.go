@@ -0,0 +1,131 @@
+// Package gedcom converts between Gramps databases and GEDCOM files, the
+// format most genealogy programs and services exchange data in.
+//
+// Export writes a Database as GEDCOM 5.5.1 or 7.0, and Import reads a GEDCOM
+// file of either version into a new Database. ExportGEDZIP and ImportGEDZIP
+// do the same with GEDZIP archives, which hold a GEDCOM 7.0 file and the
+// media files it refers to. The formats cannot represent everything the other
+// records, so all return Warnings for what was left out or converted
+// differently.
+package gedcom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Warning describes something that could not be represented the way it is
+// in the database it was converted from.
+type Warning struct {
+	// The Gramps ID of the object, or its handle if it has none.
+	ID      string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.ID == "" {
+		return w.Message
+	}
+	return w.ID + ": " + w.Message
+}
+
+// The longest line value written before it is continued with CONC. GEDCOM
+// 5.5.1 allows lines of 255 characters.
+const maxValue = 200
+
+// A lineWriter writes GEDCOM lines, remembering the first error.
+type lineWriter struct {
+	w   *bufio.Writer
+	err error
+	// Write GEDCOM 7.0, which only escapes a leading at sign and has no CONC.
+	seven bool
+}
+
+func newLineWriter(w io.Writer, seven bool) *lineWriter {
+	return &lineWriter{w: bufio.NewWriter(w), seven: seven}
+}
+
+// Write the line "level @xref@ tag value", leaving out an empty xref or
+// value. At signs in the value are doubled; only a leading one in GEDCOM 7.0.
+func (lw *lineWriter) line(level int, xref, tag, value string) {
+	if !lw.seven {
+		value = strings.Replace(value, "@", "@@", -1)
+	} else if strings.HasPrefix(value, "@") {
+		value = "@" + value
+	}
+	lw.raw(level, xref, tag, value)
+}
+
+// Write a line with a value that is not escaped, such as a date with a
+// calendar escape.
+func (lw *lineWriter) raw(level int, xref, tag, value string) {
+	if lw.err != nil {
+		return
+	}
+	s := fmt.Sprint(level)
+	if xref != "" {
+		s += " @" + xref + "@"
+	}
+	s += " " + tag
+	if value != "" {
+		s += " " + value
+	}
+	_, lw.err = lw.w.WriteString(s + "\n")
+}
+
+// Write a pointer line "level tag @xref@".
+func (lw *lineWriter) pointer(level int, tag, xref string) {
+	if lw.err != nil {
+		return
+	}
+	_, lw.err = fmt.Fprintf(lw.w, "%d %s @%s@\n", level, tag, xref)
+}
+
+// Write a line with text, which may have several lines and be long: each line
+// after the first is written with CONT, and long lines are split with CONC
+// before GEDCOM 7.0.
+func (lw *lineWriter) text(level int, xref, tag, text string) {
+	for i, l := range strings.Split(text, "\n") {
+		l = strings.TrimRight(l, "\r")
+		parts := []string{l}
+		if !lw.seven {
+			parts = splitLine(l)
+		}
+		if i == 0 {
+			lw.line(level, xref, tag, parts[0])
+		} else {
+			lw.line(level+1, "", "CONT", parts[0])
+		}
+		for _, part := range parts[1:] {
+			lw.line(level+1, "", "CONC", part)
+		}
+	}
+}
+
+// Split s into parts of at most maxValue bytes, not splitting a character and
+// not splitting next to a space, which some programs trim.
+func splitLine(s string) []string {
+	var parts []string
+	for len(s) > maxValue {
+		i := maxValue
+		for i > maxValue/2 && (!utf8.RuneStart(s[i]) || s[i] == ' ' || s[i-1] == ' ') {
+			i--
+		}
+		for !utf8.RuneStart(s[i]) {
+			i--
+		}
+		parts = append(parts, s[:i])
+		s = s[i:]
+	}
+	return append(parts, s)
+}
+
+func (lw *lineWriter) flush() error {
+	if lw.err != nil {
+		return lw.err
+	}
+	return lw.w.Flush()
+}
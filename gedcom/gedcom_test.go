@@ -0,0 +1,42 @@
+package gedcom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitLine(t *testing.T) {
+	s := strings.Repeat("ab ", 100) + strings.Repeat("ä", 200)
+	parts := splitLine(s)
+	if strings.Join(parts, "") != s {
+		t.Fatalf("Parts do not add up: %q", parts)
+	}
+	for i, p := range parts {
+		if len(p) > maxValue {
+			t.Errorf("Part %d is %d bytes long", i, len(p))
+		}
+		if strings.HasPrefix(p, " ") || strings.HasSuffix(p, " ") {
+			t.Errorf("Part %d is split at a space: %q", i, p)
+		}
+		if !strings.HasPrefix(p, "ab") && !strings.HasPrefix(p, "b") && !strings.HasPrefix(p, "ä") {
+			t.Errorf("Part %d splits a character", i)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+	lw := newLineWriter(&buf, false)
+	lw.text(0, "N1", "NOTE", "first @line\r\nsecond line\n"+strings.Repeat("x", maxValue+1))
+	if err := lw.flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "0 @N1@ NOTE first @@line\n" +
+		"1 CONT second line\n" +
+		"1 CONT " + strings.Repeat("x", maxValue) + "\n" +
+		"1 CONC x\n"
+	if buf.String() != want {
+		t.Errorf("Got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
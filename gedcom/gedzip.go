@@ -0,0 +1,177 @@
+package gedcom
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+// The name of the GEDCOM file in a GEDZIP archive.
+const gedzipFile = "gedcom.ged"
+
+// Get the path in a GEDZIP archive of a media file with path src: src itself
+// if it is relative and inside the media directory, or else its name in the
+// media directory of the archive. Paths in used are not given again.
+func archivePath(src string, used map[string]bool) string {
+	name := path.Clean(strings.Replace(src, "\\", "/", -1))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, ":") {
+		name = "media/" + path.Base(name)
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; used[name]; i++ {
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+	used[name] = true
+	return name
+}
+
+// Export db to w as a GEDZIP archive: GEDCOM 7.0 in gedcom.ged, with the
+// files of media objects. Relative paths of files are taken from
+// opts.MediaPath. Files that cannot be found are left out, and their objects
+// keep their paths. Returns what could not be represented in GEDCOM.
+func ExportGEDZIP(w io.Writer, db *xml.Database, opts ExportOptions) ([]Warning, error) {
+	opts.Version = Version70
+	dir := opts.MediaPath
+	if dir == "" {
+		dir = str(db.Header.MediaPath)
+	}
+
+	var warnings []Warning
+	// The paths in the archive by object handle and by file.
+	names := map[string]string{}
+	files := map[string]string{}
+	used := map[string]bool{gedzipFile: true}
+	for _, o := range db.Objects {
+		src := o.File.Src
+		if src == "" || isURL(src) {
+			continue
+		}
+		file := filepath.FromSlash(src)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			warnings = append(warnings, Warning{ID: idOf(o), Message: fmt.Sprintf("media file %s not found; left out", src)})
+			continue
+		}
+		if _, ok := files[file]; !ok {
+			files[file] = archivePath(src, used)
+		}
+		names[o.Handle] = files[file]
+	}
+
+	z := zip.NewWriter(w)
+	ged, err := z.Create(gedzipFile)
+	if err != nil {
+		return warnings, err
+	}
+	ws, err := export(ged, db, opts, names)
+	warnings = append(warnings, ws...)
+	if err != nil {
+		return warnings, err
+	}
+	written := map[string]bool{}
+	for _, o := range db.Objects {
+		name, ok := names[o.Handle]
+		if !ok || written[name] {
+			continue
+		}
+		written[name] = true
+		file := filepath.FromSlash(o.File.Src)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if err := copyToZip(z, name, file); err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, z.Close()
+}
+
+// Write file to z as name.
+func copyToZip(z *zip.Writer, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Import a GEDZIP archive of size bytes as a new Database. If mediaDir is not
+// "", the media files of the archive are extracted to it, and it is the
+// media path of the database. Returns what could not be imported.
+func ImportGEDZIP(r io.ReaderAt, size int64, mediaDir string) (*xml.Database, []Warning, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ged *zip.File
+	for _, f := range z.File {
+		if f.Name == gedzipFile {
+			ged = f
+		}
+	}
+	if ged == nil {
+		return nil, nil, fmt.Errorf("Not a GEDZIP file: no %s", gedzipFile)
+	}
+	rc, err := ged.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	db, warnings, err := Import(rc)
+	rc.Close()
+	if err != nil || mediaDir == "" {
+		return db, warnings, err
+	}
+
+	for _, f := range z.File {
+		if f == ged || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		name := path.Clean(f.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			warnings = append(warnings, Warning{Message: fmt.Sprintf("file %s outside the archive left out", f.Name)})
+			continue
+		}
+		if err := extract(f, filepath.Join(mediaDir, filepath.FromSlash(name))); err != nil {
+			return db, warnings, err
+		}
+	}
+	db.Header.MediaPath = strp(mediaDir)
+	return db, warnings, nil
+}
+
+// Write the file f of an archive to file.
+func extract(f *zip.File, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	w, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,83 @@
+package gedcom
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+func TestGEDZIP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gedzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	media := filepath.Join(dir, "media")
+	other := filepath.Join(dir, "other")
+	for _, d := range []string{filepath.Join(media, "scans"), other} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(media, "scans", "page 1.jpg"): "page",
+		filepath.Join(other, "photo.png"):           "photo",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := &xml.Database{}
+	for _, src := range []string{"scans/page 1.jpg", filepath.ToSlash(filepath.Join(other, "photo.png")), "gone.png"} {
+		add(t, db, &xml.Object{File: xml.File{Src: src}})
+	}
+	var buf bytes.Buffer
+	warnings, err := ExportGEDZIP(&buf, db, ExportOptions{MediaPath: media})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].String() != "O0002: media file gone.png not found; left out" {
+		t.Errorf("Got warnings %v", warnings)
+	}
+
+	out := filepath.Join(dir, "out")
+	imported, warnings, err := ImportGEDZIP(bytes.NewReader(buf.Bytes()), int64(buf.Len()), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("Got warnings %v", warnings)
+	}
+	if str(imported.Header.MediaPath) != out {
+		t.Errorf("Got media path %v", imported.Header.MediaPath)
+	}
+	for i, want := range []struct {
+		src, content string
+	}{
+		{"scans/page 1.jpg", "page"},
+		{"media/photo.png", "photo"},
+		{"gone.png", ""},
+	} {
+		o := imported.Objects[i]
+		if o.File.Src != want.src {
+			t.Errorf("Got file %s, want %s", o.File.Src, want.src)
+		}
+		if want.content == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(o.File.Src)))
+		if err != nil || string(b) != want.content {
+			t.Errorf("%s: got %q, %v", o.File.Src, b, err)
+		}
+	}
+
+	if _, _, err := ImportGEDZIP(bytes.NewReader(nil), 0, ""); err == nil {
+		t.Error("Imported an empty file")
+	}
+}
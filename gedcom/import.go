@@ -0,0 +1,1430 @@
+package gedcom
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+type importer struct {
+	db       *xml.Database
+	warnings []Warning
+	// The objects of records, and the tags of the records by xref.
+	records map[*node]xml.DBObj
+	objects map[string]xml.DBObj
+	tags    map[string]string
+	// Places by their names and those of the places enclosing them.
+	places    map[string]*xml.PlaceObj
+	placeForm []string
+	// The PEDI of the FAMC lines of individuals, by person and family handle.
+	pedigrees map[[2]string]string
+	// The ID of the record being read, and the lines of it not understood.
+	id      string
+	skipped []string
+	// Whether the file is GEDCOM 7.0.
+	seven bool
+}
+
+// Import a GEDCOM 5.5.1 file in ANSEL, UTF-8, UTF-16 or Windows-1252, or a
+// GEDCOM 7.0 file, as a new Database, in which handles and the IDs of objects
+// other than records are generated and the xrefs of records are their IDs.
+// Lines that are not understood are kept in notes of the records they are
+// in, or as attributes if they are custom tags with values. Returns what
+// could not be imported.
+func Import(r io.Reader) (*xml.Database, []Warning, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	text, warning := decode(b)
+	records, warnings := parseLines(text)
+	if len(records) == 0 || records[0].Tag != "HEAD" {
+		return nil, nil, fmt.Errorf("Not a GEDCOM file: no header")
+	}
+	if warning != "" {
+		warnings = append([]Warning{{Message: warning}}, warnings...)
+	}
+
+	e := &importer{
+		db:        &xml.Database{},
+		warnings:  warnings,
+		records:   map[*node]xml.DBObj{},
+		objects:   map[string]xml.DBObj{},
+		tags:      map[string]string{},
+		places:    map[string]*xml.PlaceObj{},
+		pedigrees: map[[2]string]string{},
+		seven:     strings.HasPrefix(version(records[0]), "7."),
+	}
+	if e.seven {
+		fromSeven(records)
+	}
+	e.db.Header.Created.Date = time.Now().Format("2006-01-02")
+	e.createRecords(records)
+	e.header(records)
+	for _, n := range records[1:] {
+		e.record(n)
+	}
+	e.link()
+	e.finish()
+	e.db.Reindex()
+	return e.db, e.warnings, nil
+}
+
+// Get the GEDCOM version of a file from its header.
+func version(head *node) string {
+	if c := head.child("GEDC"); c != nil {
+		return strings.TrimSpace(c.childText("VERS"))
+	}
+	return ""
+}
+
+// The tags of GEDCOM 5.5.1 of the extension tags of GEDCOM 7.0 files, by the
+// URIs in the SCHMA of their headers.
+var schemaTags = map[string]string{
+	schemaURI + "_RUFNAME": "_RUFNAME",
+}
+
+// Rewrite the lines of a GEDCOM 7.0 file where they differ from those of
+// 5.5.1 with the same meaning: shared notes are notes, only leading at signs
+// are doubled, and extension tags declared with a known URI are renamed.
+func fromSeven(records []*node) {
+	aliases := map[string]string{}
+	if schma := records[0].child("SCHMA"); schma != nil {
+		for _, c := range schma.Children {
+			f := strings.Fields(c.Value)
+			if c.Tag != "TAG" || len(f) != 2 {
+				continue
+			}
+			if tag, ok := schemaTags[f[1]]; ok {
+				aliases[strings.ToUpper(f[0])] = tag
+			}
+		}
+	}
+	var rewrite func(n *node)
+	rewrite = func(n *node) {
+		if n.Tag == "SNOTE" {
+			n.Tag = "NOTE"
+		} else if tag, ok := aliases[n.Tag]; ok {
+			n.Tag = tag
+		}
+		if n.pointer() == "" {
+			v := n.Value
+			if strings.HasPrefix(v, "@@") {
+				v = v[1:]
+			}
+			n.Value = strings.Replace(v, "@", "@@", -1)
+		}
+		for _, c := range n.Children {
+			rewrite(c)
+		}
+	}
+	for _, n := range records {
+		rewrite(n)
+	}
+}
+
+func (e *importer) warn(format string, args ...interface{}) {
+	e.warnings = append(e.warnings, Warning{ID: e.id, Message: fmt.Sprintf(format, args...)})
+}
+
+// Add obj to the database, which gives it a handle and an ID. This cannot
+// fail: objects are new and the IDs of records are unique.
+func (e *importer) add(obj xml.DBObj) {
+	if err := e.db.Add(obj); err != nil {
+		panic(err)
+	}
+}
+
+func link(h string) *xml.GenericLink {
+	return &xml.GenericLink{HLink: h}
+}
+
+func strp(s string) *string {
+	return &s
+}
+
+// Get a new string, or nil if s is empty.
+func optional(s string) *string {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Create the objects of the records with xrefs, with their xrefs as their IDs,
+// so that pointers to them can be followed before they are read.
+func (e *importer) createRecords(records []*node) {
+	for _, n := range records {
+		var obj xml.DBObj
+		switch n.Tag {
+		case "INDI":
+			p := &xml.Person{}
+			p.ID = n.Xref
+			obj = p
+		case "FAM":
+			f := &xml.Family{}
+			f.ID = n.Xref
+			obj = f
+		case "SOUR":
+			s := &xml.Source{}
+			s.ID = n.Xref
+			obj = s
+		case "REPO":
+			r := &xml.Repository{}
+			r.ID = n.Xref
+			obj = r
+		case "OBJE":
+			o := &xml.Object{}
+			o.ID = n.Xref
+			obj = o
+		case "NOTE":
+			nt := &xml.Note{}
+			nt.ID = n.Xref
+			obj = nt
+		default:
+			continue
+		}
+		if n.Xref == "" {
+			e.warn("Line %d: %s record without an xref imported with a new ID", n.Line, n.Tag)
+		} else if _, ok := e.objects[n.Xref]; ok {
+			e.warn("Line %d: xref @%s@ used again; imported with a new ID", n.Line, n.Xref)
+			obj = reID(obj)
+		} else {
+			e.objects[n.Xref] = obj
+			e.tags[n.Xref] = n.Tag
+		}
+		e.records[n] = obj
+	}
+	// Records with IDs first, so that new IDs do not take theirs.
+	for _, n := range records {
+		if obj := e.records[n]; obj != nil && e.objects[n.Xref] == obj {
+			e.add(obj)
+		}
+	}
+	for _, n := range records {
+		if obj := e.records[n]; obj != nil && e.objects[n.Xref] != obj {
+			e.add(obj)
+		}
+	}
+}
+
+// Get a new object of the same type as obj, without an ID.
+func reID(obj xml.DBObj) xml.DBObj {
+	switch obj.(type) {
+	case *xml.Person:
+		return &xml.Person{}
+	case *xml.Family:
+		return &xml.Family{}
+	case *xml.Source:
+		return &xml.Source{}
+	case *xml.Repository:
+		return &xml.Repository{}
+	case *xml.Object:
+		return &xml.Object{}
+	}
+	return &xml.Note{}
+}
+
+// Get the handle of the record n points to, which should have tag.
+func (e *importer) handle(n *node, tag string) string {
+	x := n.pointer()
+	if x == "VOID" && e.seven {
+		return ""
+	}
+	obj, ok := e.objects[x]
+	if !ok || e.tags[x] != tag {
+		e.warn("Line %d: no %s record @%s@", n.Line, tag, x)
+		return ""
+	}
+	return obj.GetHandle()
+}
+
+// Read the header and the submitter it points to, who is the researcher.
+func (e *importer) header(records []*node) {
+	head := records[0]
+	var subm *node
+	for _, c := range head.Children {
+		switch c.Tag {
+		case "GEDC":
+			if v := c.childText("VERS"); v != "" && !strings.HasPrefix(v, "5.") && !e.seven {
+				e.warn("GEDCOM %s read as 5.5.1", v)
+			}
+		case "PLAC":
+			e.placeForm = jurisdictions(c.childText("FORM"))
+		case "SUBM":
+			for _, n := range records {
+				if n.Tag == "SUBM" && n.Xref == c.pointer() {
+					subm = n
+				}
+			}
+		}
+	}
+	for _, n := range records {
+		if subm == nil && n.Tag == "SUBM" {
+			subm = n
+		}
+	}
+	if subm == nil {
+		return
+	}
+
+	r := &xml.Researcher{ResName: optional(subm.childText("NAME"))}
+	if c := subm.child("ADDR"); c != nil {
+		a := address(c)
+		r.ResAddr, r.ResLocality, r.ResCity = a.Street, a.Locality, a.City
+		r.ResState, r.ResCountry, r.ResPostal = a.State, a.Country, a.Postal
+	}
+	r.ResPhone = optional(subm.childText("PHON"))
+	r.ResEMail = optional(subm.childText("EMAIL"))
+	e.db.Header.Researcher = r
+}
+
+// Get the jurisdictions of a place hierarchy, e.g. of a PLAC FORM.
+func jurisdictions(s string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return parts
+}
+
+// Read a record into its object.
+func (e *importer) record(n *node) {
+	obj := e.records[n]
+	if obj == nil {
+		switch n.Tag {
+		case "SUBM", "SUBN", "TRLR":
+		default:
+			e.warn("Line %d: %s record not imported", n.Line, n.Tag)
+		}
+		return
+	}
+	e.id = idOf(obj)
+	e.skipped = nil
+	var notes *[]*xml.GenericLink
+	switch obj := obj.(type) {
+	case *xml.Person:
+		e.person(obj, n)
+		notes = &obj.NoteRefs
+	case *xml.Family:
+		e.family(obj, n)
+		notes = &obj.NoteRefs
+	case *xml.Source:
+		e.source(obj, n)
+		notes = &obj.NoteRefs
+	case *xml.Repository:
+		e.repository(obj, n)
+		notes = &obj.NoteRefs
+	case *xml.Object:
+		e.object(obj, n)
+		notes = &obj.NoteRefs
+	case *xml.Note:
+		e.note(obj, n)
+	}
+	if len(e.skipped) == 0 {
+		return
+	}
+	if notes == nil {
+		e.warn("%d lines not understood and left out", len(e.skipped))
+	} else {
+		note := &xml.Note{Type: "General",
+			Text: fmt.Sprintf("Records not imported into %s %s:\n\n%s", n.Tag, e.id, strings.Join(e.skipped, "\n"))}
+		e.add(note)
+		*notes = append(*notes, link(note.Handle))
+		e.warn("%d lines not understood; kept in note %s", len(e.skipped), note.ID)
+	}
+}
+
+// Keep the lines of n, which is not understood, for the note of its record.
+func (e *importer) unknown(n *node) {
+	e.skipped = n.lines(e.skipped)
+}
+
+// Get whether n is a custom tag with a value and nothing under it, which is
+// imported as an attribute, as Gramps does.
+func isCustom(n *node) bool {
+	return strings.HasPrefix(n.Tag, "_") && n.Value != "" && !n.hasChildren()
+}
+
+// Add n to attrs if it is a custom tag with a value. Returns whether it was
+// added.
+func custom(attrs *[]*xml.Attribute, n *node) bool {
+	if !isCustom(n) {
+		return false
+	}
+	*attrs = append(*attrs, &xml.Attribute{Type: n.Tag, Value: n.text()})
+	return true
+}
+
+// Add n to attrs if it is a custom tag with a value, for sources and
+// citations. Returns whether it was added.
+func customSrc(attrs *[]*xml.SrcAttribute, n *node) bool {
+	if !isCustom(n) {
+		return false
+	}
+	*attrs = append(*attrs, &xml.SrcAttribute{Type: n.Tag, Value: n.text()})
+	return true
+}
+
+// Get the change time of a CHAN structure as Gramps records it.
+func (e *importer) change(n *node) string {
+	date := n.child("DATE")
+	if date == nil {
+		return ""
+	}
+	s := strings.TrimSpace(date.value())
+	layout := "2 Jan 2006"
+	if t := strings.TrimSpace(date.childText("TIME")); t != "" {
+		if i := strings.Index(t, "."); i >= 0 {
+			t = t[:i]
+		}
+		t = strings.TrimSuffix(t, "Z")
+		s += " " + t
+		layout += " 15:04:05"
+		if strings.Count(t, ":") == 1 {
+			layout = "2 Jan 2006 15:04"
+		}
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		e.warn("Line %d: change date %q not understood", date.Line, s)
+		return ""
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// Set the date of v to the date of n. A date of GEDCOM 7.0 that is only a
+// phrase is read as text.
+func (e *importer) date(n *node, v interface {
+	SetDate(xml.Date)
+}) {
+	if p := n.childText("PHRASE"); strings.TrimSpace(n.Value) == "" && p != "" {
+		v.SetDate(xml.Date{Modifier: xml.ModTextOnly, Text: p})
+		return
+	}
+	d, ok := parseDate(n.Value)
+	if !ok {
+		e.warn("Line %d: date %q not understood; kept as text", n.Line, n.Value)
+	}
+	v.SetDate(d)
+}
+
+// Add the note n points to, or the note n is, to refs.
+func (e *importer) notes(refs []*xml.GenericLink, n *node, typ string) []*xml.GenericLink {
+	if n.pointer() != "" {
+		if h := e.handle(n, "NOTE"); h != "" {
+			refs = append(refs, link(h))
+		}
+		return refs
+	}
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "CONC", "CONT", "MIME", "LANG":
+		default:
+			e.unknown(c)
+		}
+	}
+	if strings.TrimSpace(n.text()) == "" {
+		return refs
+	}
+	note := &xml.Note{Type: typ, Text: n.text()}
+	e.add(note)
+	return append(refs, link(note.Handle))
+}
+
+// The confidence levels of Gramps by the GEDCOM certainty assessments.
+var confidences = map[string]string{"0": "0", "1": "1", "2": "3", "3": "4"}
+
+// Add a citation of the source n points to, or of the source n is, to refs.
+func (e *importer) citations(refs []*xml.GenericLink, n *node) []*xml.GenericLink {
+	c := &xml.Citation{Confidence: strp("2")}
+	var src *xml.Source
+	if n.pointer() != "" {
+		h := e.handle(n, "SOUR")
+		if h == "" {
+			return refs
+		}
+		c.SourceRef.HLink = h
+	} else {
+		src = &xml.Source{STitle: optional(n.text())}
+		e.add(src)
+		c.SourceRef.HLink = src.Handle
+	}
+	for _, s := range n.Children {
+		switch s.Tag {
+		case "CONC", "CONT":
+		case "PAGE":
+			c.Page = optional(s.text())
+		case "DATA":
+			for _, d := range s.Children {
+				switch d.Tag {
+				case "DATE":
+					e.date(d, c)
+				case "TEXT":
+					c.NoteRefs = e.notes(c.NoteRefs, d, "Source text")
+				default:
+					e.unknown(d)
+				}
+			}
+		case "QUAY":
+			if conf, ok := confidences[strings.TrimSpace(s.Value)]; ok {
+				c.Confidence = strp(conf)
+			} else {
+				e.unknown(s)
+			}
+		case "NOTE":
+			c.NoteRefs = e.notes(c.NoteRefs, s, "Citation")
+		case "OBJE":
+			c.ObjRefs = e.objRefs(c.ObjRefs, s)
+		case "TEXT":
+			if src != nil {
+				src.SourceText = optional(s.text())
+			} else {
+				c.NoteRefs = e.notes(c.NoteRefs, s, "Source text")
+			}
+		default:
+			if !customSrc(&c.SrcAttributes, s) {
+				e.unknown(s)
+			}
+		}
+	}
+	e.add(c)
+	return append(refs, link(c.Handle))
+}
+
+// Add a link to the media object n points to, or to the media object n is,
+// to refs.
+func (e *importer) objRefs(refs []*xml.ObjRef, n *node) []*xml.ObjRef {
+	ref := &xml.ObjRef{}
+	if n.pointer() != "" {
+		if ref.HLink = e.handle(n, "OBJE"); ref.HLink == "" {
+			return refs
+		}
+		for _, c := range n.Children {
+			e.unknown(c)
+		}
+	} else {
+		o := &xml.Object{}
+		e.object(o, n)
+		e.add(o)
+		ref.HLink = o.Handle
+	}
+	return append(refs, ref)
+}
+
+// The MIME types of multimedia formats that are not always known to package
+// mime.
+var mimeTypes = map[string]string{
+	"bmp":  "image/bmp",
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"pdf":  "application/pdf",
+	"png":  "image/png",
+	"tif":  "image/tiff",
+	"tiff": "image/tiff",
+	"wav":  "audio/x-wav",
+	"mp3":  "audio/mpeg",
+}
+
+// Get the MIME type of a multimedia format, which may be one, or of the
+// extension of file.
+func mimeType(format, file string) string {
+	if strings.Contains(format, "/") {
+		return strings.ToLower(strings.TrimSpace(format))
+	}
+	for _, ext := range []string{format, strings.TrimPrefix(path.Ext(file), ".")} {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if t, ok := mimeTypes[ext]; ok {
+			return t
+		}
+		if t := mime.TypeByExtension("." + ext); t != "" {
+			return t
+		}
+	}
+	return "unknown"
+}
+
+// Read a multimedia record or link into o. The FORM and TITL of GEDCOM 5.5
+// are read as well as those under FILE.
+func (e *importer) object(o *xml.Object, n *node) {
+	format := ""
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "FILE":
+			o.File.Src = strings.Replace(c.text(), "\\", "/", -1)
+			if e.seven {
+				o.File.Src = filePath(c.text())
+			}
+			for _, s := range c.Children {
+				switch s.Tag {
+				case "FORM":
+					format = s.value()
+				case "TITL":
+					o.File.Description = s.text()
+				case "CONC", "CONT":
+				default:
+					e.unknown(s)
+				}
+			}
+		case "FORM":
+			format = c.value()
+		case "TITL":
+			o.File.Description = c.text()
+		case "NOTE":
+			o.NoteRefs = e.notes(o.NoteRefs, c, "Media Note")
+		case "SOUR":
+			o.CitationRefs = e.citations(o.CitationRefs, c)
+		case "CHAN":
+			o.Change = e.change(c)
+		case "EXID":
+			o.Attributes = append(o.Attributes, exid(c))
+		case "RIN", "REFN":
+		default:
+			if !custom(&o.Attributes, c) {
+				e.unknown(c)
+			}
+		}
+	}
+	o.File.Mime = mimeType(format, o.File.Src)
+}
+
+// Get the path of a file from its URI in GEDCOM 7.0: the path of a file URL
+// or of a relative one. Other URLs are kept.
+func filePath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "" && u.Scheme != "file" {
+		return uri
+	}
+	p := u.Path
+	if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		// A Windows drive letter.
+		p = p[1:]
+	}
+	return p
+}
+
+// Get an external identifier of GEDCOM 7.0 as an attribute, of type EXID or
+// of EXID: and the URI of its type.
+func exid(n *node) *xml.Attribute {
+	a := &xml.Attribute{Type: "EXID", Value: n.text()}
+	if t := strings.TrimSpace(n.childText("TYPE")); t != "" {
+		a.Type += ":" + t
+	}
+	return a
+}
+
+// Get the value of an enumeration of GEDCOM 7.0: its phrase if it is OTHER,
+// or else the value itself.
+func enumValue(n *node) string {
+	v := strings.TrimSpace(n.text())
+	if p := strings.TrimSpace(n.childText("PHRASE")); strings.EqualFold(v, "OTHER") && p != "" {
+		return p
+	}
+	return v
+}
+
+// Get the Gramps role or relationship of the ROLE of a GEDCOM 7.0
+// association, or the RELA of a 5.5.1 one.
+func role(n *node) string {
+	if r := n.child("ROLE"); r != nil {
+		v := enumValue(r)
+		if name, ok := roleNames[strings.ToUpper(v)]; ok {
+			return name
+		}
+		return v
+	}
+	return strings.TrimSpace(n.childText("RELA"))
+}
+
+// Get the address of an ADDR structure. The parts of the address are taken
+// from ADR1, CITY, etc., and the street from its first line if there is no
+// ADR1.
+func address(n *node) *xml.Address {
+	a := &xml.Address{
+		Street:   optional(n.childText("ADR1")),
+		Locality: optional(n.childText("ADR2")),
+		City:     optional(n.childText("CITY")),
+		State:    optional(n.childText("STAE")),
+		Postal:   optional(n.childText("POST")),
+		Country:  optional(n.childText("CTRY")),
+	}
+	if a.Street != nil {
+		return a
+	}
+	lines := strings.Split(n.text(), "\n")
+	a.Street = optional(lines[0])
+	if a.Locality == nil && a.City == nil && a.State == nil && a.Postal == nil && a.Country == nil &&
+		len(lines) > 1 {
+		a.Locality = optional(strings.Join(lines[1:], ", "))
+	}
+	return a
+}
+
+// Get the place of a PLAC structure, creating the places of it and of the
+// jurisdictions enclosing it that were not created before.
+func (e *importer) place(n *node) *xml.GenericLink {
+	form := e.placeForm
+	if f := n.childText("FORM"); f != "" {
+		form = jurisdictions(f)
+	}
+	parts := jurisdictions(n.text())
+	var place *xml.PlaceObj
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] == "" {
+			continue
+		}
+		key := strings.ToLower(strings.Join(parts[i:], ","))
+		p := e.places[key]
+		if p == nil {
+			p = &xml.PlaceObj{Type: "Unknown", PNames: []*xml.PName{{Value: parts[i]}}}
+			if j := len(form) - len(parts) + i; j >= 0 {
+				for _, t := range placeTypes {
+					if strings.EqualFold(t, form[j]) {
+						p.Type = t
+					}
+				}
+			}
+			if place != nil {
+				ref := &xml.PlaceRef{}
+				ref.HLink = place.Handle
+				p.PlaceRefs = append(p.PlaceRefs, ref)
+			}
+			e.add(p)
+			e.places[key] = p
+		}
+		place = p
+	}
+	if place == nil {
+		return nil
+	}
+
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "FORM", "CONC", "CONT":
+		case "MAP":
+			lat, long := coord(c.childText("LATI")), coord(c.childText("LONG"))
+			if place.Coord == nil && lat != "" && long != "" {
+				place.Coord = &xml.Coord{Lat: lat, Long: long}
+			}
+		case "NOTE":
+			place.NoteRefs = e.notes(place.NoteRefs, c, "Place Note")
+		case "SOUR":
+			place.CitationRefs = e.citations(place.CitationRefs, c)
+		default:
+			e.unknown(c)
+		}
+	}
+	return link(place.Handle)
+}
+
+// Get a coordinate of GEDCOM, e.g. N50.5 or W3.2, in decimal degrees.
+func coord(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	switch s[0] {
+	case 'N', 'n', 'E', 'e':
+		return s[1:]
+	case 'S', 's', 'W', 'w':
+		return "-" + s[1:]
+	}
+	return s
+}
+
+// Read an event structure: one with tag of type typ, or EVEN or FACT with a
+// TYPE if typ is "". role is the role of the person or family in it.
+func (e *importer) event(n *node, typ, role string) *xml.EventRef {
+	ev := &xml.Event{}
+	ref := &xml.EventRef{Role: role}
+	var assoc []*node
+	value := strings.TrimSpace(n.text())
+	switch {
+	case typ == "":
+		typ = n.childText("TYPE")
+		if typ == "" {
+			typ = "Unknown"
+		}
+		ev.Description = optional(value)
+	case factTags[n.Tag]:
+		ev.Description = optional(value)
+	case value != "Y":
+		ev.Description = optional(value)
+	}
+	ev.Type = strp(typ)
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "CONC", "CONT":
+		case "TYPE":
+			if n.Tag != "EVEN" && n.Tag != "FACT" {
+				if ev.Description == nil {
+					ev.Description = optional(c.text())
+				} else {
+					e.unknown(c)
+				}
+			}
+		case "DATE":
+			e.date(c, ev)
+		case "PLAC":
+			ev.Place = e.place(c)
+		case "AGE":
+			ref.Attributes = append(ref.Attributes, &xml.Attribute{Type: "Age", Value: c.text()})
+		case "CAUS":
+			ev.Attributes = append(ev.Attributes, &xml.Attribute{Type: "Cause", Value: c.text()})
+		case "AGNC":
+			ev.Attributes = append(ev.Attributes, &xml.Attribute{Type: "Agency", Value: c.text()})
+		case "HUSB", "WIFE":
+			age := c.child("AGE")
+			if age == nil {
+				e.unknown(c)
+				continue
+			}
+			t := "Father Age"
+			if c.Tag == "WIFE" {
+				t = "Mother Age"
+			}
+			ref.Attributes = append(ref.Attributes, &xml.Attribute{Type: t, Value: age.text()})
+		case "NOTE":
+			ev.NoteRefs = e.notes(ev.NoteRefs, c, "Event Note")
+		case "SOUR":
+			ev.CitationRefs = e.citations(ev.CitationRefs, c)
+		case "OBJE":
+			ev.ObjRefs = e.objRefs(ev.ObjRefs, c)
+		case "RESN":
+			ev.Priv = 1
+		case "ASSO":
+			if !e.seven {
+				e.unknown(c)
+			} else if h := e.handle(c, "INDI"); h != "" {
+				assoc = append(assoc, c)
+			}
+		default:
+			if !custom(&ev.Attributes, c) {
+				e.unknown(c)
+			}
+		}
+	}
+	e.add(ev)
+	ref.HLink = ev.Handle
+	for _, c := range assoc {
+		e.association(ev, c)
+	}
+	return ref
+}
+
+// Link the person of an association of a GEDCOM 7.0 event to the event, with
+// the role of the association.
+func (e *importer) association(ev *xml.Event, n *node) {
+	p := e.objects[n.pointer()].(*xml.Person)
+	ref := &xml.EventRef{Role: role(n)}
+	ref.HLink = ev.Handle
+	if ref.Role == "" {
+		ref.Role = "Unknown"
+	}
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "ROLE":
+		case "NOTE":
+			ref.NoteRefs = e.notes(ref.NoteRefs, c, "Event Reference Note")
+		default:
+			e.unknown(c)
+		}
+	}
+	p.EventRefs = append(p.EventRefs, ref)
+}
+
+// Read an attribute structure of type typ, or a FACT with a TYPE if typ is "".
+func (e *importer) attribute(n *node, typ string) *xml.Attribute {
+	a := &xml.Attribute{Type: typ, Value: n.text()}
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "CONC", "CONT":
+		case "TYPE":
+			if typ == "" {
+				a.Type = c.text()
+			} else {
+				e.unknown(c)
+			}
+		case "NOTE":
+			a.NoteRefs = e.notes(a.NoteRefs, c, "Attribute Note")
+		case "SOUR":
+			a.CitationRefs = e.citations(a.CitationRefs, c)
+		default:
+			e.unknown(c)
+		}
+	}
+	if a.Type == "" {
+		a.Type = "Unknown"
+	}
+	return a
+}
+
+// Read a personal name structure. Returns the name, and the married names of
+// _MARNM lines under it.
+func (e *importer) name(n *node) []*xml.Name {
+	name := &xml.Name{Type: "Birth Name"}
+	v := n.text()
+	given, surname, suffix := v, "", ""
+	if i := strings.Index(v, "/"); i >= 0 {
+		given, surname = v[:i], v[i+1:]
+		if j := strings.Index(surname, "/"); j >= 0 {
+			surname, suffix = surname[:j], surname[j+1:]
+		}
+	}
+	name.First = optional(given)
+	name.Suffix = optional(suffix)
+	surnames := []*xml.Surname{{Value: strings.TrimSpace(surname)}}
+	prefix := ""
+	var married []*xml.Name
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "CONC", "CONT":
+		case "GIVN":
+			name.First = optional(c.text())
+		case "SURN":
+			surnames = nil
+			for i, s := range strings.Split(c.text(), ",") {
+				surnames = append(surnames, &xml.Surname{Value: strings.TrimSpace(s)})
+				if i > 0 {
+					surnames[i].Prim = "0"
+				}
+			}
+		case "SPFX":
+			prefix = strings.TrimSpace(c.text())
+		case "NPFX":
+			name.Title = optional(c.text())
+		case "NSFX":
+			name.Suffix = optional(c.text())
+		case "NICK":
+			name.Nick = optional(c.text())
+		case "_RUFNAME":
+			name.Call = optional(c.text())
+		case "TYPE":
+			t := enumValue(c)
+			if typ, ok := nameTypeNames[strings.ToLower(t)]; ok {
+				name.Type = typ
+			} else if strings.EqualFold(t, "maiden") {
+				name.Type = "Birth Name"
+			} else {
+				name.Type = t
+			}
+		case "NOTE":
+			name.NoteRefs = e.notes(name.NoteRefs, c, "Name Note")
+		case "SOUR":
+			name.CitationRefs = e.citations(name.CitationRefs, c)
+		case "_MARNM":
+			married = append(married, &xml.Name{Alt: 1, Type: "Married Name", First: name.First,
+				Surnames: []*xml.Surname{{Value: strings.TrimSpace(c.text())}}})
+		default:
+			e.unknown(c)
+		}
+	}
+	// The prefixes of the surnames of SURN are the words before them between
+	// the slashes, as Export writes them.
+	at := 0
+	for _, s := range surnames {
+		i := strings.Index(surname[at:], s.Value)
+		if s.Value == "" || i < 0 {
+			continue
+		}
+		s.Prefix = strings.TrimSpace(surname[at : at+i])
+		at += i + len(s.Value)
+	}
+	if prefix != "" {
+		surnames[0].Prefix = prefix
+		surnames[0].Value = strings.TrimSpace(strings.TrimPrefix(surnames[0].Value, prefix+" "))
+	}
+	name.Surnames = surnames
+	return append([]*xml.Name{name}, married...)
+}
+
+// Read an LDS ordinance structure.
+func (e *importer) ldsOrd(n *node) *xml.LDSOrd {
+	o := &xml.LDSOrd{Type: ldsTypes[n.Tag]}
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "DATE":
+			e.date(c, o)
+		case "TEMP":
+			o.Temple = &xml.Temple{Val: strings.TrimSpace(c.value())}
+		case "PLAC":
+			o.Place = e.place(c)
+		case "STAT":
+			if s, ok := ldsStatuses[strings.ToUpper(strings.TrimSpace(c.value()))]; ok {
+				o.Status = &xml.Status{Val: s}
+			} else {
+				e.unknown(c)
+			}
+		case "FAMC":
+			if h := e.handle(c, "FAM"); h != "" {
+				o.SealedTo = link(h)
+			}
+		case "NOTE":
+			o.NoteRefs = e.notes(o.NoteRefs, c, "LDS Note")
+		case "SOUR":
+			o.CitationRefs = e.citations(o.CitationRefs, c)
+		default:
+			e.unknown(c)
+		}
+	}
+	return o
+}
+
+// Get whether links has a link to h.
+func hasLink(links []*xml.GenericLink, h string) bool {
+	for _, l := range links {
+		if l.HLink == h {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *importer) person(p *xml.Person, n *node) {
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "NAME":
+			names := e.name(c)
+			if len(p.Names) > 0 {
+				names[0].Alt = 1
+			}
+			p.Names = append(p.Names, names...)
+		case "SEX":
+			switch v := strings.ToUpper(strings.TrimSpace(c.value())); v {
+			case "M", "F":
+				p.Gender = v
+			default:
+				p.Gender = "U"
+			}
+		case "FAMC":
+			h := e.handle(c, "FAM")
+			if h == "" || hasLink(p.ChildOfs, h) {
+				continue
+			}
+			p.ChildOfs = append(p.ChildOfs, link(h))
+			if pedi := c.child("PEDI"); pedi != nil && pedi.Value != "" {
+				e.pedigrees[[2]string{p.Handle, h}] = enumValue(pedi)
+			}
+		case "FAMS":
+			if h := e.handle(c, "FAM"); h != "" && !hasLink(p.ParentIns, h) {
+				p.ParentIns = append(p.ParentIns, link(h))
+			}
+		case "ASSO", "ALIA":
+			if c.pointer() == "" && c.Tag == "ALIA" {
+				names := e.name(c)
+				names[0].Alt, names[0].Type = 1, "Also Known As"
+				p.Names = append(p.Names, names...)
+				continue
+			}
+			h := e.handle(c, "INDI")
+			if h == "" {
+				continue
+			}
+			ref := &xml.PersonRef{Rel: role(c)}
+			ref.HLink = h
+			if c.Tag == "ALIA" {
+				ref.Rel = "Alias"
+			}
+			for _, s := range c.Children {
+				switch s.Tag {
+				case "RELA", "ROLE":
+				case "NOTE":
+					ref.NoteRefs = e.notes(ref.NoteRefs, s, "Association Note")
+				case "SOUR":
+					ref.CitationRefs = e.citations(ref.CitationRefs, s)
+				default:
+					e.unknown(s)
+				}
+			}
+			p.PersonRefs = append(p.PersonRefs, ref)
+		case "RESI":
+			if c.child("ADDR") != nil && c.child("PLAC") == nil {
+				p.Addresses = append(p.Addresses, e.residence(c))
+			} else {
+				p.EventRefs = append(p.EventRefs, e.event(c, "Residence", "Primary"))
+			}
+		case "EVEN":
+			p.EventRefs = append(p.EventRefs, e.event(c, "", "Primary"))
+		case "FACT":
+			p.Attributes = append(p.Attributes, e.attribute(c, ""))
+		case "NO":
+			p.Attributes = append(p.Attributes, e.negative(c, personEventTypes))
+		case "EXID":
+			p.Attributes = append(p.Attributes, exid(c))
+		case "BAPL", "CONL", "ENDL", "SLGC":
+			p.LDSOrds = append(p.LDSOrds, e.ldsOrd(c))
+		case "NOTE":
+			p.NoteRefs = e.notes(p.NoteRefs, c, "Person Note")
+		case "SOUR":
+			p.CitationRefs = e.citations(p.CitationRefs, c)
+		case "OBJE":
+			p.ObjRefs = e.objRefs(p.ObjRefs, c)
+		case "WWW":
+			p.URLs = append(p.URLs, &xml.URL{HRef: c.text(), Type: "Web Home"})
+		case "EMAIL":
+			p.URLs = append(p.URLs, &xml.URL{HRef: c.text(), Type: "E-mail"})
+		case "CHAN":
+			p.Change = e.change(c)
+		case "RESN":
+			p.Priv = 1
+		case "RIN", "SUBM", "ANCI", "DESI":
+		default:
+			if typ, ok := personEventTypes[c.Tag]; ok {
+				p.EventRefs = append(p.EventRefs, e.event(c, typ, "Primary"))
+			} else if typ, ok := attributeTypes[c.Tag]; ok {
+				p.Attributes = append(p.Attributes, e.attribute(c, typ))
+			} else if !custom(&p.Attributes, c) {
+				e.unknown(c)
+			}
+		}
+	}
+	if p.Gender == "" {
+		p.Gender = "U"
+	}
+	if len(p.Names) == 0 {
+		p.Names = []*xml.Name{{Type: "Birth Name", Surnames: []*xml.Surname{{}}}}
+	}
+}
+
+// Read a negative assertion of GEDCOM 7.0 as an attribute of type NO, whose
+// value is the type of the event that did not happen, by its tag in types.
+func (e *importer) negative(n *node, types map[string]string) *xml.Attribute {
+	a := e.attribute(n, "NO")
+	if typ, ok := types[strings.TrimSpace(a.Value)]; ok {
+		a.Value = typ
+	}
+	return a
+}
+
+// Read a residence with an address and no place as an address.
+func (e *importer) residence(n *node) *xml.Address {
+	a := address(n.child("ADDR"))
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "ADDR":
+		case "DATE":
+			e.date(c, a)
+		case "PHON":
+			a.Phone = optional(c.text())
+		case "NOTE":
+			a.NoteRefs = e.notes(a.NoteRefs, c, "Address Note")
+		case "SOUR":
+			a.CitationRefs = e.citations(a.CitationRefs, c)
+		default:
+			e.unknown(c)
+		}
+	}
+	return a
+}
+
+func (e *importer) family(f *xml.Family, n *node) {
+	f.Rel = &xml.Rel{Type: "Unknown"}
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "HUSB", "WIFE":
+			h := e.handle(c, "INDI")
+			if h == "" {
+				continue
+			}
+			if c.Tag == "HUSB" {
+				f.Father = link(h)
+			} else {
+				f.Mother = link(h)
+			}
+		case "CHIL":
+			h := e.handle(c, "INDI")
+			if h == "" {
+				continue
+			}
+			ref := &xml.ChildRef{}
+			ref.HLink = h
+			for _, s := range c.Children {
+				rel, ok := childRefTypes[strings.ToUpper(strings.TrimSpace(s.value()))]
+				switch {
+				case s.Tag == "_FREL" && ok:
+					ref.FRel = rel
+				case s.Tag == "_MREL" && ok:
+					ref.MRel = rel
+				default:
+					e.unknown(s)
+				}
+			}
+			f.ChildRefs = append(f.ChildRefs, ref)
+		case "EVEN":
+			f.EventRefs = append(f.EventRefs, e.event(c, "", "Family"))
+		case "NCHI":
+			f.Attributes = append(f.Attributes, e.attribute(c, "Number of Children"))
+		case "NO":
+			f.Attributes = append(f.Attributes, e.negative(c, familyEventTypes))
+		case "EXID":
+			f.Attributes = append(f.Attributes, exid(c))
+		case "SLGS":
+			f.LDSOrds = append(f.LDSOrds, e.ldsOrd(c))
+		case "NOTE":
+			f.NoteRefs = e.notes(f.NoteRefs, c, "Family Note")
+		case "SOUR":
+			f.CitationRefs = e.citations(f.CitationRefs, c)
+		case "OBJE":
+			f.ObjRefs = e.objRefs(f.ObjRefs, c)
+		case "CHAN":
+			f.Change = e.change(c)
+		case "RESN":
+			f.Priv = 1
+		case "RIN", "SUBM", "REFN":
+		default:
+			if typ, ok := familyEventTypes[c.Tag]; ok {
+				f.EventRefs = append(f.EventRefs, e.event(c, typ, "Family"))
+				if c.Tag == "MARR" {
+					f.Rel.Type = "Married"
+				}
+			} else if !custom(&f.Attributes, c) {
+				e.unknown(c)
+			}
+		}
+	}
+}
+
+func (e *importer) source(s *xml.Source, n *node) {
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "TITL":
+			s.STitle = optional(c.text())
+		case "AUTH":
+			s.SAuthor = optional(c.text())
+		case "PUBL":
+			s.SPubInfo = optional(c.text())
+		case "ABBR":
+			s.SAbbrev = optional(c.text())
+		case "TEXT":
+			s.SourceText = optional(c.text())
+		case "REPO":
+			ref := &xml.RepoRef{}
+			if c.pointer() != "" {
+				if ref.HLink = e.handle(c, "REPO"); ref.HLink == "" {
+					continue
+				}
+			} else {
+				r := &xml.Repository{RName: c.text(), Type: "Library"}
+				if r.RName == "" {
+					r.RName = "Unknown"
+				}
+				e.add(r)
+				ref.HLink = r.Handle
+			}
+			for _, s := range c.Children {
+				switch s.Tag {
+				case "CALN":
+					ref.CallNo = s.text()
+					if m := s.child("MEDI"); m != nil {
+						ref.Medium = medium(enumValue(m))
+					}
+				case "MEDI":
+					ref.Medium = medium(enumValue(s))
+				case "CONC", "CONT":
+				default:
+					e.unknown(s)
+				}
+			}
+			s.RepoRefs = append(s.RepoRefs, ref)
+		case "NOTE":
+			s.NoteRefs = e.notes(s.NoteRefs, c, "Source Note")
+		case "OBJE":
+			s.ObjRefs = e.objRefs(s.ObjRefs, c)
+		case "CHAN":
+			s.Change = e.change(c)
+		case "EXID":
+			a := exid(c)
+			s.SrcAttributes = append(s.SrcAttributes, &xml.SrcAttribute{Type: a.Type, Value: a.Value})
+		case "RIN", "REFN":
+		default:
+			if !customSrc(&s.SrcAttributes, c) {
+				e.unknown(c)
+			}
+		}
+	}
+}
+
+// Get the source media type of Gramps of a GEDCOM one, e.g. Book for book.
+func medium(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+}
+
+func (e *importer) repository(r *xml.Repository, n *node) {
+	r.Type = "Library"
+	var phone *string
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "NAME":
+			r.RName = c.text()
+		case "ADDR":
+			r.Addresses = append(r.Addresses, address(c))
+		case "PHON":
+			phone = optional(c.text())
+		case "WWW":
+			r.URL = &xml.URL{HRef: c.text(), Type: "Web Home"}
+		case "EMAIL":
+			if r.URL == nil {
+				r.URL = &xml.URL{HRef: c.text(), Type: "E-mail"}
+			} else {
+				e.unknown(c)
+			}
+		case "NOTE":
+			r.NoteRefs = e.notes(r.NoteRefs, c, "Repository Note")
+		case "CHAN":
+			r.Change = e.change(c)
+		case "RIN", "REFN":
+		default:
+			e.unknown(c)
+		}
+	}
+	if phone != nil {
+		if len(r.Addresses) == 0 {
+			r.Addresses = append(r.Addresses, &xml.Address{})
+		}
+		r.Addresses[0].Phone = phone
+	}
+}
+
+func (e *importer) note(nt *xml.Note, n *node) {
+	nt.Type = "General"
+	nt.Text = n.text()
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "CONC", "CONT", "RIN", "REFN", "MIME", "LANG":
+		case "CHAN":
+			nt.Change = e.change(c)
+		default:
+			e.unknown(c)
+		}
+	}
+}
+
+// Complete the links between people and families that are only given on one
+// side, as Gramps does, and set the relations of children to their parents.
+func (e *importer) link() {
+	db := e.db
+	for _, f := range db.Families {
+		e.id = f.ID
+		for _, parent := range []*xml.GenericLink{f.Father, f.Mother} {
+			if parent == nil {
+				continue
+			}
+			if p := db.PersonByHandle(parent.HLink); !hasLink(p.ParentIns, f.Handle) {
+				p.ParentIns = append(p.ParentIns, link(f.Handle))
+			}
+		}
+		for _, ref := range f.ChildRefs {
+			p := db.PersonByHandle(ref.HLink)
+			if !hasLink(p.ChildOfs, f.Handle) {
+				p.ChildOfs = append(p.ChildOfs, link(f.Handle))
+			}
+		}
+	}
+	for _, p := range db.People.Persons {
+		e.id = p.ID
+		for _, l := range p.ParentIns {
+			f := db.FamilyByHandle(l.HLink)
+			switch {
+			case f.Father != nil && f.Father.HLink == p.Handle, f.Mother != nil && f.Mother.HLink == p.Handle:
+			case f.Father == nil && p.Gender != "F":
+				f.Father = link(p.Handle)
+			case f.Mother == nil && p.Gender != "M":
+				f.Mother = link(p.Handle)
+			default:
+				e.warn("not a parent in family %s, which has both parents", f.ID)
+			}
+		}
+		for _, l := range p.ChildOfs {
+			f := db.FamilyByHandle(l.HLink)
+			var ref *xml.ChildRef
+			for _, r := range f.ChildRefs {
+				if r.HLink == p.Handle {
+					ref = r
+				}
+			}
+			if ref == nil {
+				ref = &xml.ChildRef{}
+				ref.HLink = p.Handle
+				f.ChildRefs = append(f.ChildRefs, ref)
+			}
+			pedi, ok := e.pedigrees[[2]string{p.Handle, f.Handle}]
+			if !ok {
+				continue
+			}
+			rel, ok := childRefTypes[strings.ToUpper(strings.TrimSpace(pedi))]
+			for _, t := range childRefTypes {
+				// The phrase of an OTHER pedigree of GEDCOM 7.0.
+				if !ok && strings.EqualFold(t, pedi) {
+					rel, ok = t, true
+				}
+			}
+			if !ok {
+				e.warn("pedigree %s in family %s not understood", pedi, f.ID)
+				continue
+			}
+			if rel == "Birth" {
+				rel = ""
+			}
+			if ref.FRel == "" && ref.MRel == "" {
+				ref.FRel, ref.MRel = rel, rel
+			}
+		}
+	}
+	e.id = ""
+}
+
+// Give the objects without a change time the time of the import.
+func (e *importer) finish() {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	change := func(c *string) {
+		if *c == "" {
+			*c = now
+		}
+	}
+	db := e.db
+	for _, o := range db.People.Persons {
+		change(&o.Change)
+	}
+	for _, o := range db.Families {
+		change(&o.Change)
+	}
+	for _, o := range db.Events {
+		change(&o.Change)
+	}
+	for _, o := range db.Citations {
+		change(&o.Change)
+	}
+	for _, o := range db.Sources {
+		change(&o.Change)
+	}
+	for _, o := range db.Places {
+		change(&o.Change)
+	}
+	for _, o := range db.Objects {
+		change(&o.Change)
+	}
+	for _, o := range db.Repositories {
+		change(&o.Change)
+	}
+	for _, o := range db.Notes {
+		change(&o.Change)
+	}
+}
@@ -0,0 +1,419 @@
+package gedcom
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.google.com/p/gogramps/xml"
+)
+
+const testGEDCOM = `0 HEAD
+1 SOUR OTHER
+1 SUBM @U1@
+1 GEDC
+2 VERS 5.5.1
+2 FORM LINEAGE-LINKED
+1 CHAR UTF-8
+1 PLAC
+2 FORM City, County, Country
+0 @U1@ SUBM
+1 NAME Ann Researcher
+1 ADDR 1 High Street
+2 CITY Leeds
+1 EMAIL ann@@example.com
+0 @I1@ INDI
+1 NAME Sir John /van Smith/ Jr
+2 GIVN John
+2 SPFX van
+2 SURN Smith
+2 NPFX Sir
+2 _MARNM Jones
+1 NAME Jack /Smith/
+2 TYPE aka
+1 SEX M
+1 BIRT
+2 DATE ABT 12 MAR 1850
+2 PLAC Leeds, Yorkshire, England
+3 MAP
+4 LATI N53.8
+4 LONG W1.55
+2 SOUR @S1@
+3 PAGE p. 3
+3 QUAY 3
+3 DATA
+4 DATE 1851
+2 AGE 0
+1 OCCU Farmer
+1 SSN 123
+1 FACT Jack
+2 TYPE Nickname
+1 RESI
+2 ADDR 1 Low Street
+3 CITY Leeds
+2 PHON 555
+1 _FAVCOLOR blue
+1 XYZ something
+2 ABC else
+1 FAMS @F1@
+1 NOTE @N1@
+1 NOTE An inline note
+1 CHAN
+2 DATE 4 MAY 2013
+3 TIME 12:30:00
+0 @I2@ INDI
+1 NAME Mary //
+1 SEX F
+1 BIRT
+2 DATE 1852
+2 PLAC York, Yorkshire, England
+1 ASSO @I1@
+2 RELA Godfather
+0 @I3@ INDI
+1 NAME Anne /Smith/
+1 FAMC @F1@
+2 PEDI adopted
+0 @I4@ INDI
+1 NAME Tom /Smith/
+0 @F1@ FAM
+1 HUSB @I1@
+1 WIFE @I2@
+1 CHIL @I3@
+1 CHIL @I4@
+1 MARR
+2 DATE BET 1875 AND 1876
+0 @S1@ SOUR
+1 TITL Parish register
+1 REPO @R1@
+2 CALN PR 12
+3 MEDI book
+1 OBJE
+2 FILE scans/register.jpg
+3 FORM jpg
+3 TITL Page 3
+0 @R1@ REPO
+1 NAME Archive
+1 WWW http://example.com
+0 @N1@ NOTE A note at @@home
+1 CONT with two lines, the sec
+1 CONC ond split
+0 @N1@ NOTE Again
+1 BOGUS
+0 TRLR
+`
+
+func TestImport(t *testing.T) {
+	db, warnings, err := Import(strings.NewReader(testGEDCOM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := db.Header.Researcher
+	if r == nil || str(r.ResName) != "Ann Researcher" || str(r.ResAddr) != "1 High Street" ||
+		str(r.ResCity) != "Leeds" || str(r.ResEMail) != "ann@example.com" {
+		t.Errorf("Got researcher %+v", r)
+	}
+
+	john := db.PersonByID("I1")
+	if john == nil || len(john.Names) != 3 {
+		t.Fatalf("Got %+v", john)
+	}
+	n := john.Names[0]
+	s := n.Surnames[0]
+	if str(n.First) != "John" || str(n.Title) != "Sir" || str(n.Suffix) != "Jr" || s.Prefix != "van" ||
+		s.Value != "Smith" || n.Type != "Birth Name" || n.Alt != 0 {
+		t.Errorf("Got name %+v, surname %+v", n, s)
+	}
+	if n := john.Names[1]; n.Type != "Married Name" || n.Surnames[0].Value != "Jones" || n.Alt != 1 {
+		t.Errorf("Got married name %+v", n)
+	}
+	if n := john.Names[2]; n.Type != "Also Known As" || str(n.First) != "Jack" || n.Alt != 1 {
+		t.Errorf("Got alternate name %+v", n)
+	}
+	if john.Gender != "M" || john.Change != "1367670600" {
+		t.Errorf("Got gender %s, change %s", john.Gender, john.Change)
+	}
+
+	if len(john.EventRefs) != 2 {
+		t.Fatalf("Got %d events", len(john.EventRefs))
+	}
+	birth := db.EventByHandle(john.EventRefs[0].HLink)
+	if str(birth.Type) != "Birth" || birth.GetDateString() != "about 1850-03-12" ||
+		john.EventRefs[0].Role != "Primary" || john.EventRefs[0].GetAttribute("Age") != "0" {
+		t.Errorf("Got birth %+v", birth)
+	}
+	leeds := db.PlaceByHandle(birth.Place.HLink)
+	if leeds.Type != "City" || leeds.PNames[0].Value != "Leeds" || leeds.Coord.Lat != "53.8" ||
+		leeds.Coord.Long != "-1.55" {
+		t.Errorf("Got place %+v", leeds)
+	}
+	county := db.PlaceByHandle(leeds.PlaceRefs[0].HLink)
+	if county.Type != "County" || county.PNames[0].Value != "Yorkshire" {
+		t.Errorf("Got county %+v", county)
+	}
+	mary := db.PersonByID("I2")
+	york := db.PlaceByHandle(db.EventByHandle(mary.EventRefs[0].HLink).Place.HLink)
+	if york.PlaceRefs[0].HLink != county.Handle || len(db.Places) != 4 {
+		t.Errorf("Places not shared: %d places", len(db.Places))
+	}
+	c := db.CitationByHandle(birth.CitationRefs[0].HLink)
+	if str(c.Page) != "p. 3" || str(c.Confidence) != "4" || c.GetDateString() != "1851" ||
+		c.SourceRef.HLink != db.SourceByID("S1").Handle {
+		t.Errorf("Got citation %+v", c)
+	}
+	if occu := db.EventByHandle(john.EventRefs[1].HLink); str(occu.Type) != "Occupation" ||
+		str(occu.Description) != "Farmer" {
+		t.Errorf("Got occupation %+v", occu)
+	}
+
+	attrs := map[string]string{}
+	for _, a := range john.Attributes {
+		attrs[a.Type] = a.Value
+	}
+	if len(attrs) != 3 || attrs["Social Security Number"] != "123" || attrs["Nickname"] != "Jack" ||
+		attrs["_FAVCOLOR"] != "blue" {
+		t.Errorf("Got attributes %v", attrs)
+	}
+	if len(john.Addresses) != 1 || str(john.Addresses[0].Street) != "1 Low Street" ||
+		str(john.Addresses[0].Phone) != "555" {
+		t.Errorf("Got addresses %+v", john.Addresses)
+	}
+
+	if len(john.NoteRefs) != 3 {
+		t.Fatalf("Got %d notes", len(john.NoteRefs))
+	}
+	if n := db.NoteByHandle(john.NoteRefs[0].HLink); n.Text != "A note at @home\nwith two lines, the second split" {
+		t.Errorf("Got note %q", n.Text)
+	}
+	if n := db.NoteByHandle(john.NoteRefs[1].HLink); n.Text != "An inline note" || n.Type != "Person Note" {
+		t.Errorf("Got note %+v", n)
+	}
+	if n := db.NoteByHandle(john.NoteRefs[2].HLink); !strings.Contains(n.Text, "1 XYZ something\nLine 47: 2 ABC else") {
+		t.Errorf("Got note %q", n.Text)
+	}
+
+	if len(mary.PersonRefs) != 1 || mary.PersonRefs[0].Rel != "Godfather" || mary.PersonRefs[0].HLink != john.Handle {
+		t.Errorf("Got associations %+v", mary.PersonRefs)
+	}
+
+	// Links only given on one side are completed.
+	f := db.FamilyByID("F1")
+	anne, tom := db.PersonByID("I3"), db.PersonByID("I4")
+	if f.Father.HLink != john.Handle || f.Mother.HLink != mary.Handle || len(f.ChildRefs) != 2 ||
+		len(mary.ParentIns) != 1 || len(tom.ChildOfs) != 1 || f.Rel.Type != "Married" {
+		t.Errorf("Got family %+v", f)
+	}
+	if ref := f.ChildRefs[0]; ref.HLink != anne.Handle || ref.FRel != "Adopted" || ref.MRel != "Adopted" {
+		t.Errorf("Got child %+v", ref)
+	}
+	if ref := f.ChildRefs[1]; ref.FRel != "" || ref.MRel != "" {
+		t.Errorf("Got child %+v", ref)
+	}
+
+	src := db.SourceByID("S1")
+	if str(src.STitle) != "Parish register" || len(src.RepoRefs) != 1 || src.RepoRefs[0].CallNo != "PR 12" ||
+		src.RepoRefs[0].Medium != "Book" || db.RepositoryByHandle(src.RepoRefs[0].HLink).RName != "Archive" {
+		t.Errorf("Got source %+v", src)
+	}
+	if o := db.ObjectByHandle(src.ObjRefs[0].HLink); o.File.Src != "scans/register.jpg" ||
+		o.File.Mime != "image/jpeg" || o.File.Description != "Page 3" {
+		t.Errorf("Got object %+v", o.File)
+	}
+	if u := db.RepositoryByID("R1").URL; u == nil || u.HRef != "http://example.com" {
+		t.Errorf("Got URL %+v", u)
+	}
+
+	var messages []string
+	for _, w := range warnings {
+		messages = append(messages, w.String())
+	}
+	for _, want := range []string{
+		"Line 90: xref @N1@ used again; imported with a new ID",
+		"N0000: 1 lines not understood and left out",
+		"I1: 2 lines not understood; kept in note N0002",
+	} {
+		found := false
+		for _, m := range messages {
+			found = found || m == want
+		}
+		if !found {
+			t.Errorf("Missing warning %q in %q", want, messages)
+		}
+	}
+
+	if _, _, err := Import(strings.NewReader("0 @I1@ INDI\n")); err == nil {
+		t.Error("Imported a file without a header")
+	}
+}
+
+const testGEDCOM7 = `0 HEAD
+1 GEDC
+2 VERS 7.0
+1 SCHMA
+2 TAG _CALL https://code.google.com/p/gogramps/gedcom/_RUFNAME
+0 @I1@ INDI
+1 NAME John /Smith/
+2 TYPE OTHER
+3 PHRASE Baptismal name
+2 _CALL Jack
+1 BIRT
+2 DATE JULIAN 4 MAR 1749
+2 ASSO @I2@
+3 ROLE WITN
+1 DEAT
+2 DATE
+3 PHRASE in the spring
+1 NO BAPM
+1 EXID 123
+2 TYPE https://www.familysearch.org/
+1 SNOTE @N1@
+1 FAMC @F1@
+2 PEDI OTHER
+3 PHRASE Stepchild
+1 CHAN
+2 DATE 4 MAY 2013
+3 TIME 12:30:00Z
+0 @I2@ INDI
+1 NAME Mary //
+1 ASSO @I1@
+2 ROLE OTHER
+3 PHRASE Godfather
+1 FAMC @VOID@
+0 @F1@ FAM
+1 WIFE @I2@
+1 NO DIV
+0 @S1@ SOUR
+1 TITL Register
+1 REPO @R1@
+2 CALN 12
+3 MEDI BOOK
+0 @R1@ REPO
+1 NAME Archive
+0 @O1@ OBJE
+1 FILE scans/my%20register.jpg
+2 FORM image/jpeg
+0 @N1@ SNOTE @@home and @ inside
+1 CONT second line
+1 MIME text/plain
+0 TRLR
+`
+
+func TestImportSeven(t *testing.T) {
+	db, warnings, err := Import(strings.NewReader(testGEDCOM7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("Got warnings %v", warnings)
+	}
+
+	john, mary := db.PersonByID("I1"), db.PersonByID("I2")
+	if n := john.Names[0]; n.Type != "Baptismal name" || str(n.Call) != "Jack" {
+		t.Errorf("Got name %+v", n)
+	}
+	birth := db.EventByHandle(john.EventRefs[0].HLink)
+	if d, _ := birth.GetDate(); d.Calendar != xml.Julian || d.Start != ymd(1749, 3, 4) {
+		t.Errorf("Got birth date %+v", d)
+	}
+	if len(mary.EventRefs) != 1 || mary.EventRefs[0].HLink != birth.Handle || mary.EventRefs[0].Role != "Witness" {
+		t.Errorf("Got witness %+v", mary.EventRefs)
+	}
+	if d, _ := db.EventByHandle(john.EventRefs[1].HLink).GetDate(); d.Modifier != xml.ModTextOnly ||
+		d.Text != "in the spring" {
+		t.Errorf("Got death date %+v", d)
+	}
+	attrs := map[string]string{}
+	for _, a := range john.Attributes {
+		attrs[a.Type] = a.Value
+	}
+	if attrs["NO"] != "Baptism" || attrs["EXID:https://www.familysearch.org/"] != "123" {
+		t.Errorf("Got attributes %v", attrs)
+	}
+	if n := db.NoteByHandle(john.NoteRefs[0].HLink); n.Text != "@home and @ inside\nsecond line" {
+		t.Errorf("Got note %q", n.Text)
+	}
+	f := db.FamilyByID("F1")
+	if len(f.ChildRefs) != 1 || f.ChildRefs[0].FRel != "Stepchild" || f.Attributes[0].Value != "Divorce" {
+		t.Errorf("Got family %+v", f)
+	}
+	if john.Change != "1367670600" {
+		t.Errorf("Got change %s", john.Change)
+	}
+	if len(mary.PersonRefs) != 1 || mary.PersonRefs[0].Rel != "Godfather" || len(mary.ChildOfs) != 0 {
+		t.Errorf("Got %+v, %+v", mary.PersonRefs, mary.ChildOfs)
+	}
+	if ref := db.SourceByID("S1").RepoRefs[0]; ref.Medium != "Book" {
+		t.Errorf("Got repository reference %+v", ref)
+	}
+	if o := db.ObjectByID("O1"); o.File.Src != "scans/my register.jpg" || o.File.Mime != "image/jpeg" {
+		t.Errorf("Got object %+v", o.File)
+	}
+}
+
+func TestImportExample(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "xml", "testdata", "example-1.5.0.gramps"))
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer f.Close()
+	db, err := xml.Parse(f)
+	if err != nil {
+		t.Fatalf("Failed to parse example: %s", err)
+	}
+	for _, version := range []string{Version551, Version70} {
+		testRoundTrip(t, db, version)
+	}
+}
+
+func testRoundTrip(t *testing.T, db *xml.Database, version string) {
+	var buf bytes.Buffer
+	if _, err := Export(&buf, db, ExportOptions{Version: version}); err != nil {
+		t.Fatal(err)
+	}
+	imported, warnings, err := Import(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("%s: got warnings %v", version, warnings)
+	}
+	if len(imported.People.Persons) != len(db.People.Persons) || len(imported.Families) != len(db.Families) {
+		t.Errorf("%s: got %d people and %d families", version, len(imported.People.Persons), len(imported.Families))
+	}
+	for _, p := range db.People.Persons {
+		q := imported.PersonByID(p.ID)
+		if q == nil {
+			t.Errorf("%s: person %s missing", version, p.ID)
+			continue
+		}
+		if a, b := p.GetPreferredName().String(), q.GetPreferredName().String(); a != b {
+			t.Errorf("%s: %s: got name %q, want %q", version, p.ID, b, a)
+		}
+		e1, _ := p.BirthOrFallback(db)
+		e2, _ := q.BirthOrFallback(imported)
+		if e1 == nil {
+			continue
+		}
+		if e2 == nil {
+			t.Errorf("%s: %s: birth missing", version, p.ID)
+			continue
+		}
+		// GEDCOM 7.0 has no dual years.
+		d1, _ := e1.GetDate()
+		d1.DualDated = d1.DualDated && version == Version551
+		if d2, _ := e2.GetDate(); d1 != d2 {
+			t.Errorf("%s: %s: birth date differs", version, p.ID)
+		}
+	}
+
+	// The imported database can be written as a .gramps file.
+	buf.Reset()
+	if err := imported.Write(&buf, xml.WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xml.Parse(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
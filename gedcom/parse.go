@@ -0,0 +1,135 @@
+package gedcom
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A node is a GEDCOM line with the lines under it.
+type node struct {
+	Level int
+	Xref  string
+	Tag   string
+	// The value as written, with at signs still doubled.
+	Value    string
+	Line     int
+	Children []*node
+}
+
+var linePattern = regexp.MustCompile(`^\s*(\d+)\s+(?:@([^@]+)@\s+)?(\S+)(?: (.*))?$`)
+
+// Parse the lines of a GEDCOM file into records. Lines that cannot be parsed
+// are left out with a warning.
+func parseLines(text string) ([]*node, []Warning) {
+	var records []*node
+	var warnings []Warning
+	var stack []*node
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	for i, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		m := linePattern.FindStringSubmatch(l)
+		if m == nil {
+			warnings = append(warnings, Warning{Message: fmt.Sprintf("Line %d: not a GEDCOM line: %q", i+1, l)})
+			continue
+		}
+		level, err := strconv.Atoi(m[1])
+		if err != nil || level > len(stack) {
+			warnings = append(warnings, Warning{Message: fmt.Sprintf("Line %d: level out of place: %q", i+1, l)})
+			continue
+		}
+		n := &node{Level: level, Xref: m[2], Tag: strings.ToUpper(m[3]), Value: m[4], Line: i + 1}
+		stack = stack[:level]
+		if level == 0 {
+			records = append(records, n)
+		} else {
+			parent := stack[level-1]
+			parent.Children = append(parent.Children, n)
+		}
+		stack = append(stack, n)
+	}
+	return records, warnings
+}
+
+// Get the line of n as it was written.
+func (n *node) String() string {
+	s := strconv.Itoa(n.Level)
+	if n.Xref != "" {
+		s += " @" + n.Xref + "@"
+	}
+	s += " " + n.Tag
+	if n.Value != "" {
+		s += " " + n.Value
+	}
+	return s
+}
+
+// Get the xref n points to, or "" if its value is not a pointer.
+func (n *node) pointer() string {
+	v := strings.TrimSpace(n.Value)
+	if len(v) > 2 && v[0] == '@' && v[len(v)-1] == '@' && v[1] != '#' &&
+		!strings.Contains(v[1:len(v)-1], "@") {
+		return v[1 : len(v)-1]
+	}
+	return ""
+}
+
+// Get the value of n with at signs undoubled.
+func (n *node) value() string {
+	return strings.Replace(n.Value, "@@", "@", -1)
+}
+
+// Get the text of n: its value continued by its CONC and CONT lines.
+func (n *node) text() string {
+	s := n.value()
+	for _, c := range n.Children {
+		switch c.Tag {
+		case "CONC":
+			s += c.value()
+		case "CONT":
+			s += "\n" + c.value()
+		}
+	}
+	return s
+}
+
+// Get the first line under n with tag, or nil if there is none.
+func (n *node) child(tag string) *node {
+	for _, c := range n.Children {
+		if c.Tag == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// Get the text of the first line under n with tag, or "".
+func (n *node) childText(tag string) string {
+	if c := n.child(tag); c != nil {
+		return c.text()
+	}
+	return ""
+}
+
+// Get whether n has lines under it other than continuations.
+func (n *node) hasChildren() bool {
+	for _, c := range n.Children {
+		if c.Tag != "CONC" && c.Tag != "CONT" {
+			return true
+		}
+	}
+	return false
+}
+
+// Append the lines of n and those under it to lines.
+func (n *node) lines(lines []string) []string {
+	lines = append(lines, fmt.Sprintf("Line %d: %s", n.Line, n))
+	for _, c := range n.Children {
+		lines = c.lines(lines)
+	}
+	return lines
+}
@@ -0,0 +1,207 @@
+package gedcom
+
+// The GEDCOM tags of the event types of Gramps for people. Other types are
+// written as EVEN with a TYPE.
+var personEventTags = map[string]string{
+	"Adopted":             "ADOP",
+	"Adult Christening":   "CHRA",
+	"Baptism":             "BAPM",
+	"Bar Mitzvah":         "BARM",
+	"Bas Mitzvah":         "BASM",
+	"Birth":               "BIRT",
+	"Blessing":            "BLES",
+	"Burial":              "BURI",
+	"Census":              "CENS",
+	"Christening":         "CHR",
+	"Confirmation":        "CONF",
+	"Cremation":           "CREM",
+	"Death":               "DEAT",
+	"Education":           "EDUC",
+	"Emigration":          "EMIG",
+	"First Communion":     "FCOM",
+	"Graduation":          "GRAD",
+	"Immigration":         "IMMI",
+	"Naturalization":      "NATU",
+	"Nobility Title":      "TITL",
+	"Number of Marriages": "NMR",
+	"Occupation":          "OCCU",
+	"Ordination":          "ORDN",
+	"Probate":             "PROB",
+	"Property":            "PROP",
+	"Religion":            "RELI",
+	"Residence":           "RESI",
+	"Retirement":          "RETI",
+	"Will":                "WILL",
+}
+
+// The GEDCOM tags of the event types of Gramps for families.
+var familyEventTags = map[string]string{
+	"Annulment":           "ANUL",
+	"Census":              "CENS",
+	"Divorce":             "DIV",
+	"Divorce Filing":      "DIVF",
+	"Engagement":          "ENGA",
+	"Marriage":            "MARR",
+	"Marriage Banns":      "MARB",
+	"Marriage Contract":   "MARC",
+	"Marriage License":    "MARL",
+	"Marriage Settlement": "MARS",
+	"Residence":           "RESI",
+}
+
+// The tags of facts, whose value is the description of the event or the
+// value of the attribute.
+var factTags = map[string]bool{
+	"CAST": true, "DSCR": true, "EDUC": true, "IDNO": true, "NATI": true, "NCHI": true,
+	"NMR": true, "OCCU": true, "PROP": true, "RELI": true, "SSN": true, "TITL": true,
+}
+
+// The GEDCOM tags of the attribute types of Gramps for people. Other types
+// are written as FACT with a TYPE.
+var attributeTags = map[string]string{
+	"Caste":                  "CAST",
+	"Description":            "DSCR",
+	"Identification Number":  "IDNO",
+	"National Origin":        "NATI",
+	"Nobility Title":         "TITL",
+	"Number of Children":     "NCHI",
+	"Occupation":             "OCCU",
+	"Social Security Number": "SSN",
+}
+
+// The GEDCOM tags of the attributes of events and event references.
+var eventAttributeTags = map[string]string{
+	"Age":    "AGE",
+	"Agency": "AGNC",
+	"Cause":  "CAUS",
+}
+
+// The pedigree linkage types of GEDCOM, by the child reference types of
+// Gramps.
+var pedigrees = map[string]string{
+	"":        "",
+	"Birth":   "",
+	"Adopted": "adopted",
+	"Foster":  "foster",
+}
+
+// The name types of GEDCOM, by the name types of Gramps.
+var nameTypes = map[string]string{
+	"":              "",
+	"Unknown":       "",
+	"Birth Name":    "birth",
+	"Also Known As": "aka",
+	"Married Name":  "married",
+}
+
+// The roles of GEDCOM 7.0 associations, by the event roles of Gramps and the
+// relationships of people to others. Other roles are written as OTHER with a
+// phrase.
+var roleTags = map[string]string{
+	"Bride":     "WIFE",
+	"Celebrant": "OFFICIATOR",
+	"Child":     "CHIL",
+	"Clergy":    "CLERGY",
+	"Father":    "FATH",
+	"Friend":    "FRIEND",
+	"Godparent": "GODP",
+	"Groom":     "HUSB",
+	"Mother":    "MOTH",
+	"Neighbor":  "NGHBR",
+	"Parent":    "PARENT",
+	"Spouse":    "SPOU",
+	"Witness":   "WITN",
+}
+
+// The source media types of GEDCOM 7.0, by the source media types of Gramps.
+var sourceMedia = map[string]string{
+	"":           "",
+	"Unknown":    "",
+	"Audio":      "AUDIO",
+	"Book":       "BOOK",
+	"Card":       "CARD",
+	"Electronic": "ELECTRONIC",
+	"Fiche":      "FICHE",
+	"Film":       "FILM",
+	"Magazine":   "MAGAZINE",
+	"Manuscript": "MANUSCRIPT",
+	"Map":        "MAP",
+	"Newspaper":  "NEWSPAPER",
+	"Photo":      "PHOTO",
+	"Tombstone":  "TOMBSTONE",
+	"Video":      "VIDEO",
+}
+
+// The URI of the extension tags of gogramps, which GEDCOM 7.0 files declare
+// in the SCHMA of their header.
+const schemaURI = "https://code.google.com/p/gogramps/gedcom/"
+
+// The extension tags written to GEDCOM 7.0 files.
+var extensionTags = []string{"_RUFNAME"}
+
+// The GEDCOM tags of the LDS ordinance types of Gramps.
+var ldsTags = map[string]string{
+	"baptism":           "BAPL",
+	"confirmation":      "CONL",
+	"endowment":         "ENDL",
+	"sealed_to_parents": "SLGC",
+	"sealed_to_spouse":  "SLGS",
+}
+
+// Get the keys of m by its values.
+func reverse(m map[string]string) map[string]string {
+	r := map[string]string{}
+	for k, v := range m {
+		if v != "" {
+			r[v] = k
+		}
+	}
+	return r
+}
+
+// The Gramps types of GEDCOM tags, for import.
+var (
+	personEventTypes = reverse(personEventTags)
+	familyEventTypes = reverse(familyEventTags)
+	attributeTypes   = reverse(attributeTags)
+	nameTypeNames    = reverse(nameTypes)
+	ldsTypes         = reverse(ldsTags)
+	roleNames        = reverse(roleTags)
+)
+
+// The child reference types of Gramps, by the pedigree linkage types of
+// GEDCOM and the _FREL and _MREL of other programs.
+var childRefTypes = map[string]string{
+	"BIRTH":   "Birth",
+	"NATURAL": "Birth",
+	"ADOPTED": "Adopted",
+	"FOSTER":  "Foster",
+	"STEP":    "Stepchild",
+	"SEALING": "Unknown",
+	"UNKNOWN": "Unknown",
+	"OTHER":   "Unknown",
+}
+
+// The LDS ordinance statuses of Gramps, by their GEDCOM keywords.
+var ldsStatuses = map[string]string{
+	"BIC":       "BIC",
+	"CANCELED":  "Canceled",
+	"CHILD":     "Child",
+	"CLEARED":   "Cleared",
+	"COMPLETED": "Completed",
+	"DNS":       "DNS",
+	"DNS/CAN":   "DNS/CAN",
+	"INFANT":    "Infant",
+	"PRE-1970":  "Pre-1970",
+	"QUALIFIED": "Qualified",
+	"STILLBORN": "Stillborn",
+	"SUBMITTED": "Submitted",
+	"UNCLEARED": "Uncleared",
+}
+
+// The place types of Gramps, for the jurisdictions of a PLAC FORM.
+var placeTypes = []string{
+	"Country", "State", "County", "City", "Parish", "Locality", "Street", "Province",
+	"Region", "Department", "Neighborhood", "District", "Borough", "Municipality", "Town",
+	"Village", "Hamlet", "Farm", "Building", "Number",
+}
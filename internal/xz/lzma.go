@@ -0,0 +1,369 @@
+package xz
+
+import (
+	"errors"
+)
+
+// The LZMA decoder used by LZMA2 chunks. LZMA2 streams never contain the
+// end-of-stream marker and give the uncompressed size of every chunk, so the
+// decoder only ever decodes a known number of bytes.
+
+const (
+	numStates          = 12
+	numPosBitsMax      = 4
+	numLenToPosStates  = 4
+	numAlignBits       = 4
+	startPosModelIndex = 4
+	endPosModelIndex   = 14
+	numFullDistances   = 1 << (endPosModelIndex >> 1)
+	matchMinLen        = 2
+	probInit           = 1 << 10
+)
+
+var errCorrupt = errors.New("xz: corrupt LZMA2 data")
+
+type rangeDecoder struct {
+	data  []byte
+	pos   int
+	rng   uint32
+	code  uint32
+	fault bool
+}
+
+func (rc *rangeDecoder) init(data []byte) error {
+	if len(data) < 5 || data[0] != 0 {
+		return errCorrupt
+	}
+	rc.data, rc.pos = data, 5
+	rc.rng = 0xFFFFFFFF
+	rc.code = uint32(data[1])<<24 | uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4])
+	rc.fault = false
+	return nil
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng < 1<<24 {
+		if rc.pos >= len(rc.data) {
+			rc.fault = true
+			return
+		}
+		rc.rng <<= 8
+		rc.code = rc.code<<8 | uint32(rc.data[rc.pos])
+		rc.pos++
+	}
+}
+
+func (rc *rangeDecoder) bit(prob *uint16) uint32 {
+	rc.normalize()
+	bound := (rc.rng >> 11) * uint32(*prob)
+	if rc.code < bound {
+		rc.rng = bound
+		*prob += (1<<11 - *prob) >> 5
+		return 0
+	}
+	rc.rng -= bound
+	rc.code -= bound
+	*prob -= *prob >> 5
+	return 1
+}
+
+func (rc *rangeDecoder) direct(numBits uint) uint32 {
+	var res uint32
+	for ; numBits > 0; numBits-- {
+		rc.normalize()
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		t := 0 - (rc.code >> 31)
+		rc.code += rc.rng & t
+		res = res<<1 + t + 1
+	}
+	return res
+}
+
+// Decode numBits bits, most significant first, with the probabilities of a
+// bit tree. probs[0] is unused.
+func (rc *rangeDecoder) tree(probs []uint16, numBits uint) uint32 {
+	m := uint32(1)
+	for i := uint(0); i < numBits; i++ {
+		m = m<<1 | rc.bit(&probs[m])
+	}
+	return m - 1<<numBits
+}
+
+// Decode numBits bits, least significant first.
+func (rc *rangeDecoder) reverseTree(probs []uint16, numBits uint) uint32 {
+	m, sym := uint32(1), uint32(0)
+	for i := uint(0); i < numBits; i++ {
+		b := rc.bit(&probs[m])
+		m = m<<1 | b
+		sym |= b << i
+	}
+	return sym
+}
+
+type lenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << numPosBitsMax][1 << 3]uint16
+	mid     [1 << numPosBitsMax][1 << 3]uint16
+	high    [1 << 8]uint16
+}
+
+func (ld *lenDecoder) reset() {
+	ld.choice, ld.choice2 = probInit, probInit
+	for i := range ld.low {
+		fill(ld.low[i][:])
+		fill(ld.mid[i][:])
+	}
+	fill(ld.high[:])
+}
+
+func (ld *lenDecoder) decode(rc *rangeDecoder, posState uint32) uint32 {
+	if rc.bit(&ld.choice) == 0 {
+		return rc.tree(ld.low[posState][:], 3)
+	}
+	if rc.bit(&ld.choice2) == 0 {
+		return 8 + rc.tree(ld.mid[posState][:], 3)
+	}
+	return 16 + rc.tree(ld.high[:], 8)
+}
+
+func fill(probs []uint16) {
+	for i := range probs {
+		probs[i] = probInit
+	}
+}
+
+// The sliding window of previously decoded bytes.
+type dictionary struct {
+	buf   []byte
+	size  int
+	pos   int
+	total int64
+}
+
+func (d *dictionary) reset() {
+	d.buf, d.pos, d.total = d.buf[:0], 0, 0
+}
+
+func (d *dictionary) put(b byte) {
+	if len(d.buf) < d.size {
+		d.buf = append(d.buf, b)
+	} else {
+		d.buf[d.pos] = b
+	}
+	d.pos++
+	if d.pos == d.size {
+		d.pos = 0
+	}
+	d.total++
+}
+
+// Get the byte dist bytes back; dist 1 is the last byte written.
+func (d *dictionary) get(dist uint32) byte {
+	i := d.pos - int(dist)
+	if i < 0 {
+		i += d.size
+	}
+	return d.buf[i]
+}
+
+func (d *dictionary) has(dist uint32) bool {
+	return int64(dist) <= d.total && int(dist) <= d.size
+}
+
+type lzmaDecoder struct {
+	lc, lp, pb uint
+
+	state                  uint32
+	rep0, rep1, rep2, rep3 uint32
+
+	isMatch    [numStates << numPosBitsMax]uint16
+	isRep      [numStates]uint16
+	isRepG0    [numStates]uint16
+	isRepG1    [numStates]uint16
+	isRepG2    [numStates]uint16
+	isRep0Long [numStates << numPosBitsMax]uint16
+	posSlot    [numLenToPosStates][1 << 6]uint16
+	posSpecial [1 + numFullDistances - endPosModelIndex]uint16
+	align      [1 << numAlignBits]uint16
+	literal    []uint16
+	matchLen   lenDecoder
+	repLen     lenDecoder
+}
+
+// Set the literal context, literal position and position bits from an LZMA
+// properties byte.
+func (ld *lzmaDecoder) setProps(props byte) error {
+	if props >= 9*5*5 {
+		return errCorrupt
+	}
+	ld.lc = uint(props % 9)
+	props /= 9
+	ld.lp = uint(props % 5)
+	ld.pb = uint(props / 5)
+	if ld.lc+ld.lp > 4 {
+		return errCorrupt
+	}
+	ld.literal = make([]uint16, 0x300<<(ld.lc+ld.lp))
+	return nil
+}
+
+func (ld *lzmaDecoder) resetState() {
+	ld.state = 0
+	ld.rep0, ld.rep1, ld.rep2, ld.rep3 = 0, 0, 0, 0
+	fill(ld.isMatch[:])
+	fill(ld.isRep[:])
+	fill(ld.isRepG0[:])
+	fill(ld.isRepG1[:])
+	fill(ld.isRepG2[:])
+	fill(ld.isRep0Long[:])
+	for i := range ld.posSlot {
+		fill(ld.posSlot[i][:])
+	}
+	fill(ld.posSpecial[:])
+	fill(ld.align[:])
+	fill(ld.literal)
+	ld.matchLen.reset()
+	ld.repLen.reset()
+}
+
+// Decode n bytes of the compressed chunk data into d, appending them to out.
+func (ld *lzmaDecoder) decode(data []byte, n int, d *dictionary, out []byte) ([]byte, error) {
+	var rc rangeDecoder
+	if err := rc.init(data); err != nil {
+		return out, err
+	}
+	pbMask := uint32(1)<<ld.pb - 1
+	lpMask := uint32(1)<<ld.lp - 1
+
+	put := func(b byte) {
+		d.put(b)
+		out = append(out, b)
+		n--
+	}
+
+	for n > 0 {
+		posState := uint32(d.total) & pbMask
+		if rc.bit(&ld.isMatch[ld.state<<numPosBitsMax+posState]) == 0 {
+			prev := uint32(0)
+			if d.total > 0 {
+				prev = uint32(d.get(1))
+			}
+			litState := (uint32(d.total)&lpMask)<<ld.lc + prev>>(8-ld.lc)
+			probs := ld.literal[0x300*litState:]
+			sym := uint32(1)
+			if ld.state >= 7 {
+				if !d.has(ld.rep0 + 1) {
+					return out, errCorrupt
+				}
+				match := uint32(d.get(ld.rep0 + 1))
+				for sym < 0x100 {
+					matchBit := (match >> 7) & 1
+					match <<= 1
+					b := rc.bit(&probs[0x100+matchBit<<8+sym])
+					sym = sym<<1 | b
+					if matchBit != b {
+						break
+					}
+				}
+			}
+			for sym < 0x100 {
+				sym = sym<<1 | rc.bit(&probs[sym])
+			}
+			put(byte(sym))
+			switch {
+			case ld.state < 4:
+				ld.state = 0
+			case ld.state < 10:
+				ld.state -= 3
+			default:
+				ld.state -= 6
+			}
+			continue
+		}
+
+		var length uint32
+		if rc.bit(&ld.isRep[ld.state]) == 0 {
+			ld.rep3, ld.rep2, ld.rep1 = ld.rep2, ld.rep1, ld.rep0
+			length = ld.matchLen.decode(&rc, posState)
+			if ld.state < 7 {
+				ld.state = 7
+			} else {
+				ld.state = 10
+			}
+			ld.rep0 = ld.distance(&rc, length)
+			if ld.rep0 == 0xFFFFFFFF {
+				return out, errCorrupt
+			}
+		} else {
+			if rc.bit(&ld.isRepG0[ld.state]) == 0 {
+				if rc.bit(&ld.isRep0Long[ld.state<<numPosBitsMax+posState]) == 0 {
+					if ld.state < 7 {
+						ld.state = 9
+					} else {
+						ld.state = 11
+					}
+					if !d.has(ld.rep0 + 1) {
+						return out, errCorrupt
+					}
+					put(d.get(ld.rep0 + 1))
+					continue
+				}
+			} else {
+				var dist uint32
+				if rc.bit(&ld.isRepG1[ld.state]) == 0 {
+					dist = ld.rep1
+				} else {
+					if rc.bit(&ld.isRepG2[ld.state]) == 0 {
+						dist = ld.rep2
+					} else {
+						dist = ld.rep3
+						ld.rep3 = ld.rep2
+					}
+					ld.rep2 = ld.rep1
+				}
+				ld.rep1 = ld.rep0
+				ld.rep0 = dist
+			}
+			length = ld.repLen.decode(&rc, posState)
+			if ld.state < 7 {
+				ld.state = 8
+			} else {
+				ld.state = 11
+			}
+		}
+
+		if !d.has(ld.rep0 + 1) {
+			return out, errCorrupt
+		}
+		for l := int(length) + matchMinLen; l > 0 && n > 0; l-- {
+			put(d.get(ld.rep0 + 1))
+		}
+		if rc.fault {
+			return out, errCorrupt
+		}
+	}
+	if rc.fault {
+		return out, errCorrupt
+	}
+	return out, nil
+}
+
+func (ld *lzmaDecoder) distance(rc *rangeDecoder, length uint32) uint32 {
+	lenState := length
+	if lenState > numLenToPosStates-1 {
+		lenState = numLenToPosStates - 1
+	}
+	slot := rc.tree(ld.posSlot[lenState][:], 6)
+	if slot < startPosModelIndex {
+		return slot
+	}
+	numDirect := uint(slot>>1) - 1
+	dist := (2 | slot&1) << numDirect
+	if slot < endPosModelIndex {
+		return dist + rc.reverseTree(ld.posSpecial[dist-slot:], numDirect)
+	}
+	dist += rc.direct(numDirect-numAlignBits) << numAlignBits
+	return dist + rc.reverseTree(ld.align[:], numAlignBits)
+}
@@ -0,0 +1,114 @@
+package xz
+
+import (
+	"errors"
+)
+
+// An lzma2Decoder decodes the chunks of the LZMA2 data of a block.
+type lzma2Decoder struct {
+	dict dictionary
+	lzma lzmaDecoder
+
+	needDictReset bool
+	needProps     bool
+	buf           []byte
+}
+
+// Get the dictionary size from the LZMA2 filter properties.
+func lzma2DictSize(props byte) (int, error) {
+	if props > 40 {
+		return 0, errors.New("xz: invalid LZMA2 dictionary size")
+	}
+	if props == 40 {
+		return 1<<31 - 1, nil
+	}
+	return (2 | int(props&1)) << (props/2 + 11), nil
+}
+
+func (d *lzma2Decoder) reset(dictSize int) {
+	d.dict.size = dictSize
+	d.dict.reset()
+	d.needDictReset, d.needProps = true, true
+}
+
+// Decode the next chunk. Returns the decoded bytes and whether the chunk was
+// the last of the block.
+func (d *lzma2Decoder) chunk(z *reader) ([]byte, bool, error) {
+	control, err := z.readByte()
+	if err != nil {
+		return nil, false, err
+	}
+	if control == 0x00 {
+		return nil, true, nil
+	}
+
+	var sizes [4]byte
+	if control < 0x80 {
+		// An uncompressed chunk, with (0x01) or without (0x02) a dictionary
+		// reset.
+		if control > 0x02 || control == 0x02 && d.needDictReset {
+			return nil, false, errCorrupt
+		}
+		if control == 0x01 {
+			d.dict.reset()
+			d.needDictReset = false
+		}
+		if err := z.readFull(sizes[:2]); err != nil {
+			return nil, false, err
+		}
+		n := int(sizes[0])<<8 | int(sizes[1]) + 1
+		data := make([]byte, n)
+		if err := z.readFull(data); err != nil {
+			return nil, false, err
+		}
+		for _, b := range data {
+			d.dict.put(b)
+		}
+		return data, false, nil
+	}
+
+	if err := z.readFull(sizes[:]); err != nil {
+		return nil, false, err
+	}
+	unpacked := int(control&0x1F)<<16 | int(sizes[0])<<8 | int(sizes[1]) + 1
+	packed := int(sizes[2])<<8 | int(sizes[3]) + 1
+
+	switch reset := control >> 5 & 0x03; {
+	case reset == 3:
+		d.dict.reset()
+		d.needDictReset = false
+		fallthrough
+	case reset == 2:
+		props, err := z.readByte()
+		if err != nil {
+			return nil, false, err
+		}
+		if err := d.lzma.setProps(props); err != nil {
+			return nil, false, err
+		}
+		d.needProps = false
+		fallthrough
+	case reset == 1:
+		if d.needProps {
+			return nil, false, errCorrupt
+		}
+		d.lzma.resetState()
+	default:
+		if d.needProps {
+			return nil, false, errCorrupt
+		}
+	}
+	if d.needDictReset {
+		return nil, false, errCorrupt
+	}
+
+	if cap(d.buf) < packed {
+		d.buf = make([]byte, packed)
+	}
+	data := d.buf[:packed]
+	if err := z.readFull(data); err != nil {
+		return nil, false, err
+	}
+	out, err := d.lzma.decode(data, unpacked, &d.dict, make([]byte, 0, unpacked))
+	return out, false, err
+}
@@ -0,0 +1,340 @@
+// Package xz implements a decompressor for the .xz file format, as written by
+// the xz utility, with the LZMA2 filter it uses by default. It exists so that
+// old backups compressed with xz can be read without depending on packages
+// outside the standard library.
+package xz
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+// The magic bytes at the start of an xz stream.
+var Magic = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+
+var footerMagic = []byte{'Y', 'Z'}
+
+const (
+	checkNone   = 0x00
+	checkCRC32  = 0x01
+	checkCRC64  = 0x04
+	checkSHA256 = 0x0A
+
+	filterLZMA2 = 0x21
+
+	// The largest dictionary a block may use. The dictionary holds as much
+	// of the output as its size, so this bounds the memory a crafted file
+	// can make the reader use. 64 MiB is what xz -9 uses.
+	maxDictSize = 64 << 20
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// ErrFormat is returned when the input is not valid xz data.
+var ErrFormat = errors.New("xz: invalid format")
+
+type reader struct {
+	r *bufio.Reader
+	// The number of bytes read from r, used for alignment.
+	n int64
+
+	check      byte
+	hash       hash.Hash
+	inBlock    bool
+	blockStart int64
+
+	lzma2 lzma2Decoder
+	out   []byte
+	err   error
+}
+
+// NewReader creates a reader of the decompressed contents of the xz data in r.
+// The stream header is read immediately. Integrity checks are verified at the
+// end of every block; concatenated streams are read one after another.
+func NewReader(r io.Reader) (io.Reader, error) {
+	z := &reader{r: bufio.NewReader(r)}
+	if err := z.streamHeader(); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+func (z *reader) readFull(buf []byte) error {
+	n, err := io.ReadFull(z.r, buf)
+	z.n += int64(n)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (z *reader) readByte() (byte, error) {
+	b, err := z.r.ReadByte()
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		z.n++
+	}
+	return b, err
+}
+
+// Read a variable length integer: 7 bits per byte, least significant first.
+func (z *reader) varint() (uint64, error) {
+	var v uint64
+	for i := uint(0); i < 9; i++ {
+		b, err := z.readByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			if b == 0 && i > 0 {
+				return 0, ErrFormat
+			}
+			return v, nil
+		}
+	}
+	return 0, ErrFormat
+}
+
+// Skip the zero padding up to a multiple of four bytes.
+func (z *reader) pad(start int64) error {
+	for (z.n-start)%4 != 0 {
+		b, err := z.readByte()
+		if err != nil {
+			return err
+		}
+		if b != 0 {
+			return ErrFormat
+		}
+	}
+	return nil
+}
+
+func (z *reader) streamHeader() error {
+	var header [12]byte
+	if err := z.readFull(header[:]); err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:6], Magic) {
+		return ErrFormat
+	}
+	if crc32.ChecksumIEEE(header[6:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return ErrFormat
+	}
+	if header[6] != 0 {
+		return ErrFormat
+	}
+	z.check = header[7]
+	switch z.check {
+	case checkNone:
+		z.hash = nil
+	case checkCRC32:
+		z.hash = crc32.NewIEEE()
+	case checkCRC64:
+		z.hash = crc64.New(crc64Table)
+	case checkSHA256:
+		z.hash = sha256.New()
+	default:
+		return fmt.Errorf("xz: unsupported check type %d", z.check)
+	}
+	return nil
+}
+
+// Read a block header, or the index and stream footer that follow the last
+// block. Returns io.EOF after the last stream.
+func (z *reader) nextBlock() error {
+	start := z.n
+	size, err := z.readByte()
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		if err := z.index(start); err != nil {
+			return err
+		}
+		return z.nextStream()
+	}
+
+	header := make([]byte, (int(size)+1)*4)
+	header[0] = size
+	if err := z.readFull(header[1:]); err != nil {
+		return err
+	}
+	n := len(header) - 4
+	if crc32.ChecksumIEEE(header[:n]) != binary.LittleEndian.Uint32(header[n:]) {
+		return ErrFormat
+	}
+	flags := header[1]
+	if flags&0x3C != 0 {
+		return ErrFormat
+	}
+	hr := &byteReader{buf: header[2:n]}
+	if flags&0x40 != 0 {
+		if _, err := hr.varint(); err != nil {
+			return err
+		}
+	}
+	if flags&0x80 != 0 {
+		if _, err := hr.varint(); err != nil {
+			return err
+		}
+	}
+	if flags&0x03 != 0 {
+		return errors.New("xz: only the LZMA2 filter is supported")
+	}
+	id, err := hr.varint()
+	if err != nil {
+		return err
+	}
+	propsSize, err := hr.varint()
+	if err != nil {
+		return err
+	}
+	if id != filterLZMA2 || propsSize != 1 || len(hr.buf) < 1 {
+		return errors.New("xz: only the LZMA2 filter is supported")
+	}
+	dictSize, err := lzma2DictSize(hr.buf[0])
+	if err != nil {
+		return err
+	}
+	if dictSize > maxDictSize {
+		return fmt.Errorf("xz: dictionary of %d bytes is larger than the limit of %d", dictSize, maxDictSize)
+	}
+	for _, b := range hr.buf[1:] {
+		if b != 0 {
+			return ErrFormat
+		}
+	}
+
+	z.lzma2.reset(dictSize)
+	z.inBlock = true
+	z.blockStart = z.n
+	if z.hash != nil {
+		z.hash.Reset()
+	}
+	return nil
+}
+
+// Finish a block: skip its padding and verify its check.
+func (z *reader) endBlock() error {
+	z.inBlock = false
+	if err := z.pad(z.blockStart); err != nil {
+		return err
+	}
+	var sum []byte
+	switch z.check {
+	case checkCRC32, checkCRC64:
+		// The sums are big endian; xz stores them little endian.
+		sum = z.hash.Sum(nil)
+		for i := 0; i < len(sum)/2; i++ {
+			sum[i], sum[len(sum)-1-i] = sum[len(sum)-1-i], sum[i]
+		}
+	case checkSHA256:
+		sum = z.hash.Sum(nil)
+	}
+	stored := make([]byte, len(sum))
+	if err := z.readFull(stored); err != nil {
+		return err
+	}
+	if !bytes.Equal(sum, stored) {
+		return errors.New("xz: checksum mismatch")
+	}
+	return nil
+}
+
+// Skip the index; the blocks have already been verified by their checks.
+func (z *reader) index(start int64) error {
+	records, err := z.varint()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < 2*records; i++ {
+		if _, err := z.varint(); err != nil {
+			return err
+		}
+	}
+	if err := z.pad(start); err != nil {
+		return err
+	}
+	var crc [4]byte
+	return z.readFull(crc[:])
+}
+
+// Read the stream footer, then any stream padding and the next stream.
+func (z *reader) nextStream() error {
+	var footer [12]byte
+	if err := z.readFull(footer[:]); err != nil {
+		return err
+	}
+	if !bytes.Equal(footer[10:], footerMagic) {
+		return ErrFormat
+	}
+	for {
+		b, err := z.r.Peek(4)
+		if err == io.EOF && len(b) == 0 {
+			return io.EOF
+		}
+		if err != nil && len(b) < 4 {
+			return ErrFormat
+		}
+		if !bytes.Equal(b, []byte{0, 0, 0, 0}) {
+			break
+		}
+		z.readFull(make([]byte, 4))
+	}
+	return z.streamHeader()
+}
+
+func (z *reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		if !z.inBlock {
+			z.err = z.nextBlock()
+			continue
+		}
+		out, done, err := z.lzma2.chunk(z)
+		if err != nil {
+			z.err = err
+			continue
+		}
+		if z.hash != nil {
+			z.hash.Write(out)
+		}
+		z.out = out
+		if done {
+			z.err = z.endBlock()
+		}
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+type byteReader struct {
+	buf []byte
+}
+
+func (r *byteReader) varint() (uint64, error) {
+	var v uint64
+	for i := uint(0); i < 9 && i < uint(len(r.buf)); i++ {
+		b := r.buf[i]
+		v |= uint64(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			r.buf = r.buf[i+1:]
+			return v, nil
+		}
+	}
+	return 0, ErrFormat
+}
@@ -0,0 +1,120 @@
+package xz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func decompress(t *testing.T, name string) []byte {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	return data
+}
+
+func TestDecompress(t *testing.T) {
+	text := readFile(t, "text.txt")
+	random := readFile(t, "random.bin")
+	for _, test := range []struct {
+		name string
+		want []byte
+	}{
+		{"text.xz", text},
+		{"text-crc32.xz", text},
+		{"text-sha256.xz", text},
+		{"props.xz", text},
+		// A small dictionary that wraps, in several blocks.
+		{"blocks.xz", text},
+		// Incompressible data is stored in uncompressed chunks.
+		{"random.xz", random},
+		{"multi.xz", append(append([]byte{}, text...), random...)},
+	} {
+		if got := decompress(t, test.name); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got %d bytes, want %d", test.name, len(got), len(test.want))
+		}
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	data := readFile(t, "text.xz")
+	if _, err := NewReader(bytes.NewReader(data[1:])); err != ErrFormat {
+		t.Errorf("Bad magic: got %v, want %v", err, ErrFormat)
+	}
+
+	data[len(data)/2] ^= 0x55
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Error("Corrupt data was decompressed without error")
+	}
+}
+
+// A block may not make the reader keep a larger dictionary than the limit.
+func TestDictSizeLimit(t *testing.T) {
+	data := readFile(t, "text.xz")
+	// The block header after the stream header: its size, flags, sizes, the
+	// LZMA2 filter with its dictionary size, padding and CRC32.
+	header := data[12 : 12+(int(data[12])+1)*4]
+	i := bytes.Index(header, []byte{filterLZMA2, 0x01})
+	if i < 0 {
+		t.Fatalf("No LZMA2 filter in block header % x", header)
+	}
+	header[i+2] = 40
+	n := len(header) - 4
+	binary.LittleEndian.PutUint32(header[n:], crc32.ChecksumIEEE(header[:n]))
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("Got %v decompressing a block with a 2 GiB dictionary", err)
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.xz"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		io.Copy(ioutil.Discard, io.LimitReader(r, 1<<20))
+	})
+}
@@ -0,0 +1,171 @@
+package phonetic
+
+import (
+	"strings"
+)
+
+// A rule of Daitch-Mokotoff Soundex: the codes of a sequence of letters at
+// the start of a name, before a vowel and anywhere else. A code with
+// alternatives, such as "5|4" for c, gives a key for each.
+type dmRule struct {
+	letters                   string
+	start, beforeVowel, other string
+}
+
+// The rules of Daitch-Mokotoff Soundex, from the table of JewishGen. Vowels
+// are only coded at the start of a name.
+var dmRules = []dmRule{
+	{"ai", "0", "1", ""}, {"aj", "0", "1", ""}, {"ay", "0", "1", ""},
+	{"au", "0", "7", ""},
+	{"a", "0", "", ""},
+	{"b", "7", "7", "7"},
+	{"chs", "5", "54", "54"},
+	{"ch", "5|4", "5|4", "5|4"},
+	{"ck", "5|45", "5|45", "5|45"},
+	{"csz", "4", "4", "4"}, {"czs", "4", "4", "4"},
+	{"cz", "4", "4", "4"}, {"cs", "4", "4", "4"},
+	{"c", "5|4", "5|4", "5|4"},
+	{"drz", "4", "4", "4"}, {"drs", "4", "4", "4"},
+	{"dsh", "4", "4", "4"}, {"dsz", "4", "4", "4"}, {"ds", "4", "4", "4"},
+	{"dzh", "4", "4", "4"}, {"dzs", "4", "4", "4"}, {"dz", "4", "4", "4"},
+	{"dt", "3", "3", "3"},
+	{"d", "3", "3", "3"},
+	{"ei", "0", "1", ""}, {"ej", "0", "1", ""}, {"ey", "0", "1", ""},
+	{"eu", "1", "1", ""},
+	{"e", "0", "", ""},
+	{"fb", "7", "7", "7"},
+	{"f", "7", "7", "7"},
+	{"g", "5", "5", "5"},
+	{"h", "5", "5", ""},
+	{"ia", "1", "", ""}, {"ie", "1", "", ""}, {"io", "1", "", ""}, {"iu", "1", "", ""},
+	{"i", "0", "", ""},
+	{"j", "1|4", "|4", "|4"},
+	{"ks", "5", "54", "54"},
+	{"kh", "5", "5", "5"},
+	{"k", "5", "5", "5"},
+	{"l", "8", "8", "8"},
+	{"mn", "66", "66", "66"},
+	{"m", "6", "6", "6"},
+	{"nm", "66", "66", "66"},
+	{"n", "6", "6", "6"},
+	{"oi", "0", "1", ""}, {"oj", "0", "1", ""}, {"oy", "0", "1", ""},
+	{"o", "0", "", ""},
+	{"pf", "7", "7", "7"}, {"ph", "7", "7", "7"},
+	{"p", "7", "7", "7"},
+	{"q", "5", "5", "5"},
+	{"rz", "94|4", "94|4", "94|4"}, {"rs", "94|4", "94|4", "94|4"},
+	{"r", "9", "9", "9"},
+	{"schtsch", "2", "4", "4"}, {"schtsh", "2", "4", "4"}, {"schtch", "2", "4", "4"},
+	{"shtch", "2", "4", "4"}, {"shtsh", "2", "4", "4"}, {"shch", "2", "4", "4"},
+	{"stsch", "2", "4", "4"}, {"strz", "2", "4", "4"}, {"strs", "2", "4", "4"},
+	{"stch", "2", "4", "4"}, {"stsh", "2", "4", "4"},
+	{"szcz", "2", "4", "4"}, {"szcs", "2", "4", "4"},
+	{"scht", "2", "43", "43"}, {"schd", "2", "43", "43"},
+	{"sch", "4", "4", "4"},
+	{"sht", "2", "43", "43"}, {"szt", "2", "43", "43"}, {"shd", "2", "43", "43"},
+	{"szd", "2", "43", "43"},
+	{"sc", "2", "4", "4"},
+	{"st", "2", "43", "43"}, {"sd", "2", "43", "43"},
+	{"sh", "4", "4", "4"}, {"sz", "4", "4", "4"},
+	{"s", "4", "4", "4"},
+	{"ttsch", "4", "4", "4"}, {"ttsz", "4", "4", "4"}, {"ttch", "4", "4", "4"},
+	{"tsch", "4", "4", "4"},
+	{"trz", "4", "4", "4"}, {"trs", "4", "4", "4"},
+	{"tch", "4", "4", "4"}, {"tsh", "4", "4", "4"}, {"tts", "4", "4", "4"},
+	{"ttz", "4", "4", "4"}, {"tzs", "4", "4", "4"}, {"tsz", "4", "4", "4"},
+	{"th", "3", "3", "3"},
+	{"ts", "4", "4", "4"}, {"tc", "4", "4", "4"}, {"tz", "4", "4", "4"},
+	{"t", "3", "3", "3"},
+	{"ui", "0", "1", ""}, {"uj", "0", "1", ""}, {"uy", "0", "1", ""},
+	{"ue", "0", "", ""},
+	{"u", "0", "", ""},
+	{"v", "7", "7", "7"},
+	{"w", "7", "7", "7"},
+	{"x", "5", "54", "54"},
+	{"y", "1", "", ""},
+	{"zhdzh", "2", "4", "4"}, {"zdzh", "2", "4", "4"}, {"zdz", "2", "4", "4"},
+	{"zhd", "2", "43", "43"}, {"zd", "2", "43", "43"},
+	{"zsch", "4", "4", "4"}, {"zsh", "4", "4", "4"},
+	{"zh", "4", "4", "4"}, {"zs", "4", "4", "4"},
+	{"z", "4", "4", "4"},
+}
+
+// The rules by their first letter, longest first.
+var dmIndex = func() map[byte][]dmRule {
+	index := map[byte][]dmRule{}
+	for _, r := range dmRules {
+		index[r.letters[0]] = append(index[r.letters[0]], r)
+	}
+	for _, rules := range index {
+		for i := 1; i < len(rules); i++ {
+			for j := i; j > 0 && len(rules[j].letters) > len(rules[j-1].letters); j-- {
+				rules[j], rules[j-1] = rules[j-1], rules[j]
+			}
+		}
+	}
+	return index
+}()
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
+
+// A key being built, with the code of the letters before.
+type dmBranch struct {
+	key  string
+	last string
+}
+
+// Get the Daitch-Mokotoff Soundex keys of s, e.g. [739400 734000] for
+// Peters. Names whose letters may be pronounced in more than one way
+// have several keys. Keys are six digits long.
+func DaitchMokotoff(s string) []string {
+	s = letters(s)
+	branches := []dmBranch{{}}
+	var lastLetter byte
+	for i := 0; i < len(s); {
+		var rule dmRule
+		for _, r := range dmIndex[s[i]] {
+			if strings.HasPrefix(s[i:], r.letters) {
+				rule = r
+				break
+			}
+		}
+		next := i + len(rule.letters)
+		code := rule.other
+		switch {
+		case i == 0:
+			code = rule.start
+		case next < len(s) && isVowel(s[next]):
+			code = rule.beforeVowel
+		}
+		// The m and n of mn and nm are both coded even when they are coded
+		// separately.
+		force := (lastLetter == 'm' && s[i] == 'n') || (lastLetter == 'n' && s[i] == 'm')
+		var grown []dmBranch
+		for _, b := range branches {
+			for _, alt := range strings.Split(code, "|") {
+				nb := b
+				if alt != "" && (force || alt != nb.last) {
+					nb.key += alt
+				}
+				nb.last = alt
+				grown = append(grown, nb)
+			}
+		}
+		branches = grown
+		lastLetter = s[next-1]
+		i = next
+	}
+
+	var keys []string
+	seen := map[string]bool{}
+	for _, b := range branches {
+		key := (b.key + "000000")[:6]
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
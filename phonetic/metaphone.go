@@ -0,0 +1,558 @@
+package phonetic
+
+import (
+	"strings"
+)
+
+// The length of Double Metaphone keys.
+const metaphoneLength = 4
+
+// The state of the Double Metaphone encoding of a name.
+type metaphone struct {
+	s                  string
+	primary, alternate []byte
+}
+
+func (m *metaphone) add(primary, alternate string) {
+	m.primary = append(m.primary, primary...)
+	m.alternate = append(m.alternate, alternate...)
+}
+
+func (m *metaphone) both(code string) {
+	m.add(code, code)
+}
+
+func (m *metaphone) done() bool {
+	return len(m.primary) >= metaphoneLength && len(m.alternate) >= metaphoneLength
+}
+
+// Get the character at i, or 0 outside of the name.
+func (m *metaphone) at(i int) byte {
+	if i < 0 || i >= len(m.s) {
+		return 0
+	}
+	return m.s[i]
+}
+
+// Whether one of subs starts at i.
+func (m *metaphone) matches(i int, subs ...string) bool {
+	if i < 0 {
+		return false
+	}
+	for _, sub := range subs {
+		if i+len(sub) <= len(m.s) && m.s[i:i+len(sub)] == sub {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *metaphone) vowel(i int) bool {
+	return i >= 0 && i < len(m.s) && strings.IndexByte("AEIOUY", m.s[i]) >= 0
+}
+
+// Whether the name is likely Slavo-Germanic.
+func (m *metaphone) slavoGermanic() bool {
+	return strings.ContainsAny(m.s, "WK") || strings.Contains(m.s, "CZ") ||
+		strings.Contains(m.s, "WITZ")
+}
+
+// Whether the name is likely Germanic, for the CH, G and SCH rules.
+func (m *metaphone) germanic() bool {
+	return m.matches(0, "VAN ", "VON ") || m.matches(0, "SCH")
+}
+
+// Get the Double Metaphone keys of s, e.g. SM0 and XMT for Smith. The
+// alternate key is the same as the primary one unless s may be pronounced in
+// another way. Keys have at most four letters.
+func DoubleMetaphone(s string) (primary, alternate string) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return "", ""
+	}
+	// Keep Ç and Ñ, which have rules of their own, and drop other accents.
+	var b []byte
+	for _, r := range s {
+		switch r {
+		case 'Ç':
+			b = append(b, 'c')
+		case 'Ñ':
+			b = append(b, 'n')
+		default:
+			if r < 0x80 {
+				b = append(b, byte(r))
+			} else if a := accents[[]rune(strings.ToLower(string(r)))[0]]; a != "" {
+				b = append(b, strings.ToUpper(a)...)
+			}
+		}
+	}
+	m := &metaphone{s: string(b)}
+
+	i := 0
+	if m.matches(0, "GN", "KN", "PN", "WR", "PS") {
+		i = 1
+	}
+	if m.at(0) == 'X' {
+		m.both("S")
+		i = 1
+	}
+	for !m.done() && i < len(m.s) {
+		i = m.next(i)
+	}
+
+	primary, alternate = string(m.primary), string(m.alternate)
+	if len(primary) > metaphoneLength {
+		primary = primary[:metaphoneLength]
+	}
+	if len(alternate) > metaphoneLength {
+		alternate = alternate[:metaphoneLength]
+	}
+	return primary, alternate
+}
+
+// Encode the letters at i and get the index of the letters after them.
+func (m *metaphone) next(i int) int {
+	switch c := m.at(i); c {
+	case 'A', 'E', 'I', 'O', 'U', 'Y':
+		if i == 0 {
+			m.both("A")
+		}
+		return i + 1
+	case 'B':
+		m.both("P")
+		if m.at(i+1) == 'B' {
+			return i + 2
+		}
+		return i + 1
+	case 'c':
+		m.both("S")
+		return i + 1
+	case 'C':
+		return m.c(i)
+	case 'D':
+		switch {
+		case m.matches(i, "DG"):
+			if m.matches(i+2, "I", "E", "Y") {
+				m.both("J")
+				return i + 3
+			}
+			m.both("TK")
+			return i + 2
+		case m.matches(i, "DT", "DD"):
+			m.both("T")
+			return i + 2
+		}
+		m.both("T")
+		return i + 1
+	case 'F', 'K', 'Q', 'V':
+		code := map[byte]string{'F': "F", 'K': "K", 'Q': "K", 'V': "F"}[c]
+		m.both(code)
+		if m.at(i+1) == c {
+			return i + 2
+		}
+		return i + 1
+	case 'G':
+		return m.g(i)
+	case 'H':
+		if (i == 0 || m.vowel(i-1)) && m.vowel(i+1) {
+			m.both("H")
+			return i + 2
+		}
+		return i + 1
+	case 'J':
+		return m.j(i)
+	case 'L':
+		if m.at(i+1) == 'L' {
+			if m.conditionL0(i) {
+				m.add("L", "")
+			} else {
+				m.both("L")
+			}
+			return i + 2
+		}
+		m.both("L")
+		return i + 1
+	case 'M':
+		m.both("M")
+		if m.conditionM0(i) {
+			return i + 2
+		}
+		return i + 1
+	case 'N':
+		m.both("N")
+		if m.at(i+1) == 'N' {
+			return i + 2
+		}
+		return i + 1
+	case 'n':
+		m.both("N")
+		return i + 1
+	case 'P':
+		if m.at(i+1) == 'H' {
+			m.both("F")
+			return i + 2
+		}
+		m.both("P")
+		if m.matches(i+1, "P", "B") {
+			return i + 2
+		}
+		return i + 1
+	case 'R':
+		if i == len(m.s)-1 && !m.slavoGermanic() && m.matches(i-2, "IE") &&
+			!m.matches(i-4, "ME", "MA") {
+			m.add("", "R")
+		} else {
+			m.both("R")
+		}
+		if m.at(i+1) == 'R' {
+			return i + 2
+		}
+		return i + 1
+	case 'S':
+		return m.sRule(i)
+	case 'T':
+		return m.t(i)
+	case 'W':
+		return m.w(i)
+	case 'X':
+		if !(i == len(m.s)-1 && (m.matches(i-3, "IAU", "EAU") || m.matches(i-2, "AU", "OU"))) {
+			m.both("KS")
+		}
+		if m.matches(i+1, "C", "X") {
+			return i + 2
+		}
+		return i + 1
+	case 'Z':
+		if m.at(i+1) == 'H' {
+			m.both("J")
+			return i + 2
+		}
+		if m.matches(i+1, "ZO", "ZI", "ZA") || (m.slavoGermanic() && i > 0 && m.at(i-1) != 'T') {
+			m.add("S", "TS")
+		} else {
+			m.both("S")
+		}
+		if m.at(i+1) == 'Z' {
+			return i + 2
+		}
+		return i + 1
+	}
+	return i + 1
+}
+
+func (m *metaphone) c(i int) int {
+	switch {
+	case m.conditionC0(i):
+		m.both("K")
+		return i + 2
+	case i == 0 && m.matches(i, "CAESAR"):
+		m.both("S")
+		return i + 2
+	case m.matches(i, "CH"):
+		return m.ch(i)
+	case m.matches(i, "CZ") && !m.matches(i-2, "WICZ"):
+		m.add("S", "X")
+		return i + 2
+	case m.matches(i+1, "CIA"):
+		m.both("X")
+		return i + 3
+	case m.matches(i, "CC") && !(i == 1 && m.at(0) == 'M'):
+		if m.matches(i+2, "I", "E", "H") && !m.matches(i+2, "HU") {
+			if (i == 1 && m.at(0) == 'A') || m.matches(i-1, "UCCEE", "UCCES") {
+				m.both("KS")
+			} else {
+				m.both("X")
+			}
+			return i + 3
+		}
+		m.both("K")
+		return i + 2
+	case m.matches(i, "CK", "CG", "CQ"):
+		m.both("K")
+		return i + 2
+	case m.matches(i, "CI", "CE", "CY"):
+		if m.matches(i, "CIO", "CIE", "CIA") {
+			m.add("S", "X")
+		} else {
+			m.both("S")
+		}
+		return i + 2
+	}
+	m.both("K")
+	switch {
+	case m.matches(i+1, " C", " Q", " G"):
+		return i + 3
+	case m.matches(i+1, "C", "K", "Q") && !m.matches(i+1, "CE", "CI"):
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) ch(i int) int {
+	switch {
+	case i > 0 && m.matches(i, "CHAE"):
+		m.add("K", "X")
+	case m.conditionCH0(i), m.conditionCH1(i):
+		m.both("K")
+	case i > 0:
+		if m.matches(0, "MC") {
+			m.both("K")
+		} else {
+			m.add("X", "K")
+		}
+	default:
+		m.both("X")
+	}
+	return i + 2
+}
+
+func (m *metaphone) g(i int) int {
+	switch {
+	case m.at(i+1) == 'H':
+		return m.gh(i)
+	case m.at(i+1) == 'N':
+		switch {
+		case i == 1 && m.vowel(0) && !m.slavoGermanic():
+			m.add("KN", "N")
+		case !m.matches(i+2, "EY") && m.at(i+1) != 'Y' && !m.slavoGermanic():
+			m.add("N", "KN")
+		default:
+			m.both("KN")
+		}
+		return i + 2
+	case m.matches(i+1, "LI") && !m.slavoGermanic():
+		m.add("KL", "L")
+		return i + 2
+	case i == 0 && (m.at(i+1) == 'Y' ||
+		m.matches(i+1, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		m.add("K", "J")
+		return i + 2
+	case (m.matches(i+1, "ER") || m.at(i+1) == 'Y') &&
+		!m.matches(0, "DANGER", "RANGER", "MANGER") &&
+		!m.matches(i-1, "E", "I") && !m.matches(i-1, "RGY", "OGY"):
+		m.add("K", "J")
+		return i + 2
+	case m.matches(i+1, "E", "I", "Y") || m.matches(i-1, "AGGI", "OGGI"):
+		switch {
+		case m.germanic() || m.matches(i+1, "ET"):
+			m.both("K")
+		case m.matches(i+1, "IER"):
+			m.both("J")
+		default:
+			m.add("J", "K")
+		}
+		return i + 2
+	}
+	m.both("K")
+	if m.at(i+1) == 'G' {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) gh(i int) int {
+	switch {
+	case i > 0 && !m.vowel(i-1):
+		m.both("K")
+	case i == 0:
+		if m.at(i+2) == 'I' {
+			m.both("J")
+		} else {
+			m.both("K")
+		}
+	case (i > 1 && m.matches(i-2, "B", "H", "D")) ||
+		(i > 2 && m.matches(i-3, "B", "H", "D")) ||
+		(i > 3 && m.matches(i-4, "B", "H")):
+		// Silent, as in hugh, bough and broughton.
+	default:
+		if i > 2 && m.at(i-1) == 'U' && m.matches(i-3, "C", "G", "L", "R", "T") {
+			m.both("F")
+		} else if i > 0 && m.at(i-1) != 'I' {
+			m.both("K")
+		}
+	}
+	return i + 2
+}
+
+func (m *metaphone) j(i int) int {
+	if m.matches(i, "JOSE") || m.matches(0, "SAN ") {
+		if (i == 0 && m.at(i+4) == ' ') || len(m.s) == 4 || m.matches(0, "SAN ") {
+			m.both("H")
+		} else {
+			m.add("J", "H")
+		}
+		return i + 1
+	}
+	switch {
+	case i == 0 && !m.matches(i, "JOSE"):
+		m.add("J", "A")
+	case m.vowel(i-1) && !m.slavoGermanic() && (m.at(i+1) == 'A' || m.at(i+1) == 'O'):
+		m.add("J", "H")
+	case i == len(m.s)-1:
+		m.add("J", " ")
+	case !m.matches(i+1, "L", "T", "K", "S", "N", "M", "B", "Z") &&
+		!m.matches(i-1, "S", "K", "L"):
+		m.both("J")
+	}
+	if m.at(i+1) == 'J' {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) sRule(i int) int {
+	switch {
+	case m.matches(i-1, "ISL", "YSL"):
+		return i + 1
+	case i == 0 && m.matches(i, "SUGAR"):
+		m.add("X", "S")
+		return i + 1
+	case m.matches(i, "SH"):
+		if m.matches(i+1, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			m.both("S")
+		} else {
+			m.both("X")
+		}
+		return i + 2
+	case m.matches(i, "SIO", "SIA") || m.matches(i, "SIAN"):
+		if m.slavoGermanic() {
+			m.both("S")
+		} else {
+			m.add("S", "X")
+		}
+		return i + 3
+	case (i == 0 && m.matches(i+1, "M", "N", "L", "W")) || m.matches(i+1, "Z"):
+		m.add("S", "X")
+		if m.matches(i+1, "Z") {
+			return i + 2
+		}
+		return i + 1
+	case m.matches(i, "SC"):
+		return m.sc(i)
+	}
+	if i == len(m.s)-1 && m.matches(i-2, "AI", "OI") {
+		m.add("", "S")
+	} else {
+		m.both("S")
+	}
+	if m.matches(i+1, "S", "Z") {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) sc(i int) int {
+	if m.at(i+2) == 'H' {
+		switch {
+		case m.matches(i+3, "OO", "ER", "EN", "UY", "ED", "EM"):
+			if m.matches(i+3, "ER", "EN") {
+				m.add("X", "SK")
+			} else {
+				m.both("SK")
+			}
+		case i == 0 && !m.vowel(3) && m.at(3) != 'W':
+			m.add("X", "S")
+		default:
+			m.both("X")
+		}
+		return i + 3
+	}
+	if m.matches(i+2, "I", "E", "Y") {
+		m.both("S")
+	} else {
+		m.both("SK")
+	}
+	return i + 3
+}
+
+func (m *metaphone) t(i int) int {
+	switch {
+	case m.matches(i, "TION"), m.matches(i, "TIA", "TCH"):
+		m.both("X")
+		return i + 3
+	case m.matches(i, "TH", "TTH"):
+		if m.matches(i+2, "OM", "AM") || m.germanic() {
+			m.both("T")
+		} else {
+			m.add("0", "T")
+		}
+		return i + 2
+	}
+	m.both("T")
+	if m.matches(i+1, "T", "D") {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) w(i int) int {
+	if m.matches(i, "WR") {
+		m.both("R")
+		return i + 2
+	}
+	if i == 0 && (m.vowel(i+1) || m.matches(i, "WH")) {
+		if m.vowel(i + 1) {
+			m.add("A", "F")
+		} else {
+			m.both("A")
+		}
+		return i + 1
+	}
+	if (i == len(m.s)-1 && m.vowel(i-1)) || m.matches(i-1, "EWSKI", "EWSKY", "OWSKI", "OWSKY") ||
+		m.matches(0, "SCH") {
+		m.add("", "F")
+		return i + 1
+	}
+	if m.matches(i, "WICZ", "WITZ") {
+		m.add("TS", "FX")
+		return i + 4
+	}
+	return i + 1
+}
+
+// Whether CH or C is a K, as in chianti or bacchus.
+func (m *metaphone) conditionC0(i int) bool {
+	if m.matches(i, "CHIA") {
+		return true
+	}
+	if i <= 1 || m.vowel(i-2) || !m.matches(i-1, "ACH") {
+		return false
+	}
+	return m.at(i+2) != 'I' && m.at(i+2) != 'E' || m.matches(i-2, "BACHER", "MACHER")
+}
+
+// Whether CH at the start is a K, as in charisma or chorus.
+func (m *metaphone) conditionCH0(i int) bool {
+	if i != 0 {
+		return false
+	}
+	if !m.matches(i+1, "HARAC", "HARIS") && !m.matches(i+1, "HOR", "HYM", "HIA", "HEM") {
+		return false
+	}
+	return !m.matches(0, "CHORE")
+}
+
+// Whether CH is a K in Germanic and Greek words, as in orchestra or school.
+func (m *metaphone) conditionCH1(i int) bool {
+	return m.germanic() || m.matches(i-2, "ORCHES", "ARCHIT", "ORCHID") ||
+		m.matches(i+2, "T", "S") ||
+		((m.matches(i-1, "A", "O", "U", "E") || i == 0) &&
+			(m.matches(i+2, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") || i+1 == len(m.s)-1))
+}
+
+// Whether LL is silent in the alternate key, as in cabrillo or gallegos.
+func (m *metaphone) conditionL0(i int) bool {
+	if i == len(m.s)-3 && m.matches(i-1, "ILLO", "ILLA", "ALLE") {
+		return true
+	}
+	return (m.matches(len(m.s)-2, "AS", "OS") || m.matches(len(m.s)-1, "A", "O")) &&
+		m.matches(i-1, "ALLE")
+}
+
+// Whether M is followed by a silent B or another M, as in dumb or thumb.
+func (m *metaphone) conditionM0(i int) bool {
+	if m.at(i+1) == 'M' {
+		return true
+	}
+	return m.matches(i-1, "UMB") && (i+1 == len(m.s)-1 || m.matches(i+2, "ER"))
+}
@@ -0,0 +1,76 @@
+// Package phonetic computes keys of names that sound alike: Soundex, as
+// Gramps uses for its soundex filters, Daitch-Mokotoff Soundex, which suits
+// Slavic and Yiddish surnames, and Double Metaphone. Names sound alike when
+// they have a key in common.
+package phonetic
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The letters accented letters are reduced to, from the decompositions of
+// Unicode.
+var accents = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g",
+	'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j",
+	'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r",
+	'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w",
+	'ý': "y", 'ÿ': "y", 'ŷ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'æ': "ae", 'œ': "oe", 'ß': "ss", 'þ': "th",
+}
+
+// Get the lower case letters a to z of s, with accents removed and anything
+// else left out.
+func letters(s string) string {
+	var b []byte
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z':
+			b = append(b, byte(r))
+		case accents[r] != "":
+			b = append(b, accents[r]...)
+		}
+	}
+	return string(b)
+}
+
+// The Soundex digits of the letters a to z. Vowels and y are 0; h and w are
+// left out.
+const soundexDigits = "01230120022455012623010202"
+
+// Get the Soundex key of s, e.g. R163 for Robert and Rupert, as Gramps
+// computes it: Z000 if s has no letters.
+func Soundex(s string) string {
+	s = letters(s)
+	if s == "" {
+		return "Z000"
+	}
+	key := []byte{byte(unicode.ToUpper(rune(s[0])))}
+	prev := byte(0)
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'h' || s[i] == 'w' {
+			continue
+		}
+		digit := soundexDigits[s[i]-'a']
+		if i > 0 && digit != prev && digit != '0' {
+			key = append(key, digit)
+		}
+		prev = digit
+	}
+	return string(append(key, "000"...)[:4])
+}
@@ -0,0 +1,70 @@
+package phonetic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSoundex(t *testing.T) {
+	for _, test := range []struct{ name, want string }{
+		{"Robert", "R163"},
+		{"Rupert", "R163"},
+		{"Tymczak", "T522"},
+		{"Pfister", "P236"},
+		{"Ashcraft", "A261"},
+		{"Lee", "L000"},
+		{"Müller", "M460"},
+		{"Garçon", "G625"},
+		{"", "Z000"},
+		{"123", "Z000"},
+	} {
+		if got := Soundex(test.name); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDaitchMokotoff(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want []string
+	}{
+		{"Moskowitz", []string{"645740"}},
+		{"Peters", []string{"739400", "734000"}},
+		{"Auerbach", []string{"097500", "097400"}},
+		{"Schwarzenegger", []string{"479465", "474659"}},
+		{"Kleinman", []string{"586660"}},
+		{"Lewinsky", []string{"876450"}},
+		{"Jackson", []string{"154600", "145460", "454600", "445460"}},
+		{"", []string{"000000"}},
+	} {
+		if got := DaitchMokotoff(test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	for _, test := range []struct{ name, primary, alternate string }{
+		{"Smith", "SM0", "XMT"},
+		{"Schmidt", "XMT", "SMT"},
+		{"Thompson", "TMPS", "TMPS"},
+		{"Jose", "HS", "HS"},
+		{"Xavier", "SF", "SFR"},
+		{"Knight", "NT", "NT"},
+		{"Ghislane", "JLN", "JLN"},
+		{"Gallegos", "KLKS", "KKS"},
+		{"Wright", "RT", "RT"},
+		{"Czerny", "SRN", "XRN"},
+		{"Caesar", "SSR", "SSR"},
+		{"Zhao", "J", "J"},
+		{"Gonçalves", "KNSL", "KNSL"},
+		{"", "", ""},
+	} {
+		primary, alternate := DoubleMetaphone(test.name)
+		if primary != test.primary || alternate != test.alternate {
+			t.Errorf("%q: got %s %s, want %s %s", test.name, primary, alternate,
+				test.primary, test.alternate)
+		}
+	}
+}
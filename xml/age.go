@@ -0,0 +1,396 @@
+package xml
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The event types Gramps falls back to when a person has no birth or death
+// event, in order of preference.
+var (
+	birthFallbacks = []string{"Christening", "Baptism"}
+	deathFallbacks = []string{"Burial", "Cremation", "Cause Of Death"}
+)
+
+// Whether ref is to an event the person it belongs to is the main person of.
+func isPrimary(ref *EventRef) bool {
+	return ref.Role == "Primary" || ref.Role == ""
+}
+
+// Get the first event of type typ that p has the role Primary in, or nil.
+func (p *Person) primaryEvent(db *Database, typ string) *Event {
+	for _, ref := range p.EventRefs {
+		if !isPrimary(ref) {
+			continue
+		}
+		if e := db.EventByHandle(ref.HLink); e != nil && e.Type != nil && *e.Type == typ {
+			return e
+		}
+	}
+	return nil
+}
+
+// Get the birth of p: the first Birth event p has the role Primary in, or
+// nil if there is none.
+func (p *Person) Birth(db *Database) *Event {
+	return p.primaryEvent(db, "Birth")
+}
+
+// Get the death of p: the first Death event p has the role Primary in, or
+// nil if there is none.
+func (p *Person) Death(db *Database) *Event {
+	return p.primaryEvent(db, "Death")
+}
+
+// Get the birth of p or, if there is none, a christening or baptism. Returns
+// whether the event is a fallback.
+func (p *Person) BirthOrFallback(db *Database) (*Event, bool) {
+	if e := p.Birth(db); e != nil {
+		return e, false
+	}
+	for _, typ := range birthFallbacks {
+		if e := p.primaryEvent(db, typ); e != nil {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Get the death of p or, if there is none, a burial, cremation or cause of
+// death. Returns whether the event is a fallback.
+func (p *Person) DeathOrFallback(db *Database) (*Event, bool) {
+	if e := p.Death(db); e != nil {
+		return e, false
+	}
+	for _, typ := range deathFallbacks {
+		if e := p.primaryEvent(db, typ); e != nil {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Get the date of e if it is one that can be computed with.
+func eventDate(e *Event) (Date, bool) {
+	if e == nil {
+		return Date{}, false
+	}
+	d, err := e.GetDate()
+	if err != nil || d.SortValue() == 0 {
+		return Date{}, false
+	}
+	return d, true
+}
+
+// An Age is the time between two dates.
+type Age struct {
+	Years, Months, Days int
+	// Whether the age is not exact, because a date is not a regular date of a
+	// known day or a fallback event was used.
+	Approximate bool
+}
+
+func (a Age) String() string {
+	var parts []string
+	for _, part := range []struct {
+		n    int
+		unit string
+	}{{a.Years, "year"}, {a.Months, "month"}, {a.Days, "day"}} {
+		switch {
+		case part.n == 1:
+			parts = append(parts, "1 "+part.unit)
+		case part.n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", part.n, part.unit))
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{"0 days"}
+	}
+	s := strings.Join(parts, ", ")
+	if a.Approximate {
+		s = "about " + s
+	}
+	return s
+}
+
+// Get the number of days in a month of the Gregorian calendar.
+func daysInMonth(year, month int) int {
+	next := YMD{year, month + 1, 1}
+	if month == 12 {
+		next = YMD{year + 1, 1, 1}
+		if year == -1 {
+			next.Year = 1
+		}
+	}
+	return Gregorian.JDN(next) - Gregorian.JDN(YMD{year, month, 1})
+}
+
+// Get the age at to of someone born at from, counting from the start of both
+// dates. Parts of the dates that are not known are left out of the age.
+// Returns false if a date is empty or text-only, or to is before from.
+func AgeBetween(from, to Date) (Age, bool) {
+	if from.SortValue() == 0 || to.SortValue() == 0 || to.JDN() < from.JDN() {
+		return Age{}, false
+	}
+	s, e := Gregorian.FromJDN(from.JDN()), Gregorian.FromJDN(to.JDN())
+	a := Age{Years: e.Year - s.Year, Months: e.Month - s.Month}
+	if s.Year < 0 && e.Year > 0 {
+		// There is no year 0.
+		a.Years--
+	}
+	if e.Day < s.Day {
+		a.Months--
+	}
+	if a.Months < 0 {
+		a.Years--
+		a.Months += 12
+	}
+	// The days are counted from the same day of the last month, or its last
+	// day if it is shorter: January 31 to March 1 is 1 month and 1 day.
+	anchor := YMD{s.Year + a.Years, s.Month + a.Months, s.Day}
+	if anchor.Month > 12 {
+		anchor.Year++
+		anchor.Month -= 12
+	}
+	if s.Year < 0 && anchor.Year >= 0 {
+		anchor.Year++
+	}
+	if n := daysInMonth(anchor.Year, anchor.Month); anchor.Day > n {
+		anchor.Day = n
+	}
+	a.Days = to.JDN() - Gregorian.JDN(anchor)
+	for _, d := range []Date{from, to} {
+		if d.Modifier != ModNone || d.Quality != QualityRegular {
+			a.Approximate = true
+		}
+		if d.Start.Day == 0 {
+			a.Days, a.Approximate = 0, true
+		}
+		if d.Start.Month == 0 {
+			a.Months, a.Approximate = 0, true
+		}
+	}
+	return a, true
+}
+
+// Get the age of p at the date of e, from the birth of p or its fallback.
+// Returns false if either date is not known.
+func (p *Person) AgeAt(db *Database, e *Event) (Age, bool) {
+	birth, fallback := p.BirthOrFallback(db)
+	from, ok := eventDate(birth)
+	if !ok {
+		return Age{}, false
+	}
+	to, ok := eventDate(e)
+	if !ok {
+		return Age{}, false
+	}
+	a, ok := AgeBetween(from, to)
+	a.Approximate = a.Approximate || fallback
+	return a, ok
+}
+
+// Get the age of p at death, from the birth and death of p or their
+// fallbacks.
+func (p *Person) AgeAtDeath(db *Database) (Age, bool) {
+	death, fallback := p.DeathOrFallback(db)
+	a, ok := p.AgeAt(db, death)
+	a.Approximate = a.Approximate || fallback
+	return a, ok
+}
+
+// The limits ProbablyAlive uses, in years.
+type AliveOptions struct {
+	// The oldest a person is thought to become.
+	MaxAge int
+	// The most years between the births of siblings.
+	MaxSiblingAgeDiff int
+	// The years between the births of a parent and a child, on average and
+	// at least.
+	AvgGenerationGap, MinGenerationYears int
+}
+
+// The defaults of Gramps.
+var DefaultAliveOptions = AliveOptions{
+	MaxAge:             110,
+	MaxSiblingAgeDiff:  20,
+	AvgGenerationGap:   20,
+	MinGenerationYears: 13,
+}
+
+// How many generations of ancestors and descendants ProbablyAlive looks at.
+const aliveGenerations = 5
+
+// Get the number of days in years.
+func yearDays(years int) int {
+	return years * 36525 / 100
+}
+
+// An estimate of the JDNs of the birth and death of a person.
+type lifespan struct {
+	birth, death int
+}
+
+// Estimate when p lived from the dates of p, like the probably alive rules
+// of Gramps: from the birth and death of p or their fallbacks, then the other
+// events of p. Returns whether p is known to be dead, even without a date.
+func (p *Person) ownLifespan(db *Database, opts AliveOptions) (span lifespan, dead, ok bool) {
+	birth, _ := p.BirthOrFallback(db)
+	death, _ := p.DeathOrFallback(db)
+	dead = death != nil
+	b, hasBirth := eventDate(birth)
+	d, hasDeath := eventDate(death)
+	switch {
+	case hasBirth && hasDeath:
+		return lifespan{b.JDN(), d.JDN()}, true, true
+	case hasBirth:
+		return lifespan{b.JDN(), b.JDN() + yearDays(opts.MaxAge)}, dead, true
+	case hasDeath:
+		return lifespan{d.JDN() - yearDays(opts.MaxAge), d.JDN()}, true, true
+	}
+	// Any other event happened during the life of p.
+	first, last := 0, 0
+	for _, ref := range p.EventRefs {
+		if !isPrimary(ref) {
+			continue
+		}
+		if date, ok := eventDate(db.EventByHandle(ref.HLink)); ok {
+			if first == 0 || date.JDN() < first {
+				first = date.JDN()
+			}
+			if date.JDN() > last {
+				last = date.JDN()
+			}
+		}
+	}
+	if last != 0 {
+		return lifespan{last - yearDays(opts.MaxAge), first + yearDays(opts.MaxAge)}, dead, true
+	}
+	return lifespan{}, dead, false
+}
+
+// Estimate the life of a person from the birth of p, its descendant gen
+// generations down, or for negative gen its ancestor. The person was born
+// about AvgGenerationGap years per generation before a descendant, and at
+// least MinGenerationYears.
+func (p *Person) relativeLifespan(db *Database, gen int, visited map[*Person]bool, opts AliveOptions) (lifespan, bool) {
+	if visited[p] {
+		return lifespan{}, false
+	}
+	visited[p] = true
+	if birth, ok := eventDate(p.firstBirth(db)); ok && gen != 0 {
+		b, maxAge := birth.JDN(), yearDays(opts.MaxAge)
+		avg, least := yearDays(gen*opts.AvgGenerationGap), yearDays(gen*opts.MinGenerationYears)
+		if gen > 0 {
+			return lifespan{b - avg, b - least + maxAge}, true
+		}
+		return lifespan{b - least, b - avg + maxAge}, true
+	}
+	if gen < aliveGenerations && gen >= 0 {
+		for _, f := range p.families(db, p.ParentIns) {
+			for _, ref := range f.ChildRefs {
+				if c := db.PersonByHandle(ref.HLink); c != nil {
+					if span, ok := c.relativeLifespan(db, gen+1, visited, opts); ok {
+						return span, true
+					}
+				}
+			}
+		}
+	}
+	if gen > -aliveGenerations && gen <= 0 {
+		for _, f := range p.families(db, p.ChildOfs) {
+			for _, l := range []*GenericLink{f.Father, f.Mother} {
+				if l == nil {
+					continue
+				}
+				if parent := db.PersonByHandle(l.HLink); parent != nil {
+					if span, ok := parent.relativeLifespan(db, gen-1, visited, opts); ok {
+						return span, true
+					}
+				}
+			}
+		}
+	}
+	return lifespan{}, false
+}
+
+// Get the birth or its fallback of p.
+func (p *Person) firstBirth(db *Database) *Event {
+	e, _ := p.BirthOrFallback(db)
+	return e
+}
+
+// Get the families links refer to.
+func (p *Person) families(db *Database, links []*GenericLink) []*Family {
+	var families []*Family
+	for _, l := range links {
+		if f := db.FamilyByHandle(l.HLink); f != nil {
+			families = append(families, f)
+		}
+	}
+	return families
+}
+
+// Estimate when p lived: from the dates of p, then the births of siblings,
+// then those of descendants and ancestors, and finally those of spouses.
+func (p *Person) estimateLifespan(db *Database, opts AliveOptions) (span lifespan, dead, ok bool) {
+	if span, dead, ok = p.ownLifespan(db, opts); ok {
+		return span, dead, ok
+	}
+	maxAge := yearDays(opts.MaxAge)
+	for _, f := range p.families(db, p.ChildOfs) {
+		for _, ref := range f.ChildRefs {
+			sibling := db.PersonByHandle(ref.HLink)
+			if sibling == nil || sibling == p {
+				continue
+			}
+			if birth, ok := eventDate(sibling.firstBirth(db)); ok {
+				diff := yearDays(opts.MaxSiblingAgeDiff)
+				return lifespan{birth.JDN() - diff, birth.JDN() + diff + maxAge}, dead, true
+			}
+		}
+	}
+	if span, ok := p.relativeLifespan(db, 0, map[*Person]bool{}, opts); ok {
+		return span, dead, true
+	}
+	for _, f := range p.families(db, p.ParentIns) {
+		for _, l := range []*GenericLink{f.Father, f.Mother} {
+			if l == nil || l.HLink == p.Handle {
+				continue
+			}
+			if spouse := db.PersonByHandle(l.HLink); spouse != nil {
+				if birth, ok := eventDate(spouse.firstBirth(db)); ok {
+					diff := yearDays(opts.MaxSiblingAgeDiff)
+					return lifespan{birth.JDN() - diff, birth.JDN() + diff + maxAge}, dead, true
+				}
+			}
+		}
+	}
+	return lifespan{}, dead, false
+}
+
+// Get the JDN of today.
+func today() int {
+	y, m, d := time.Now().Date()
+	return Gregorian.JDN(YMD{y, int(m), d})
+}
+
+// Whether p was probably alive at the date at, or today if at is empty, by
+// the rules of Gramps: p is dead today if there is a death or a fallback for
+// it, even without a date. Otherwise p was alive if at is within the life of
+// p, estimated from the dates of p and of close relatives. With no evidence
+// at all, p is thought to be alive.
+func (p *Person) ProbablyAlive(db *Database, at Date, opts AliveOptions) bool {
+	span, dead, ok := p.estimateLifespan(db, opts)
+	if at.SortValue() == 0 {
+		if dead {
+			return false
+		}
+		return !ok || today() <= span.death
+	}
+	if !ok {
+		return !dead
+	}
+	return span.birth <= at.JDN() && at.JDN() <= span.death
+}
@@ -0,0 +1,186 @@
+package xml
+
+import (
+	"testing"
+)
+
+// Add a person with events of the given types and dates to db.
+func addPerson(t *testing.T, db *Database, events ...string) *Person {
+	p := &Person{}
+	for i := 0; i+1 < len(events); i += 2 {
+		typ := events[i]
+		e := &Event{Type: &typ}
+		e.SetDateString(events[i+1])
+		if err := db.Add(e); err != nil {
+			t.Fatal(err)
+		}
+		p.EventRefs = append(p.EventRefs, &EventRef{GenericLink: GenericLink{HLink: e.Handle},
+			Role: "Primary"})
+	}
+	if err := db.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// Add a family of father and mother, which may be nil, with children to db.
+func addFamily(t *testing.T, db *Database, father, mother *Person, children ...*Person) {
+	f := &Family{}
+	if err := db.Add(f); err != nil {
+		t.Fatal(err)
+	}
+	if father != nil {
+		f.Father = &GenericLink{HLink: father.Handle}
+		father.ParentIns = append(father.ParentIns, &GenericLink{HLink: f.Handle})
+	}
+	if mother != nil {
+		f.Mother = &GenericLink{HLink: mother.Handle}
+		mother.ParentIns = append(mother.ParentIns, &GenericLink{HLink: f.Handle})
+	}
+	for _, c := range children {
+		f.ChildRefs = append(f.ChildRefs, &ChildRef{GenericLink: GenericLink{HLink: c.Handle}})
+		c.ChildOfs = append(c.ChildOfs, &GenericLink{HLink: f.Handle})
+	}
+}
+
+func TestAgeBetween(t *testing.T) {
+	for _, test := range []struct {
+		from, to string
+		want     Age
+		ok       bool
+	}{
+		{"1850-03-12", "1900-06-15", Age{50, 3, 3, false}, true},
+		{"1850-03-12", "1900-03-11", Age{49, 11, 27, false}, true},
+		{"1850-01-31", "1850-03-01", Age{0, 1, 1, false}, true},
+		{"1850-03-12", "1850-03-12", Age{0, 0, 0, false}, true},
+		{"1850", "1900-06-15", Age{50, 0, 0, true}, true},
+		{"1850-03", "1900-06-15", Age{50, 3, 0, true}, true},
+		{"abt 1850-03-12", "1900-06-15", Age{50, 3, 3, true}, true},
+		{"10 BC", "10", Age{19, 0, 0, true}, true},
+		{"1900", "1850", Age{}, false},
+		{"", "1850", Age{}, false},
+		{"Easter 1850", "1900", Age{}, false},
+	} {
+		from, to := DefaultDateParser.Parse(test.from), DefaultDateParser.Parse(test.to)
+		got, ok := AgeBetween(from, to)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s to %s: got %v, %v, want %v, %v", test.from, test.to, got, ok,
+				test.want, test.ok)
+		}
+	}
+	if s := (Age{50, 1, 0, true}).String(); s != "about 50 years, 1 month" {
+		t.Errorf("Got %q", s)
+	}
+	if s := (Age{}).String(); s != "0 days" {
+		t.Errorf("Got %q", s)
+	}
+}
+
+func TestBirthDeath(t *testing.T) {
+	db := &Database{}
+	p := addPerson(t, db, "Baptism", "1850-03-20", "Birth", "1850-03-12",
+		"Death", "1900-06-15", "Burial", "1900-06-18")
+	if e := p.Birth(db); e == nil || e.GetDateString() != "1850-03-12" {
+		t.Errorf("Birth = %v", e)
+	}
+	if e, fallback := p.DeathOrFallback(db); fallback || e != p.Death(db) {
+		t.Errorf("DeathOrFallback = %v, %v", e, fallback)
+	}
+	if a, ok := p.AgeAtDeath(db); !ok || a != (Age{50, 3, 3, false}) {
+		t.Errorf("AgeAtDeath = %v, %v", a, ok)
+	}
+
+	// A birth with another role is not the birth of p.
+	q := addPerson(t, db, "Birth", "1850", "Christening", "1850-03-20", "Burial", "1900-06")
+	q.EventRefs[0].Role = "Witness"
+	if e := q.Birth(db); e != nil {
+		t.Errorf("Birth = %v", e)
+	}
+	if e, fallback := q.BirthOrFallback(db); !fallback || e.GetDateString() != "1850-03-20" {
+		t.Errorf("BirthOrFallback = %v, %v", e, fallback)
+	}
+	if a, ok := q.AgeAtDeath(db); !ok || a != (Age{50, 2, 0, true}) {
+		t.Errorf("AgeAtDeath = %v, %v", a, ok)
+	}
+	if a, ok := q.AgeAt(db, db.EventByHandle(q.EventRefs[0].HLink)); ok {
+		t.Errorf("AgeAt a date before birth = %v", a)
+	}
+}
+
+func TestProbablyAlive(t *testing.T) {
+	db := &Database{}
+	opts := DefaultAliveOptions
+	at := func(s string) Date { return DefaultDateParser.Parse(s) }
+
+	born := addPerson(t, db, "Birth", "1850")
+	if !born.ProbablyAlive(db, at("1900"), opts) || born.ProbablyAlive(db, at("1970"), opts) ||
+		born.ProbablyAlive(db, Date{}, opts) || born.ProbablyAlive(db, at("1840"), opts) {
+		t.Error("Wrong span from the birth")
+	}
+	recent := addPerson(t, db, "Birth", "1990-05-01")
+	if !recent.ProbablyAlive(db, Date{}, opts) {
+		t.Error("Person born in 1990 is not alive")
+	}
+	died := addPerson(t, db, "Birth", "1990-05-01", "Death", "2000")
+	if died.ProbablyAlive(db, Date{}, opts) || !died.ProbablyAlive(db, at("1995"), opts) {
+		t.Error("Wrong span from the birth and death")
+	}
+	buried := addPerson(t, db, "Burial", "")
+	if buried.ProbablyAlive(db, Date{}, opts) {
+		t.Error("Person with a burial is alive")
+	}
+	unknown := addPerson(t, db)
+	if !unknown.ProbablyAlive(db, Date{}, opts) {
+		t.Error("Person without evidence is not alive")
+	}
+	occupation := addPerson(t, db, "Occupation", "1880")
+	if occupation.ProbablyAlive(db, Date{}, opts) || !occupation.ProbablyAlive(db, at("1900"), opts) {
+		t.Error("Wrong span from another event")
+	}
+
+	// Relatives.
+	sibling := addPerson(t, db, "Birth", "1800")
+	child := addPerson(t, db)
+	addFamily(t, db, nil, nil, sibling, child)
+	if child.ProbablyAlive(db, Date{}, opts) || !child.ProbablyAlive(db, at("1850"), opts) {
+		t.Error("Wrong span from a sibling")
+	}
+	grandchild := addPerson(t, db, "Birth", "1990")
+	parent := addPerson(t, db)
+	addFamily(t, db, parent, nil, grandchild)
+	grandparent := addPerson(t, db)
+	addFamily(t, db, nil, grandparent, parent)
+	if !grandparent.ProbablyAlive(db, Date{}, opts) || grandparent.ProbablyAlive(db, at("1940"), opts) {
+		t.Error("Wrong span from a grandchild")
+	}
+	ancestor := addPerson(t, db, "Birth", "1700")
+	descendant := addPerson(t, db)
+	middle := addPerson(t, db)
+	addFamily(t, db, ancestor, nil, middle)
+	addFamily(t, db, middle, nil, descendant)
+	if descendant.ProbablyAlive(db, Date{}, opts) || !descendant.ProbablyAlive(db, at("1800"), opts) {
+		t.Error("Wrong span from a grandparent")
+	}
+	spouse := addPerson(t, db, "Birth", "1700")
+	widow := addPerson(t, db)
+	addFamily(t, db, spouse, widow)
+	if widow.ProbablyAlive(db, Date{}, opts) {
+		t.Error("Wrong span from a spouse")
+	}
+}
+
+func TestAgeExample(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	ages := 0
+	for _, p := range db.People.Persons {
+		if _, ok := p.AgeAtDeath(db); ok {
+			ages++
+		}
+		if p.Death(db) != nil && p.ProbablyAlive(db, Date{}, DefaultAliveOptions) {
+			t.Errorf("%s is dead but probably alive", p.ID)
+		}
+	}
+	if ages == 0 {
+		t.Error("No ages at death in the example")
+	}
+}
@@ -0,0 +1,370 @@
+package xml
+
+// Conversions between the calendars of Gramps and Julian Day Numbers (JDN),
+// the number of days since January 1, 4713 BC in the Julian calendar. They
+// give the same days as the conversions of Gramps, so dates compare the same
+// way as in Gramps.
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func floorMod(a, b int) int {
+	return a - floorDiv(a, b)*b
+}
+
+func ceilDiv(a, b int) int {
+	return -floorDiv(-a, b)
+}
+
+// Get the JDN of the day v in calendar c. Parts of v that are not known are
+// taken to be 1, so a year is the JDN of its first day.
+func (c Calendar) JDN(v YMD) int {
+	year, month, day := v.Year, v.Month, v.Day
+	if year == 0 {
+		year = 1
+	}
+	if month < 1 {
+		month = 1
+	}
+	if day < 1 {
+		day = 1
+	}
+	switch c {
+	case Julian:
+		return julianSDN(year, month, day)
+	case Hebrew:
+		return hebrewSDN(year, month, day)
+	case FrenchRepublican:
+		return frenchSDN(year, month, day)
+	case Persian:
+		return persianSDN(year, month, day)
+	case Islamic:
+		return islamicSDN(year, month, day)
+	case Swedish:
+		return swedishSDN(year, month, day)
+	}
+	return gregorianSDN(year, month, day)
+}
+
+// Get the day with JDN jdn in calendar c.
+func (c Calendar) FromJDN(jdn int) YMD {
+	switch c {
+	case Julian:
+		return julianYMD(jdn)
+	case Hebrew:
+		return hebrewYMD(jdn)
+	case FrenchRepublican:
+		return frenchYMD(jdn)
+	case Persian:
+		return persianYMD(jdn)
+	case Islamic:
+		return islamicYMD(jdn)
+	case Swedish:
+		return swedishYMD(jdn)
+	}
+	return gregorianYMD(jdn)
+}
+
+func gregorianSDN(year, month, day int) int {
+	if year < 0 {
+		year += 4801
+	} else {
+		year += 4800
+	}
+	if month > 2 {
+		month -= 3
+	} else {
+		month += 9
+		year--
+	}
+	return (year/100)*146097/4 + (year%100)*1461/4 + (month*153+2)/5 + day - 32045
+}
+
+func gregorianYMD(sdn int) YMD {
+	temp := (sdn+32045)*4 - 1
+	century := floorDiv(temp, 146097)
+	temp = floorDiv(floorMod(temp, 146097), 4)*4 + 3
+	year := century*100 + temp/1461
+	dayOfYear := temp%1461/4 + 1
+	return fromMarch(year, dayOfYear)
+}
+
+// Get the date from the year counted from 4800 BC and the day of the year
+// counted from March 1, as used by the Gregorian and Julian calendars.
+func fromMarch(year, dayOfYear int) YMD {
+	temp := dayOfYear*5 - 3
+	month := temp / 153
+	day := temp%153/5 + 1
+	if month < 10 {
+		month += 3
+	} else {
+		year++
+		month -= 9
+	}
+	year -= 4800
+	if year <= 0 {
+		// There is no year 0.
+		year--
+	}
+	return YMD{year, month, day}
+}
+
+func julianSDN(year, month, day int) int {
+	if year < 0 {
+		year += 4801
+	} else {
+		year += 4800
+	}
+	if month > 2 {
+		month -= 3
+	} else {
+		month += 9
+		year--
+	}
+	return year*1461/4 + (month*153+2)/5 + day - 32083
+}
+
+func julianYMD(sdn int) YMD {
+	temp := (sdn+32083)*4 - 1
+	year := floorDiv(temp, 1461)
+	dayOfYear := floorMod(temp, 1461)/4 + 1
+	return fromMarch(year, dayOfYear)
+}
+
+// The Swedish calendar ran one day ahead of the Julian calendar from March 1,
+// 1700 to February 30, 1712. It was Gregorian from March 1, 1753.
+
+func swedishSDN(year, month, day int) int {
+	v := YMD{year, month, day}
+	switch {
+	case !v.before(YMD{1700, 3, 1}) && !YMD{1712, 2, 30}.before(v):
+		return julianSDN(year, month, day) - 1
+	case !v.before(YMD{1753, 3, 1}):
+		return gregorianSDN(year, month, day)
+	}
+	return julianSDN(year, month, day)
+}
+
+func swedishYMD(sdn int) YMD {
+	switch {
+	case sdn == 2346425:
+		return YMD{1712, 2, 30}
+	case sdn >= 2342042 && sdn < 2346425:
+		return julianYMD(sdn + 1)
+	case sdn >= 2361390:
+		return gregorianYMD(sdn)
+	}
+	return julianYMD(sdn)
+}
+
+func (v YMD) before(o YMD) bool {
+	if v.Year != o.Year {
+		return v.Year < o.Year
+	}
+	if v.Month != o.Month {
+		return v.Month < o.Month
+	}
+	return v.Day < o.Day
+}
+
+// The French Republican calendar has 12 months of 30 days and a 13th month of
+// 5 or 6 complementary days. It was used from year 1 (1792) to 14 (1805).
+
+func frenchSDN(year, month, day int) int {
+	return floorDiv(year*1461, 4) + (month-1)*30 + day + 2375474
+}
+
+func frenchYMD(sdn int) YMD {
+	temp := (sdn-2375474)*4 - 1
+	year := floorDiv(temp, 1461)
+	dayOfYear := floorMod(temp, 1461) / 4
+	return YMD{year, dayOfYear/30 + 1, dayOfYear%30 + 1}
+}
+
+// The arithmetic Persian calendar, with a 2820 year cycle of leap years.
+
+func persianSDN(year, month, day int) int {
+	epbase := year - 473
+	if year >= 0 {
+		epbase = year - 474
+	}
+	epyear := 474 + floorMod(epbase, 2820)
+	v1 := (month-1)*30 + 6
+	if month <= 7 {
+		v1 = (month - 1) * 31
+	}
+	v2 := floorDiv(epyear*682-110, 2816)
+	v3 := (epyear-1)*365 + day
+	v4 := floorDiv(epbase, 2820) * 1029983
+	return v1 + v2 + v3 + v4 + 1948320
+}
+
+func persianYMD(sdn int) YMD {
+	depoch := sdn - 2121446
+	cycle := floorDiv(depoch, 1029983)
+	cyear := floorMod(depoch, 1029983)
+	ycycle := 2820
+	if cyear != 1029982 {
+		aux1, aux2 := cyear/366, cyear%366
+		ycycle = (2134*aux1+2816*aux2+2815)/1028522 + aux1 + 1
+	}
+	year := ycycle + 2820*cycle + 474
+	if year <= 0 {
+		year--
+	}
+	yday := sdn - persianSDN(year, 1, 1) + 1
+	month := ceilDiv(yday-6, 30)
+	if yday <= 186 {
+		month = ceilDiv(yday, 31)
+	}
+	return YMD{year, month, sdn - persianSDN(year, month, 1) + 1}
+}
+
+// The arithmetic Islamic calendar, with months of alternately 30 and 29 days
+// and 11 leap years in 30.
+
+func islamicSDN(year, month, day int) int {
+	v1 := ceilDiv(59*(month-1), 2)
+	v2 := (year - 1) * 354
+	v3 := floorDiv(3+11*year, 30)
+	return day + v1 + v2 + v3 + 1948439
+}
+
+func islamicYMD(sdn int) YMD {
+	year := floorDiv(30*(sdn-1948440)+10646, 10631)
+	month := ceilDiv(2*(sdn-29-islamicSDN(year, 1, 1)), 59) + 1
+	if month > 12 {
+		month = 12
+	}
+	return YMD{year, month, sdn - islamicSDN(year, month, 1) + 1}
+}
+
+// The Hebrew calendar, with months numbered as in Gramps: 1 Tishri,
+// 2 Heshvan, 3 Kislev, 4 Tevet, 5 Shevat, 6 Adar I (Adar in common years),
+// 7 Adar II, 8 Nisan, 9 Iyyar, 10 Sivan, 11 Tammuz, 12 Av, 13 Elul. The
+// computations number the months from Nisan, as in Calendrical Calculations.
+
+const hebrewEpoch = 347998 // The JDN of 1 Tishri of year 1.
+
+func hebrewLeapYear(year int) bool {
+	return floorMod(7*year+1, 19) < 7
+}
+
+// Get the number of days from the epoch to the new year of year, before the
+// postponements for the length of the year.
+func hebrewElapsedDays(year int) int {
+	months := floorDiv(235*year-234, 19)
+	parts := 12084 + 13753*months
+	day := 29*months + floorDiv(parts, 25920)
+	if floorMod(3*(day+1), 7) < 3 {
+		day++
+	}
+	return day
+}
+
+// Get the JDN of 1 Tishri of year.
+func hebrewNewYear(year int) int {
+	ny0, ny1, ny2 := hebrewElapsedDays(year-1), hebrewElapsedDays(year), hebrewElapsedDays(year+1)
+	correction := 0
+	if ny2-ny1 == 356 {
+		correction = 2
+	} else if ny1-ny0 == 382 {
+		correction = 1
+	}
+	return hebrewEpoch + ny1 + correction
+}
+
+// Get the length of a month, numbered from Nisan.
+func hebrewMonthLength(month, year int) int {
+	days := hebrewNewYear(year+1) - hebrewNewYear(year)
+	switch {
+	case month == 2 || month == 4 || month == 6 || month == 10 || month == 13:
+		return 29
+	case month == 12 && !hebrewLeapYear(year):
+		return 29
+	case month == 8 && days%10 != 5:
+		// Heshvan is long only in complete years of 355 or 385 days.
+		return 29
+	case month == 9 && days%10 == 3:
+		// Kislev is short in deficient years of 353 or 383 days.
+		return 29
+	}
+	return 30
+}
+
+// Get the JDN of a day, with the month numbered from Nisan.
+func hebrewNisanSDN(year, month, day int) int {
+	last := 12
+	if hebrewLeapYear(year) {
+		last = 13
+	}
+	sdn := hebrewNewYear(year) + day - 1
+	if month < 7 {
+		for m := 7; m <= last; m++ {
+			sdn += hebrewMonthLength(m, year)
+		}
+		for m := 1; m < month; m++ {
+			sdn += hebrewMonthLength(m, year)
+		}
+	} else {
+		for m := 7; m < month; m++ {
+			sdn += hebrewMonthLength(m, year)
+		}
+	}
+	return sdn
+}
+
+func hebrewSDN(year, month, day int) int {
+	switch {
+	case month <= 5:
+		month += 6
+	case month == 6:
+		month = 12
+	case month == 7:
+		month = 13
+		if !hebrewLeapYear(year) {
+			month = 12
+		}
+	default:
+		month -= 7
+	}
+	return hebrewNisanSDN(year, month, day)
+}
+
+func hebrewYMD(sdn int) YMD {
+	year := floorDiv((sdn-hebrewEpoch)*98496, 35975351) + 1
+	for hebrewNewYear(year) > sdn {
+		year--
+	}
+	for hebrewNewYear(year+1) <= sdn {
+		year++
+	}
+	month := 7
+	if sdn < hebrewNisanSDN(year, 1, 1) {
+		for sdn > hebrewNisanSDN(year, month, hebrewMonthLength(month, year)) {
+			month++
+		}
+	} else {
+		month = 1
+		for sdn > hebrewNisanSDN(year, month, hebrewMonthLength(month, year)) {
+			month++
+		}
+	}
+	day := sdn - hebrewNisanSDN(year, month, 1) + 1
+	switch {
+	case month >= 7 && month <= 11:
+		month -= 6
+	case month == 12:
+		month = 6
+	case month == 13:
+		month = 7
+	default:
+		month += 7
+	}
+	return YMD{year, month, day}
+}
@@ -0,0 +1,98 @@
+package xml
+
+import (
+	"testing"
+)
+
+func TestCalendars(t *testing.T) {
+	for _, test := range []struct {
+		cal       Calendar
+		v         YMD
+		gregorian YMD
+	}{
+		{Julian, YMD{1582, 10, 5}, YMD{1582, 10, 15}},
+		{Julian, YMD{1700, 2, 29}, YMD{1700, 3, 11}},
+		{Hebrew, YMD{5760, 1, 1}, YMD{1999, 9, 11}},
+		{Hebrew, YMD{5760, 8, 15}, YMD{2000, 4, 20}},
+		{Hebrew, YMD{5785, 1, 1}, YMD{2024, 10, 3}},
+		{Hebrew, YMD{5784, 6, 1}, YMD{2024, 2, 10}},
+		{Hebrew, YMD{5784, 7, 14}, YMD{2024, 3, 24}},
+		{Hebrew, YMD{5783, 6, 14}, YMD{2023, 3, 7}},
+		{FrenchRepublican, YMD{1, 1, 1}, YMD{1792, 9, 22}},
+		{FrenchRepublican, YMD{8, 2, 18}, YMD{1799, 11, 9}},
+		{Persian, YMD{1388, 1, 1}, YMD{2009, 3, 21}},
+		{Persian, YMD{1357, 11, 22}, YMD{1979, 2, 11}},
+		{Islamic, YMD{1, 1, 1}, YMD{622, 7, 19}},
+		{Islamic, YMD{1421, 1, 1}, YMD{2000, 4, 6}},
+		{Swedish, YMD{1712, 2, 30}, YMD{1712, 3, 11}},
+		{Swedish, YMD{1705, 6, 1}, YMD{1705, 6, 11}},
+	} {
+		jdn := test.cal.JDN(test.v)
+		if want := Gregorian.JDN(test.gregorian); jdn != want {
+			t.Errorf("%s %v: got JDN %d (%v), want %d (%v)", test.cal, test.v, jdn,
+				Gregorian.FromJDN(jdn), want, test.gregorian)
+		}
+		if got := test.cal.FromJDN(jdn); got != test.v {
+			t.Errorf("%s %d: got %v, want %v", test.cal, jdn, got, test.v)
+		}
+	}
+	if jdn := Julian.JDN(YMD{-4713, 1, 1}); jdn != 0 {
+		t.Errorf("Got JDN %d for the epoch", jdn)
+	}
+}
+
+// Converting every day of several centuries to each calendar and back must
+// give the same day. The French Republican calendar starts in 1792.
+func TestCalendarRoundTrip(t *testing.T) {
+	for _, cal := range []Calendar{Gregorian, Julian, Hebrew, FrenchRepublican, Persian,
+		Islamic, Swedish} {
+		start := 2200000
+		if first := cal.JDN(YMD{1, 1, 1}); first > start {
+			start = first
+		}
+		prev := cal.FromJDN(start - 1)
+		for jdn := start; jdn < 2500000; jdn++ {
+			v := cal.FromJDN(jdn)
+			if got := cal.JDN(v); got != jdn {
+				t.Fatalf("%s: %d is %v, which is %d", cal, jdn, v, got)
+			}
+			if !prev.before(v) {
+				t.Fatalf("%s: %v follows %v", cal, v, prev)
+			}
+			prev = v
+		}
+	}
+}
+
+func TestNewYear(t *testing.T) {
+	// February 1, 1749 with the year starting on Lady Day is in 1750.
+	d := Date{Calendar: Julian, Start: YMD{1749, 2, 1}, NewYear: "Mar25"}
+	if got := d.In(Julian).Start; got != (YMD{1750, 2, 1}) {
+		t.Errorf("Got %v", got)
+	}
+	if got := d.In(Gregorian).Start; got != (YMD{1750, 2, 12}) {
+		t.Errorf("Got %v", got)
+	}
+	// Dual dated, February 1, 1749/50 is 1750 with or without the new year.
+	for _, ny := range []string{"", "Mar25"} {
+		dual := Date{Calendar: Julian, Start: YMD{1750, 2, 1}, DualDated: true, NewYear: ny}
+		if dual.Compare(d) != 0 {
+			t.Errorf("Dual dated date with new year %q differs from the date with the new year", ny)
+		}
+	}
+	later := Date{Calendar: Julian, Start: YMD{1749, 4, 1}, NewYear: "Mar25"}
+	if later.Compare(d) != -1 {
+		t.Error("April 1749 is not before February 1749 with the new year on Lady Day")
+	}
+	custom := Date{Start: YMD{1700, 3, 1}, NewYear: "03-25"}
+	if custom.JDN() != Gregorian.JDN(YMD{1701, 3, 1}) {
+		t.Error("Custom new year was not honored")
+	}
+
+	span := Date{Modifier: ModSpan, Calendar: Hebrew, Start: YMD{5760, 1, 1},
+		Stop: YMD{5760, 8, 15}}
+	want := Date{Modifier: ModSpan, Start: YMD{1999, 9, 11}, Stop: YMD{2000, 4, 20}}
+	if got := span.In(Gregorian); got != want {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,274 @@
+package xml
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Elements are written the way Gramps writes them, so that a file that is
+// parsed and written again is byte for byte the same as the original: in the
+// order of the struct fields (which follow the DTD), with two space indents,
+// self-closing empty elements and Gramps's escaping.
+
+// Gramps's indentation is irregular in a few places. These are the number of
+// spaces added to the indent of a child element, keyed by parent/child.
+var indentQuirks = map[string]int{
+	"person/citationref": 2,
+	"objref/region":      -1,
+}
+
+// Attributes that Gramps writes with an extra space before them, keyed by
+// element/attribute.
+var spaceQuirks = map[string]bool{
+	"url/href": true,
+}
+
+var escaper = strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`,
+	`"`, `&quot;`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// How a struct field is written, from its xml tag.
+type fieldInfo struct {
+	name      string
+	index     []int
+	attr      bool
+	omitEmpty bool
+	charData  bool
+	any       bool
+}
+
+var (
+	fieldsMu    sync.Mutex
+	fieldsCache = make(map[reflect.Type][]fieldInfo)
+)
+
+// Get the fields of struct type t that are written, in order. Fields of
+// embedded structs are included at the position of the embedded struct.
+func fieldsOf(t reflect.Type) []fieldInfo {
+	fieldsMu.Lock()
+	defer fieldsMu.Unlock()
+	if fields, ok := fieldsCache[t]; ok {
+		return fields
+	}
+
+	var fields []fieldInfo
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Anonymous || f.Name == "XMLName" || !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		info := fieldInfo{name: parts[0], index: f.Index}
+		for _, flag := range parts[1:] {
+			switch flag {
+			case "attr":
+				info.attr = true
+			case "omitempty":
+				info.omitEmpty = true
+			case "chardata":
+				info.charData = true
+			case "any":
+				info.any = true
+			}
+		}
+		if info.name == "" && !info.charData && !info.any {
+			info.name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, info)
+	}
+	fieldsCache[t] = fields
+	return fields
+}
+
+type canonicalWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *canonicalWriter) indent(spaces int) {
+	for i := 0; i < spaces; i++ {
+		w.buf.WriteByte(' ')
+	}
+}
+
+func (w *canonicalWriter) startTag(name string, attrs []string, indent int) {
+	w.indent(indent)
+	w.buf.WriteString("<" + name)
+	for _, a := range attrs {
+		w.buf.WriteString(" " + a)
+	}
+}
+
+// Write the start of a collection element, e.g. <events>, at depth.
+func (w *canonicalWriter) open(name string, attrs []string, depth int) {
+	w.startTag(name, attrs, 2*depth)
+	w.buf.WriteString(">\n")
+}
+
+// Write the end of a collection element, e.g. </events>, at depth.
+func (w *canonicalWriter) close(name string, depth int) {
+	w.closeTag(name, 2*depth)
+}
+
+func (w *canonicalWriter) closeTag(name string, indent int) {
+	w.indent(indent)
+	w.buf.WriteString("</" + name + ">\n")
+}
+
+func attrValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	panic("Unsupported attribute type: " + v.Type().String())
+}
+
+// Write v, a struct or a pointer to one, as the element name at depth.
+func (w *canonicalWriter) element(name string, v reflect.Value, depth int) {
+	w.elementAt(name, v, 2*depth)
+}
+
+func (w *canonicalWriter) elementAt(name string, v reflect.Value, indent int) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	var attrs []string
+	var text string
+	// The parsed elements within the element, one function for each.
+	var children []func()
+	var unparsed []*raw
+	for _, f := range fieldsOf(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		switch {
+		case f.attr:
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			attr := f.name + `="` + escape(attrValue(fv)) + `"`
+			if spaceQuirks[name+"/"+f.name] {
+				attr = " " + attr
+			}
+			attrs = append(attrs, attr)
+		case f.charData:
+			text = fv.String()
+		case f.any:
+			unparsed = append(unparsed, fv.Interface().([]*raw)...)
+		default:
+			f, fv := f, fv
+			childIndent := indent + 2 + indentQuirks[name+"/"+f.name]
+			switch {
+			case fv.Kind() == reflect.Slice:
+				for i := 0; i < fv.Len(); i++ {
+					elem := fv.Index(i)
+					children = append(children, func() {
+						w.elementAt(f.name, elem, childIndent)
+					})
+				}
+			case fv.Kind() == reflect.Ptr && fv.IsNil():
+			case reflect.Indirect(fv).Kind() == reflect.String:
+				children = append(children, func() {
+					w.indent(childIndent)
+					s := escape(reflect.Indirect(fv).String())
+					w.buf.WriteString("<" + f.name + ">" + s + "</" + f.name + ">\n")
+				})
+			default:
+				children = append(children, func() {
+					w.elementAt(f.name, fv, childIndent)
+				})
+			}
+		}
+	}
+
+	w.startTag(name, attrs, indent)
+	switch {
+	case len(children) > 0 || len(unparsed) > 0:
+		w.buf.WriteString(">\n")
+		for i, c := range children {
+			w.rawsBefore(unparsed, i, len(children), indent+2)
+			c()
+		}
+		w.rawsBefore(unparsed, len(children), len(children), indent+2)
+		w.closeTag(name, indent)
+	case text != "":
+		w.buf.WriteString(">" + escape(text) + "</" + name + ">\n")
+	default:
+		w.buf.WriteString("/>\n")
+	}
+}
+
+// Write the unparsed elements that were before parsed element i of the n
+// parsed elements of their parent, or after the last if i is n. Unparsed
+// elements that were before more elements than there are go first.
+func (w *canonicalWriter) rawsBefore(raws []*raw, i, n, indent int) {
+	for _, r := range raws {
+		if r.following == n-i || i == 0 && r.following > n {
+			w.raw(r, indent)
+		}
+	}
+}
+
+// The namespace of the xml prefix.
+const xmlURL = "http://www.w3.org/XML/1998/namespace"
+
+// Write an unparsed element as it was read, with the prefix of its name. A
+// namespace other than Gramps's that was declared on an ancestor is declared
+// on the element, with the same prefix.
+func (w *canonicalWriter) raw(r *raw, indent int) {
+	name := r.XMLName.Local
+	if r.prefix != "" {
+		name = r.prefix + ":" + name
+	}
+	var attrs []string
+	declared := false
+	for _, a := range r.Attrs {
+		attr := a.Name.Local
+		switch a.Name.Space {
+		case "":
+			declared = declared || r.prefix == "" && attr == "xmlns"
+		case "xmlns":
+			declared = declared || attr == r.prefix
+			attr = "xmlns:" + attr
+		case xmlURL:
+			attr = "xml:" + attr
+		default:
+			prefix := ""
+			if a.Name.Space == r.XMLName.Space {
+				prefix = r.prefix
+			}
+			for _, decl := range r.Attrs {
+				if decl.Name.Space == "xmlns" && decl.Value == a.Name.Space {
+					prefix = decl.Name.Local
+				}
+			}
+			if prefix != "" {
+				attr = prefix + ":" + attr
+			}
+		}
+		attrs = append(attrs, attr+`="`+escape(a.Value)+`"`)
+	}
+	if _, ok := VersionOf(r.XMLName.Space); !ok && r.XMLName.Space != "" && !declared {
+		decl := "xmlns"
+		if r.prefix != "" {
+			decl += ":" + r.prefix
+		}
+		attrs = append([]string{decl + `="` + escape(r.XMLName.Space) + `"`}, attrs...)
+	}
+
+	w.startTag(name, attrs, indent)
+	if r.Contents == "" {
+		w.buf.WriteString("/>\n")
+	} else {
+		w.buf.WriteString(">" + r.Contents + "</" + name + ">\n")
+	}
+}
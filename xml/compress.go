@@ -0,0 +1,84 @@
+package xml
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"code.google.com/p/gogramps/internal/xz"
+)
+
+// The compression of a .gramps file.
+type Compression int
+
+const (
+	// Uncompressed XML, as saved by Gramps with compression turned off.
+	None Compression = iota
+	// The default for files written by Gramps.
+	Gzip
+	Bzip2
+	XZ
+)
+
+func (c Compression) String() string {
+	switch c {
+	case None:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Bzip2:
+		return "bzip2"
+	case XZ:
+		return "xz"
+	}
+	return "unknown"
+}
+
+var magics = []struct {
+	magic       []byte
+	compression Compression
+}{
+	{[]byte{0x1f, 0x8b}, Gzip},
+	{[]byte("BZh"), Bzip2},
+	{xz.Magic, XZ},
+}
+
+// Detect the compression of the input of br from its first bytes, which are
+// peeked and not read. Input that is not compressed in a known format is None.
+func DetectCompression(br *bufio.Reader) Compression {
+	for _, m := range magics {
+		if head, _ := br.Peek(len(m.magic)); bytes.Equal(head, m.magic) {
+			return m.compression
+		}
+	}
+	return None
+}
+
+// Detect the compression of r from its first bytes and return a reader of the
+// decompressed contents. Input that is not compressed in a known format is
+// returned as is, to be read as plain XML. The returned reader must be closed;
+// closing it does not close r.
+func Decompress(r io.Reader) (io.ReadCloser, Compression, error) {
+	br := bufio.NewReader(r)
+	compression := DetectCompression(br)
+	switch compression {
+	case Gzip:
+		unzipped, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, compression, err
+		}
+		return unzipped, compression, nil
+	case Bzip2:
+		return ioutil.NopCloser(bzip2.NewReader(br)), compression, nil
+	case XZ:
+		unzipped, err := xz.NewReader(br)
+		if err != nil {
+			return nil, compression, err
+		}
+		return ioutil.NopCloser(unzipped), compression, nil
+	}
+	return ioutil.NopCloser(br), compression, nil
+}
@@ -0,0 +1,81 @@
+package xml
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var compressedExamples = []struct {
+	filename    string
+	compression Compression
+}{
+	{"example-1.7.1.gramps", Gzip},
+	{"example-1.7.1.xml", None},
+	{"example-1.7.1.gramps.bz2", Bzip2},
+	{"example-1.7.1.gramps.xz", XZ},
+}
+
+func TestDecompress(t *testing.T) {
+	want, err := ioutil.ReadFile(filepath.Join(*testDir, "example-1.7.1.xml"))
+	if err != nil {
+		t.Fatalf("Failed to read file: %s", err)
+	}
+	for _, e := range compressedExamples {
+		f, err := os.Open(filepath.Join(*testDir, e.filename))
+		if err != nil {
+			t.Fatalf("Failed to open file: %s", err)
+		}
+		unzipped, compression, err := Decompress(f)
+		if err != nil {
+			t.Fatalf("Failed to decompress %s: %s", e.filename, err)
+		}
+		got, err := ioutil.ReadAll(unzipped)
+		unzipped.Close()
+		f.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %s", e.filename, err)
+		}
+		if compression != e.compression {
+			t.Errorf("%s: got compression %s, want %s", e.filename, compression, e.compression)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decompressed contents differ", e.filename)
+		}
+	}
+}
+
+func TestParsesCompressed(t *testing.T) {
+	for _, e := range compressedExamples {
+		f, err := os.Open(filepath.Join(*testDir, e.filename))
+		if err != nil {
+			t.Fatalf("Failed to open file: %s", err)
+		}
+		db, err := Parse(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %s", e.filename, err)
+		}
+		if db.Version() != "1.7.1" || len(db.People.Persons) == 0 {
+			t.Errorf("%s: parsed version %s with %d people", e.filename,
+				db.Version(), len(db.People.Persons))
+		}
+
+		f, err = os.Open(filepath.Join(*testDir, e.filename))
+		if err != nil {
+			t.Fatalf("Failed to open file: %s", err)
+		}
+		dec, err := NewDecoder(f)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %s", e.filename, err)
+		}
+		if dec.Compression != e.compression {
+			t.Errorf("%s: decoder got compression %s, want %s", e.filename,
+				dec.Compression, e.compression)
+		}
+		dec.Close()
+		f.Close()
+	}
+}
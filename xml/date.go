@@ -0,0 +1,396 @@
+package xml
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// How the value of a Date is qualified.
+type Modifier int
+
+const (
+	ModNone Modifier = iota
+	ModBefore
+	ModAfter
+	ModAbout
+	// Between Start and Stop.
+	ModRange
+	// From Start to Stop.
+	ModSpan
+	// A date that could not be interpreted, kept as Text.
+	ModTextOnly
+)
+
+// The type attributes of dateval elements, by Modifier.
+var modifierNames = map[Modifier]string{
+	ModBefore: "before",
+	ModAfter:  "after",
+	ModAbout:  "about",
+}
+
+// How a Date was arrived at.
+type Quality int
+
+const (
+	QualityRegular Quality = iota
+	QualityEstimated
+	QualityCalculated
+)
+
+var qualityNames = map[Quality]string{
+	QualityEstimated:  "estimated",
+	QualityCalculated: "calculated",
+}
+
+// The calendar a Date is given in.
+type Calendar int
+
+const (
+	Gregorian Calendar = iota
+	Julian
+	Hebrew
+	FrenchRepublican
+	Persian
+	Islamic
+	Swedish
+)
+
+// The cformat attributes, by Calendar.
+var calendarNames = map[Calendar]string{
+	Julian:           "Julian",
+	Hebrew:           "Hebrew",
+	FrenchRepublican: "French Republican",
+	Persian:          "Persian",
+	Islamic:          "Islamic",
+	Swedish:          "Swedish",
+}
+
+func (c Calendar) String() string {
+	if c == Gregorian {
+		return "Gregorian"
+	}
+	return calendarNames[c]
+}
+
+// A YMD is a year, month and day in some calendar. Zero means the part is not
+// known, e.g. {1897, 3, 0} is March 1897.
+type YMD struct {
+	Year, Month, Day int
+}
+
+var ymdPattern = regexp.MustCompile(`^(-?\d+|\?+)(?:-(\d+|\?+)(?:-(\d+|\?+))?)?$`)
+
+// Parse the val of a date element: a possibly partial ISO date such as 1897,
+// 1897-03 or 1897-03-00, with ? for unknown parts as Gramps writes them.
+func parseYMD(s string) (YMD, error) {
+	m := ymdPattern.FindStringSubmatch(s)
+	if m == nil {
+		return YMD{}, fmt.Errorf("Invalid date: %q", s)
+	}
+	var parts [3]int
+	for i, p := range m[1:] {
+		if p == "" || strings.HasPrefix(p, "?") {
+			continue
+		}
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return YMD{}, fmt.Errorf("Invalid date: %q", s)
+		}
+		parts[i] = v
+	}
+	ymd := YMD{parts[0], parts[1], parts[2]}
+	if ymd.Month < 0 || ymd.Month > 13 || ymd.Day < 0 || ymd.Day > 31 {
+		return YMD{}, fmt.Errorf("Invalid date: %q", s)
+	}
+	return ymd, nil
+}
+
+// Format a YMD the way Gramps writes it.
+func (v YMD) String() string {
+	y := "????"
+	if v.Year != 0 {
+		y = fmt.Sprintf("%04d", v.Year)
+	}
+	m := ""
+	if v.Month != 0 {
+		m = fmt.Sprintf("-%02d", v.Month)
+	} else if v.Day != 0 {
+		m = "-??"
+	}
+	d := ""
+	if v.Day != 0 {
+		d = fmt.Sprintf("-%02d", v.Day)
+	}
+	s := y + m + d
+	if strings.Trim(s, "-?") == "" {
+		return ""
+	}
+	return s
+}
+
+// A Date is the interpreted value of the dateval, daterange, datespan or
+// datestr element of an object.
+type Date struct {
+	Modifier Modifier
+	Quality  Quality
+	Calendar Calendar
+	// The date, or the first date of a range or span.
+	Start YMD
+	// The last date of a range or span.
+	Stop YMD
+	// Whether the year is shown in both the old and new style. As in Gramps,
+	// the year of the date is the later one: 1750 is shown as 1749/50.
+	DualDated bool
+	// The first day of the year when it is not January 1, e.g. Mar25.
+	NewYear string
+	// The text of a ModTextOnly date.
+	Text string
+}
+
+// Whether there is no date.
+func (d Date) IsEmpty() bool {
+	return d.Modifier != ModTextOnly && d.Start == YMD{} && d.Stop == YMD{}
+}
+
+// Get the month and day the year of d starts on.
+func (d Date) newYear() (month, day int) {
+	switch d.NewYear {
+	case "Mar1":
+		return 3, 1
+	case "Mar25":
+		return 3, 25
+	case "Sep1":
+		return 9, 1
+	}
+	if _, err := fmt.Sscanf(d.NewYear, "%d-%d", &month, &day); err == nil {
+		return month, day
+	}
+	return 1, 1
+}
+
+// Get the JDN of v, a day of d. When the year does not start on January 1,
+// the days before the new year belong to the following year in the calendar
+// of d: e.g. February 1, 1749 with the new year on March 25 is February 1,
+// 1750 in the Julian calendar. The years of dual dated dates are already
+// those of the new style.
+func (d Date) jdn(v YMD) int {
+	month, day := d.newYear()
+	if !d.DualDated && v.Month != 0 && (YMD{0, v.Month, v.Day}).before(YMD{0, month, day}) {
+		v.Year++
+		if v.Year == 0 {
+			v.Year = 1
+		}
+	}
+	return d.Calendar.JDN(v)
+}
+
+// Get the JDN of the first day of the date, or the start of a range or span.
+// Parts that are not known are taken to be 1.
+func (d Date) JDN() int {
+	return d.jdn(d.Start)
+}
+
+// Get the JDN of the first day of the end of a range or span.
+func (d Date) StopJDN() int {
+	return d.jdn(d.Stop)
+}
+
+// Get a value for sorting Dates, like the sortval of Gramps: the JDN of the
+// date, or 0 for empty and text-only dates.
+func (d Date) SortValue() int {
+	if d.Modifier == ModTextOnly || d.Start == (YMD{}) {
+		return 0
+	}
+	return d.JDN()
+}
+
+// Convert d to the calendar cal, with the year starting on January 1. As in
+// Gramps, parts of the date that are not known are taken to be 1 and are
+// known after the conversion.
+func (d Date) In(cal Calendar) Date {
+	c := d
+	c.Calendar, c.NewYear, c.DualDated = cal, "", false
+	if d.Start != (YMD{}) {
+		c.Start = cal.FromJDN(d.JDN())
+	}
+	if d.Stop != (YMD{}) {
+		c.Stop = cal.FromJDN(d.StopJDN())
+	}
+	return c
+}
+
+// Compare d to o by their SortValues. Returns -1, 0 or 1 as d is before, the
+// same as or after o.
+func (d Date) Compare(o Date) int {
+	a, b := d.SortValue(), o.SortValue()
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (d Date) String() string {
+	var s string
+	switch d.Modifier {
+	case ModTextOnly:
+		return d.Text
+	case ModRange:
+		s = fmt.Sprintf("between %s and %s", d.Start, d.Stop)
+	case ModSpan:
+		s = fmt.Sprintf("from %s to %s", d.Start, d.Stop)
+	case ModNone:
+		s = d.Start.String()
+	default:
+		s = modifierNames[d.Modifier] + " " + d.Start.String()
+	}
+	if d.Quality != QualityRegular {
+		s = qualityNames[d.Quality] + " " + s
+	}
+	if d.Calendar != Gregorian {
+		s += " (" + d.Calendar.String() + ")"
+	}
+	return s
+}
+
+// Dates sorts Dates by their SortValues.
+type Dates []Date
+
+func (d Dates) Len() int           { return len(d) }
+func (d Dates) Less(i, j int) bool { return d[i].SortValue() < d[j].SortValue() }
+func (d Dates) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// Read the attributes shared by dateval, daterange and datespan.
+func (d *Date) setCommon(c *dateCommon) error {
+	switch c.Quality {
+	case "":
+	case "estimated":
+		d.Quality = QualityEstimated
+	case "calculated":
+		d.Quality = QualityCalculated
+	default:
+		return fmt.Errorf("Invalid date quality: %q", c.Quality)
+	}
+	if c.CFormat != "" {
+		found := false
+		for cal, name := range calendarNames {
+			if name == c.CFormat {
+				d.Calendar, found = cal, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Invalid calendar: %q", c.CFormat)
+		}
+	}
+	d.DualDated = c.DualDated == "1"
+	d.NewYear = c.NewYear
+	return nil
+}
+
+// Get the interpreted date. An empty Date is returned if there is none, and
+// an error if the date elements are not valid.
+func (v *hasDate) GetDate() (Date, error) {
+	var d Date
+	var err error
+	switch {
+	case v.DateVal != nil:
+		for mod, name := range modifierNames {
+			if name == v.DateVal.Type {
+				d.Modifier = mod
+			}
+		}
+		if d.Modifier == ModNone && v.DateVal.Type != "" {
+			return Date{}, fmt.Errorf("Invalid date type: %q", v.DateVal.Type)
+		}
+		if d.Start, err = parseYMD(v.DateVal.Val); err != nil {
+			return Date{}, err
+		}
+		err = d.setCommon(&v.DateVal.dateCommon)
+	case v.DateRange != nil || v.DateSpan != nil:
+		r := v.DateRange
+		d.Modifier = ModRange
+		if r == nil {
+			r = v.DateSpan
+			d.Modifier = ModSpan
+		}
+		if d.Start, err = parseYMD(r.Start); err != nil {
+			return Date{}, err
+		}
+		if d.Stop, err = parseYMD(r.Stop); err != nil {
+			return Date{}, err
+		}
+		err = d.setCommon(&r.dateCommon)
+	case v.DateStr != nil:
+		d.Modifier = ModTextOnly
+		d.Text = v.DateStr.Val
+	}
+	if err != nil {
+		return Date{}, err
+	}
+	return d, nil
+}
+
+// Format v for a val, start or stop attribute, keeping old if it is another
+// way of writing the same date, e.g. 1897-03-00 for 1897-03.
+func formatYMD(v YMD, old string) string {
+	if parsed, err := parseYMD(old); err == nil && parsed == v {
+		return old
+	}
+	return v.String()
+}
+
+func (d Date) common() dateCommon {
+	c := dateCommon{Quality: qualityNames[d.Quality], CFormat: calendarNames[d.Calendar],
+		NewYear: d.NewYear}
+	if d.DualDated {
+		c.DualDated = "1"
+	}
+	return c
+}
+
+// Set the date elements to d, replacing any others. Dates that are unchanged
+// are written back exactly as they were read.
+func (v *hasDate) SetDate(d Date) {
+	old := *v
+	v.DateVal, v.DateRange, v.DateSpan, v.DateStr = nil, nil, nil, nil
+	switch d.Modifier {
+	case ModTextOnly:
+		v.DateStr = &DateStr{Val: d.Text}
+		if old.DateStr != nil {
+			v.DateStr.Unparsed = old.DateStr.Unparsed
+		}
+	case ModRange, ModSpan:
+		r := &DateRange{dateCommon: d.common()}
+		prev := old.DateRange
+		if d.Modifier == ModSpan {
+			prev = old.DateSpan
+		}
+		if prev != nil {
+			r.Start, r.Stop = prev.Start, prev.Stop
+			r.Unparsed = prev.Unparsed
+		}
+		r.Start, r.Stop = formatYMD(d.Start, r.Start), formatYMD(d.Stop, r.Stop)
+		if d.Modifier == ModRange {
+			r.XMLName.Local = "daterange"
+			v.DateRange = r
+		} else {
+			r.XMLName.Local = "datespan"
+			v.DateSpan = r
+		}
+	default:
+		if d.IsEmpty() {
+			return
+		}
+		dv := &DateVal{Type: modifierNames[d.Modifier], dateCommon: d.common()}
+		if old.DateVal != nil {
+			dv.Val = old.DateVal.Val
+			dv.Unparsed = old.DateVal.Unparsed
+		}
+		dv.Val = formatYMD(d.Start, dv.Val)
+		v.DateVal = dv
+	}
+}
@@ -0,0 +1,180 @@
+package xml
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseYMD(t *testing.T) {
+	for _, test := range []struct {
+		val  string
+		want YMD
+		str  string
+	}{
+		{"1897", YMD{1897, 0, 0}, "1897"},
+		{"1897-03", YMD{1897, 3, 0}, "1897-03"},
+		{"1897-03-00", YMD{1897, 3, 0}, "1897-03"},
+		{"1897-03-15", YMD{1897, 3, 15}, "1897-03-15"},
+		{"????-03-15", YMD{0, 3, 15}, "????-03-15"},
+		{"1897-??-15", YMD{1897, 0, 15}, "1897-??-15"},
+		{"0044", YMD{44, 0, 0}, "0044"},
+		{"-0044-03-15", YMD{-44, 3, 15}, "-044-03-15"},
+	} {
+		got, err := parseYMD(test.val)
+		if err != nil {
+			t.Errorf("Failed to parse %s: %s", test.val, err)
+			continue
+		}
+		if got != test.want || got.String() != test.str {
+			t.Errorf("%s: got %v (%s), want %v (%s)", test.val, got, got, test.want, test.str)
+		}
+	}
+	for _, val := range []string{"", "march 1897", "1897-14", "1897-03-32", "1897/03/15"} {
+		if _, err := parseYMD(val); err == nil {
+			t.Errorf("Parsed invalid date %q", val)
+		}
+	}
+}
+
+func TestGetDate(t *testing.T) {
+	var v hasDate
+	v.DateVal = &DateVal{Val: "1827-04-24", Type: "about"}
+	v.DateVal.Quality, v.DateVal.CFormat, v.DateVal.DualDated = "estimated", "Julian", "1"
+	d, err := v.GetDate()
+	if err != nil {
+		t.Fatalf("Failed to get date: %s", err)
+	}
+	want := Date{Modifier: ModAbout, Quality: QualityEstimated, Calendar: Julian,
+		Start: YMD{1827, 4, 24}, DualDated: true}
+	if d != want {
+		t.Errorf("Got %#v, want %#v", d, want)
+	}
+	if s := d.String(); s != "estimated about 1827-04-24 (Julian)" {
+		t.Errorf("Got %q", s)
+	}
+
+	v = hasDate{DateSpan: &DateRange{Start: "1889", Stop: "2019-05"}}
+	if d, err = v.GetDate(); err != nil || d.Modifier != ModSpan ||
+		d.Start != (YMD{1889, 0, 0}) || d.Stop != (YMD{2019, 5, 0}) {
+		t.Errorf("Got %#v, %v for a span", d, err)
+	}
+
+	v = hasDate{DateStr: &DateStr{Val: "Christmas 1900"}}
+	if d, err = v.GetDate(); err != nil || d.Modifier != ModTextOnly || d.Text != "Christmas 1900" {
+		t.Errorf("Got %#v, %v for a text date", d, err)
+	}
+
+	v = hasDate{DateVal: &DateVal{Val: "1900", Type: "circa"}}
+	if _, err = v.GetDate(); err == nil {
+		t.Error("Got a date with an invalid type")
+	}
+	if d, err = (&hasDate{}).GetDate(); err != nil || !d.IsEmpty() {
+		t.Errorf("Got %#v, %v for no date", d, err)
+	}
+}
+
+func TestSortValue(t *testing.T) {
+	for _, test := range []struct {
+		d    Date
+		want int
+	}{
+		{Date{Start: YMD{2000, 1, 1}}, 2451545},
+		{Date{Start: YMD{1582, 10, 15}}, 2299161},
+		{Date{Start: YMD{1582, 10, 5}, Calendar: Julian}, 2299161},
+		{Date{Start: YMD{1582, 10, 0}}, 2299147},
+		{Date{Modifier: ModTextOnly, Text: "1900"}, 0},
+		{Date{}, 0},
+	} {
+		if got := test.d.SortValue(); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.d, got, test.want)
+		}
+	}
+
+	dates := Dates{{Start: YMD{1900, 2, 0}}, {Start: YMD{1899, 0, 0}},
+		{Start: YMD{1900, 1, 31}}}
+	sort.Sort(dates)
+	if dates[0].Start.Year != 1899 || dates[1].Start.Month != 1 ||
+		dates[2].Compare(dates[1]) != 1 {
+		t.Errorf("Got %v", dates)
+	}
+}
+
+type dater interface {
+	GetDate() (Date, error)
+	SetDate(d Date)
+}
+
+// Call f with every struct with a date in v.
+func eachDate(v reflect.Value, f func(dater)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			eachDate(v.Elem(), f)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			eachDate(v.Index(i), f)
+		}
+	case reflect.Struct:
+		if d, ok := v.Addr().Interface().(dater); ok {
+			f(d)
+		}
+		for _, field := range fieldsOf(v.Type()) {
+			eachDate(v.FieldByIndex(field.index), f)
+		}
+	}
+}
+
+// Setting every date to itself must not change the file.
+func TestSetDateExample(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	var want bytes.Buffer
+	if err := db.Write(&want, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatalf("Failed to write db: %s", err)
+	}
+
+	n := 0
+	eachDate(reflect.ValueOf(db), func(v dater) {
+		d, err := v.GetDate()
+		if err != nil {
+			t.Errorf("Failed to get date: %s", err)
+			return
+		}
+		if !d.IsEmpty() {
+			n++
+		}
+		v.SetDate(d)
+	})
+	if n < 2000 {
+		t.Errorf("Found only %d dates", n)
+	}
+
+	var got bytes.Buffer
+	if err := db.Write(&got, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatalf("Failed to write db: %s", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("Setting dates changed the file")
+	}
+}
+
+func TestSetDate(t *testing.T) {
+	var v hasDate
+	v.SetDate(Date{Modifier: ModRange, Quality: QualityCalculated, Start: YMD{1850, 0, 0},
+		Stop: YMD{1860, 6, 0}})
+	if r := v.DateRange; r == nil || r.Start != "1850" || r.Stop != "1860-06" ||
+		r.Quality != "calculated" || v.DateVal != nil {
+		t.Errorf("Got %#v", v)
+	}
+	v.SetDate(Date{Modifier: ModBefore, Start: YMD{1850, 3, 1}, NewYear: "Mar25"})
+	if d := v.DateVal; d == nil || d.Val != "1850-03-01" || d.Type != "before" ||
+		d.NewYear != "Mar25" || v.DateRange != nil {
+		t.Errorf("Got %#v", v)
+	}
+	v.SetDate(Date{})
+	if v != (hasDate{}) {
+		t.Errorf("Got %#v for no date", v)
+	}
+}
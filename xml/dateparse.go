@@ -0,0 +1,327 @@
+package xml
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A DateParser reads Dates from text, like the date parser of Gramps. It
+// understands the formats a DateDisplayer writes in its language, the short
+// forms of modifiers with or without their dots, and a few more words such as
+// "circa". Text that is not a date is kept as a text-only Date.
+type DateParser struct {
+	Lang *DateLang
+}
+
+// Create a DateParser for a language code such as "de" or "de_DE".
+func NewDateParser(lang string) (*DateParser, error) {
+	l, err := findDateLang(lang)
+	if err != nil {
+		return nil, err
+	}
+	return &DateParser{Lang: l}, nil
+}
+
+// DefaultDateParser is how SetDateString reads dates: in English.
+var DefaultDateParser = &DateParser{Lang: DateLangs["en"]}
+
+var (
+	// The calendar and new year following a date, e.g. (Julian,Mar25).
+	extrasPattern  = regexp.MustCompile(`^(.*?)\s*\(([^()]*)\)$`)
+	newYearPattern = regexp.MustCompile(`^\d{1,2}-\d{1,2}$`)
+	isoPattern     = regexp.MustCompile(`^(\d+(?:/\d+)?|\?+)(?:-(\d{1,2}|\?+)(?:-(\d{1,2}|\?+))?)?$`)
+	numericPattern = regexp.MustCompile(`^(\d{1,2})[./-](\d{1,2})[./-](\d+(?:/\d+)?)$`)
+	monthPattern   = regexp.MustCompile(`^(\d{1,2})[./-](\d{3,}(?:/\d+)?)$`)
+	yearPattern    = regexp.MustCompile(`^(\d+)(?:/(\d+))?$`)
+)
+
+// The suffixes of years before the common era in every language.
+var bceSuffixes = []string{"bc", "b.c.", "bce", "b.c.e."}
+
+// Get the Date in text. Text is compared without regard to case.
+func (p *DateParser) Parse(text string) Date {
+	text = strings.TrimSpace(text)
+	s := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	if s == "" {
+		return Date{}
+	}
+	d, ok := p.parse(s)
+	if !ok {
+		return Date{Modifier: ModTextOnly, Text: text}
+	}
+	return d
+}
+
+func (p *DateParser) parse(s string) (Date, bool) {
+	var d Date
+	if m := extrasPattern.FindStringSubmatch(s); m != nil {
+		s = m[1]
+		for _, extra := range strings.Split(m[2], ",") {
+			extra = strings.TrimSpace(extra)
+			if cal, ok := p.calendar(extra); ok {
+				d.Calendar = cal
+				continue
+			}
+			switch {
+			case extra == "mar1":
+				d.NewYear = "Mar1"
+			case extra == "mar25":
+				d.NewYear = "Mar25"
+			case extra == "sep1":
+				d.NewYear = "Sep1"
+			case newYearPattern.MatchString(extra):
+				d.NewYear = extra
+			default:
+				return Date{}, false
+			}
+		}
+	}
+
+	qualities := map[string]int{}
+	for q, w := range p.Lang.Qualities {
+		addWord(qualities, w, int(q))
+	}
+	for q, w := range p.Lang.ShortQualities {
+		addWord(qualities, w, int(q))
+	}
+	if q, rest, ok := prefixWord(s, qualities); ok {
+		d.Quality, s = Quality(q), rest
+	}
+
+	forms := map[Modifier][]string{
+		ModRange: append([]string{p.Lang.Range}, p.Lang.RangeAliases...),
+		ModSpan:  append([]string{p.Lang.Span}, p.Lang.SpanAliases...),
+	}
+	for _, mod := range []Modifier{ModRange, ModSpan} {
+		for _, form := range forms[mod] {
+			start, stop, ok := splitForm(s, form)
+			if !ok {
+				continue
+			}
+			var dual1, dual2 bool
+			if d.Start, dual1, ok = p.day(start, d.Calendar); !ok {
+				continue
+			}
+			if d.Stop, dual2, ok = p.day(stop, d.Calendar); !ok {
+				continue
+			}
+			d.Modifier, d.DualDated = mod, dual1 || dual2
+			return d, true
+		}
+	}
+
+	modifiers := map[string]int{}
+	for m, w := range p.Lang.Modifiers {
+		addWord(modifiers, w, int(m))
+	}
+	for m, w := range p.Lang.ShortModifiers {
+		addWord(modifiers, w, int(m))
+	}
+	for w, m := range p.Lang.ModifierAliases {
+		addWord(modifiers, w, int(m))
+	}
+	if m, rest, ok := prefixWord(s, modifiers); ok {
+		d.Modifier, s = Modifier(m), rest
+	}
+	var ok bool
+	if d.Start, d.DualDated, ok = p.day(s, d.Calendar); !ok {
+		return Date{}, false
+	}
+	return d, true
+}
+
+// Add a word to words, also without its final dot.
+func addWord(words map[string]int, w string, v int) {
+	w = strings.ToLower(w)
+	words[w] = v
+	if strings.HasSuffix(w, ".") {
+		words[strings.TrimSuffix(w, ".")] = v
+	}
+}
+
+// Find the longest word of words that s starts with, followed by a space.
+// Returns the value of the word and the rest of s.
+func prefixWord(s string, words map[string]int) (int, string, bool) {
+	found := ""
+	for w := range words {
+		if len(w) > len(found) && strings.HasPrefix(s, w+" ") {
+			found = w
+		}
+	}
+	if found == "" {
+		return 0, s, false
+	}
+	return words[found], s[len(found)+1:], true
+}
+
+// Split s into the two days of a range or span format such as
+// "between %s and %s".
+func splitForm(s, form string) (start, stop string, ok bool) {
+	parts := strings.Split(strings.ToLower(form), "%s")
+	if len(parts) != 3 || !strings.HasPrefix(s, parts[0]) || !strings.HasSuffix(s, parts[2]) {
+		return "", "", false
+	}
+	body := s[len(parts[0]) : len(s)-len(parts[2])]
+	i := strings.Index(body, parts[1])
+	if i < 0 {
+		return "", "", false
+	}
+	return body[:i], body[i+len(parts[1]):], true
+}
+
+// Get the calendar named name, in the language of p or as in cformat.
+func (p *DateParser) calendar(name string) (Calendar, bool) {
+	if name == "gregorian" {
+		return Gregorian, true
+	}
+	for _, names := range []map[Calendar]string{p.Lang.Calendars, calendarNames} {
+		for cal, n := range names {
+			if strings.ToLower(n) == name {
+				return cal, true
+			}
+		}
+	}
+	return Gregorian, false
+}
+
+// Parse a year, which may be dual dated with the last digits of the next
+// year as in 1721/22. As in Gramps, the year of a dual dated date is the later
+// one.
+func parseYear(s string) (year int, dual bool, ok bool) {
+	m := yearPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false, false
+	}
+	year, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		if len(m[2]) >= len(m[1]) || !strings.HasSuffix(strconv.Itoa(year+1), m[2]) {
+			return 0, false, false
+		}
+		year, dual = year+1, true
+	}
+	return year, dual, true
+}
+
+// Parse a month or day, where ? is unknown.
+func parsePart(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
+
+// Parse one day in the calendar cal: in ISO, numeric or text format.
+func (p *DateParser) day(s string, cal Calendar) (YMD, bool, bool) {
+	s = strings.TrimSpace(s)
+	bce := false
+	for _, suffix := range append(bceSuffixes, strings.ToLower(strings.Replace(p.Lang.BCE, "%s", "", 1))) {
+		if suffix = strings.TrimSpace(suffix); strings.HasSuffix(s, " "+suffix) {
+			s, bce = strings.TrimSpace(strings.TrimSuffix(s, suffix)), true
+			break
+		}
+	}
+	if strings.HasPrefix(s, "-") {
+		s, bce = s[1:], true
+	}
+
+	var v YMD
+	var dual, ok bool
+	if m := isoPattern.FindStringSubmatch(s); m != nil {
+		v.Month, v.Day = parsePart(m[2]), parsePart(m[3])
+		ok = true
+		if !strings.HasPrefix(m[1], "?") {
+			v.Year, dual, ok = parseYear(m[1])
+		}
+		// 15-03-44 is not ISO but numeric.
+		ok = ok && validDay(v, cal)
+	}
+	if m := numericPattern.FindStringSubmatch(s); !ok && m != nil {
+		v.Month, v.Day = parsePart(m[1]), parsePart(m[2])
+		if p.Lang.NumericOrder != "mdy" {
+			v.Day, v.Month = v.Month, v.Day
+		}
+		v.Year, dual, ok = parseYear(m[3])
+	}
+	if m := monthPattern.FindStringSubmatch(s); !ok && m != nil {
+		v = YMD{Month: parsePart(m[1])}
+		v.Year, dual, ok = parseYear(m[2])
+	}
+	if !ok {
+		v, dual, ok = p.textDay(s, cal)
+	}
+	if !ok || !validDay(v, cal) || (bce && v.Year == 0) {
+		return YMD{}, false, false
+	}
+	if bce {
+		v.Year = -v.Year
+	}
+	return v, dual, true
+}
+
+// Parse a day with the name of its month, e.g. 12 March 1897 or March 12,
+// 1897.
+func (p *DateParser) textDay(s string, cal Calendar) (YMD, bool, bool) {
+	months := map[string]int{}
+	if names := calendarMonths[cal]; names != nil {
+		for i, name := range names {
+			addWord(months, name, i+1)
+		}
+	} else {
+		for i := range p.Lang.Months {
+			addWord(months, p.Lang.Months[i], i+1)
+			addWord(months, p.Lang.ShortMonths[i], i+1)
+		}
+	}
+	var v YMD
+	var nums []string
+	monthAt := -1
+	for i, tok := range strings.Fields(strings.Replace(s, ",", " ", -1)) {
+		if m, ok := months[tok]; ok && monthAt < 0 {
+			v.Month, monthAt = m, i
+			continue
+		}
+		if m, ok := months[strings.TrimSuffix(tok, ".")]; ok && monthAt < 0 {
+			v.Month, monthAt = m, i
+			continue
+		}
+		if n := strings.TrimSuffix(tok, "."); yearPattern.MatchString(n) {
+			nums = append(nums, n)
+			continue
+		}
+		return YMD{}, false, false
+	}
+	if monthAt < 0 || monthAt > len(nums) || len(nums) > 2 ||
+		(len(nums) == 2 && monthAt == 2) {
+		return YMD{}, false, false
+	}
+	var dual, ok bool
+	switch {
+	case len(nums) == 2:
+		if len(nums[0]) > 2 {
+			return YMD{}, false, false
+		}
+		v.Day = parsePart(nums[0])
+		v.Year, dual, ok = parseYear(nums[1])
+		return v, dual, ok
+	case len(nums) == 1 && len(nums[0]) <= 2:
+		v.Day = parsePart(nums[0])
+	case len(nums) == 1:
+		v.Year, dual, ok = parseYear(nums[0])
+		return v, dual, ok
+	}
+	return v, false, true
+}
+
+// Whether v is a day of the calendar cal.
+func validDay(v YMD, cal Calendar) bool {
+	months := 12
+	if cal == Hebrew || cal == FrenchRepublican {
+		months = 13
+	}
+	if v.Month > months || v.Day > 31 {
+		return false
+	}
+	if v.Year == 0 || v.Month == 0 || v.Day == 0 {
+		return true
+	}
+	return cal.FromJDN(cal.JDN(v)) == v
+}
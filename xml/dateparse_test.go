@@ -0,0 +1,128 @@
+package xml
+
+import (
+	"testing"
+)
+
+func TestParseDate(t *testing.T) {
+	for _, test := range []struct {
+		lang string
+		text string
+		want Date
+	}{
+		{"en", "1850", Date{Start: YMD{1850, 0, 0}}},
+		{"en", "1850-03-12", Date{Start: YMD{1850, 3, 12}}},
+		{"en", "????-03-12", Date{Start: YMD{0, 3, 12}}},
+		{"en", "abt 1850", Date{Modifier: ModAbout, Start: YMD{1850, 0, 0}}},
+		{"en", "Abt. 1850", Date{Modifier: ModAbout, Start: YMD{1850, 0, 0}}},
+		{"en", "circa 1850", Date{Modifier: ModAbout, Start: YMD{1850, 0, 0}}},
+		{"en", "bef 12 March 1850", Date{Modifier: ModBefore, Start: YMD{1850, 3, 12}}},
+		{"en", "after Mar 12, 1850", Date{Modifier: ModAfter, Start: YMD{1850, 3, 12}}},
+		{"en", "est. about 1850", Date{Modifier: ModAbout, Quality: QualityEstimated,
+			Start: YMD{1850, 0, 0}}},
+		{"en", "calculated 1850", Date{Quality: QualityCalculated, Start: YMD{1850, 0, 0}}},
+		{"en", "March 1850", Date{Start: YMD{1850, 3, 0}}},
+		{"en", "March 12", Date{Start: YMD{0, 3, 12}}},
+		{"en", "03/12/1850", Date{Start: YMD{1850, 3, 12}}},
+		{"en", "3/1850", Date{Start: YMD{1850, 3, 0}}},
+		{"en", "between 1820 and 1825", Date{Modifier: ModRange, Start: YMD{1820, 0, 0},
+			Stop: YMD{1825, 0, 0}}},
+		{"en", "bet. 1820 and 1825", Date{Modifier: ModRange, Start: YMD{1820, 0, 0},
+			Stop: YMD{1825, 0, 0}}},
+		{"en", "from 3 Mar 1900 to 1910", Date{Modifier: ModSpan, Start: YMD{1900, 3, 3},
+			Stop: YMD{1910, 0, 0}}},
+		{"en", "25 Dec 1720 (Julian)", Date{Calendar: Julian, Start: YMD{1720, 12, 25}}},
+		{"en", "1721/22", Date{Start: YMD{1722, 0, 0}, DualDated: true}},
+		{"en", "5 January 1721/2 (Julian,Mar25)", Date{Calendar: Julian,
+			Start: YMD{1722, 1, 5}, DualDated: true, NewYear: "Mar25"}},
+		{"en", "1799/800-02-01", Date{Start: YMD{1800, 2, 1}, DualDated: true}},
+		{"en", "44 B.C.E.", Date{Start: YMD{-44, 0, 0}}},
+		{"en", "March 15, 44 BC", Date{Start: YMD{-44, 3, 15}}},
+		{"en", "15 Nisan 5760 (Hebrew)", Date{Calendar: Hebrew, Start: YMD{5760, 8, 15}}},
+		{"en", "18 Brumaire 8 (French Republican)", Date{Calendar: FrenchRepublican,
+			Start: YMD{8, 2, 18}}},
+
+		{"de", "um 1850", Date{Modifier: ModAbout, Start: YMD{1850, 0, 0}}},
+		{"de", "12. März 1850", Date{Start: YMD{1850, 3, 12}}},
+		{"de", "12.03.1850", Date{Start: YMD{1850, 3, 12}}},
+		{"de", "zwischen 1820 und 1825", Date{Modifier: ModRange, Start: YMD{1820, 0, 0},
+			Stop: YMD{1825, 0, 0}}},
+		{"fr", "vers 1850", Date{Modifier: ModAbout, Start: YMD{1850, 0, 0}}},
+		{"fr", "de 1 mai 1889 à 1890 (julien)", Date{Modifier: ModSpan, Calendar: Julian,
+			Start: YMD{1889, 5, 1}, Stop: YMD{1890, 0, 0}}},
+		{"fr", "12/03/1850", Date{Start: YMD{1850, 3, 12}}},
+		{"nl", "tussen 1820 en 1825", Date{Modifier: ModRange, Start: YMD{1820, 0, 0},
+			Stop: YMD{1825, 0, 0}}},
+		{"nl", "12 mrt 1850", Date{Start: YMD{1850, 3, 12}}},
+		{"es", "antes de 1850", Date{Modifier: ModBefore, Start: YMD{1850, 0, 0}}},
+		{"es", "desde 1820 hasta 1825", Date{Modifier: ModSpan, Start: YMD{1820, 0, 0},
+			Stop: YMD{1825, 0, 0}}},
+
+		{"en", "", Date{}},
+		{"en", "Easter 1850", Date{Modifier: ModTextOnly, Text: "Easter 1850"}},
+		{"en", "30 February 1850", Date{Modifier: ModTextOnly, Text: "30 February 1850"}},
+		{"en", "1721/23", Date{Modifier: ModTextOnly, Text: "1721/23"}},
+		{"en", "1850 (Klingon)", Date{Modifier: ModTextOnly, Text: "1850 (Klingon)"}},
+		{"en", "12 März 1850", Date{Modifier: ModTextOnly, Text: "12 März 1850"}},
+	} {
+		p, err := NewDateParser(test.lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Parse(test.text); got != test.want {
+			t.Errorf("%s %q: got %#v, want %#v", test.lang, test.text, got, test.want)
+		}
+	}
+}
+
+// Dates written by a DateDisplayer are read back by a DateParser.
+func TestParseDisplayed(t *testing.T) {
+	dates := []Date{
+		{Start: YMD{1897, 3, 12}},
+		{Start: YMD{1897, 3, 0}},
+		{Modifier: ModAbout, Quality: QualityEstimated, Start: YMD{1897, 0, 0}},
+		{Modifier: ModRange, Start: YMD{1889, 5, 1}, Stop: YMD{2019, 0, 0}},
+		{Modifier: ModSpan, Calendar: Julian, Start: YMD{1749, 2, 1}, Stop: YMD{1751, 0, 0},
+			DualDated: true, NewYear: "Mar25"},
+		{Modifier: ModBefore, Start: YMD{-44, 3, 15}},
+	}
+	for lang := range DateLangs {
+		p, err := NewDateParser(lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for format := FormatISO; format <= FormatDayShortMonthYear; format++ {
+			for _, abbreviate := range []bool{false, true} {
+				dd := &DateDisplayer{Lang: DateLangs[lang], Format: format, Abbreviate: abbreviate}
+				for _, d := range dates {
+					text := dd.Display(d)
+					if got := p.Parse(text); got != d {
+						t.Errorf("%s %d: %q read as %#v", lang, format, text, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSetDateString(t *testing.T) {
+	var v hasDate
+	v.SetDateString("from 3 Mar 1900 to 1910")
+	if v.DateSpan == nil || v.DateSpan.XMLName.Local != "datespan" ||
+		v.DateSpan.Start != "1900-03-03" || v.DateSpan.Stop != "1910" {
+		t.Errorf("Got %#v", v)
+	}
+	v.SetDateString("abt 1850 (Julian)")
+	if v.DateSpan != nil || v.DateVal == nil || v.DateVal.Val != "1850" ||
+		v.DateVal.Type != "about" || v.DateVal.CFormat != "Julian" {
+		t.Errorf("Got %#v", v)
+	}
+	v.SetDateString("Easter")
+	if v.DateVal != nil || v.DateStr == nil || v.DateStr.Val != "Easter" {
+		t.Errorf("Got %#v", v)
+	}
+	v.SetDateString("")
+	if v != (hasDate{}) {
+		t.Errorf("Got %#v", v)
+	}
+}
@@ -0,0 +1,362 @@
+package xml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How a DateDisplayer writes the days of dates. The formats are those of the
+// date displayers of Gramps, in the same order.
+type DateFormat int
+
+const (
+	// 1897-03-12
+	FormatISO DateFormat = iota
+	// 03/12/1897, with the order and separator of the language.
+	FormatNumeric
+	// March 12, 1897
+	FormatMonthDayYear
+	// Mar 12, 1897
+	FormatShortMonthDayYear
+	// 12 March 1897
+	FormatDayMonthYear
+	// 12 Mar 1897
+	FormatDayShortMonthYear
+)
+
+// A DateLang holds the words dates are written and read with in a language.
+type DateLang struct {
+	// The months of the Gregorian, Julian and Swedish calendars.
+	Months, ShortMonths [12]string
+	// The words for modifiers and qualities, and their short forms.
+	Modifiers, ShortModifiers map[Modifier]string
+	Qualities, ShortQualities map[Quality]string
+	// Formats of the two days of a range and a span, e.g. "between %s and %s".
+	Range, Span string
+	// The format of years before the common era, e.g. "%s B.C.E.".
+	BCE string
+	// The names of the calendars other than the Gregorian.
+	Calendars map[Calendar]string
+	// The order of the day, month and year in FormatNumeric, e.g. "mdy", and
+	// the separator between them.
+	NumericOrder, NumericSep string
+	// Whether the day is followed by a dot when it comes before the month, as
+	// in German.
+	DayDot bool
+	// Other words and formats recognized by a DateParser, e.g. "circa" and
+	// "bet %s and %s".
+	ModifierAliases map[string]Modifier
+	RangeAliases    []string
+	SpanAliases     []string
+}
+
+// The languages dates can be displayed and parsed in, by language code.
+var DateLangs = map[string]*DateLang{
+	"en": {
+		Months: [12]string{"January", "February", "March", "April", "May", "June",
+			"July", "August", "September", "October", "November", "December"},
+		ShortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun",
+			"Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		Modifiers:      map[Modifier]string{ModBefore: "before", ModAfter: "after", ModAbout: "about"},
+		ShortModifiers: map[Modifier]string{ModBefore: "bef.", ModAfter: "aft.", ModAbout: "abt."},
+		Qualities:      map[Quality]string{QualityEstimated: "estimated", QualityCalculated: "calculated"},
+		ShortQualities: map[Quality]string{QualityEstimated: "est.", QualityCalculated: "calc."},
+		Range:          "between %s and %s",
+		Span:           "from %s to %s",
+		BCE:            "%s B.C.E.",
+		Calendars: map[Calendar]string{Julian: "Julian", Hebrew: "Hebrew",
+			FrenchRepublican: "French Republican", Persian: "Persian", Islamic: "Islamic",
+			Swedish: "Swedish"},
+		NumericOrder: "mdy",
+		NumericSep:   "/",
+		ModifierAliases: map[string]Modifier{"circa": ModAbout, "c.": ModAbout,
+			"ca.": ModAbout, "around": ModAbout},
+		RangeAliases: []string{"bet %s and %s", "bet. %s and %s", "btw %s and %s"},
+	},
+	"de": {
+		Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+			"Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun",
+			"Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		Modifiers:      map[Modifier]string{ModBefore: "vor", ModAfter: "nach", ModAbout: "um"},
+		ShortModifiers: map[Modifier]string{ModBefore: "vor", ModAfter: "nach", ModAbout: "ca."},
+		Qualities:      map[Quality]string{QualityEstimated: "geschätzt", QualityCalculated: "errechnet"},
+		ShortQualities: map[Quality]string{QualityEstimated: "gesch.", QualityCalculated: "err."},
+		Range:          "zwischen %s und %s",
+		Span:           "von %s bis %s",
+		BCE:            "%s v. u. Z.",
+		Calendars: map[Calendar]string{Julian: "Julianisch", Hebrew: "Hebräisch",
+			FrenchRepublican: "Französisch Republikanisch", Persian: "Persisch",
+			Islamic: "Islamisch", Swedish: "Schwedisch"},
+		NumericOrder: "dmy",
+		NumericSep:   ".",
+		DayDot:       true,
+		ModifierAliases: map[string]Modifier{"circa": ModAbout, "etwa": ModAbout,
+			"ungefähr": ModAbout},
+		RangeAliases: []string{"zw. %s und %s"},
+	},
+	"fr": {
+		Months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin",
+			"juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		ShortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin",
+			"juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		Modifiers:      map[Modifier]string{ModBefore: "avant", ModAfter: "après", ModAbout: "vers"},
+		ShortModifiers: map[Modifier]string{ModBefore: "av.", ModAfter: "ap.", ModAbout: "v."},
+		Qualities:      map[Quality]string{QualityEstimated: "estimée", QualityCalculated: "calculée"},
+		ShortQualities: map[Quality]string{QualityEstimated: "est.", QualityCalculated: "calc."},
+		Range:          "entre %s et %s",
+		Span:           "de %s à %s",
+		BCE:            "%s av. J.-C.",
+		Calendars: map[Calendar]string{Julian: "julien", Hebrew: "hébraïque",
+			FrenchRepublican: "républicain", Persian: "persan", Islamic: "islamique",
+			Swedish: "suédois"},
+		NumericOrder: "dmy",
+		NumericSep:   "/",
+		ModifierAliases: map[string]Modifier{"environ": ModAbout, "circa": ModAbout,
+			"ca.": ModAbout},
+		SpanAliases: []string{"du %s au %s"},
+	},
+	"nl": {
+		Months: [12]string{"januari", "februari", "maart", "april", "mei", "juni",
+			"juli", "augustus", "september", "oktober", "november", "december"},
+		ShortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun",
+			"jul", "aug", "sep", "okt", "nov", "dec"},
+		Modifiers:      map[Modifier]string{ModBefore: "voor", ModAfter: "na", ModAbout: "omstreeks"},
+		ShortModifiers: map[Modifier]string{ModBefore: "vóór", ModAfter: "na", ModAbout: "ca."},
+		Qualities:      map[Quality]string{QualityEstimated: "geschat", QualityCalculated: "berekend"},
+		ShortQualities: map[Quality]string{QualityEstimated: "gesch.", QualityCalculated: "ber."},
+		Range:          "tussen %s en %s",
+		Span:           "van %s tot %s",
+		BCE:            "%s v. Chr.",
+		Calendars: map[Calendar]string{Julian: "Juliaans", Hebrew: "Hebreeuws",
+			FrenchRepublican: "Frans republikeins", Persian: "Perzisch", Islamic: "Islamitisch",
+			Swedish: "Zweeds"},
+		NumericOrder: "dmy",
+		NumericSep:   "-",
+		ModifierAliases: map[string]Modifier{"circa": ModAbout, "rond": ModAbout,
+			"ongeveer": ModAbout},
+	},
+	"es": {
+		Months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio",
+			"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		ShortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun",
+			"jul", "ago", "sep", "oct", "nov", "dic"},
+		Modifiers:      map[Modifier]string{ModBefore: "antes de", ModAfter: "después de", ModAbout: "hacia"},
+		ShortModifiers: map[Modifier]string{ModBefore: "ant.", ModAfter: "desp.", ModAbout: "h."},
+		Qualities:      map[Quality]string{QualityEstimated: "estimado", QualityCalculated: "calculado"},
+		ShortQualities: map[Quality]string{QualityEstimated: "est.", QualityCalculated: "calc."},
+		Range:          "entre %s y %s",
+		Span:           "desde %s hasta %s",
+		BCE:            "%s a. C.",
+		Calendars: map[Calendar]string{Julian: "Juliano", Hebrew: "Hebreo",
+			FrenchRepublican: "Republicano francés", Persian: "Persa", Islamic: "Islámico",
+			Swedish: "Sueco"},
+		NumericOrder: "dmy",
+		NumericSep:   "/",
+		ModifierAliases: map[string]Modifier{"antes": ModBefore, "después": ModAfter,
+			"circa": ModAbout, "ca.": ModAbout},
+		SpanAliases: []string{"de %s a %s"},
+	},
+}
+
+// The months of the other calendars, which Gramps writes the same way in every
+// language.
+var calendarMonths = map[Calendar][]string{
+	Hebrew: {"Tishri", "Heshvan", "Kislev", "Tevet", "Shevat", "AdarI", "AdarII",
+		"Nisan", "Iyyar", "Sivan", "Tammuz", "Av", "Elul"},
+	FrenchRepublican: {"Vendémiaire", "Brumaire", "Frimaire", "Nivôse", "Pluviôse",
+		"Ventôse", "Germinal", "Floréal", "Prairial", "Messidor", "Thermidor",
+		"Fructidor", "Extra"},
+	Persian: {"Farvardin", "Ordibehesht", "Khordad", "Tir", "Mordad", "Shahrivar",
+		"Mehr", "Aban", "Azar", "Dey", "Bahman", "Esfand"},
+	Islamic: {"Muharram", "Safar", "Rabi`al-Awwal", "Rabi`ath-Thani", "Jumada l-Ula",
+		"Jumada t-Tania", "Rajab", "Sha`ban", "Ramadan", "Shawwal", "Dhu l-Qa`da",
+		"Dhu l-Hijja"},
+}
+
+// A DateDisplayer writes Dates as text, like the date displayers of Gramps.
+type DateDisplayer struct {
+	Lang   *DateLang
+	Format DateFormat
+	// Whether to write the short forms of modifiers and qualities, e.g. abt.
+	Abbreviate bool
+}
+
+// Get the DateLang for a language code such as "de" or "de_DE".
+func findDateLang(lang string) (*DateLang, error) {
+	l := DateLangs[lang]
+	if l == nil {
+		if i := strings.IndexAny(lang, "_-"); i > 0 {
+			l = DateLangs[lang[:i]]
+		}
+	}
+	if l == nil {
+		return nil, fmt.Errorf("Unknown date language: %q", lang)
+	}
+	return l, nil
+}
+
+// Create a DateDisplayer for a language code such as "de" or "de_DE".
+func NewDateDisplayer(lang string, format DateFormat) (*DateDisplayer, error) {
+	l, err := findDateLang(lang)
+	if err != nil {
+		return nil, err
+	}
+	return &DateDisplayer{Lang: l, Format: format}, nil
+}
+
+// DefaultDateDisplayer is how GetDateString writes dates: in English, in the
+// ISO format.
+var DefaultDateDisplayer = &DateDisplayer{Lang: DateLangs["en"]}
+
+// Get the text of d.
+func (dd *DateDisplayer) Display(d Date) string {
+	if d.Modifier == ModTextOnly {
+		return d.Text
+	}
+	if d.IsEmpty() {
+		return ""
+	}
+	modifiers, qualities := dd.Lang.Modifiers, dd.Lang.Qualities
+	if dd.Abbreviate {
+		modifiers, qualities = dd.Lang.ShortModifiers, dd.Lang.ShortQualities
+	}
+	var s string
+	switch d.Modifier {
+	case ModRange:
+		s = fmt.Sprintf(dd.Lang.Range, dd.day(d, d.Start), dd.day(d, d.Stop))
+	case ModSpan:
+		s = fmt.Sprintf(dd.Lang.Span, dd.day(d, d.Start), dd.day(d, d.Stop))
+	default:
+		s = dd.day(d, d.Start)
+		if m := modifiers[d.Modifier]; m != "" {
+			s = m + " " + s
+		}
+	}
+	if q := qualities[d.Quality]; q != "" {
+		s = q + " " + s
+	}
+	// The calendar and new year follow in parentheses, e.g. (Julian,Mar25).
+	var extras []string
+	if d.Calendar != Gregorian {
+		extras = append(extras, dd.Lang.Calendars[d.Calendar])
+	}
+	if d.NewYear != "" {
+		extras = append(extras, d.NewYear)
+	}
+	if len(extras) > 0 {
+		s += " (" + strings.Join(extras, ",") + ")"
+	}
+	return s
+}
+
+// Get the text of the year y. Dual dated years are the later year, shown as in
+// Gramps after the year before: 1722 is 1721/2, 1750 is 1749/50, 1800 is
+// 1799/800 and 2000 is 1999/0.
+func (dd *DateDisplayer) year(y int, dual bool) string {
+	if y == 0 {
+		return ""
+	}
+	abs := y
+	if abs < 0 {
+		abs = -abs
+	}
+	first := abs
+	if dual {
+		first = abs - 1
+	}
+	s := fmt.Sprint(first)
+	if dd.Format == FormatISO {
+		s = fmt.Sprintf("%04d", first)
+	}
+	if dual {
+		switch {
+		case first%100 == 99:
+			s += fmt.Sprintf("/%d", abs%1000)
+		case first%10 == 9:
+			s += fmt.Sprintf("/%d", abs%100)
+		default:
+			s += fmt.Sprintf("/%d", abs%10)
+		}
+	}
+	if y < 0 {
+		if dd.Format == FormatISO {
+			return "-" + s
+		}
+		return fmt.Sprintf(dd.Lang.BCE, s)
+	}
+	return s
+}
+
+// Get the name of month m of the calendar cal.
+func (dd *DateDisplayer) month(cal Calendar, m int) string {
+	months := calendarMonths[cal]
+	if months == nil {
+		months = dd.Lang.Months[:]
+		if dd.Format == FormatShortMonthDayYear || dd.Format == FormatDayShortMonthYear {
+			months = dd.Lang.ShortMonths[:]
+		}
+	}
+	if m < 1 || m > len(months) {
+		return fmt.Sprint(m)
+	}
+	return months[m-1]
+}
+
+// Get the text of v, a day of d.
+func (dd *DateDisplayer) day(d Date, v YMD) string {
+	year := dd.year(v.Year, d.DualDated)
+	switch dd.Format {
+	case FormatISO:
+		if year == "" {
+			year = "????"
+		}
+		switch {
+		case v.Month == 0 && v.Day == 0:
+			return year
+		case v.Day == 0:
+			return fmt.Sprintf("%s-%02d", year, v.Month)
+		case v.Month == 0:
+			return fmt.Sprintf("%s-??-%02d", year, v.Day)
+		}
+		return fmt.Sprintf("%s-%02d-%02d", year, v.Month, v.Day)
+	case FormatNumeric:
+		var parts []string
+		for _, c := range dd.Lang.NumericOrder {
+			switch {
+			case c == 'd' && v.Day != 0 && v.Month != 0:
+				parts = append(parts, fmt.Sprintf("%02d", v.Day))
+			case c == 'm' && v.Month != 0:
+				parts = append(parts, fmt.Sprintf("%02d", v.Month))
+			case c == 'y' && year != "":
+				parts = append(parts, year)
+			}
+		}
+		return strings.Join(parts, dd.Lang.NumericSep)
+	}
+	if v.Month == 0 {
+		return year
+	}
+	month := dd.month(d.Calendar, v.Month)
+	if v.Day == 0 {
+		return strings.TrimSpace(month + " " + year)
+	}
+	var s string
+	switch dd.Format {
+	case FormatDayMonthYear, FormatDayShortMonthYear:
+		s = fmt.Sprint(v.Day)
+		if dd.Lang.DayDot {
+			s += "."
+		}
+		s += " " + month
+		if year != "" {
+			s += " " + year
+		}
+	default:
+		s = fmt.Sprintf("%s %d", month, v.Day)
+		if year != "" {
+			s += ", " + year
+		}
+	}
+	return s
+}
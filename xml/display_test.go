@@ -0,0 +1,120 @@
+package xml
+
+import (
+	"testing"
+)
+
+func TestDisplay(t *testing.T) {
+	day := Date{Start: YMD{1897, 3, 12}}
+	month := Date{Start: YMD{1897, 3, 0}}
+	for _, test := range []struct {
+		lang   string
+		format DateFormat
+		d      Date
+		want   string
+	}{
+		{"en", FormatISO, day, "1897-03-12"},
+		{"en", FormatISO, month, "1897-03"},
+		{"en", FormatISO, Date{Start: YMD{0, 3, 12}}, "????-03-12"},
+		{"en", FormatNumeric, day, "03/12/1897"},
+		{"en", FormatNumeric, month, "03/1897"},
+		{"en", FormatMonthDayYear, day, "March 12, 1897"},
+		{"en", FormatMonthDayYear, month, "March 1897"},
+		{"en", FormatShortMonthDayYear, day, "Mar 12, 1897"},
+		{"en", FormatDayMonthYear, day, "12 March 1897"},
+		{"en", FormatDayShortMonthYear, Date{Start: YMD{1897, 0, 0}}, "1897"},
+		{"de", FormatNumeric, day, "12.03.1897"},
+		{"de", FormatDayMonthYear, day, "12. März 1897"},
+		{"fr", FormatDayShortMonthYear, day, "12 mars 1897"},
+		{"nl", FormatNumeric, day, "12-03-1897"},
+		{"es", FormatMonthDayYear, day, "marzo 12, 1897"},
+
+		{"en", FormatDayMonthYear, Date{Modifier: ModAbout, Quality: QualityEstimated,
+			Start: YMD{1897, 3, 0}}, "estimated about March 1897"},
+		{"de", FormatISO, Date{Modifier: ModBefore, Start: YMD{1897, 0, 0}}, "vor 1897"},
+		{"es", FormatISO, Date{Modifier: ModAfter, Start: YMD{1897, 0, 0}}, "después de 1897"},
+		{"en", FormatISO, Date{Modifier: ModRange, Start: YMD{1889, 0, 0},
+			Stop: YMD{2019, 0, 0}}, "between 1889 and 2019"},
+		{"fr", FormatDayMonthYear, Date{Modifier: ModSpan, Start: YMD{1889, 5, 1},
+			Stop: YMD{2019, 0, 0}}, "de 1 mai 1889 à 2019"},
+		{"nl", FormatISO, Date{Modifier: ModSpan, Start: YMD{1889, 0, 0},
+			Stop: YMD{2019, 0, 0}}, "van 1889 tot 2019"},
+		{"en", FormatISO, Date{Modifier: ModTextOnly, Text: "Easter 1900"}, "Easter 1900"},
+		{"en", FormatISO, Date{}, ""},
+
+		{"en", FormatMonthDayYear, Date{Start: YMD{-44, 3, 15}}, "March 15, 44 B.C.E."},
+		{"en", FormatISO, Date{Start: YMD{-44, 3, 15}}, "-0044-03-15"},
+		{"en", FormatDayMonthYear, Date{Calendar: Julian, Start: YMD{1722, 1, 5},
+			DualDated: true}, "5 January 1721/2 (Julian)"},
+		{"en", FormatISO, Date{Calendar: Julian, Start: YMD{1750, 2, 1},
+			DualDated: true}, "1749/50-02-01 (Julian)"},
+		{"en", FormatISO, Date{Calendar: Julian, Start: YMD{1800, 2, 1},
+			NewYear: "Mar25", DualDated: true}, "1799/800-02-01 (Julian,Mar25)"},
+		{"en", FormatDayMonthYear, Date{Calendar: Julian, Start: YMD{2000, 2, 1},
+			DualDated: true}, "1 February 1999/0 (Julian)"},
+		{"en", FormatISO, Date{Start: YMD{1700, 3, 1}, NewYear: "03-25"}, "1700-03-01 (03-25)"},
+		{"de", FormatDayMonthYear, Date{Calendar: Hebrew, Start: YMD{5760, 8, 15}},
+			"15. Nisan 5760 (Hebräisch)"},
+		{"fr", FormatDayMonthYear, Date{Calendar: FrenchRepublican, Start: YMD{8, 2, 18}},
+			"18 Brumaire 8 (républicain)"},
+	} {
+		dd, err := NewDateDisplayer(test.lang, test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dd.Display(test.d); got != test.want {
+			t.Errorf("%s %d %v: got %q, want %q", test.lang, test.format, test.d, got, test.want)
+		}
+	}
+}
+
+func TestDisplayAbbreviated(t *testing.T) {
+	dd, err := NewDateDisplayer("en_GB", FormatISO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dd.Abbreviate = true
+	d := Date{Modifier: ModAbout, Quality: QualityEstimated, Start: YMD{1897, 0, 0}}
+	if got := dd.Display(d); got != "est. abt. 1897" {
+		t.Errorf("Got %q", got)
+	}
+	if _, err := NewDateDisplayer("xx", FormatISO); err == nil {
+		t.Error("Created a displayer for an unknown language")
+	}
+}
+
+func TestGetDateStringDisplayer(t *testing.T) {
+	v := hasDate{DateVal: &DateVal{Val: "1897-03-12", Type: "before"}}
+	if got := v.GetDateString(); got != "before 1897-03-12" {
+		t.Errorf("Got %q", got)
+	}
+	saved := DefaultDateDisplayer
+	defer func() { DefaultDateDisplayer = saved }()
+	DefaultDateDisplayer = &DateDisplayer{Lang: DateLangs["de"], Format: FormatDayMonthYear}
+	if got := v.GetDateString(); got != "vor 12. März 1897" {
+		t.Errorf("Got %q", got)
+	}
+}
+
+// Gramps stores the later year of a dual dated date.
+func TestDisplayDualDated(t *testing.T) {
+	v := hasDate{DateVal: &DateVal{Val: "1722-01-05",
+		dateCommon: dateCommon{CFormat: "Julian", DualDated: "1"}}}
+	d, err := v.GetDate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dd := &DateDisplayer{Lang: DateLangs["en"], Format: FormatDayMonthYear}
+	if got := dd.Display(d); got != "5 January 1721/2 (Julian)" {
+		t.Errorf("Got %q", got)
+	}
+	if d.SortValue() != Julian.JDN(YMD{1722, 1, 5}) {
+		t.Errorf("Got sort value %d, want that of January 5, 1722", d.SortValue())
+	}
+
+	var set hasDate
+	set.SetDate(DefaultDateParser.Parse("1721/22"))
+	if set.DateVal == nil || set.DateVal.Val != "1722" || set.DateVal.DualDated != "1" {
+		t.Errorf("Got %+v", set.DateVal)
+	}
+}
@@ -5,8 +5,12 @@ Information about the Gramps XML format can be found here:
 http://www.gramps-project.org/wiki/index.php?title=GRAMPS_XML#Parsing_Gramps_XML_file
 
 The DTD file is the best documentation I've found so far for what the various
-fields mean: http://gramps-project.org/xml/1.5.0/grampsxml.dtd. Note that we
-only parse a single version of the XML: 1.5.0 (the most recently released).
+fields mean: http://gramps-project.org/xml/1.7.1/grampsxml.dtd. Versions 1.5.0,
+1.6.0, 1.7.0 and 1.7.1 of the XML are parsed. The structs are a superset of
+all of them and a Database is serialized in the version it was parsed from.
+Gramps packages (.gpkg), which hold a file with its media, are read by
+ReadPackage and written by Database.WritePackage. A Database is also encoded
+as JSON by Database.WriteJSON and read back by ParseJSON without loss.
 
 The fields and structs in this file are, for the most part, named after fields
 in the XML. Notable exceptions:
@@ -0,0 +1,35 @@
+package xml
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Create a new handle in the form Gramps uses: the time in units of 100
+// microseconds followed by a random number, both in hex.
+func NewHandle() string {
+	return fmt.Sprintf("_%08x%08x", time.Now().UnixNano()/100000, rand.Uint32())
+}
+
+// An idGenerator creates Gramps IDs (e.g. P0001) that are not yet in use.
+type idGenerator struct {
+	format string
+	next   int
+	used   map[string]bool
+}
+
+func newIDGenerator(format string, used map[string]bool) *idGenerator {
+	return &idGenerator{format: format, used: used}
+}
+
+func (g *idGenerator) ID() string {
+	for {
+		id := fmt.Sprintf(g.format, g.next)
+		g.next++
+		if !g.used[id] {
+			g.used[id] = true
+			return id
+		}
+	}
+}
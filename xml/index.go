@@ -0,0 +1,448 @@
+package xml
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// The kinds of objects with handles, by element name: the collection of the
+// Database holding them and the Gramps default format of their IDs.
+var kinds = map[string]struct {
+	slice    func(db *Database) interface{}
+	idFormat string
+}{
+	"tag":        {func(db *Database) interface{} { return &db.Tags }, ""},
+	"event":      {func(db *Database) interface{} { return &db.Events }, "E%04d"},
+	"person":     {func(db *Database) interface{} { return &db.People.Persons }, "I%04d"},
+	"family":     {func(db *Database) interface{} { return &db.Families }, "F%04d"},
+	"citation":   {func(db *Database) interface{} { return &db.Citations }, "C%04d"},
+	"source":     {func(db *Database) interface{} { return &db.Sources }, "S%04d"},
+	"placeobj":   {func(db *Database) interface{} { return &db.Places }, "P%04d"},
+	"object":     {func(db *Database) interface{} { return &db.Objects }, "O%04d"},
+	"repository": {func(db *Database) interface{} { return &db.Repositories }, "R%04d"},
+	"note":       {func(db *Database) interface{} { return &db.Notes }, "N%04d"},
+}
+
+// Guards setting the index of a Database and building its referrers, which
+// are done lazily by methods that only read the Database. It is not a field
+// of Database since Databases are copied.
+var indexMu sync.RWMutex
+
+// An index of the objects of a Database by handle, and by ID for each kind of
+// object.
+type index struct {
+	byHandle map[string]DBObj
+	byID     map[string]map[string]DBObj
+	ids      map[string]*idGenerator
+	// Built by Referrers.
+	referrers map[string][]Reference
+}
+
+// Get the name of the element of obj, e.g. person. Returns "" if obj is not
+// one of the top-level objects of a Database.
+func ElementName(obj DBObj) string {
+	switch obj.(type) {
+	case *Tag:
+		return "tag"
+	case *Event:
+		return "event"
+	case *Person:
+		return "person"
+	case *Family:
+		return "family"
+	case *Citation:
+		return "citation"
+	case *Source:
+		return "source"
+	case *PlaceObj:
+		return "placeobj"
+	case *Object:
+		return "object"
+	case *Repository:
+		return "repository"
+	case *Note:
+		return "note"
+	}
+	return ""
+}
+
+// Get all the objects with handles in the Database, in the order they are
+// written.
+func (db *Database) DBObjs() []DBObj {
+	var objs []DBObj
+	for _, o := range db.Tags {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Events {
+		objs = append(objs, o)
+	}
+	for _, o := range db.People.Persons {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Families {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Citations {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Sources {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Places {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Objects {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Repositories {
+		objs = append(objs, o)
+	}
+	for _, o := range db.Notes {
+		objs = append(objs, o)
+	}
+	return objs
+}
+
+func (idx *index) add(obj DBObj) {
+	idx.byHandle[obj.GetHandle()] = obj
+	o, ok := obj.(interface {
+		GetID() string
+	})
+	if !ok || o.GetID() == "" {
+		return
+	}
+	name := ElementName(obj)
+	if idx.byID[name] == nil {
+		idx.byID[name] = make(map[string]DBObj)
+	}
+	idx.byID[name][o.GetID()] = obj
+	if g := idx.ids[name]; g != nil {
+		g.used[o.GetID()] = true
+	}
+}
+
+// Rebuild the indexes used to look up objects by handle and ID, and their
+// Referrers. The indexes are built when a Database is parsed or first looked
+// up in, and are kept up to date by Add and Remove; Reindex must be called
+// after objects are added to or removed from the Database in other ways. If
+// several objects have the same handle or ID only the first is indexed, and
+// when it is removed or renumbered the next one is.
+func (db *Database) Reindex() {
+	idx := db.newIndex()
+	indexMu.Lock()
+	db.index = idx
+	indexMu.Unlock()
+}
+
+func (db *Database) newIndex() *index {
+	idx := &index{
+		byHandle: make(map[string]DBObj),
+		byID:     make(map[string]map[string]DBObj),
+		ids:      make(map[string]*idGenerator),
+	}
+	objs := db.DBObjs()
+	for i := len(objs) - 1; i >= 0; i-- {
+		idx.add(objs[i])
+	}
+	return idx
+}
+
+func (db *Database) lookup() *index {
+	indexMu.RLock()
+	idx := db.index
+	indexMu.RUnlock()
+	if idx != nil {
+		return idx
+	}
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if db.index == nil {
+		db.index = db.newIndex()
+	}
+	return db.index
+}
+
+// Index the first of the remaining objects with handle h, and of those of
+// kind name with ID id, after an object with the same handle or ID was
+// removed from the Database or renumbered.
+func (db *Database) indexDuplicates(idx *index, name, h, id string) {
+	for _, o := range db.DBObjs() {
+		if h != "" && o.GetHandle() == h {
+			idx.byHandle[h] = o
+			break
+		}
+	}
+	if id == "" {
+		return
+	}
+	slice := reflect.ValueOf(kinds[name].slice(db)).Elem()
+	for i := 0; i < slice.Len(); i++ {
+		o := slice.Index(i).Interface().(DBObj)
+		if o.(interface {
+			GetID() string
+		}).GetID() == id {
+			idx.byID[name][id] = o
+			break
+		}
+	}
+}
+
+// Get the object with handle h, or nil if there is none.
+func (db *Database) ByHandle(h string) DBObj {
+	return db.lookup().byHandle[h]
+}
+
+// Get the object that l links to. Returns an error if there is none.
+func (db *Database) Resolve(l Link) (DBObj, error) {
+	obj := db.ByHandle(l.GetHLink())
+	if obj == nil {
+		return nil, fmt.Errorf("Dangling link: %q", l.GetHLink())
+	}
+	return obj, nil
+}
+
+func (db *Database) byID(name, id string) DBObj {
+	return db.lookup().byID[name][id]
+}
+
+// Get the tag with handle h, or nil if there is none.
+func (db *Database) TagByHandle(h string) *Tag {
+	o, _ := db.ByHandle(h).(*Tag)
+	return o
+}
+
+// Get the event with handle h, or nil if there is none.
+func (db *Database) EventByHandle(h string) *Event {
+	o, _ := db.ByHandle(h).(*Event)
+	return o
+}
+
+// Get the event with Gramps ID id, or nil if there is none.
+func (db *Database) EventByID(id string) *Event {
+	o, _ := db.byID("event", id).(*Event)
+	return o
+}
+
+// Get the person with handle h, or nil if there is none.
+func (db *Database) PersonByHandle(h string) *Person {
+	o, _ := db.ByHandle(h).(*Person)
+	return o
+}
+
+// Get the person with Gramps ID id, or nil if there is none.
+func (db *Database) PersonByID(id string) *Person {
+	o, _ := db.byID("person", id).(*Person)
+	return o
+}
+
+// Get the family with handle h, or nil if there is none.
+func (db *Database) FamilyByHandle(h string) *Family {
+	o, _ := db.ByHandle(h).(*Family)
+	return o
+}
+
+// Get the family with Gramps ID id, or nil if there is none.
+func (db *Database) FamilyByID(id string) *Family {
+	o, _ := db.byID("family", id).(*Family)
+	return o
+}
+
+// Get the citation with handle h, or nil if there is none.
+func (db *Database) CitationByHandle(h string) *Citation {
+	o, _ := db.ByHandle(h).(*Citation)
+	return o
+}
+
+// Get the citation with Gramps ID id, or nil if there is none.
+func (db *Database) CitationByID(id string) *Citation {
+	o, _ := db.byID("citation", id).(*Citation)
+	return o
+}
+
+// Get the source with handle h, or nil if there is none.
+func (db *Database) SourceByHandle(h string) *Source {
+	o, _ := db.ByHandle(h).(*Source)
+	return o
+}
+
+// Get the source with Gramps ID id, or nil if there is none.
+func (db *Database) SourceByID(id string) *Source {
+	o, _ := db.byID("source", id).(*Source)
+	return o
+}
+
+// Get the place with handle h, or nil if there is none.
+func (db *Database) PlaceByHandle(h string) *PlaceObj {
+	o, _ := db.ByHandle(h).(*PlaceObj)
+	return o
+}
+
+// Get the place with Gramps ID id, or nil if there is none.
+func (db *Database) PlaceByID(id string) *PlaceObj {
+	o, _ := db.byID("placeobj", id).(*PlaceObj)
+	return o
+}
+
+// Get the media object with handle h, or nil if there is none.
+func (db *Database) ObjectByHandle(h string) *Object {
+	o, _ := db.ByHandle(h).(*Object)
+	return o
+}
+
+// Get the media object with Gramps ID id, or nil if there is none.
+func (db *Database) ObjectByID(id string) *Object {
+	o, _ := db.byID("object", id).(*Object)
+	return o
+}
+
+// Get the repository with handle h, or nil if there is none.
+func (db *Database) RepositoryByHandle(h string) *Repository {
+	o, _ := db.ByHandle(h).(*Repository)
+	return o
+}
+
+// Get the repository with Gramps ID id, or nil if there is none.
+func (db *Database) RepositoryByID(id string) *Repository {
+	o, _ := db.byID("repository", id).(*Repository)
+	return o
+}
+
+// Get the note with handle h, or nil if there is none.
+func (db *Database) NoteByHandle(h string) *Note {
+	o, _ := db.ByHandle(h).(*Note)
+	return o
+}
+
+// Get the note with Gramps ID id, or nil if there is none.
+func (db *Database) NoteByID(id string) *Note {
+	o, _ := db.byID("note", id).(*Note)
+	return o
+}
+
+// Get the next free ID for objects of the given element name.
+func (idx *index) nextID(name string) string {
+	g := idx.ids[name]
+	if g == nil {
+		used := make(map[string]bool)
+		for id := range idx.byID[name] {
+			used[id] = true
+		}
+		g = newIDGenerator(kinds[name].idFormat, used)
+		idx.ids[name] = g
+	}
+	return g.ID()
+}
+
+// Add obj, a pointer to one of the objects with handles (e.g. a *Person), to
+// the end of its collection in the Database. An empty handle is replaced by a
+// new one and an empty Gramps ID by the next free ID in the default format.
+// Returns an error if the handle or ID is already in use.
+func (db *Database) Add(obj DBObj) error {
+	name := ElementName(obj)
+	if name == "" {
+		return fmt.Errorf("Cannot add %T to a database", obj)
+	}
+	idx := db.lookup()
+
+	var base *dbObj
+	if o, ok := obj.(interface {
+		base() *dbObj
+	}); ok {
+		base = o.base()
+	}
+	if obj.GetHandle() != "" {
+		if _, ok := idx.byHandle[obj.GetHandle()]; ok {
+			return fmt.Errorf("Duplicate handle: %s", obj.GetHandle())
+		}
+	}
+	if base != nil && base.ID != "" {
+		if _, ok := idx.byID[name][base.ID]; ok {
+			return fmt.Errorf("Duplicate %s ID: %s", name, base.ID)
+		}
+	}
+
+	if obj.GetHandle() == "" {
+		h := NewHandle()
+		for idx.byHandle[h] != nil {
+			h = NewHandle()
+		}
+		if tag, ok := obj.(*Tag); ok {
+			tag.Handle = h
+		} else {
+			base.Handle = h
+		}
+	}
+	if base != nil && base.ID == "" {
+		base.ID = idx.nextID(name)
+	}
+
+	slice := reflect.ValueOf(kinds[name].slice(db)).Elem()
+	slice.Set(reflect.Append(slice, reflect.ValueOf(obj)))
+	idx.add(obj)
+	if idx.referrers != nil {
+		idx.addReferences(obj)
+	}
+	return nil
+}
+
+// Remove the object with handle h from the Database and return it. Links to
+// the object are not removed. Returns an error if there is no such object.
+func (db *Database) Remove(h string) (DBObj, error) {
+	idx := db.lookup()
+	obj := idx.byHandle[h]
+	if obj == nil {
+		return nil, fmt.Errorf("Unknown handle: %s", h)
+	}
+
+	name := ElementName(obj)
+	slice := reflect.ValueOf(kinds[name].slice(db)).Elem()
+	for i := 0; i < slice.Len(); i++ {
+		if slice.Index(i).Interface() == obj {
+			slice.Set(reflect.AppendSlice(slice.Slice(0, i), slice.Slice(i+1, slice.Len())))
+			break
+		}
+	}
+
+	delete(idx.byHandle, h)
+	id := ""
+	if o, ok := obj.(interface {
+		GetID() string
+	}); ok {
+		id = o.GetID()
+		if idx.byID[name][id] == obj {
+			delete(idx.byID[name], id)
+		} else {
+			id = ""
+		}
+	}
+	db.indexDuplicates(idx, name, h, id)
+	if idx.referrers != nil && idx.byHandle[h] != obj {
+		idx.removeReferences(obj)
+	}
+	return obj, nil
+}
+
+// Give obj, an object of the Database with a Gramps ID, the next free ID in
+// the default format, e.g. to resolve duplicate IDs.
+func (db *Database) Renumber(obj DBObj) error {
+	o, ok := obj.(interface {
+		base() *dbObj
+	})
+	if !ok || db.ByHandle(obj.GetHandle()) != obj {
+		return fmt.Errorf("Cannot renumber %T", obj)
+	}
+	idx := db.lookup()
+	name := ElementName(obj)
+	base := o.base()
+	old := base.ID
+	if idx.byID[name][old] == obj {
+		delete(idx.byID[name], old)
+	} else {
+		old = ""
+	}
+	base.ID = idx.nextID(name)
+	idx.add(obj)
+	db.indexDuplicates(idx, name, "", old)
+	return nil
+}
@@ -0,0 +1,183 @@
+package xml
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLookups(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+
+	p := db.PersonByID("I0552")
+	if p == nil || p.Handle != "_004KQCGYT27EEPQHK" {
+		t.Fatalf("PersonByID(I0552) = %v", p)
+	}
+	if db.PersonByHandle("_004KQCGYT27EEPQHK") != p {
+		t.Error("PersonByHandle did not find I0552")
+	}
+	if db.EventByHandle(p.Handle) != nil {
+		t.Error("EventByHandle found a person")
+	}
+	if e := db.EventByID("E0000"); e == nil || e.Handle != "_a5af0eb667015e355db" {
+		t.Errorf("EventByID(E0000) = %v", e)
+	}
+
+	for _, p := range db.People.Persons {
+		for _, ref := range p.EventRefs {
+			obj, err := db.Resolve(ref)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s of %s: %s", ref.HLink, p.ID, err)
+			}
+			if _, ok := obj.(*Event); !ok {
+				t.Errorf("Eventref of %s resolved to %T", p.ID, obj)
+			}
+		}
+	}
+	if _, err := db.Resolve(&GenericLink{HLink: "_missing"}); err == nil {
+		t.Error("Resolved a dangling link")
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	n := len(db.People.Persons)
+
+	p := &Person{Gender: "F"}
+	if err := db.Add(p); err != nil {
+		t.Fatalf("Failed to add person: %s", err)
+	}
+	if p.Handle == "" || p.ID == "" {
+		t.Fatalf("Added person has handle %q and ID %q", p.Handle, p.ID)
+	}
+	if len(db.People.Persons) != n+1 || db.People.Persons[n] != p {
+		t.Error("Person was not appended to people")
+	}
+	if db.PersonByID(p.ID) != p || db.PersonByHandle(p.Handle) != p {
+		t.Errorf("Added person %s is not indexed", p.ID)
+	}
+
+	dup := &Person{}
+	dup.ID = p.ID
+	if err := db.Add(dup); err == nil {
+		t.Error("Added a person with a duplicate ID")
+	}
+	dup = &Person{}
+	dup.Handle = p.Handle
+	if err := db.Add(dup); err == nil {
+		t.Error("Added a person with a duplicate handle")
+	}
+	if len(db.People.Persons) != n+1 {
+		t.Error("Duplicate people were appended")
+	}
+
+	tag := &Tag{Name: "ToDo"}
+	if err := db.Add(tag); err != nil || db.TagByHandle(tag.Handle) != tag {
+		t.Errorf("Failed to add tag: %v", err)
+	}
+
+	removed, err := db.Remove(p.Handle)
+	if err != nil || removed != p {
+		t.Fatalf("Remove(%s) = %v, %v", p.Handle, removed, err)
+	}
+	if len(db.People.Persons) != n || db.PersonByID(p.ID) != nil ||
+		db.PersonByHandle(p.Handle) != nil {
+		t.Errorf("Removed person %s is still present", p.ID)
+	}
+	if _, err := db.Remove(p.Handle); err == nil {
+		t.Error("Removed a person twice")
+	}
+}
+
+func TestRemoveDuplicate(t *testing.T) {
+	first, second, third := &Person{}, &Person{}, &Person{}
+	first.Handle, first.ID = "_dup", "I0001"
+	second.Handle, second.ID = "_dup", "I0002"
+	third.Handle, third.ID = "_third", "I0001"
+	db := &Database{}
+	db.People.Persons = []*Person{first, second, third}
+
+	if db.PersonByHandle("_dup") != first || db.PersonByID("I0001") != first {
+		t.Fatal("The first of the duplicates is not indexed")
+	}
+	if _, err := db.Remove("_dup"); err != nil {
+		t.Fatalf("Failed to remove person: %s", err)
+	}
+	if db.PersonByHandle("_dup") != second {
+		t.Error("The remaining person with the handle is not indexed")
+	}
+	if db.PersonByID("I0001") != third {
+		t.Error("The remaining person with the ID is not indexed")
+	}
+	if err := db.Renumber(third); err != nil {
+		t.Fatalf("Failed to renumber: %s", err)
+	}
+	if _, err := db.Remove("_dup"); err != nil {
+		t.Fatalf("Failed to remove the other person: %s", err)
+	}
+	if len(db.People.Persons) != 1 || db.PersonByHandle("_dup") != nil {
+		t.Errorf("People after removing both duplicates: %v", db.People.Persons)
+	}
+}
+
+func TestRenumberDuplicate(t *testing.T) {
+	first, second := &Person{}, &Person{}
+	first.Handle, first.ID = "_first", "I0001"
+	second.Handle, second.ID = "_second", "I0001"
+	db := &Database{}
+	db.People.Persons = []*Person{first, second}
+
+	if err := db.Renumber(first); err != nil {
+		t.Fatalf("Failed to renumber: %s", err)
+	}
+	if db.PersonByID("I0001") != second || db.PersonByID(first.ID) != first {
+		t.Errorf("After renumbering I0001 is %v and %s is %v", db.PersonByID("I0001"), first.ID, db.PersonByID(first.ID))
+	}
+}
+
+func TestConcurrentLookups(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	db.index = nil
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if db.PersonByID("I0552") == nil {
+				t.Error("Did not find I0552")
+			}
+			db.Referrers("_004KQCGYT27EEPQHK")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReindex(t *testing.T) {
+	db := &Database{}
+	if db.NoteByID("N0000") != nil {
+		t.Error("Found a note in an empty database")
+	}
+	note := &Note{Text: "text"}
+	note.Handle, note.ID = "_note", "N0000"
+	db.Notes = append(db.Notes, note)
+	if db.NoteByID("N0000") != nil {
+		t.Error("Found a note that was not indexed")
+	}
+	db.Reindex()
+	if db.NoteByID("N0000") != note || db.NoteByHandle("_note") != note {
+		t.Error("Did not find note after reindexing")
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	p := db.PersonByID("I0552")
+	if err := db.Renumber(p); err != nil {
+		t.Fatalf("Failed to renumber: %s", err)
+	}
+	if p.ID == "I0552" || db.PersonByID(p.ID) != p || db.PersonByID("I0552") != nil {
+		t.Errorf("Renumbered person has ID %s", p.ID)
+	}
+	if err := db.Renumber(&Person{}); err == nil {
+		t.Error("Renumbered a person that is not in the database")
+	}
+}
@@ -0,0 +1,802 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Database and its objects are encoded as JSON objects with a member for
+// each field, in the order of the struct fields. Members are named after the
+// XML with the names of the JSON of Gramps where it has them: gramps_id,
+// private, ref, note_list, event_ref_list and so on. Other repeated elements
+// are named e.g. pname_list, and collections after their XML elements, e.g.
+// events. Empty fields are left out.
+//
+// Links are encoded as their handles, and dates as a date member with the
+// interpreted Date. Anything that would not be written back exactly as it was
+// read is kept as XML: dates in an xml member of the date, and unparsed
+// elements in an unparsed member. Unparsed elements are strings, or objects
+// with the string as xml and the number of parsed elements that followed
+// them as following if they were not after all of them. A Database encoded as
+// JSON and decoded again is written out as the same XML file.
+//
+// The JSON is not that of Gramps or the Gramps Web API, which differ in member
+// names (e.g. primary_name), in the types of values (e.g. gender, private and
+// typed values such as event types) and in the form of dates.
+
+// Options controlling how objects are encoded as JSON.
+type JSONOptions struct {
+	// Indent members by this string, one more for each level. The JSON is
+	// compact if it is empty.
+	Indent string
+}
+
+// The JSON names of repeated elements that Gramps has other names for.
+var jsonListNames = map[string]string{
+	"address":     "address_list",
+	"attribute":   "attribute_list",
+	"childof":     "parent_family_list",
+	"childref":    "child_ref_list",
+	"citationref": "citation_list",
+	"eventref":    "event_ref_list",
+	"lds_ord":     "lds_ord_list",
+	"noteref":     "note_list",
+	"objref":      "media_list",
+	"parentin":    "family_list",
+	"personref":   "person_ref_list",
+	"tagref":      "tag_list",
+	"url":         "urls",
+}
+
+// The JSON names of other elements and attributes that Gramps has other
+// names for.
+var jsonNames = map[string]string{
+	"father":    "father_handle",
+	"hlink":     "ref",
+	"id":        "gramps_id",
+	"mother":    "mother_handle",
+	"priv":      "private",
+	"sourceref": "source_handle",
+}
+
+// The modifier members of dates, by Modifier.
+var jsonModifiers = map[Modifier]string{
+	ModBefore:   "before",
+	ModAfter:    "after",
+	ModAbout:    "about",
+	ModRange:    "range",
+	ModSpan:     "span",
+	ModTextOnly: "textonly",
+}
+
+var (
+	hasDateType     = reflect.TypeOf(hasDate{})
+	genericLinkType = reflect.TypeOf(GenericLink{})
+	rawsType        = reflect.TypeOf([]*raw{})
+	databaseType    = reflect.TypeOf(Database{})
+)
+
+// A member of the JSON of a struct, from a field or, for a date, an embedded
+// hasDate.
+type jsonField struct {
+	name  string
+	index []int
+	date  bool
+}
+
+var (
+	jsonFieldsMu    sync.Mutex
+	jsonFieldsCache = make(map[reflect.Type][]jsonField)
+)
+
+// Get the JSON members of struct type t, in order.
+func jsonFieldsOf(t reflect.Type) []jsonField {
+	jsonFieldsMu.Lock()
+	defer jsonFieldsMu.Unlock()
+	if fields, ok := jsonFieldsCache[t]; ok {
+		return fields
+	}
+
+	var fields, unparsed []jsonField
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Anonymous && f.Type == hasDateType {
+			fields = append(fields, jsonField{name: "date", index: f.Index, date: true})
+			continue
+		}
+		if f.Anonymous || f.Name == "XMLName" || !f.IsExported() ||
+			len(f.Index) > 1 && t.FieldByIndex(f.Index[:1]).Type == hasDateType {
+			continue
+		}
+		parts := strings.Split(f.Tag.Get("xml"), ",")
+		name := parts[0]
+		for _, flag := range parts[1:] {
+			switch flag {
+			case "chardata":
+				// The text of an element is named after the element.
+				if x, ok := t.FieldByName("XMLName"); ok {
+					name = x.Tag.Get("xml")
+				}
+			case "any":
+				name = "unparsed"
+			}
+		}
+		switch {
+		case name == "":
+			name = strings.ToLower(f.Name)
+		case strings.Contains(name, ">"):
+			name = strings.Replace(name[:strings.Index(name, ">")], "-", "_", -1)
+		case f.Type.Kind() == reflect.Slice && name != "unparsed":
+			if n, ok := jsonListNames[name]; ok {
+				name = n
+			} else {
+				name += "_list"
+			}
+		default:
+			if n, ok := jsonNames[name]; ok {
+				name = n
+			}
+		}
+		if name == "unparsed" {
+			// The unparsed member is written last.
+			unparsed = append(unparsed, jsonField{name: name, index: f.Index})
+			continue
+		}
+		fields = append(fields, jsonField{name: name, index: f.Index})
+	}
+	fields = append(fields, unparsed...)
+	jsonFieldsCache[t] = fields
+	return fields
+}
+
+// Copy the date fields of v, an embedded hasDate, which is not exported.
+func getHasDate(v reflect.Value) hasDate {
+	var d hasDate
+	dv := reflect.ValueOf(&d).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		dv.Field(i).Set(v.Field(i))
+	}
+	return d
+}
+
+func setHasDate(v reflect.Value, d hasDate) {
+	dv := reflect.ValueOf(&d).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		v.Field(i).Set(dv.Field(i))
+	}
+}
+
+// Get the date elements of d as they are written in a file.
+func dateXML(d *hasDate) string {
+	var w canonicalWriter
+	v := reflect.ValueOf(d).Elem()
+	for _, f := range fieldsOf(hasDateType) {
+		if fv := v.FieldByIndex(f.index); !fv.IsNil() {
+			w.element(f.name, fv, 0)
+		}
+	}
+	return strings.TrimSuffix(w.buf.String(), "\n")
+}
+
+// Get an unparsed element as it is written in a file.
+func rawXML(r *raw) string {
+	var w canonicalWriter
+	w.raw(r, 0)
+	return strings.TrimSuffix(w.buf.String(), "\n")
+}
+
+// Whether v is left out of the JSON of the struct it is a field of.
+func jsonOmitted(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		return v.IsNil()
+	case reflect.Slice:
+		return v.Len() == 0
+	case reflect.Struct:
+		for _, f := range jsonFieldsOf(v.Type()) {
+			fv := v.FieldByIndex(f.index)
+			if f.date && getHasDate(fv) != (hasDate{}) || !f.date && !jsonOmitted(fv) {
+				return false
+			}
+		}
+		return true
+	}
+	return v.IsZero()
+}
+
+type jsonEncoder struct {
+	buf  bytes.Buffer
+	opts JSONOptions
+	// Whether the next member is the first of its object.
+	first bool
+}
+
+func (e *jsonEncoder) raw(r *raw) {
+	if r.following == 0 {
+		e.str(rawXML(r))
+		return
+	}
+	e.buf.WriteString(`{"xml":`)
+	e.str(rawXML(r))
+	e.buf.WriteString(`,"following":` + strconv.Itoa(r.following) + "}")
+}
+
+func (e *jsonEncoder) str(s string) {
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode ends the value with a newline.
+	e.buf.Truncate(e.buf.Len() - 1)
+}
+
+func (e *jsonEncoder) begin() {
+	e.buf.WriteByte('{')
+	e.first = true
+}
+
+func (e *jsonEncoder) end() {
+	e.buf.WriteByte('}')
+	// The object is a value of the enclosing one.
+	e.first = false
+}
+
+func (e *jsonEncoder) member(name string) {
+	if !e.first {
+		e.buf.WriteByte(',')
+	}
+	e.first = false
+	e.str(name)
+	e.buf.WriteByte(':')
+}
+
+func (e *jsonEncoder) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return
+		}
+		e.value(v.Elem())
+	case reflect.String:
+		e.str(v.String())
+	case reflect.Int:
+		e.buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Slice:
+		e.buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if v.Type() == rawsType {
+				e.raw(v.Index(i).Interface().(*raw))
+			} else {
+				e.value(v.Index(i))
+			}
+		}
+		e.buf.WriteByte(']')
+	case reflect.Struct:
+		if v.Type() == genericLinkType && v.FieldByName("Unparsed").Len() == 0 {
+			e.str(v.FieldByName("HLink").String())
+			return
+		}
+		e.object(v)
+	default:
+		panic("Unsupported JSON type: " + v.Type().String())
+	}
+}
+
+func (e *jsonEncoder) object(v reflect.Value) {
+	e.begin()
+	if v.Type() == databaseType {
+		e.member("version")
+		version, ok := VersionOf(v.FieldByName("XMLName").Interface().(xml.Name).Space)
+		if !ok {
+			version = LatestVersion
+		}
+		e.str(version)
+	}
+	for _, f := range jsonFieldsOf(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		if f.date {
+			if d := getHasDate(fv); d != (hasDate{}) {
+				e.member(f.name)
+				e.date(&d)
+			}
+			continue
+		}
+		if jsonOmitted(fv) {
+			continue
+		}
+		e.member(f.name)
+		e.value(fv)
+	}
+	e.end()
+}
+
+func (e *jsonEncoder) ymd(v YMD) {
+	e.begin()
+	for _, p := range []struct {
+		name  string
+		value int
+	}{{"year", v.Year}, {"month", v.Month}, {"day", v.Day}} {
+		if p.value != 0 {
+			e.member(p.name)
+			e.buf.WriteString(strconv.Itoa(p.value))
+		}
+	}
+	e.end()
+}
+
+// Write the date of d. Its XML is kept if the Date is not written back the
+// same way, or cannot be interpreted.
+func (e *jsonEncoder) date(d *hasDate) {
+	e.begin()
+	text := dateXML(d)
+	date, err := d.GetDate()
+	if err == nil {
+		if date.Calendar != Gregorian {
+			e.member("calendar")
+			e.str(date.Calendar.String())
+		}
+		if date.Modifier != ModNone {
+			e.member("modifier")
+			e.str(jsonModifiers[date.Modifier])
+		}
+		if date.Quality != QualityRegular {
+			e.member("quality")
+			e.str(qualityNames[date.Quality])
+		}
+		if date.Start != (YMD{}) {
+			e.member("start")
+			e.ymd(date.Start)
+		}
+		if date.Stop != (YMD{}) {
+			e.member("stop")
+			e.ymd(date.Stop)
+		}
+		if date.DualDated {
+			e.member("dual_dated")
+			e.buf.WriteString("true")
+		}
+		if date.NewYear != "" {
+			e.member("new_year")
+			e.str(date.NewYear)
+		}
+		if date.Text != "" {
+			e.member("text")
+			e.str(date.Text)
+		}
+		var set hasDate
+		set.SetDate(date)
+		if dateXML(&set) == text {
+			e.end()
+			return
+		}
+	}
+	e.member("xml")
+	e.str(text)
+	e.end()
+}
+
+// Encode v, a Database or any of the structs in it, or a pointer to one, as
+// JSON.
+func EncodeJSON(v interface{}, opts JSONOptions) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Cannot encode as JSON: %T", v)
+	}
+	e := &jsonEncoder{opts: opts}
+	e.object(rv)
+	if opts.Indent == "" {
+		return e.buf.Bytes(), nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, e.buf.Bytes(), "", opts.Indent); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// Decode JSON written by EncodeJSON into v, a pointer to a Database or any of
+// the structs in it. Members that are missing leave their fields unchanged.
+func DecodeJSON(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot decode JSON into %T", v)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var j interface{}
+	if err := d.Decode(&j); err != nil {
+		return err
+	}
+	return decodeJSON(rv.Elem(), j, rv.Elem().Type().Name())
+}
+
+func invalidJSON(path string, j interface{}) error {
+	return fmt.Errorf("Invalid JSON value for %s: %v", path, j)
+}
+
+// Decode j, a value decoded by encoding/json, into v, the field at path.
+func decodeJSON(v reflect.Value, j interface{}, path string) error {
+	if j == nil {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		return decodeJSON(v.Elem(), j, path)
+	case reflect.String:
+		s, ok := j.(string)
+		if !ok {
+			return invalidJSON(path, j)
+		}
+		v.SetString(s)
+	case reflect.Int:
+		n, ok := j.(json.Number)
+		if !ok {
+			return invalidJSON(path, j)
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return invalidJSON(path, j)
+		}
+		v.SetInt(i)
+	case reflect.Slice:
+		a, ok := j.([]interface{})
+		if !ok {
+			return invalidJSON(path, j)
+		}
+		s := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, elem := range a {
+			var err error
+			if v.Type() == rawsType {
+				err = decodeRaw(s.Index(i), elem, path)
+			} else {
+				err = decodeJSON(s.Index(i), elem, path)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Struct:
+		if s, ok := j.(string); ok && v.Type() == genericLinkType {
+			v.FieldByName("HLink").SetString(s)
+			return nil
+		}
+		m, ok := j.(map[string]interface{})
+		if !ok {
+			return invalidJSON(path, j)
+		}
+		return decodeObject(v, m, path)
+	default:
+		return fmt.Errorf("Unsupported JSON type: %s", v.Type())
+	}
+	return nil
+}
+
+// Check that every member of m is one of those in used.
+func checkMembers(m map[string]interface{}, used map[string]bool, path string) error {
+	var unknown []string
+	for k := range m {
+		if !used[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("Unknown JSON member: %q", path+"."+unknown[0])
+	}
+	return nil
+}
+
+func decodeObject(v reflect.Value, m map[string]interface{}, path string) error {
+	used := map[string]bool{}
+	if v.Type() == databaseType {
+		version := LatestVersion
+		if j, ok := m["version"]; ok {
+			s, ok := j.(string)
+			if !ok {
+				return invalidJSON(path+".version", j)
+			}
+			version = s
+		}
+		if _, ok := VersionOf(Namespace(version)); !ok {
+			return fmt.Errorf("Unsupported version: %q", version)
+		}
+		v.FieldByName("XMLName").Set(reflect.ValueOf(xml.Name{Space: Namespace(version), Local: "database"}))
+		used["version"] = true
+	}
+	for _, f := range jsonFieldsOf(v.Type()) {
+		j, ok := m[f.name]
+		if !ok {
+			continue
+		}
+		used[f.name] = true
+		fv := v.FieldByIndex(f.index)
+		var err error
+		if f.date {
+			err = decodeDate(fv, j, path+"."+f.name)
+		} else {
+			err = decodeJSON(fv, j, path+"."+f.name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return checkMembers(m, used, path)
+}
+
+var jsonRawMembers = map[string]bool{"xml": true, "following": true}
+
+// Decode an unparsed element into v, a *raw.
+func decodeRaw(v reflect.Value, j interface{}, path string) error {
+	var following int64
+	if m, ok := j.(map[string]interface{}); ok {
+		if err := checkMembers(m, jsonRawMembers, path); err != nil {
+			return err
+		}
+		n, ok := m["following"].(json.Number)
+		var err error
+		if following, err = n.Int64(); !ok || err != nil || following < 0 {
+			return invalidJSON(path+".following", m["following"])
+		}
+		j = m["xml"]
+	}
+	s, ok := j.(string)
+	if !ok {
+		return invalidJSON(path, j)
+	}
+	r, err := parseRaw(s)
+	if err != nil {
+		return fmt.Errorf("Invalid XML in %s: %v", path, err)
+	}
+	r.following = int(following)
+	v.Set(reflect.ValueOf(r))
+	return nil
+}
+
+var jsonDateMembers = map[string]bool{
+	"calendar": true, "modifier": true, "quality": true, "start": true,
+	"stop": true, "dual_dated": true, "new_year": true, "text": true, "xml": true,
+}
+
+// Decode a date into v, an embedded hasDate.
+func decodeDate(v reflect.Value, j interface{}, path string) error {
+	m, ok := j.(map[string]interface{})
+	if !ok {
+		return invalidJSON(path, j)
+	}
+	if err := checkMembers(m, jsonDateMembers, path); err != nil {
+		return err
+	}
+	var d hasDate
+	if j, ok := m["xml"]; ok {
+		s, ok := j.(string)
+		if !ok {
+			return invalidJSON(path+".xml", j)
+		}
+		data := []byte("<date>" + s + "</date>")
+		if err := xml.Unmarshal(data, &d); err != nil {
+			return fmt.Errorf("Invalid XML in %s: %v", path, err)
+		}
+		if err := placeUnparsed(reflect.ValueOf(&d), data, 0); err != nil {
+			return fmt.Errorf("Invalid XML in %s: %v", path, err)
+		}
+		setHasDate(v, d)
+		return nil
+	}
+
+	var date Date
+	for _, name := range []string{"calendar", "modifier", "quality", "new_year", "text"} {
+		j, ok := m[name]
+		if !ok {
+			continue
+		}
+		s, ok := j.(string)
+		if !ok {
+			return invalidJSON(path+"."+name, j)
+		}
+		found := false
+		switch name {
+		case "calendar":
+			for c := Gregorian; c <= Swedish; c++ {
+				if c.String() == s {
+					date.Calendar, found = c, true
+				}
+			}
+		case "modifier":
+			for mod, n := range jsonModifiers {
+				if n == s {
+					date.Modifier, found = mod, true
+				}
+			}
+		case "quality":
+			for q, n := range qualityNames {
+				if n == s {
+					date.Quality, found = q, true
+				}
+			}
+		case "new_year":
+			date.NewYear, found = s, true
+		case "text":
+			date.Text, found = s, true
+		}
+		if !found {
+			return invalidJSON(path+"."+name, s)
+		}
+	}
+	for _, p := range []struct {
+		name string
+		v    *YMD
+	}{{"start", &date.Start}, {"stop", &date.Stop}} {
+		if j, ok := m[p.name]; ok {
+			if err := decodeYMD(p.v, j, path+"."+p.name); err != nil {
+				return err
+			}
+		}
+	}
+	if j, ok := m["dual_dated"]; ok {
+		if date.DualDated, ok = j.(bool); !ok {
+			return invalidJSON(path+".dual_dated", j)
+		}
+	}
+	d.SetDate(date)
+	setHasDate(v, d)
+	return nil
+}
+
+func decodeYMD(v *YMD, j interface{}, path string) error {
+	m, ok := j.(map[string]interface{})
+	if !ok {
+		return invalidJSON(path, j)
+	}
+	if err := checkMembers(m, map[string]bool{"year": true, "month": true, "day": true}, path); err != nil {
+		return err
+	}
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"year", &v.Year}, {"month", &v.Month}, {"day", &v.Day}} {
+		j, ok := m[p.name]
+		if !ok {
+			continue
+		}
+		n, ok := j.(json.Number)
+		i, err := n.Int64()
+		if !ok || err != nil {
+			return invalidJSON(path+"."+p.name, j)
+		}
+		*p.v = int(i)
+	}
+	return nil
+}
+
+// Write the Database to w as JSON.
+func (db *Database) WriteJSON(w io.Writer, opts JSONOptions) error {
+	data, err := EncodeJSON(db, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Read a Database written as JSON by WriteJSON. It is written out as XML in
+// the version it was encoded from.
+func ParseJSON(r io.Reader) (*Database, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	db := &Database{}
+	if err := DecodeJSON(data, db); err != nil {
+		return nil, err
+	}
+	db.Reindex()
+	return db, nil
+}
+
+// Every struct of a Database is encoded by EncodeJSON and decoded by DecodeJSON
+// when it is marshaled or unmarshaled by encoding/json, also as a value.
+func (o Created) MarshalJSON() ([]byte, error)          { return EncodeJSON(o, JSONOptions{}) }
+func (o *Created) UnmarshalJSON(data []byte) error      { return DecodeJSON(data, o) }
+func (o Researcher) MarshalJSON() ([]byte, error)       { return EncodeJSON(o, JSONOptions{}) }
+func (o *Researcher) UnmarshalJSON(data []byte) error   { return DecodeJSON(data, o) }
+func (o Header) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Header) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o NameFormat) MarshalJSON() ([]byte, error)       { return EncodeJSON(o, JSONOptions{}) }
+func (o *NameFormat) UnmarshalJSON(data []byte) error   { return DecodeJSON(data, o) }
+func (o Tag) MarshalJSON() ([]byte, error)              { return EncodeJSON(o, JSONOptions{}) }
+func (o *Tag) UnmarshalJSON(data []byte) error          { return DecodeJSON(data, o) }
+func (o DateStr) MarshalJSON() ([]byte, error)          { return EncodeJSON(o, JSONOptions{}) }
+func (o *DateStr) UnmarshalJSON(data []byte) error      { return DecodeJSON(data, o) }
+func (o DateVal) MarshalJSON() ([]byte, error)          { return EncodeJSON(o, JSONOptions{}) }
+func (o *DateVal) UnmarshalJSON(data []byte) error      { return DecodeJSON(data, o) }
+func (o DateRange) MarshalJSON() ([]byte, error)        { return EncodeJSON(o, JSONOptions{}) }
+func (o *DateRange) UnmarshalJSON(data []byte) error    { return DecodeJSON(data, o) }
+func (o GenericLink) MarshalJSON() ([]byte, error)      { return EncodeJSON(o, JSONOptions{}) }
+func (o *GenericLink) UnmarshalJSON(data []byte) error  { return DecodeJSON(data, o) }
+func (o Attribute) MarshalJSON() ([]byte, error)        { return EncodeJSON(o, JSONOptions{}) }
+func (o *Attribute) UnmarshalJSON(data []byte) error    { return DecodeJSON(data, o) }
+func (o Event) MarshalJSON() ([]byte, error)            { return EncodeJSON(o, JSONOptions{}) }
+func (o *Event) UnmarshalJSON(data []byte) error        { return DecodeJSON(data, o) }
+func (o EventRef) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *EventRef) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o Region) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Region) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o ObjRef) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *ObjRef) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o Surname) MarshalJSON() ([]byte, error)          { return EncodeJSON(o, JSONOptions{}) }
+func (o *Surname) UnmarshalJSON(data []byte) error      { return DecodeJSON(data, o) }
+func (o Name) MarshalJSON() ([]byte, error)             { return EncodeJSON(o, JSONOptions{}) }
+func (o *Name) UnmarshalJSON(data []byte) error         { return DecodeJSON(data, o) }
+func (o Temple) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Temple) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o Status) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Status) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o LDSOrd) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *LDSOrd) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o Address) MarshalJSON() ([]byte, error)          { return EncodeJSON(o, JSONOptions{}) }
+func (o *Address) UnmarshalJSON(data []byte) error      { return DecodeJSON(data, o) }
+func (o PersonRef) MarshalJSON() ([]byte, error)        { return EncodeJSON(o, JSONOptions{}) }
+func (o *PersonRef) UnmarshalJSON(data []byte) error    { return DecodeJSON(data, o) }
+func (o Person) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Person) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o People) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *People) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o ChildRef) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *ChildRef) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o Rel) MarshalJSON() ([]byte, error)              { return EncodeJSON(o, JSONOptions{}) }
+func (o *Rel) UnmarshalJSON(data []byte) error          { return DecodeJSON(data, o) }
+func (o Family) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Family) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o Citation) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *Citation) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o DataItem) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *DataItem) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o SrcAttribute) MarshalJSON() ([]byte, error)     { return EncodeJSON(o, JSONOptions{}) }
+func (o *SrcAttribute) UnmarshalJSON(data []byte) error { return DecodeJSON(data, o) }
+func (o RepoRef) MarshalJSON() ([]byte, error)          { return EncodeJSON(o, JSONOptions{}) }
+func (o *RepoRef) UnmarshalJSON(data []byte) error      { return DecodeJSON(data, o) }
+func (o Source) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Source) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o Coord) MarshalJSON() ([]byte, error)            { return EncodeJSON(o, JSONOptions{}) }
+func (o *Coord) UnmarshalJSON(data []byte) error        { return DecodeJSON(data, o) }
+func (o Location) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *Location) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o URL) MarshalJSON() ([]byte, error)              { return EncodeJSON(o, JSONOptions{}) }
+func (o *URL) UnmarshalJSON(data []byte) error          { return DecodeJSON(data, o) }
+func (o PName) MarshalJSON() ([]byte, error)            { return EncodeJSON(o, JSONOptions{}) }
+func (o *PName) UnmarshalJSON(data []byte) error        { return DecodeJSON(data, o) }
+func (o PlaceRef) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *PlaceRef) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o PlaceObj) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *PlaceObj) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o File) MarshalJSON() ([]byte, error)             { return EncodeJSON(o, JSONOptions{}) }
+func (o *File) UnmarshalJSON(data []byte) error         { return DecodeJSON(data, o) }
+func (o Object) MarshalJSON() ([]byte, error)           { return EncodeJSON(o, JSONOptions{}) }
+func (o *Object) UnmarshalJSON(data []byte) error       { return DecodeJSON(data, o) }
+func (o Repository) MarshalJSON() ([]byte, error)       { return EncodeJSON(o, JSONOptions{}) }
+func (o *Repository) UnmarshalJSON(data []byte) error   { return DecodeJSON(data, o) }
+func (o Range) MarshalJSON() ([]byte, error)            { return EncodeJSON(o, JSONOptions{}) }
+func (o *Range) UnmarshalJSON(data []byte) error        { return DecodeJSON(data, o) }
+func (o Style) MarshalJSON() ([]byte, error)            { return EncodeJSON(o, JSONOptions{}) }
+func (o *Style) UnmarshalJSON(data []byte) error        { return DecodeJSON(data, o) }
+func (o Note) MarshalJSON() ([]byte, error)             { return EncodeJSON(o, JSONOptions{}) }
+func (o *Note) UnmarshalJSON(data []byte) error         { return DecodeJSON(data, o) }
+func (o Bookmark) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *Bookmark) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
+func (o NameMap) MarshalJSON() ([]byte, error)          { return EncodeJSON(o, JSONOptions{}) }
+func (o *NameMap) UnmarshalJSON(data []byte) error      { return DecodeJSON(data, o) }
+func (o Database) MarshalJSON() ([]byte, error)         { return EncodeJSON(o, JSONOptions{}) }
+func (o *Database) UnmarshalJSON(data []byte) error     { return DecodeJSON(data, o) }
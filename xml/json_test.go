@@ -0,0 +1,126 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Encoding a Database as JSON and decoding it again must not change the XML
+// it is written as.
+func TestJSONRoundTrip(t *testing.T) {
+	lenient, _ := ParseWith(gzipped(addonXML), ParseOptions{})
+	dbs := map[string]*Database{"addon": lenient}
+	for _, version := range []string{"1.5.0", "1.7.1"} {
+		f, err := os.Open(filepath.Join(*testDir, "example-"+version+".gramps"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := Parse(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs[version] = db
+	}
+	// A date that is not written back the same way.
+	dbs["addon"].People.Persons[0].Names = []*Name{{hasDate: hasDate{DateVal: &DateVal{Val: "1850-3-1"}}}}
+
+	for name, db := range dbs {
+		var want bytes.Buffer
+		if err := db.Write(&want, WriteOptions{Uncompressed: true}); err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range []JSONOptions{{}, {Indent: "  "}} {
+			var buf bytes.Buffer
+			if err := db.WriteJSON(&buf, opts); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := ParseJSON(&buf)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			var got bytes.Buffer
+			if err := decoded.Write(&got, WriteOptions{Uncompressed: true}); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("%s with %+v: written differently after JSON", name, opts)
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	first := "Anna"
+	e := &Event{dbObj: dbObj{Handle: "_e", ID: "E0001", Priv: 1}}
+	e.SetDate(Date{Modifier: ModAbout, Calendar: Julian, Start: YMD{1850, 3, 0}})
+	p := &Person{dbObj: dbObj{Handle: "_p", ID: "I0001"}, Gender: "F",
+		Names:     []*Name{{First: &first, Surnames: []*Surname{{Value: "Berg"}}}},
+		EventRefs: []*EventRef{{GenericLink: GenericLink{HLink: "_e"}, Role: "Primary"}},
+		ChildOfs:  []*GenericLink{{HLink: "_f"}}}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"handle":"_p","gramps_id":"I0001","gender":"F",` +
+		`"name_list":[{"first":"Anna","surname_list":[{"surname":"Berg"}]}],` +
+		`"event_ref_list":[{"ref":"_e","role":"Primary"}],"parent_family_list":["_f"]}`
+	if string(b) != want {
+		t.Errorf("Got %s, want %s", b, want)
+	}
+	// Values and the structs within objects are encoded the same way.
+	for _, v := range []interface{}{*p, *p.Names[0], *p.EventRefs[0], People{Persons: []*Person{p}}, *e} {
+		b, err := json.Marshal(v)
+		if err != nil || bytes.Contains(b, []byte("XMLName")) || bytes.Contains(b, []byte("Unparsed")) ||
+			bytes.Contains(b, []byte("DateVal")) {
+			t.Errorf("Encoded %T as %s, %v", v, b, err)
+		}
+	}
+	if b, _ := json.Marshal(*p); string(b) != want {
+		t.Errorf("Got %s, want %s", b, want)
+	}
+	var decoded Person
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Handle != "_p" || decoded.GetPreferredName().GetFirstName() != "Anna" ||
+		decoded.EventRefs[0].HLink != "_e" || decoded.ChildOfs[0].HLink != "_f" {
+		t.Errorf("Decoded %+v", decoded)
+	}
+
+	b, err = EncodeJSON(e, JSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"handle":"_e","gramps_id":"E0001","private":1,` +
+		`"date":{"calendar":"Julian","modifier":"about","start":{"year":1850,"month":3}}}`
+	if string(b) != want {
+		t.Errorf("Got %s, want %s", b, want)
+	}
+	var event Event
+	if err := DecodeJSON(b, &event); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := event.GetDate(); err != nil || d != (Date{Modifier: ModAbout, Calendar: Julian, Start: YMD{1850, 3, 0}}) {
+		t.Errorf("Decoded date %v, %v", d, err)
+	}
+
+	for _, s := range []string{
+		`{"handle":"_e","colour":"red"}`,
+		`{"_class":"Event","handle":"_e"}`,
+		`{"handle":1}`,
+		`{"date":{"modifier":"around"}}`,
+	} {
+		if err := DecodeJSON([]byte(s), &event); err == nil {
+			t.Errorf("Decoded %s", s)
+		}
+	}
+	if _, err := ParseJSON(strings.NewReader(`{"version":"0.9"}`)); err == nil {
+		t.Error("Decoded an unsupported version")
+	}
+}
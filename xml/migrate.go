@@ -0,0 +1,400 @@
+package xml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// The place types that correspond to the attributes of a Location, from the
+// largest to the smallest.
+var placeLevels = []struct {
+	Type  string
+	Field func(*Location) *string
+}{
+	{"Country", func(l *Location) *string { return &l.Country }},
+	{"State", func(l *Location) *string { return &l.State }},
+	{"County", func(l *Location) *string { return &l.County }},
+	{"City", func(l *Location) *string { return &l.City }},
+	{"Parish", func(l *Location) *string { return &l.Parish }},
+	{"Locality", func(l *Location) *string { return &l.Locality }},
+	{"Street", func(l *Location) *string { return &l.Street }},
+}
+
+func versionIndex(version string) int {
+	for i, v := range Versions {
+		if v == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// Migrate converts db, in place, into the model of version target.
+//
+// Upgrading from 1.5.0 turns the main Location of each place into a hierarchy
+// of enclosing places (creating the enclosing places that do not exist yet)
+// and turns DataItems into SrcAttributes, the same way Gramps upgrades its
+// database. Downgrading to 1.5.0 does the reverse, but only if nothing would
+// be lost; otherwise db is left unchanged and an error describing what could
+// not be represented is returned. The enclosing places created by upgrading
+// are removed again, unless other objects link to them or they were given
+// more than a type, a name and an enclosing place. The 1.6.0 and later
+// versions share a model, so migrating between them only changes the
+// namespace.
+func Migrate(db *Database, target string) error {
+	to := versionIndex(target)
+	if to < 0 {
+		return fmt.Errorf("Unsupported version: %s", target)
+	}
+	from := versionIndex(db.Version())
+	if from == 0 && to > 0 {
+		upgradePlaces(db)
+		upgradeDataItems(db)
+	}
+	if from > 0 && to == 0 {
+		if err := downgrade(db); err != nil {
+			return err
+		}
+	}
+	db.XMLName = xml.Name{Space: Namespace(target), Local: "database"}
+	// Places may have been added or removed.
+	db.Reindex()
+	return nil
+}
+
+// The path of a place in the hierarchy: the types and names of the place and
+// all the places enclosing it, largest first.
+type placePath []struct{ Type, Name string }
+
+func (p placePath) key() string {
+	parts := make([]string, len(p))
+	for i, v := range p {
+		parts[i] = v.Type + "=" + v.Name
+	}
+	return strings.Join(parts, "|")
+}
+
+func locationPath(l *Location) placePath {
+	var path placePath
+	for _, level := range placeLevels {
+		if v := *level.Field(l); v != "" {
+			path = append(path, struct{ Type, Name string }{level.Type, v})
+		}
+	}
+	return path
+}
+
+func upgradePlaces(db *Database) {
+	usedIDs := make(map[string]bool)
+	for _, p := range db.Places {
+		usedIDs[p.ID] = true
+	}
+	ids := newIDGenerator("P%04d", usedIDs)
+
+	// Existing places are preferred as the enclosing places of others.
+	byPath := make(map[string]*PlaceObj)
+	paths := make(map[*PlaceObj]placePath)
+	for _, p := range db.Places {
+		if len(p.PNames) > 0 || len(p.Locations) == 0 {
+			continue
+		}
+		path := locationPath(p.Locations[0])
+		paths[p] = path
+		if _, ok := byPath[path.key()]; !ok && len(path) > 0 {
+			byPath[path.key()] = p
+		}
+	}
+
+	var placeFor func(path placePath, change string) *PlaceObj
+	placeFor = func(path placePath, change string) *PlaceObj {
+		if p, ok := byPath[path.key()]; ok {
+			return p
+		}
+		last := path[len(path)-1]
+		p := &PlaceObj{Type: last.Type, PNames: []*PName{{Value: last.Name}}}
+		p.Handle = NewHandle()
+		p.Change = change
+		p.ID = ids.ID()
+		p.generated = true
+		if len(path) > 1 {
+			parent := placeFor(path[:len(path)-1], change)
+			p.PlaceRefs = []*PlaceRef{newPlaceRef(parent.Handle)}
+		}
+		byPath[path.key()] = p
+		db.Places = append(db.Places, p)
+		return p
+	}
+
+	// db.Places grows while enclosing places are created; those are already
+	// in the new model.
+	places := db.Places
+	for _, p := range places {
+		if len(p.PNames) > 0 {
+			continue
+		}
+		path, ok := paths[p]
+		if !ok || len(path) == 0 {
+			p.Type = "Unknown"
+			name := ""
+			if p.PTitle != nil {
+				name = *p.PTitle
+			}
+			p.PNames = []*PName{{Value: name}}
+			continue
+		}
+
+		last := path[len(path)-1]
+		p.Type = last.Type
+		p.PNames = []*PName{{Value: last.Name}}
+		if len(path) > 1 {
+			parent := placeFor(path[:len(path)-1], p.Change)
+			p.PlaceRefs = []*PlaceRef{newPlaceRef(parent.Handle)}
+		}
+
+		main := p.Locations[0]
+		if main.Postal != "" {
+			postal := main.Postal
+			p.Code = &postal
+		}
+		// There is nowhere else to keep the phone number.
+		if main.Phone != "" {
+			p.Locations[0] = &Location{Phone: main.Phone}
+		} else {
+			p.Locations = p.Locations[1:]
+		}
+		if len(p.Locations) == 0 {
+			p.Locations = nil
+		}
+	}
+}
+
+func newPlaceRef(hlink string) *PlaceRef {
+	r := &PlaceRef{}
+	r.HLink = hlink
+	return r
+}
+
+func upgradeDataItems(db *Database) {
+	convert := func(items []*DataItem) []*SrcAttribute {
+		var attrs []*SrcAttribute
+		for _, d := range items {
+			attrs = append(attrs, &SrcAttribute{Type: d.Key, Value: d.Value})
+		}
+		return attrs
+	}
+	for _, c := range db.Citations {
+		c.SrcAttributes = append(c.SrcAttributes, convert(c.DataItems)...)
+		c.DataItems = nil
+	}
+	for _, s := range db.Sources {
+		s.SrcAttributes = append(s.SrcAttributes, convert(s.DataItems)...)
+		s.DataItems = nil
+	}
+}
+
+// Find the main Location a place had in 1.5.0 and the names of the place and
+// the places enclosing it (smallest first). Any reasons the place cannot be
+// represented in 1.5.0 are appended to problems.
+func downgradeLocation(p *PlaceObj, byHandle map[string]*PlaceObj,
+	problems *[]string) (*Location, []string) {
+	loc := &Location{}
+	var names []string
+	visited := make(map[*PlaceObj]bool)
+	for cur := p; cur != nil; {
+		if visited[cur] {
+			*problems = append(*problems, fmt.Sprintf("place %s: hierarchy contains a cycle", p.ID))
+			break
+		}
+		visited[cur] = true
+
+		if len(cur.PNames) != 1 {
+			*problems = append(*problems, fmt.Sprintf("place %s: %d names", cur.ID, len(cur.PNames)))
+		} else {
+			n := cur.PNames[0]
+			if n.Lang != "" || n.GetDateString() != "" {
+				*problems = append(*problems, fmt.Sprintf("place %s: name has a language or date", cur.ID))
+			}
+			names = append(names, n.Value)
+			field := (*string)(nil)
+			for _, level := range placeLevels {
+				if level.Type == cur.Type {
+					field = level.Field(loc)
+				}
+			}
+			switch {
+			case cur.Type == "Unknown" && cur == p && len(cur.PlaceRefs) == 0:
+			case field == nil:
+				*problems = append(*problems, fmt.Sprintf("place %s: type %q has no location attribute", cur.ID, cur.Type))
+			case *field != "":
+				*problems = append(*problems, fmt.Sprintf("place %s: more than one %s in hierarchy", p.ID, cur.Type))
+			default:
+				*field = n.Value
+			}
+		}
+		if cur.Code != nil {
+			if cur == p {
+				loc.Postal = *cur.Code
+			} else {
+				*problems = append(*problems, fmt.Sprintf("place %s: enclosing place has a code", p.ID))
+			}
+		}
+
+		if len(cur.PlaceRefs) > 1 {
+			*problems = append(*problems, fmt.Sprintf("place %s: enclosed by %d places", cur.ID, len(cur.PlaceRefs)))
+		}
+		if len(cur.PlaceRefs) == 0 {
+			break
+		}
+		ref := cur.PlaceRefs[0]
+		if ref.Priv != 0 || ref.GetDateString() != "" || len(ref.CitationRefs) > 0 || len(ref.NoteRefs) > 0 {
+			*problems = append(*problems, fmt.Sprintf("place %s: reference to enclosing place has data", cur.ID))
+		}
+		cur = byHandle[ref.HLink]
+		if cur == nil {
+			*problems = append(*problems, fmt.Sprintf("place %s: enclosing place %s not found", p.ID, ref.HLink))
+		}
+	}
+	return loc, names
+}
+
+// Get the places created by upgrading that can be removed when downgrading:
+// those only linked to by the placerefs of other places, with nothing but a
+// type, a name and an enclosing place.
+func generatedPlaces(db *Database) map[*PlaceObj]bool {
+	generated := make(map[string]*PlaceObj)
+	for _, p := range db.Places {
+		if p.generated && len(p.PNames) == 1 && len(p.PlaceRefs) <= 1 && p.PTitle == nil &&
+			p.Code == nil && p.Coord == nil && len(p.Locations) == 0 && len(p.ObjRefs) == 0 &&
+			len(p.URLs) == 0 && len(p.NoteRefs) == 0 && len(p.CitationRefs) == 0 &&
+			len(p.TagRefs) == 0 && len(p.Unparsed) == 0 {
+			generated[p.Handle] = p
+		}
+	}
+	for _, ref := range db.Links() {
+		if _, ok := ref.From.(*PlaceObj); !ok || ref.Path != "placeobj.placeref" {
+			delete(generated, ref.HLink(db))
+		}
+	}
+	removable := make(map[*PlaceObj]bool)
+	for _, p := range generated {
+		removable[p] = true
+	}
+	return removable
+}
+
+func downgrade(db *Database) error {
+	var problems []string
+	lossy := func(kind, id, what string) {
+		problems = append(problems, fmt.Sprintf("%s %s: %s", kind, id, what))
+	}
+	for _, e := range db.Events {
+		if len(e.TagRefs) > 0 {
+			lossy("event", e.ID, "tags")
+		}
+	}
+	for _, c := range db.Citations {
+		if len(c.TagRefs) > 0 {
+			lossy("citation", c.ID, "tags")
+		}
+		if len(c.Attributes) > 0 {
+			lossy("citation", c.ID, "attributes")
+		}
+		for _, a := range c.SrcAttributes {
+			if a.Priv != 0 {
+				lossy("citation", c.ID, "private source attribute")
+			}
+		}
+	}
+	for _, s := range db.Sources {
+		if len(s.TagRefs) > 0 {
+			lossy("source", s.ID, "tags")
+		}
+		if len(s.Attributes) > 0 {
+			lossy("source", s.ID, "attributes")
+		}
+		if s.SourceText != nil {
+			lossy("source", s.ID, "source text")
+		}
+		for _, a := range s.SrcAttributes {
+			if a.Priv != 0 {
+				lossy("source", s.ID, "private source attribute")
+			}
+		}
+	}
+	for _, r := range db.Repositories {
+		if len(r.TagRefs) > 0 {
+			lossy("repository", r.ID, "tags")
+		}
+	}
+
+	byHandle := make(map[string]*PlaceObj)
+	for _, p := range db.Places {
+		byHandle[p.Handle] = p
+	}
+	locations := make(map[*PlaceObj]*Location)
+	titles := make(map[*PlaceObj]string)
+	for _, p := range db.Places {
+		if len(p.TagRefs) > 0 {
+			lossy("place", p.ID, "tags")
+		}
+		if len(p.PNames) == 0 {
+			continue
+		}
+		loc, names := downgradeLocation(p, byHandle, &problems)
+		locations[p] = loc
+		titles[p] = strings.Join(names, ", ")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Cannot migrate to 1.5.0 without losing data: %s", problems)
+	}
+
+	generated := generatedPlaces(db)
+	for p, loc := range locations {
+		if generated[p] {
+			continue
+		}
+		// Undo keeping the phone number in a Location of its own.
+		if len(p.Locations) > 0 {
+			if l := p.Locations[0]; l.Phone != "" && l.Postal == "" && len(locationPath(l)) == 0 {
+				loc.Phone = l.Phone
+				p.Locations = p.Locations[1:]
+			}
+		}
+		if len(locationPath(loc)) > 0 || loc.Postal != "" || loc.Phone != "" {
+			p.Locations = append([]*Location{loc}, p.Locations...)
+		}
+		if p.PTitle == nil && titles[p] != "" {
+			title := titles[p]
+			p.PTitle = &title
+		}
+		p.Type, p.PNames, p.Code, p.PlaceRefs = "", nil, nil, nil
+	}
+	if len(generated) > 0 {
+		var places []*PlaceObj
+		for _, p := range db.Places {
+			if !generated[p] {
+				places = append(places, p)
+			}
+		}
+		db.Places = places
+	}
+
+	convert := func(attrs []*SrcAttribute) []*DataItem {
+		var items []*DataItem
+		for _, a := range attrs {
+			items = append(items, &DataItem{Key: a.Type, Value: a.Value})
+		}
+		return items
+	}
+	for _, c := range db.Citations {
+		c.DataItems = append(c.DataItems, convert(c.SrcAttributes)...)
+		c.SrcAttributes = nil
+	}
+	for _, s := range db.Sources {
+		s.DataItems = append(s.DataItems, convert(s.SrcAttributes)...)
+		s.SrcAttributes = nil
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package xml
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func parseExample(t *testing.T, name string) *Database {
+	f, err := os.Open(filepath.Join(*testDir, name))
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer f.Close()
+	db, err := Parse(f)
+	if err != nil {
+		t.Fatalf("Failed to parse example: %s", err)
+	}
+	return db
+}
+
+func findPlace(db *Database, id string) *PlaceObj {
+	for _, p := range db.Places {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func TestMigrateRoundTrip(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	before := *findPlace(db, "P0852").Locations[0]
+	n := len(db.Places)
+
+	if err := Migrate(db, "1.7.1"); err != nil {
+		t.Fatalf("Failed to upgrade: %s", err)
+	}
+	if db.Version() != "1.7.1" {
+		t.Errorf("Expected version 1.7.1, got %s", db.Version())
+	}
+	if len(db.Places) <= n {
+		t.Errorf("Expected enclosing places to be created")
+	}
+	byHandle := make(map[string]*PlaceObj)
+	for _, p := range db.Places {
+		byHandle[p.Handle] = p
+	}
+	var names []string
+	for p := findPlace(db, "P0852"); p != nil; {
+		if len(p.PNames) != 1 {
+			t.Fatalf("Expected one name for %s, got %d", p.ID, len(p.PNames))
+		}
+		names = append(names, p.Type+" "+p.PNames[0].Value)
+		if len(p.PlaceRefs) == 0 {
+			break
+		}
+		p = byHandle[p.PlaceRefs[0].HLink]
+	}
+	expected := []string{"City Deltona", "County Volusia", "State FL", "Country USA"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected hierarchy %s, got %s", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected hierarchy %s, got %s", expected, names)
+		}
+	}
+	for _, s := range db.Sources {
+		if len(s.DataItems) > 0 {
+			t.Errorf("Data items of source %s not migrated", s.ID)
+		}
+	}
+
+	if err := Migrate(db, "1.5.0"); err != nil {
+		t.Fatalf("Failed to downgrade: %s", err)
+	}
+	if len(db.Places) != n {
+		t.Errorf("Expected %d places after downgrading, got %d", n, len(db.Places))
+	}
+	p := findPlace(db, "P0852")
+	if len(p.PNames) > 0 || len(p.Locations) != 1 {
+		t.Fatalf("Place not downgraded: %+v", p)
+	}
+	after := *p.Locations[0]
+	if after.City != before.City || after.County != before.County ||
+		after.State != before.State || after.Country != before.Country {
+		t.Errorf("Expected location %+v, got %+v", before, after)
+	}
+}
+
+func TestMigrateLossy(t *testing.T) {
+	db := parseExample(t, "example-1.7.1.gramps")
+	if err := Migrate(db, "1.5.0"); err == nil {
+		t.Errorf("Expected an error downgrading tags on events")
+	}
+	if db.Version() != "1.7.1" {
+		t.Errorf("Database changed by failed migration")
+	}
+	if err := Migrate(db, "1.6.0"); err != nil {
+		t.Errorf("Failed to migrate to 1.6.0: %s", err)
+	}
+	if err := Migrate(db, "2.0.0"); err == nil {
+		t.Errorf("Expected an error migrating to an unknown version")
+	}
+}
+
+func writeString(t *testing.T, db *Database) string {
+	var buf bytes.Buffer
+	if err := db.Write(&buf, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// Migrating to another version and back gives the same file, for every pair
+// of versions.
+func TestMigrateVersions(t *testing.T) {
+	for _, v := range Versions[1:] {
+		for _, via := range Versions[1:] {
+			db := parseExample(t, "example-1.5.0.gramps")
+			want := writeString(t, db)
+			for _, to := range []string{v, via, "1.5.0"} {
+				if err := Migrate(db, to); err != nil {
+					t.Fatalf("Failed to migrate to %s: %s", to, err)
+				}
+			}
+			if writeString(t, db) != want {
+				t.Errorf("1.5.0 changed by migrating to %s and %s", v, via)
+			}
+		}
+	}
+	for _, from := range Versions[1:] {
+		for _, to := range Versions[1:] {
+			db := parseExample(t, "example-1.7.1.gramps")
+			if err := Migrate(db, from); err != nil {
+				t.Fatal(err)
+			}
+			want := writeString(t, db)
+			if err := Migrate(db, to); err != nil {
+				t.Fatal(err)
+			}
+			if err := Migrate(db, from); err != nil {
+				t.Fatal(err)
+			}
+			if writeString(t, db) != want {
+				t.Errorf("%s changed by migrating to %s", from, to)
+			}
+		}
+	}
+}
+
+// Enclosing places that were linked to after upgrading are kept.
+func TestMigrateLinkedPlace(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	n := len(db.Places)
+	if err := Migrate(db, "1.7.1"); err != nil {
+		t.Fatal(err)
+	}
+	p := findPlace(db, "P0852")
+	county := db.PlaceByHandle(p.PlaceRefs[0].HLink)
+	db.Events[0].Place = &GenericLink{HLink: county.Handle}
+	if err := Migrate(db, "1.5.0"); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Places) != n+1 || db.PlaceByHandle(county.Handle) == nil {
+		t.Errorf("Got %d places, want %d with the county", len(db.Places), n+1)
+	}
+	if county.PTitle == nil || *county.PTitle != "Volusia, FL, USA" {
+		t.Errorf("Got county %+v", county)
+	}
+}
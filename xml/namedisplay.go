@@ -0,0 +1,317 @@
+package xml
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The numbers of the name formats built into Gramps. Custom formats have
+// negative numbers.
+const (
+	NameFormatDefault           = 0
+	NameFormatSurnameGiven      = 1
+	NameFormatGivenSurname      = 2
+	NameFormatPatronymicGiven   = 3
+	NameFormatGiven             = 4
+	NameFormatMainSurnamesGiven = 5
+)
+
+// The name formats built into Gramps. Patronymic, Given is no longer offered.
+var builtinNameFormats = []*NameFormat{
+	{Number: "1", Name: "Surname, Given Suffix", FmtStr: "%l, %f %s", Active: 1},
+	{Number: "2", Name: "Given Surname Suffix", FmtStr: "%f %l %s", Active: 1},
+	{Number: "3", Name: "Patronymic, Given", FmtStr: "%y, %s %f"},
+	{Number: "4", Name: "Given", FmtStr: "%f", Active: 1},
+	{Number: "5", Name: "Main Surnames, Given Patronymic Suffix Prefix",
+		FmtStr: "%1m %2m %o, %f %1y %s %0m", Active: 1},
+}
+
+// Whether s is a patronymic or matronymic surname.
+func (s *Surname) isPatronymic() bool {
+	return s.Derivation == "Patronymic" || s.Derivation == "Matronymic"
+}
+
+// Get the surname with its prefix and connector, e.g. "van der Berg y".
+func (s *Surname) full() string {
+	return strings.Join(strings.Fields(s.Prefix+" "+s.Value+" "+s.Connector), " ")
+}
+
+// Join the full surnames of n that keep returns true for.
+func (n *Name) joinSurnames(keep func(s *Surname) bool) string {
+	var parts []string
+	for _, s := range n.Surnames {
+		if keep(s) {
+			parts = append(parts, s.full())
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// Get the primary surname of n: the first one marked primary, or else the
+// first one.
+func (n *Name) PrimarySurname() *Surname {
+	for _, s := range n.Surnames {
+		if s.IsPrimary() {
+			return s
+		}
+	}
+	if len(n.Surnames) > 0 {
+		return n.Surnames[0]
+	}
+	return nil
+}
+
+// Get the first patronymic surname of n, or else the first matronymic one.
+func (n *Name) patronymic() *Surname {
+	for _, derivation := range []string{"Patronymic", "Matronymic"} {
+		for _, s := range n.Surnames {
+			if s.Derivation == derivation {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// A part of a surname for the format language: the prefix, surname, connector
+// or all of them.
+func surnamePart(s *Surname, part string) string {
+	if s == nil {
+		return ""
+	}
+	switch part {
+	case "pre":
+		return s.Prefix
+	case "sur":
+		return s.Value
+	case "con":
+		return s.Connector
+	}
+	return s.full()
+}
+
+// The parts of names in the format language of Gramps, by code and keyword.
+// %m or primary is the primary surname, %0m or primary[pre] its prefix, %1m
+// or primary[sur] the surname itself and %2m or primary[con] its connector.
+var nameParts = []struct {
+	code, keyword string
+	value         func(n *Name) string
+}{
+	{"t", "title", func(n *Name) string { return str(n.Title) }},
+	{"f", "given", func(n *Name) string { return str(n.First) }},
+	{"l", "surname", func(n *Name) string {
+		return n.joinSurnames(func(s *Surname) bool { return true })
+	}},
+	{"c", "call", func(n *Name) string { return str(n.Call) }},
+	{"x", "common", func(n *Name) string {
+		if nick := str(n.Nick); nick != "" {
+			return nick
+		}
+		if call := str(n.Call); call != "" {
+			return call
+		}
+		return strings.Split(strings.TrimSpace(str(n.First)), " ")[0]
+	}},
+	{"i", "initials", func(n *Name) string {
+		var initials string
+		for _, given := range strings.Fields(str(n.First)) {
+			initials += string([]rune(given)[0]) + "."
+		}
+		return initials
+	}},
+	{"s", "suffix", func(n *Name) string { return str(n.Suffix) }},
+	{"m", "primary", func(n *Name) string { return surnamePart(n.PrimarySurname(), "") }},
+	{"0m", "primary[pre]", func(n *Name) string { return surnamePart(n.PrimarySurname(), "pre") }},
+	{"1m", "primary[sur]", func(n *Name) string { return surnamePart(n.PrimarySurname(), "sur") }},
+	{"2m", "primary[con]", func(n *Name) string { return surnamePart(n.PrimarySurname(), "con") }},
+	{"y", "patronymic", func(n *Name) string { return surnamePart(n.patronymic(), "") }},
+	{"0y", "patronymic[pre]", func(n *Name) string { return surnamePart(n.patronymic(), "pre") }},
+	{"1y", "patronymic[sur]", func(n *Name) string { return surnamePart(n.patronymic(), "sur") }},
+	{"2y", "patronymic[con]", func(n *Name) string { return surnamePart(n.patronymic(), "con") }},
+	{"o", "notpatronymic", func(n *Name) string {
+		return n.joinSurnames(func(s *Surname) bool { return !s.isPatronymic() })
+	}},
+	{"r", "rawsurnames", func(n *Name) string {
+		var parts []string
+		for _, s := range n.Surnames {
+			if s.Value != "" {
+				parts = append(parts, s.Value)
+			}
+		}
+		return strings.Join(parts, " ")
+	}},
+	{"p", "prefix", func(n *Name) string {
+		var parts []string
+		for _, s := range n.Surnames {
+			if s.Prefix != "" {
+				parts = append(parts, s.Prefix)
+			}
+		}
+		return strings.Join(parts, " ")
+	}},
+	{"q", "rest", func(n *Name) string {
+		primary := n.PrimarySurname()
+		return n.joinSurnames(func(s *Surname) bool { return s != primary })
+	}},
+	{"n", "nickname", func(n *Name) string { return str(n.Nick) }},
+	{"g", "familynick", func(n *Name) string { return str(n.FamilyNick) }},
+}
+
+var (
+	codePattern    = regexp.MustCompile(`^%([0-2]?[a-zA-Z])`)
+	keywordPattern = regexp.MustCompile(`^[a-zA-Z]+(?:\[[a-zA-Z]+\])?`)
+	emptyBrackets  = regexp.MustCompile(`\(\s*\)|\[\s*\]|\{\s*\}`)
+	repeatedCommas = regexp.MustCompile(`,(\s*,)+`)
+)
+
+// Get the value of the name part with code or keyword word, and whether
+// there is one. Upper case codes and keywords give upper case values.
+func namePart(n *Name, word string, isCode bool) (string, bool) {
+	lower := strings.ToLower(word)
+	for _, part := range nameParts {
+		if (isCode && part.code == lower) || (!isCode && part.keyword == lower) {
+			v := part.value(n)
+			if word == strings.ToUpper(word) && word != lower {
+				v = strings.ToUpper(v)
+			}
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Format n by a format string of Gramps, e.g. "%l, %f %s" or
+// "SURNAME, given (common)". Text in double quotes is kept as it is. Empty
+// parentheses, doubled commas and the spaces and commas around missing parts
+// are removed, as Gramps does.
+func FormatName(n *Name, format string) string {
+	var buf []string
+	for rest := format; rest != ""; {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				buf = append(buf, rest[1:])
+				break
+			}
+			buf = append(buf, rest[1:end+1])
+			rest = rest[end+2:]
+			continue
+		}
+		if m := codePattern.FindStringSubmatch(rest); m != nil {
+			if v, ok := namePart(n, m[1], true); ok {
+				buf = append(buf, v)
+				rest = rest[len(m[0]):]
+				continue
+			}
+		}
+		if m := keywordPattern.FindString(rest); m != "" {
+			if v, ok := namePart(n, m, false); ok {
+				buf = append(buf, v)
+			} else {
+				buf = append(buf, m)
+			}
+			rest = rest[len(m):]
+			continue
+		}
+		buf = append(buf, rest[:1])
+		rest = rest[1:]
+	}
+	return cleanupName(strings.Join(buf, ""))
+}
+
+// Remove what is left around missing parts of a formatted name.
+func cleanupName(s string) string {
+	for {
+		t := repeatedCommas.ReplaceAllString(emptyBrackets.ReplaceAllString(s, ""), ",")
+		if t == s {
+			break
+		}
+		s = t
+	}
+	// Put separators after the word before them: "a , b" is "a, b".
+	var result string
+	for _, word := range strings.Fields(s) {
+		if result != "" && len(word) == 1 && strings.ContainsAny(word, ",;:.") {
+			result += word
+		} else if result != "" {
+			result += " " + word
+		} else {
+			result = word
+		}
+	}
+	return strings.TrimFunc(result, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(",;:", r)
+	})
+}
+
+// A NameDisplayer writes names by the name formats of a Database, like the
+// name displayer of Gramps.
+type NameDisplayer struct {
+	// The format strings of the active formats, by number.
+	formats map[int]string
+	// The number of the format for names that do not choose one or choose one
+	// that is not active: Surname, Given Suffix unless set.
+	Default int
+}
+
+// Create a NameDisplayer for the built-in formats and formats, which may
+// replace them.
+func NewNameDisplayer(formats []*NameFormat) *NameDisplayer {
+	nd := &NameDisplayer{formats: map[int]string{}, Default: NameFormatSurnameGiven}
+	for _, f := range append(builtinNameFormats, formats...) {
+		number, err := strconv.Atoi(f.Number)
+		if err != nil {
+			continue
+		}
+		if f.Active != 0 {
+			nd.formats[number] = f.FmtStr
+		} else {
+			delete(nd.formats, number)
+		}
+	}
+	return nd
+}
+
+// DefaultNameDisplayer writes names with the formats built into Gramps. It is
+// used by Name.String.
+var DefaultNameDisplayer = NewNameDisplayer(nil)
+
+// Create a NameDisplayer for the name formats of db.
+func (db *Database) NameDisplayer() *NameDisplayer {
+	return NewNameDisplayer(db.NameFormats)
+}
+
+// Get the format string with number, or the default format if it is not active.
+func (nd *NameDisplayer) format(number int) string {
+	if f, ok := nd.formats[number]; ok && number != NameFormatDefault {
+		return f
+	}
+	if f, ok := nd.formats[nd.Default]; ok {
+		return f
+	}
+	return builtinNameFormats[0].FmtStr
+}
+
+// Format n by its display format.
+func (nd *NameDisplayer) Display(n *Name) string {
+	return FormatName(n, nd.format(n.Display))
+}
+
+// Format n by its sort format, for sorting names.
+func (nd *NameDisplayer) Sorted(n *Name) string {
+	return FormatName(n, nd.format(n.Sort))
+}
+
+// Format n by the format with number.
+func (nd *NameDisplayer) DisplayWith(n *Name, number int) string {
+	return FormatName(n, nd.format(number))
+}
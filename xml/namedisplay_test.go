@@ -0,0 +1,106 @@
+package xml
+
+import (
+	"testing"
+)
+
+func strp(s string) *string { return &s }
+
+func TestFormatName(t *testing.T) {
+	n := &Name{
+		First:  strp("José María"),
+		Call:   strp("María"),
+		Title:  strp("Dr."),
+		Suffix: strp("Jr."),
+		Nick:   strp("Pepe"),
+		Surnames: []*Surname{
+			{Prefix: "de la", Value: "Cruz", Connector: "y"},
+			{Value: "Ortega", Prim: "0"},
+			{Value: "Pérez", Prim: "0", Derivation: "Patronymic"},
+		},
+	}
+	for _, test := range []struct{ format, want string }{
+		{"%l, %f %s", "de la Cruz y Ortega Pérez, José María Jr."},
+		{"%f %l %s", "José María de la Cruz y Ortega Pérez Jr."},
+		{"%f", "José María"},
+		{"%1m %2m %o, %f %1y %s %0m", "Cruz y de la Cruz y Ortega, José María Pérez Jr. de la"},
+		{"SURNAME, given (common)", "DE LA CRUZ Y ORTEGA PÉREZ, José María (Pepe)"},
+		{"%L, %i", "DE LA CRUZ Y ORTEGA PÉREZ, J.M."},
+		{"title given primary[sur]", "Dr. José María Cruz"},
+		{"call rawsurnames", "María Cruz Ortega Pérez"},
+		{"prefix: rest", "de la: Ortega Pérez"},
+		{"patronymic[sur]", "Pérez"},
+		{"%t %c %1m", "Dr. María Cruz"},
+		{`given "the" nickname`, "José María the Pepe"},
+	} {
+		if got := FormatName(n, test.format); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.format, got, test.want)
+		}
+	}
+
+	// Missing parts leave no separators behind.
+	n = &Name{First: strp("John")}
+	for _, test := range []struct{ format, want string }{
+		{"%l, %f %s", "John"},
+		{"SURNAME, given (familynick)", "John"},
+		{"%f (%n), %s", "John"},
+		{"surname, title, given", "John"},
+	} {
+		if got := FormatName(n, test.format); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.format, got, test.want)
+		}
+	}
+	if got := FormatName(&Name{}, "%l, %f"); got != "" {
+		t.Errorf("Got %q for an empty name", got)
+	}
+}
+
+func TestNameDisplayer(t *testing.T) {
+	n := &Name{First: strp("John"), Suffix: strp("Sr."),
+		Surnames: []*Surname{{Value: "Smith"}}}
+	if s := n.String(); s != "Smith, John Sr." {
+		t.Errorf("Got %q", s)
+	}
+	n.Display = NameFormatGivenSurname
+	if s := n.String(); s != "John Smith Sr." {
+		t.Errorf("Got %q", s)
+	}
+	n.Display = NameFormatPatronymicGiven
+	if s := n.String(); s != "Smith, John Sr." {
+		t.Errorf("Inactive format: got %q", s)
+	}
+
+	nd := NewNameDisplayer([]*NameFormat{
+		{Number: "-1", Name: "SURNAME, Given", FmtStr: "SURNAME, given", Active: 1},
+		{Number: "-2", Name: "Inactive", FmtStr: "given"},
+	})
+	n.Display, n.Sort = -1, NameFormatGiven
+	if s := nd.Display(n); s != "SMITH, John" {
+		t.Errorf("Got %q", s)
+	}
+	if s := nd.Sorted(n); s != "John" {
+		t.Errorf("Got %q", s)
+	}
+	n.Display = -2
+	if s := nd.Display(n); s != "Smith, John Sr." {
+		t.Errorf("Inactive format: got %q", s)
+	}
+	nd.Default = -1
+	if s := nd.Display(n); s != "SMITH, John" {
+		t.Errorf("Default format: got %q", s)
+	}
+	if s := nd.DisplayWith(n, NameFormatGiven); s != "John" {
+		t.Errorf("Got %q", s)
+	}
+}
+
+func TestNameDisplayerExample(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	nd := db.NameDisplayer()
+	nd.Default = -1
+	p := db.PersonByID("I0552")
+	n := p.GetPreferredName()
+	if s := nd.Display(n); s != "NIELSEN, Martha (Martha)" {
+		t.Errorf("Got %q", s)
+	}
+}
@@ -0,0 +1,196 @@
+package xml
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The name of the XML file in a Gramps package.
+const packageData = "data.gramps"
+
+// A Package is a Database read from a Gramps package (.gpkg), a tar.gz of
+// data.gramps and the media files of its objects.
+type Package struct {
+	*Database
+	// The contents of the media files of the package by their paths in it.
+	Media map[string][]byte
+}
+
+// Get the path in a package of a media file with path src. Packages written
+// by Gramps have absolute paths without the leading slash.
+func packagePath(src string) string {
+	return path.Clean(strings.TrimLeft(strings.Replace(src, "\\", "/", -1), "/"))
+}
+
+// Get the contents of the media file of o, or nil if the package does not
+// have it.
+func (p *Package) MediaFile(o *Object) []byte {
+	return p.Media[packagePath(o.File.Src)]
+}
+
+// Read a Gramps package. Its data.gramps is parsed as by Parse, and the other
+// files in it are its media.
+func ReadPackage(r io.Reader) (*Package, error) {
+	unzipped, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer unzipped.Close()
+	p := &Package{Media: make(map[string][]byte)}
+	tr := tar.NewReader(unzipped)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if packagePath(h.Name) == packageData {
+			if p.Database, err = Parse(tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.Media[packagePath(h.Name)], err = ioutil.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+	if p.Database == nil {
+		return nil, fmt.Errorf("Not a Gramps package: no %s", packageData)
+	}
+	return p, nil
+}
+
+// A MissingMediaError lists the media objects whose files could not be put in
+// a package.
+type MissingMediaError struct {
+	Objects []*Object
+}
+
+func (e *MissingMediaError) Error() string {
+	files := make([]string, len(e.Objects))
+	for i, o := range e.Objects {
+		files[i] = o.File.Src
+	}
+	return fmt.Sprintf("Missing media files: %s", files)
+}
+
+// Get whether src is a URL rather than a file path. Windows drive letters are
+// not schemes.
+func isURL(src string) bool {
+	u, err := url.Parse(src)
+	return err == nil && len(u.Scheme) > 1
+}
+
+// Write the Database to w as a Gramps package, with the media files of its
+// objects. Relative paths of files are taken from the media path of the
+// Database, and are kept in the package; files outside it are put in a media
+// directory. The paths of objects are rewritten to those in the package.
+// Files that are missing are left out and returned in a *MissingMediaError
+// after the rest of the package is written.
+func (db *Database) WritePackage(w io.Writer, opts WriteOptions) error {
+	dir := ""
+	if db.Header.MediaPath != nil {
+		dir = *db.Header.MediaPath
+	}
+	// The objects are copied to rewrite their paths.
+	pkg := *db
+	pkg.Header.MediaPath = nil
+	pkg.Objects = make([]*Object, len(db.Objects))
+	var files, names []string
+	// The files by their paths in the package.
+	used := make(map[string]string)
+	var missing []*Object
+	for i, o := range db.Objects {
+		pkg.Objects[i] = o
+		src := o.File.Src
+		if src == "" || isURL(src) {
+			continue
+		}
+		file := filepath.FromSlash(src)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			missing = append(missing, o)
+			continue
+		}
+		name := packagePath(src)
+		if filepath.IsAbs(filepath.FromSlash(src)) || name == ".." || strings.HasPrefix(name, "../") ||
+			strings.Contains(name, ":") {
+			name = "media/" + path.Base(name)
+		}
+		ext := path.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		for n := 2; name == packageData || used[name] != "" && used[name] != file; n++ {
+			name = base + "-" + strconv.Itoa(n) + ext
+		}
+		if used[name] == "" {
+			used[name] = file
+			files = append(files, file)
+			names = append(names, name)
+		}
+		c := *o
+		c.File.Src = name
+		pkg.Objects[i] = &c
+	}
+
+	var data bytes.Buffer
+	if err := pkg.Write(&data, opts); err != nil {
+		return err
+	}
+	zipped := gzip.NewWriter(w)
+	tw := tar.NewWriter(zipped)
+	now := time.Now()
+	err := tw.WriteHeader(&tar.Header{Name: packageData, Mode: 0644, Size: int64(data.Len()), ModTime: now})
+	if err == nil {
+		_, err = tw.Write(data.Bytes())
+	}
+	for i := 0; i < len(files) && err == nil; i++ {
+		err = addToTar(tw, names[i], files[i])
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = zipped.Close()
+	}
+	if err == nil && len(missing) > 0 {
+		err = &MissingMediaError{Objects: missing}
+	}
+	return err
+}
+
+// Write file to tw as name.
+func addToTar(tw *tar.Writer, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	h := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
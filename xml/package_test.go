@@ -0,0 +1,75 @@
+package xml
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xml-package-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	media := filepath.Join(dir, "media")
+	if err := os.MkdirAll(filepath.Join(media, "scans"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(dir, "photo.png")
+	files := map[string]string{
+		filepath.Join(media, "scans", "page.jpg"): "page",
+		filepath.Join(media, "photo.png"):         "other photo",
+		outside:                                   "photo",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := &Database{}
+	db.Header.MediaPath = &media
+	for _, src := range []string{"scans/page.jpg", "photo.png", filepath.ToSlash(outside), "gone.png",
+		"http://example.com/a.jpg"} {
+		if err := db.Add(&Object{File: File{Src: src}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	err = db.WritePackage(&buf, WriteOptions{})
+	if e, ok := err.(*MissingMediaError); !ok || len(e.Objects) != 1 || e.Objects[0].File.Src != "gone.png" {
+		t.Errorf("Got error %v", err)
+	}
+	if db.Objects[2].File.Src != filepath.ToSlash(outside) {
+		t.Errorf("Path of written database changed to %s", db.Objects[2].File.Src)
+	}
+
+	p, err := ReadPackage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header.MediaPath != nil || len(p.Media) != 3 {
+		t.Errorf("Got media path %v, %d files", p.Header.MediaPath, len(p.Media))
+	}
+	for i, want := range []struct {
+		src, content string
+	}{
+		{"scans/page.jpg", "page"},
+		{"photo.png", "other photo"},
+		{"media/photo.png", "photo"},
+		{"gone.png", ""},
+		{"http://example.com/a.jpg", ""},
+	} {
+		o := p.Objects[i]
+		if o.File.Src != want.src || string(p.MediaFile(o)) != want.content {
+			t.Errorf("Got %s with %q, want %s with %q", o.File.Src, p.MediaFile(o), want.src, want.content)
+		}
+	}
+
+	if _, err := ReadPackage(bytes.NewReader([]byte("not a package"))); err == nil {
+		t.Error("Read a file that is not a package")
+	}
+}
@@ -1,7 +1,7 @@
 package xml
 
 import (
-	"compress/gzip"
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -9,73 +9,128 @@ import (
 	"os"
 	"reflect"
 	"sort"
+	"strings"
 )
 
-func findUnparsedFields(v interface{}, path string, unparsed *map[string]bool) {
-	value := reflect.ValueOf(v)
-	fv := value.FieldByName("Unparsed")
-	if !fv.IsValid() {
-		panic(fmt.Sprintf("Unable to find Unparsed field in type: %s",
-			value.Type()))
-	}
-	unparsedXML := fv.Interface().([]*raw)
+// An UnparsedField is an element that was not parsed into a struct field.
+type UnparsedField struct {
+	// The path of the element, e.g. database.people.person.foo.
+	Path string
+	// The handle and ID of the object containing the element, if any.
+	Handle string
+	ID     string
+	// The line of the element in the decompressed XML.
+	Line int
+}
 
-	fv = value.FieldByName("XMLName")
-	if !fv.IsValid() {
-		panic(fmt.Sprintf("Unable to find XMLName field in type: %s",
-			value.Type()))
+// An UnparsedError lists the elements of a file that were not parsed.
+type UnparsedError struct {
+	Fields []UnparsedField
+}
+
+func (e *UnparsedError) Error() string {
+	paths := make(map[string]bool)
+	for _, f := range e.Fields {
+		paths[f.Path] = true
 	}
-	newPath := fv.Interface().(xml.Name).Local
-	if path != "" {
-		newPath = path + "." + newPath
+	names := make([]string, 0, len(paths))
+	for k := range paths {
+		names = append(names, k)
 	}
+	sort.Strings(names)
+	return fmt.Sprintf("Unparsed fields: %s", names)
+}
 
-	for _, v := range unparsedXML {
-		(*unparsed)[newPath+"."+v.XMLName.Local] = true
-	}
+var rawType = reflect.TypeOf(raw{})
 
-	n := value.NumField()
-	for i := 0; i < n; i++ {
-		subField := value.Field(i)
-		subType := value.Type().Field(i)
-		if value.Type().Field(i).Name == "XMLName" {
-			continue
+// Append the unparsed elements within v, the element at path, to fields.
+// Handle and id are those of the innermost object containing v.
+func findUnparsedFields(v reflect.Value, path, handle, id string,
+	fields *[]UnparsedField) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			findUnparsedFields(v.Elem(), path, handle, id, fields)
 		}
-		if subField.Type().String() == "*xml.raw" ||
-			subField.Type().String() == "[]*xml.raw" {
-			continue
+		return
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			findUnparsedFields(v.Index(i), path, handle, id, fields)
 		}
-		if subField.Kind() == reflect.Struct && !subType.Anonymous {
-			findUnparsedFields(subField.Interface(), newPath, unparsed)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+	if v.Type() == rawType {
+		r := v.Interface().(raw)
+		*fields = append(*fields, UnparsedField{
+			Path: path + "." + r.XMLName.Local, Handle: handle, ID: id, Line: r.line})
+		return
+	}
+
+	if h := v.FieldByName("Handle"); h.IsValid() && h.Kind() == reflect.String {
+		handle, id = h.String(), ""
+		if i := v.FieldByName("ID"); i.IsValid() {
+			id = i.String()
 		}
-		if subField.Kind() == reflect.Slice &&
-			subField.Type().Elem().Kind() == reflect.Struct {
-			n := subField.Len()
-			for j := 0; j < n; j++ {
-				findUnparsedFields(subField.Index(j).Interface(), newPath, unparsed)
-			}
+	}
+	for _, f := range fieldsOf(v.Type()) {
+		switch {
+		case f.any:
+			findUnparsedFields(v.FieldByIndex(f.index), path, handle, id, fields)
+		case !f.attr && !f.charData:
+			name := strings.Replace(f.name, ">", ".", -1)
+			findUnparsedFields(v.FieldByIndex(f.index), path+"."+name, handle, id, fields)
 		}
 	}
 }
 
-func fullyParsed(db *Database) error {
-	unparsedFields := make(map[string]bool)
-	findUnparsedFields(*db, "", &unparsedFields)
-	if len(unparsedFields) > 0 {
-		names := make([]string, 0, len(unparsedFields))
-		for k := range unparsedFields {
-			names = append(names, k)
+// Get the unparsed elements within v, a pointer to the element at path.
+func unparsedError(v interface{}, path string) *UnparsedError {
+	var fields []UnparsedField
+	findUnparsedFields(reflect.ValueOf(v), path, "", "", &fields)
+	if len(fields) == 0 {
+		return nil
+	}
+	return &UnparsedError{fields}
+}
+
+var nameType = reflect.TypeOf(xml.Name{})
+
+// Remove the namespace from the XMLName of v and every element within it. The
+// namespace is only written on the root element, so that it always matches
+// the version being written.
+func clearNamespaces(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			clearNamespaces(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearNamespaces(v.Index(i))
+		}
+	case reflect.Struct:
+		// Unparsed elements may belong to other namespaces.
+		if v.Type() == rawType {
+			return
+		}
+		if v.Type() == nameType {
+			v.FieldByName("Space").SetString("")
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			clearNamespaces(v.Field(i))
 		}
-		sort.Strings(names)
-		return fmt.Errorf("Unparsed fields: %s", names)
 	}
-	return nil
 }
 
 // Unmarshal a .gramps XML file in an arbitrary interface with XML tags.
-// Useful for parsing a portion of a database.
+// Useful for parsing a portion of a database. The file may be compressed with
+// gzip, bzip2 or xz, or not compressed at all.
 func Unmarshal(r io.Reader, v interface{}) error {
-	unzipped, err := gzip.NewReader(r)
+	unzipped, _, err := Decompress(r)
 	if err != nil {
 		return err
 	}
@@ -85,46 +140,178 @@ func Unmarshal(r io.Reader, v interface{}) error {
 	if err != nil {
 		return err
 	}
-	return xml.Unmarshal(data, v)
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return err
+	}
+	return placeUnparsed(reflect.ValueOf(v), data, 0)
+}
+
+// A recorder keeps the bytes read from r since they were last cleared, so
+// that an object decoded from them can be read again by placeUnparsed.
+type recorder struct {
+	r   io.ByteReader
+	buf []byte
+}
+
+func (rec *recorder) ReadByte() (byte, error) {
+	b, err := rec.r.ReadByte()
+	if err == nil {
+		rec.buf = append(rec.buf, b)
+	}
+	return b, err
+}
+
+func (rec *recorder) Read(p []byte) (int, error) {
+	for i := range p {
+		b, err := rec.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = b
+	}
+	return len(p), nil
+}
+
+// Add the unparsed elements within v to raws, by their offsets.
+func collectRaws(v reflect.Value, raws map[int64]*raw) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if r, ok := v.Interface().(*raw); ok {
+			raws[r.offset] = r
+			return
+		}
+		collectRaws(v.Elem(), raws)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			collectRaws(v.Index(i), raws)
+		}
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			if !f.attr && !f.charData {
+				collectRaws(v.FieldByIndex(f.index), raws)
+			}
+		}
+	}
 }
 
-// Parse a .gramps XML file into a full Database. Returns an error if any
-// portion of the XML was unparsed.
+// Find the prefixes of the names of the unparsed elements within v, which
+// encoding/xml does not report, and their positions among the elements of
+// their parents, by reading data again. v was decoded from data, which starts
+// at offset base of the input.
+func placeUnparsed(v reflect.Value, data []byte, base int64) error {
+	raws := make(map[int64]*raw)
+	collectRaws(v, raws)
+	if len(raws) == 0 {
+		return nil
+	}
+
+	// The number of parsed elements and the unparsed elements within each
+	// open element. The first is the element data starts in.
+	type open struct {
+		parsed int
+		raws   []*raw
+	}
+	stack := []*open{{}}
+	end := func(o *open) {
+		// Count the parsed elements after each unparsed element.
+		for _, r := range o.raws {
+			r.following = o.parsed - r.following
+		}
+	}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for len(stack) > 0 {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			if r, ok := raws[base+d.InputOffset()]; ok {
+				r.prefix = tok.Name.Space
+				r.following = parent.parsed
+				parent.raws = append(parent.raws, r)
+			} else {
+				parent.parsed++
+			}
+			stack = append(stack, &open{})
+		case xml.EndElement:
+			end(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+		}
+	}
+	for _, o := range stack {
+		end(o)
+	}
+	return nil
+}
+
+// Parse an unparsed element written by canonicalWriter.raw.
+func parseRaw(s string) (*raw, error) {
+	r := &raw{}
+	if err := xml.NewDecoder(strings.NewReader(s)).Decode(r); err != nil {
+		return nil, err
+	}
+	return r, placeUnparsed(reflect.ValueOf(r), []byte(s), 0)
+}
+
+// Options controlling how a file is parsed.
+type ParseOptions struct {
+	// Fail if any portion of the XML is not parsed. Otherwise unparsed
+	// elements are kept and written back out when the Database is written,
+	// where they were among the elements of their parents.
+	Strict bool
+}
+
+// Parse a .gramps XML file into a full Database. The version of the file is
+// detected from the namespace of its root element. Returns an error if the
+// version is not supported or if any portion of the XML was unparsed.
 func Parse(r io.Reader) (*Database, error) {
+	return ParseWith(r, ParseOptions{Strict: true})
+}
+
+// Parse a .gramps XML file into a full Database with the given options. When
+// not parsing strictly, elements that were not parsed are reported by
+// returning the Database along with an *UnparsedError.
+func ParseWith(r io.Reader, opts ParseOptions) (*Database, error) {
 	var parsed Database
 	if err := Unmarshal(r, &parsed); err != nil {
 		return nil, err
 	}
-
-	if err := fullyParsed(&parsed); err != nil {
-		return nil, err
+	if _, ok := VersionOf(parsed.XMLName.Space); !ok {
+		return nil, fmt.Errorf("Unsupported namespace: %q", parsed.XMLName.Space)
 	}
 
+	ns := parsed.XMLName.Space
+	clearNamespaces(reflect.ValueOf(&parsed))
+	parsed.XMLName.Space = ns
+
+	parsed.Reindex()
+	if err := unparsedError(&parsed, "database"); err != nil {
+		if opts.Strict {
+			return nil, err
+		}
+		return &parsed, err
+	}
 	return &parsed, nil
 }
 
-// Serialize the Database back out into a .gramps XML file.
+// Serialize the Database back out into a .gramps XML file. The file is written
+// in the version the Database was parsed from.
 func (db Database) Serialize(filename string) error {
-	data, err := xml.MarshalIndent(db, "", "\t")
-	if err != nil {
-		return err
-	}
-
 	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	zipped := gzip.NewWriter(f)
-	if err != nil {
+	if err = db.Write(f, WriteOptions{}); err != nil {
+		f.Close()
 		return err
 	}
-
-	_, err = zipped.Write(data)
-	zipped.Close()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return f.Close()
 }
@@ -2,10 +2,14 @@ package xml
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/xml"
 	"flag"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -28,8 +32,60 @@ func BenchmarkParse(b *testing.B) {
 	}
 }
 
+func readGzipped(t *testing.T, filename string) []byte {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer f.Close()
+	unzipped, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Failed to unzip %s: %s", filename, err)
+	}
+	data, err := ioutil.ReadAll(unzipped)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %s", filename, err)
+	}
+	return data
+}
+
+// Parsing and serializing a Gramps export must reproduce it exactly.
 func TestParsesExample(t *testing.T) {
-	f, err := os.Open(filepath.Join(*testDir, "example-1.5.0.gramps"))
+	for _, version := range []string{"1.5.0", "1.7.1"} {
+		ef := filepath.Join(*testDir, "example-"+version+".gramps")
+		f, err := os.Open(ef)
+		if err != nil {
+			t.Fatalf("Failed to open file: %s", err)
+		}
+		db, err := Parse(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Failed to parse example: %s", err)
+		}
+
+		tmpDir, err := ioutil.TempDir("/tmp", "xml-parse-test")
+		if err != nil {
+			t.Fatalf("Failed to create tmp dir: %s", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		af := filepath.Join(tmpDir, "actual-"+version+".gramps")
+		if err = db.Serialize(af); err != nil {
+			t.Fatalf("Failed to serialize db: %s", err)
+		}
+
+		ab := readGzipped(t, af)
+		eb := readGzipped(t, ef)
+		if !bytes.Equal(ab, eb) {
+			nf := filepath.Join(*testDir, "actual-"+version+".gramps")
+			os.Rename(af, nf)
+			t.Errorf("Actual not equal to example %s vs %s", nf, ef)
+		}
+	}
+}
+
+func TestParsesVersions(t *testing.T) {
+	f, err := os.Open(filepath.Join(*testDir, "example-1.7.1.gramps"))
 	if err != nil {
 		t.Fatalf("Failed to open file: %s", err)
 	}
@@ -37,6 +93,25 @@ func TestParsesExample(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to parse example: %s", err)
 	}
+	if db.Version() != "1.7.1" {
+		t.Errorf("Expected version 1.7.1, got %s", db.Version())
+	}
+	if len(db.Places) != 2 {
+		t.Fatalf("Expected 2 places, got %d", len(db.Places))
+	}
+	p := db.Places[0]
+	if p.Type != "City" || len(p.PNames) != 1 || p.PNames[0].Value != "Great Falls" {
+		t.Errorf("Place not parsed: %+v", p)
+	}
+	if len(p.PlaceRefs) != 1 || p.PlaceRefs[0].HLink != "_ZZMONTANA" {
+		t.Errorf("Place hierarchy not parsed: %+v", p.PlaceRefs)
+	}
+	if n := db.Places[1].PNames[1]; n.Lang != "en" || n.GetDateString() != "between 1889 and 2019" {
+		t.Errorf("Place name not parsed: %+v", n)
+	}
+	if len(db.Citations[0].SrcAttributes) != 1 || len(db.Sources[0].TagRefs) != 1 {
+		t.Errorf("Citation or source not parsed")
+	}
 
 	tmpDir, err := ioutil.TempDir("/tmp", "xml-parse-test")
 	if err != nil {
@@ -44,27 +119,214 @@ func TestParsesExample(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	af := filepath.Join(tmpDir, "actual-1.5.0.gramps")
+	af := filepath.Join(tmpDir, "actual-1.7.1.gramps")
 	if err = db.Serialize(af); err != nil {
 		t.Fatalf("Failed to serialize db: %s", err)
 	}
-
-	ab, err := ioutil.ReadFile(af)
+	f, err = os.Open(af)
 	if err != nil {
-		t.Fatalf("Failed to read actual output: %s", err)
+		t.Fatalf("Failed to open actual output: %s", err)
+	}
+	db, err = Parse(f)
+	if err != nil {
+		t.Fatalf("Failed to parse actual output: %s", err)
+	}
+	if db.Version() != "1.7.1" {
+		t.Errorf("Expected version 1.7.1 after serializing, got %s", db.Version())
+	}
+}
+
+func TestRejectsUnknownVersion(t *testing.T) {
+	var buf bytes.Buffer
+	zipped := gzip.NewWriter(&buf)
+	zipped.Write([]byte(`<database xmlns="http://gramps-project.org/xml/9.9.9/"/>`))
+	zipped.Close()
+	if _, err := Parse(&buf); err == nil {
+		t.Errorf("Expected an error parsing an unknown version")
+	}
+}
+
+const addonXML = `<?xml version="1.0" encoding="UTF-8"?>
+<database xmlns="http://gramps-project.org/xml/1.7.1/">
+  <header>
+    <created date="2019-08-10" version="5.1.0"/>
+  </header>
+  <people>
+    <person handle="_a" change="1" id="I0001">
+      <gender>F</gender>
+      <addon:extra xmlns:addon="http://example.com/addon" addon:level="2" kind="x">kept</addon:extra>
+    </person>
+  </people>
+  <unknown/>
+</database>
+`
+
+func gzipped(s string) *bytes.Buffer {
+	var buf bytes.Buffer
+	zipped := gzip.NewWriter(&buf)
+	zipped.Write([]byte(s))
+	zipped.Close()
+	return &buf
+}
+
+func TestParseLenient(t *testing.T) {
+	if _, err := Parse(gzipped(addonXML)); err == nil {
+		t.Fatalf("Expected an error parsing strictly")
+	}
+
+	db, err := ParseWith(gzipped(addonXML), ParseOptions{})
+	uerr, ok := err.(*UnparsedError)
+	if !ok {
+		t.Fatalf("Expected an UnparsedError, got %v", err)
+	}
+	expected := []UnparsedField{
+		{Path: "database.people.person.extra", Handle: "_a", ID: "I0001", Line: 9},
+		{Path: "database.unknown", Line: 12},
+	}
+	if len(uerr.Fields) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, uerr.Fields)
+	}
+	for i := range expected {
+		if uerr.Fields[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], uerr.Fields[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := db.Write(&buf, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatalf("Failed to write db: %s", err)
+	}
+	for _, s := range []string{
+		"      <gender>F</gender>\n" +
+			`      <addon:extra xmlns:addon="http://example.com/addon" addon:level="2" kind="x">kept</addon:extra>` + "\n",
+		"  <unknown/>\n</database>\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Expected output to contain %q, got %s", s, buf.String())
+		}
+	}
+}
+
+// Unparsed elements keep their prefixes, also when they are declared on an
+// ancestor, so that their children stay in their namespaces.
+func TestUnparsedPrefixes(t *testing.T) {
+	const in = `<?xml version="1.0" encoding="UTF-8"?>
+<database xmlns="http://gramps-project.org/xml/1.7.1/" xmlns:addon="http://example.com/addon">
+  <people>
+    <person handle="_a" change="1" id="I0001">
+      <gender>F</gender>
+      <addon:extra addon:level="2" xml:lang="en"><child/></addon:extra>
+      <plain xmlns="http://example.com/other"/>
+    </person>
+  </people>
+</database>
+`
+	want := []string{
+		`      <addon:extra xmlns:addon="http://example.com/addon" addon:level="2" xml:lang="en"><child/></addon:extra>` + "\n",
+		`      <plain xmlns="http://example.com/other"/>` + "\n",
+	}
+	db, _ := ParseWith(gzipped(in), ParseOptions{})
+	var buf bytes.Buffer
+	if err := db.Write(&buf, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range want {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Expected output to contain %q, got %s", s, buf.String())
+		}
+	}
+	written := buf.String()
+	if db, _ = ParseWith(&buf, ParseOptions{}); db == nil {
+		t.Fatal("Failed to parse written file")
 	}
-	gf := filepath.Join(*testDir, "golden-1.5.0.gramps")
-	gb, err := ioutil.ReadFile(gf)
+	if got := db.People.Persons[0].Unparsed[0].Contents; got != "<child/>" {
+		t.Errorf("Got contents %q", got)
+	}
+	buf.Reset()
+	db.Write(&buf, WriteOptions{Uncompressed: true})
+	if buf.String() != written {
+		t.Errorf("Written again as %s", buf.String())
+	}
+
+	dec, err := NewDecoder(gzipped(in))
 	if err != nil {
-		t.Fatalf("Failed to read golden output: %s", err)
+		t.Fatal(err)
 	}
-	if !bytes.Equal(ab, gb) {
-		nf := filepath.Join(*testDir, "/actual-1.5.0.gramps")
-		os.Rename(af, nf)
-		t.Errorf("Actual not equal to golden %s vs %s", nf, gf)
+	defer dec.Close()
+	dec.Strict = false
+	dec.Next()
+	obj, _ := dec.Next()
+	if p, ok := obj.(*Person); !ok || p.Unparsed[0].prefix != "addon" {
+		t.Errorf("Got %#v", obj)
 	}
 }
 
-func init() {
-	flag.Parse()
+// Unparsed elements are written back where they were among the elements of
+// their parents.
+func TestUnparsedPosition(t *testing.T) {
+	first, event := "Anna", "Birth"
+	db := &Database{XMLName: xml.Name{Space: Namespace(LatestVersion)}}
+	p := &Person{Gender: "F", Names: []*Name{{First: &first}},
+		EventRefs: []*EventRef{{GenericLink: GenericLink{HLink: "_e"}}}}
+	p.Handle, p.ID = "_p", "I0000"
+	e := &Event{Type: &event}
+	e.Handle, e.ID = "_e", "E0000"
+	db.Events, db.People.Persons = []*Event{e}, []*Person{p}
+	var buf bytes.Buffer
+	if err := db.Write(&buf, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatal(err)
+	}
+	in := buf.String()
+	for _, insert := range []struct{ before, s string }{
+		{"      <name", "      <nickname>Ann</nickname>\n"},
+		{"      <eventref", `      <x:y xmlns:x="http://example.com/x"/>` + "\n"},
+		{"    <person", "    <group/>\n"},
+		{"  <people", "  <between/>\n"},
+	} {
+		in = strings.Replace(in, insert.before, insert.s+insert.before, 1)
+	}
+
+	db, err := ParseWith(strings.NewReader(in), ParseOptions{})
+	if _, ok := err.(*UnparsedError); !ok {
+		t.Fatalf("Got %v, want an UnparsedError", err)
+	}
+	buf.Reset()
+	if err := db.Write(&buf, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != in {
+		t.Errorf("Written as %s, want %s", buf.String(), in)
+	}
+
+	var j bytes.Buffer
+	if err := db.WriteJSON(&j, JSONOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = ParseJSON(&j); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	db.Write(&buf, WriteOptions{Uncompressed: true})
+	if buf.String() != in {
+		t.Errorf("Written after JSON as %s, want %s", buf.String(), in)
+	}
+
+	dec, err := NewDecoder(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	dec.Strict = false
+	var person *Person
+	for person == nil {
+		obj, err := dec.Next()
+		if err == io.EOF {
+			t.Fatal("No person decoded")
+		}
+		person, _ = obj.(*Person)
+	}
+	if len(person.Unparsed) != 2 || person.Unparsed[0].following != 2 ||
+		person.Unparsed[1].following != 1 || person.Unparsed[1].prefix != "x" {
+		t.Errorf("Got unparsed elements %+v %+v", person.Unparsed[0], person.Unparsed[1])
+	}
 }
@@ -0,0 +1,226 @@
+package xml
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Reference is a link to an object, found by Referrers.
+type Reference struct {
+	// The object containing the link, or nil for a link from the Database
+	// itself: a Bookmark or the home person of People.
+	From DBObj
+	// The path of the link within From, e.g. person.eventref.citationref, or
+	// bookmark or people.home for links from the Database.
+	Path string
+	// The link, or nil for the home person.
+	Link Link
+}
+
+var linkType = reflect.TypeOf((*Link)(nil)).Elem()
+
+// Call visit with each link with a handle in v, a struct or a pointer or slice
+// of them, and the path of the link. path is the path of v.
+func walkLinks(v reflect.Value, path string, visit func(path string, l Link)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			walkLinks(v.Elem(), path, visit)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkLinks(v.Index(i), path, visit)
+		}
+	case reflect.Struct:
+		if v.CanAddr() && v.Addr().Type().Implements(linkType) {
+			if l := v.Addr().Interface().(Link); l.GetHLink() != "" {
+				visit(path, l)
+			}
+		}
+		for _, f := range fieldsOf(v.Type()) {
+			if !f.attr && !f.charData && !f.any {
+				walkLinks(v.FieldByIndex(f.index), path+"."+f.name, visit)
+			}
+		}
+	}
+}
+
+// Call visit with every link in the Database, in the order they are written.
+func (db *Database) eachReference(visit func(ref Reference)) {
+	if home := db.People.Home; home != "" {
+		visit(Reference{Path: "people.home"})
+	}
+	for _, obj := range db.DBObjs() {
+		obj := obj
+		walkLinks(reflect.ValueOf(obj), ElementName(obj), func(path string, l Link) {
+			visit(Reference{obj, path, l})
+		})
+	}
+	for _, b := range db.Bookmarks {
+		if b.HLink != "" {
+			visit(Reference{Path: "bookmark", Link: b})
+		}
+	}
+}
+
+// Get the handle a Reference links to.
+func (ref Reference) HLink(db *Database) string {
+	if ref.Link == nil {
+		return db.People.Home
+	}
+	return ref.Link.GetHLink()
+}
+
+func (idx *index) addReferences(obj DBObj) {
+	walkLinks(reflect.ValueOf(obj), ElementName(obj), func(path string, l Link) {
+		h := l.GetHLink()
+		idx.referrers[h] = append(idx.referrers[h], Reference{obj, path, l})
+	})
+}
+
+func (idx *index) removeReferences(obj DBObj) {
+	walkLinks(reflect.ValueOf(obj), ElementName(obj), func(path string, l Link) {
+		h := l.GetHLink()
+		var kept []Reference
+		for _, ref := range idx.referrers[h] {
+			if ref.From != obj {
+				kept = append(kept, ref)
+			}
+		}
+		idx.referrers[h] = kept
+	})
+}
+
+// Get all the links to the object with handle h: from other objects, from
+// Bookmarks and from People if h is the home person. Like the handle and ID
+// indexes, the references are found once and then kept up to date by Add and
+// Remove; Reindex must be called after links are changed in other ways.
+func (db *Database) Referrers(h string) []Reference {
+	idx := db.lookup()
+	indexMu.RLock()
+	if idx.referrers != nil {
+		defer indexMu.RUnlock()
+		return append([]Reference(nil), idx.referrers[h]...)
+	}
+	indexMu.RUnlock()
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if idx.referrers == nil {
+		idx.referrers = make(map[string][]Reference)
+		db.eachReference(func(ref Reference) {
+			h := ref.HLink(db)
+			idx.referrers[h] = append(idx.referrers[h], ref)
+		})
+	}
+	return append([]Reference(nil), idx.referrers[h]...)
+}
+
+// Get all the links in the Database, including those to objects that do not
+// exist, in the order they are written.
+func (db *Database) Links() []Reference {
+	var refs []Reference
+	db.eachReference(func(ref Reference) {
+		refs = append(refs, ref)
+	})
+	return refs
+}
+
+// Remove l from v, a struct or a pointer or slice of them. If remove is
+// false l is only looked for.
+func removeLink(v reflect.Value, l Link, remove bool) (bool, error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return removeLink(v.Elem(), l, remove)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if v.Index(i).Interface() == l {
+				if remove {
+					v.Set(reflect.AppendSlice(v.Slice(0, i), v.Slice(i+1, v.Len())))
+				}
+				return true, nil
+			}
+			if found, err := removeLink(v.Index(i), l, remove); found || err != nil {
+				return found, err
+			}
+		}
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			if f.attr || f.charData || f.any {
+				continue
+			}
+			fv := v.FieldByIndex(f.index)
+			switch {
+			case fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.Interface() == l:
+				if remove {
+					fv.Set(reflect.Zero(fv.Type()))
+				}
+				return true, nil
+			case fv.Kind() == reflect.Struct && fv.Addr().Interface() == l:
+				return true, fmt.Errorf("Cannot remove required link %s", f.name)
+			}
+			if found, err := removeLink(fv, l, remove); found || err != nil {
+				return found, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// Remove the link of ref (found by Referrers or Links) from the Database.
+// Links from Bookmarks are removed by removing the Bookmark. Returns an error
+// if the link is not found, or if it cannot be removed because the element is
+// required, e.g. the sourceref of a Citation.
+func (db *Database) Unlink(ref Reference) error {
+	return db.unlink(ref, true)
+}
+
+// Check that the link of ref can be removed by Unlink, without changing the
+// Database, e.g. before removing several links that must all be removed.
+func (db *Database) CanUnlink(ref Reference) error {
+	return db.unlink(ref, false)
+}
+
+func (db *Database) unlink(ref Reference, remove bool) error {
+	h := ref.HLink(db)
+	switch {
+	case ref.From == nil && ref.Link == nil:
+		if remove {
+			db.People.Home = ""
+		}
+	case ref.From == nil:
+		found := false
+		for i, b := range db.Bookmarks {
+			if Link(b) == ref.Link {
+				if remove {
+					db.Bookmarks = append(db.Bookmarks[:i], db.Bookmarks[i+1:]...)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Link not found: %s", ref.Path)
+		}
+	default:
+		found, err := removeLink(reflect.ValueOf(ref.From), ref.Link, remove)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("Link not found: %s", ref.Path)
+		}
+	}
+
+	if idx := db.lookup(); remove && idx.referrers != nil {
+		var kept []Reference
+		for _, r := range idx.referrers[h] {
+			if r.From != ref.From || r.Link != ref.Link {
+				kept = append(kept, r)
+			}
+		}
+		idx.referrers[h] = kept
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package xml
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// Every hlink in the file must be found as a reference to its object.
+func TestReferrersExample(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	data := readGzipped(t, filepath.Join(*testDir, "example-1.5.0.gramps"))
+	counts := make(map[string]int)
+	for _, m := range regexp.MustCompile(`hlink="([^"]*)"`).FindAllSubmatch(data, -1) {
+		counts[string(m[1])]++
+	}
+	counts[db.People.Home]++
+
+	for _, obj := range db.DBObjs() {
+		h := obj.GetHandle()
+		if got := len(db.Referrers(h)); got != counts[h] {
+			t.Errorf("Got %d referrers of %s, want %d", got, h, counts[h])
+		}
+	}
+}
+
+func TestReferrers(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+
+	refs := db.Referrers("_08TJQCCFIX31BXPNXN")
+	found := false
+	for _, ref := range refs {
+		if ref.Path != "event.place" {
+			t.Errorf("Got reference from %s to a place", ref.Path)
+		}
+		if e, ok := ref.From.(*Event); ok && e.ID == "E0000" {
+			found = ref.Link == e.Place
+		}
+	}
+	if !found {
+		t.Error("Did not find the place of E0000")
+	}
+
+	home := db.Referrers(db.People.Home)
+	if len(home) == 0 || home[0].Path != "people.home" || home[0].From != nil {
+		t.Errorf("Did not find the home person, got %v", home)
+	}
+
+	note := &Note{Text: "text"}
+	if err := db.Add(note); err != nil {
+		t.Fatalf("Failed to add note: %s", err)
+	}
+	e := db.EventByID("E0000")
+	e.NoteRefs = append(e.NoteRefs, &GenericLink{HLink: note.Handle})
+	db.Reindex()
+	citation := &Citation{}
+	citation.NoteRefs = []*GenericLink{{HLink: note.Handle}}
+	if err := db.Add(citation); err != nil {
+		t.Fatalf("Failed to add citation: %s", err)
+	}
+	refs = db.Referrers(note.Handle)
+	if len(refs) != 2 || refs[0].From != e || refs[0].Path != "event.noteref" ||
+		refs[1].From != citation || refs[1].Path != "citation.noteref" {
+		t.Errorf("Got references %v to the note", refs)
+	}
+	if _, err := db.Remove(citation.Handle); err != nil {
+		t.Fatalf("Failed to remove citation: %s", err)
+	}
+	if refs = db.Referrers(note.Handle); len(refs) != 1 || refs[0].From != e {
+		t.Errorf("Got references %v to the note after removing the citation", refs)
+	}
+}
+
+func TestUnlink(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	e := db.EventByID("E0000")
+	place := e.Place.HLink
+	n := len(db.Referrers(place))
+	for _, ref := range db.Referrers(place) {
+		if ref.From == e {
+			if err := db.Unlink(ref); err != nil {
+				t.Fatalf("Failed to unlink: %s", err)
+			}
+		}
+	}
+	if e.Place != nil || len(db.Referrers(place)) != n-1 {
+		t.Error("Place of E0000 was not unlinked")
+	}
+
+	c := db.Citations[0]
+	err := db.Unlink(Reference{From: c, Path: "citation.sourceref", Link: &c.SourceRef})
+	if err == nil {
+		t.Error("Unlinked the source of a citation")
+	}
+
+	b := len(db.Bookmarks)
+	for _, ref := range db.Referrers(db.Bookmarks[0].HLink) {
+		if ref.Path != "bookmark" {
+			continue
+		}
+		if err := db.Unlink(ref); err != nil {
+			t.Fatalf("Failed to unlink bookmark: %s", err)
+		}
+	}
+	if len(db.Bookmarks) != b-1 {
+		t.Error("Bookmark was not removed")
+	}
+}
+
+func TestCanUnlink(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+	e := db.EventByID("E0000")
+	n := len(db.Referrers(e.Place.HLink))
+	for _, ref := range db.Referrers(e.Place.HLink) {
+		if err := db.CanUnlink(ref); err != nil {
+			t.Errorf("Cannot unlink %s: %s", ref.Path, err)
+		}
+	}
+	if e.Place == nil || len(db.Referrers(e.Place.HLink)) != n {
+		t.Error("CanUnlink removed links")
+	}
+
+	c := db.Citations[0]
+	if err := db.CanUnlink(Reference{From: c, Path: "citation.sourceref", Link: &c.SourceRef}); err == nil {
+		t.Error("Can unlink the source of a citation")
+	}
+}
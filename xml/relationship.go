@@ -0,0 +1,379 @@
+package xml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// How many generations of ancestors Relationships looks at.
+const relationshipGenerations = 40
+
+// A step from a child up to a parent in a family.
+type parentStep struct {
+	family        *Family
+	child, parent *Person
+	// Whether the child was born to the parent, rather than adopted,
+	// fostered or a stepchild.
+	birth bool
+}
+
+// A path from a person up to an ancestor, one parentStep per generation.
+type ancestorPath []parentStep
+
+// Get the key of the families and the parents of the path, leaving out the
+// last parent, which is where the paths to the two parents of a family meet.
+func (path ancestorPath) key() string {
+	var parts []string
+	for i, step := range path {
+		parts = append(parts, step.family.Handle)
+		if i < len(path)-1 {
+			parts = append(parts, step.parent.Handle)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Get the child on the path just below its ancestor, or nil for an empty
+// path.
+func (path ancestorPath) below() *Person {
+	if len(path) == 0 {
+		return nil
+	}
+	return path[len(path)-1].child
+}
+
+func (path ancestorPath) families() []*Family {
+	families := make([]*Family, len(path))
+	for i, step := range path {
+		families[i] = step.family
+	}
+	return families
+}
+
+func (path ancestorPath) birth() bool {
+	for _, step := range path {
+		if !step.birth {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether child was born to the parent father or mother of f, by the mrel and
+// frel of its childref.
+func isBirthChild(f *Family, child *Person, father bool) bool {
+	for _, ref := range f.ChildRefs {
+		if ref.HLink == child.Handle {
+			rel := ref.MRel
+			if father {
+				rel = ref.FRel
+			}
+			return rel == "" || rel == "Birth"
+		}
+	}
+	return true
+}
+
+// Get p and its ancestors, in the order they are found, with the paths from p
+// up to each of them.
+func (db *Database) ancestorPaths(p *Person) ([]*Person, map[*Person][]ancestorPath) {
+	ancestors := []*Person{p}
+	paths := map[*Person][]ancestorPath{p: {nil}}
+	onPath := map[*Person]bool{p: true}
+	var walk func(child *Person, path ancestorPath)
+	walk = func(child *Person, path ancestorPath) {
+		if len(path) >= relationshipGenerations {
+			return
+		}
+		for _, f := range child.families(db, child.ChildOfs) {
+			for i, l := range []*GenericLink{f.Father, f.Mother} {
+				if l == nil {
+					continue
+				}
+				parent := db.PersonByHandle(l.HLink)
+				if parent == nil || onPath[parent] {
+					continue
+				}
+				step := parentStep{f, child, parent, isBirthChild(f, child, i == 0)}
+				up := append(append(ancestorPath{}, path...), step)
+				if paths[parent] == nil {
+					ancestors = append(ancestors, parent)
+				}
+				paths[parent] = append(paths[parent], up)
+				onPath[parent] = true
+				walk(parent, up)
+				onPath[parent] = false
+			}
+		}
+	}
+	walk(p, nil)
+	return ancestors, paths
+}
+
+// A Relationship is a way in which a person B is related to a person A: by
+// blood through common ancestors, as a spouse, as a stepparent or stepchild,
+// or as an in-law.
+type Relationship struct {
+	// The nearest common ancestors of A and B: a person, or the father and
+	// mother of a family. Empty for spouses, stepparents and stepchildren.
+	Ancestors []*Person
+	// The families from A and from B up to the common ancestors, one per
+	// generation. The last family of each path has the ancestors as parents.
+	PathA, PathB []*Family
+	// The number of generations from A and from B up to the common
+	// ancestors. B is a child of A when Ga is 0 and Gb is 1.
+	Ga, Gb int
+	// Whether A and B descend from only one parent of a family, such as half
+	// siblings.
+	Half bool
+	// Whether any child on the paths was adopted, fostered or a stepchild,
+	// or B is a child of a spouse of A, or a spouse of a parent of A, without
+	// being related by blood. For the latter Ga and Gb are 0 and 1 or 1 and
+	// 0, and the path is the family of the child and the parent.
+	Step bool
+	// The family of A or B and a spouse, for spouses and in-laws. B is an
+	// in-law of A when this is set and there are common ancestors.
+	Marriage *Family
+
+	// The gender of B and the type of the marriage, for String.
+	gender, marriage string
+}
+
+// Get the ways in which b is related to a, closest first: b as a spouse of
+// a, b and a by blood, with the family paths to each pair of nearest common
+// ancestors, or else b as a stepparent, stepchild or in-law of a. Different
+// paths give different relationships, e.g. for cousins who are also second
+// cousins.
+func (db *Database) Relationships(a, b *Person) []*Relationship {
+	if a == nil || b == nil || a == b {
+		return nil
+	}
+	var rels []*Relationship
+	for _, f := range a.families(db, a.ParentIns) {
+		if db.spouse(f, a) == b {
+			rels = append(rels, &Relationship{Marriage: f, gender: b.Gender, marriage: marriageType(f)})
+		}
+	}
+	blood := db.bloodRelationships(a, b)
+	if len(blood) == 0 {
+		// b is married to a relative of a, or to a parent of a as a
+		// stepparent.
+		for _, f := range b.families(db, b.ParentIns) {
+			if s := db.spouse(f, b); s != nil && s != a {
+				for _, r := range db.bloodRelationships(a, s) {
+					if r.Ga == 1 && r.Gb == 0 {
+						r = &Relationship{PathA: r.PathA, Ga: 1, Step: true}
+					} else {
+						r.Marriage = f
+					}
+					r.gender = b.Gender
+					blood = append(blood, r)
+				}
+			}
+		}
+		// b is a relative of the spouse of a, or a child of the spouse as
+		// a stepchild.
+		for _, f := range a.families(db, a.ParentIns) {
+			if s := db.spouse(f, a); s != nil && s != b {
+				for _, r := range db.bloodRelationships(s, b) {
+					if r.Ga == 0 && r.Gb == 1 {
+						r = &Relationship{PathB: r.PathB, Gb: 1, Step: true, gender: b.Gender}
+					} else {
+						r.Marriage = f
+					}
+					blood = append(blood, r)
+				}
+			}
+		}
+	}
+	sort.Stable(byDistance(blood))
+	return append(rels, blood...)
+}
+
+// Get the closest relationship of b to a, e.g. "second cousin once removed",
+// or "" if they are not related.
+func (db *Database) Relationship(a, b *Person) string {
+	if rels := db.Relationships(a, b); len(rels) > 0 {
+		return rels[0].String()
+	}
+	return ""
+}
+
+// Get the relationships of b to a by blood.
+func (db *Database) bloodRelationships(a, b *Person) []*Relationship {
+	ancestorsA, pathsA := db.ancestorPaths(a)
+	_, pathsB := db.ancestorPaths(b)
+	var rels []*Relationship
+	byKey := map[string]*Relationship{}
+	for _, ancestor := range ancestorsA {
+		for _, pathA := range pathsA[ancestor] {
+			for _, pathB := range pathsB[ancestor] {
+				// A common ancestor is nearest when the paths meet only there.
+				if pathA.below() != nil && pathA.below() == pathB.below() {
+					continue
+				}
+				key := pathA.key() + "|" + pathB.key()
+				r := byKey[key]
+				if r == nil {
+					r = &Relationship{
+						PathA:  pathA.families(),
+						PathB:  pathB.families(),
+						Ga:     len(pathA),
+						Gb:     len(pathB),
+						Half:   len(pathA) > 0 && len(pathB) > 0 && pathA[len(pathA)-1].family != pathB[len(pathB)-1].family,
+						Step:   !pathA.birth() || !pathB.birth(),
+						gender: b.Gender,
+					}
+					byKey[key] = r
+					rels = append(rels, r)
+				}
+				r.Ancestors = append(r.Ancestors, ancestor)
+			}
+		}
+	}
+	return rels
+}
+
+// Get the other parent of f than p.
+func (db *Database) spouse(f *Family, p *Person) *Person {
+	for _, l := range []*GenericLink{f.Father, f.Mother} {
+		if l != nil && l.HLink != p.Handle {
+			return db.PersonByHandle(l.HLink)
+		}
+	}
+	return nil
+}
+
+func marriageType(f *Family) string {
+	if f.Rel == nil {
+		return ""
+	}
+	return f.Rel.Type
+}
+
+// Relationships by the number of generations between the people, blood
+// relatives before in-laws.
+type byDistance []*Relationship
+
+func (rels byDistance) Len() int      { return len(rels) }
+func (rels byDistance) Swap(i, j int) { rels[i], rels[j] = rels[j], rels[i] }
+func (rels byDistance) Less(i, j int) bool {
+	a, b := rels[i], rels[j]
+	if (a.Marriage == nil) != (b.Marriage == nil) {
+		return a.Marriage == nil
+	}
+	return a.Ga+a.Gb < b.Ga+b.Gb
+}
+
+// Choose the word for a man, a woman or either by gender.
+func gendered(gender, male, female, unknown string) string {
+	switch gender {
+	case "M":
+		return male
+	case "F":
+		return female
+	}
+	return unknown
+}
+
+var ordinals = []string{"", "first", "second", "third", "fourth", "fifth", "sixth",
+	"seventh", "eighth", "ninth", "tenth"}
+
+// Get the ordinal of n in figures, e.g. "3rd" or "12th".
+func numeral(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// Get the ordinal of n in words, e.g. "second", or in figures from 11th on.
+func ordinal(n int) string {
+	if n < len(ordinals) {
+		return ordinals[n]
+	}
+	return numeral(n)
+}
+
+// Put n greats before name: "great-grandson", "great-great-grandson", then
+// "3rd great-grandson".
+func greats(n int, name string) string {
+	switch {
+	case n <= 0:
+		return name
+	case n <= 2:
+		return strings.Repeat("great-", n) + name
+	}
+	return numeral(n) + " great-" + name
+}
+
+// Describe the relationship in English, like Gramps: "half-brother",
+// "great-grandaunt", "step-son", "second cousin once removed" or
+// "sister-in-law".
+func (r *Relationship) String() string {
+	g := r.gender
+	var name string
+	switch {
+	case r.Marriage != nil && len(r.Ancestors) == 0:
+		if r.marriage == "Married" {
+			return gendered(g, "husband", "wife", "spouse")
+		}
+		return "partner"
+	case r.Ga == 0:
+		name = gendered(g, "son", "daughter", "child")
+		if r.Gb > 1 {
+			name = greats(r.Gb-2, "grand"+name)
+		}
+	case r.Gb == 0:
+		name = gendered(g, "father", "mother", "parent")
+		if r.Ga > 1 {
+			name = greats(r.Ga-2, "grand"+name)
+		}
+	case r.Ga == 1 && r.Gb == 1:
+		name = gendered(g, "brother", "sister", "sibling")
+	case r.Ga == 1:
+		name = gendered(g, "nephew", "niece", "nephew or niece")
+		if r.Gb > 2 {
+			name = greats(r.Gb-3, "grand"+name)
+		}
+	case r.Gb == 1:
+		name = gendered(g, "uncle", "aunt", "uncle or aunt")
+		if r.Ga > 2 {
+			name = greats(r.Ga-3, "grand"+name)
+		}
+	default:
+		gen, removed := r.Ga, r.Gb-r.Ga
+		if r.Gb < r.Ga {
+			gen, removed = r.Gb, r.Ga-r.Gb
+		}
+		name = ordinal(gen-1) + " cousin"
+		switch removed {
+		case 0:
+		case 1:
+			name += " once removed"
+		case 2:
+			name += " twice removed"
+		default:
+			name += fmt.Sprintf(" %d times removed", removed)
+		}
+	}
+	switch {
+	case r.Step:
+		name = "step-" + name
+	case r.Half:
+		name = "half-" + name
+	}
+	if r.Marriage != nil {
+		name += "-in-law"
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package xml
+
+import (
+	"testing"
+)
+
+func TestRelationships(t *testing.T) {
+	db := &Database{}
+	person := func(gender string) *Person {
+		p := addPerson(t, db)
+		p.Gender = gender
+		return p
+	}
+	ggf, ggm, gf, gm, ga, gah := person("M"), person("F"), person("M"), person("F"), person("F"), person("M")
+	father, mother, mother2, uncle, uncleWife := person("M"), person("F"), person("F"), person("M"), person("F")
+	x, sister, halfBrother, adopted := person("M"), person("F"), person("M"), person("F")
+	cousin, gaChild, cousinChild := person("F"), person("M"), person("M")
+	stranger := person("U")
+	mother2Husband, mother2Son := person("M"), person("M")
+
+	addFamily(t, db, ggf, ggm, gf, ga)
+	addFamily(t, db, gf, gm, father, uncle)
+	addFamily(t, db, ga, gah, gaChild)
+	addFamily(t, db, father, mother, x, sister, adopted)
+	addFamily(t, db, father, mother2, halfBrother)
+	addFamily(t, db, uncle, uncleWife, cousin)
+	addFamily(t, db, gaChild, cousin, cousinChild)
+	addFamily(t, db, mother2Husband, mother2, mother2Son)
+
+	parents := db.FamilyByHandle(x.ChildOfs[0].HLink)
+	parents.Rel = &Rel{Type: "Married"}
+	for _, ref := range parents.ChildRefs {
+		if ref.HLink == adopted.Handle {
+			ref.FRel, ref.MRel = "Adopted", "Adopted"
+		}
+	}
+
+	for _, test := range []struct {
+		a, b *Person
+		want string
+	}{
+		{x, sister, "sister"},
+		{x, halfBrother, "half-brother"},
+		{x, adopted, "step-sister"},
+		{father, adopted, "step-daughter"},
+		{x, father, "father"},
+		{x, ggf, "great-grandfather"},
+		{ggf, x, "great-grandson"},
+		{ggf, cousinChild, "great-grandson"},
+		{x, uncle, "uncle"},
+		{uncle, x, "nephew"},
+		{x, ga, "grandaunt"},
+		{cousinChild, ga, "grandmother"},
+		{x, cousin, "first cousin"},
+		{x, gaChild, "first cousin once removed"},
+		{gaChild, x, "first cousin once removed"},
+		{x, cousinChild, "first cousin once removed"},
+		{father, mother, "wife"},
+		{father, mother2, "partner"},
+		{gf, mother, "daughter-in-law"},
+		{mother, uncle, "brother-in-law"},
+		{x, uncleWife, "aunt-in-law"},
+		{father, mother2Son, "step-son"},
+		{x, mother2, "step-mother"},
+		{mother2Son, father, "step-father"},
+		{mother2Husband, father, ""},
+		{x, stranger, ""},
+		{x, x, ""},
+	} {
+		if got := db.Relationship(test.a, test.b); got != test.want {
+			t.Errorf("%s to %s: got %q, want %q", test.b.ID, test.a.ID, got, test.want)
+		}
+	}
+
+	// Full siblings share both parents through one family.
+	rels := db.Relationships(x, sister)
+	if len(rels) != 1 || len(rels[0].Ancestors) != 2 || len(rels[0].PathA) != 1 ||
+		rels[0].PathA[0] != parents || rels[0].PathB[0] != parents {
+		t.Errorf("Got sibling relationships %+v", rels)
+	}
+
+	// The child of cousins is related through both of them.
+	rels = db.Relationships(x, cousinChild)
+	if len(rels) != 2 {
+		t.Fatalf("Got %d relationships", len(rels))
+	}
+	for i, want := range []string{"first cousin once removed", "second cousin"} {
+		if s := rels[i].String(); s != want {
+			t.Errorf("Relationship %d: got %q, want %q", i, s, want)
+		}
+	}
+	if len(rels[1].Ancestors) != 2 || rels[1].Ancestors[0] != ggf ||
+		len(rels[1].PathA) != 3 || len(rels[1].PathB) != 3 {
+		t.Errorf("Got ancestors %v, paths of %d and %d families",
+			rels[1].Ancestors, len(rels[1].PathA), len(rels[1].PathB))
+	}
+}
+
+func TestRelationshipString(t *testing.T) {
+	for _, test := range []struct {
+		r    Relationship
+		want string
+	}{
+		{Relationship{Ga: 4, Gb: 1, gender: "F"}, "great-grandaunt"},
+		{Relationship{Ga: 1, Gb: 5, gender: "M"}, "great-great-grandnephew"},
+		{Relationship{Ga: 0, Gb: 6, gender: "U"}, "4th great-grandchild"},
+		{Relationship{Ga: 3, Gb: 5}, "second cousin twice removed"},
+		{Relationship{Ga: 12, Gb: 16}, "11th cousin 4 times removed"},
+		{Relationship{Ga: 1, Gb: 1, gender: "F", Half: true}, "half-sister"},
+	} {
+		if got := test.r.String(); got != test.want {
+			t.Errorf("%d, %d: got %q, want %q", test.r.Ga, test.r.Gb, got, test.want)
+		}
+	}
+}
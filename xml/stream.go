@@ -0,0 +1,198 @@
+package xml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// The elements that group the repeated top-level objects of a Database, with
+// the name of the repeated element and a constructor for its type.
+var collections = map[string]struct {
+	elem string
+	new  func() interface{}
+}{
+	"name-formats": {"format", func() interface{} { return new(NameFormat) }},
+	"tags":         {"tag", func() interface{} { return new(Tag) }},
+	"events":       {"event", func() interface{} { return new(Event) }},
+	"people":       {"person", func() interface{} { return new(Person) }},
+	"families":     {"family", func() interface{} { return new(Family) }},
+	"citations":    {"citation", func() interface{} { return new(Citation) }},
+	"sources":      {"source", func() interface{} { return new(Source) }},
+	"places":       {"placeobj", func() interface{} { return new(PlaceObj) }},
+	"objects":      {"object", func() interface{} { return new(Object) }},
+	"repositories": {"repository", func() interface{} { return new(Repository) }},
+	"notes":        {"note", func() interface{} { return new(Note) }},
+	"bookmarks":    {"bookmark", func() interface{} { return new(Bookmark) }},
+	"namemaps":     {"map", func() interface{} { return new(NameMap) }},
+}
+
+// A Decoder reads the top-level objects of a .gramps XML file one at a time,
+// so that files too large to hold in memory as a Database can be processed.
+type Decoder struct {
+	// The version of the file, from the namespace of its root element.
+	Version string
+	// The compression of the file.
+	Compression Compression
+	// Fail on any portion of the XML that is not parsed. NewDecoder sets
+	// this; see Next for what happens when it is cleared.
+	Strict bool
+
+	unzipped   io.ReadCloser
+	d          *xml.Decoder
+	rec        *recorder
+	collection string
+}
+
+// Create a Decoder reading from r. The root element is read immediately so
+// that the Version is known.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	unzipped, compression, err := Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	br, ok := unzipped.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(unzipped)
+	}
+	dec := &Decoder{Compression: compression, Strict: true, unzipped: unzipped,
+		rec: &recorder{r: br}}
+	dec.d = xml.NewDecoder(dec.rec)
+	for {
+		tok, err := dec.d.Token()
+		if err != nil {
+			dec.Close()
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			v, ok := VersionOf(start.Name.Space)
+			if start.Name.Local != "database" || !ok {
+				dec.Close()
+				return nil, fmt.Errorf("Unsupported root element: %s %s",
+					start.Name.Space, start.Name.Local)
+			}
+			dec.Version = v
+			return dec, nil
+		}
+	}
+}
+
+// Close releases the resources of the Decoder. It does not close the
+// underlying reader.
+func (dec *Decoder) Close() error {
+	return dec.unzipped.Close()
+}
+
+// Report the unknown element start, skipping it unless decoding strictly.
+func (dec *Decoder) skip(path string, start xml.StartElement) error {
+	line, _ := dec.d.InputPos()
+	err := &UnparsedError{[]UnparsedField{
+		{Path: path + "." + start.Name.Local, Line: line}}}
+	if dec.Strict {
+		return err
+	}
+	if skipErr := dec.d.Skip(); skipErr != nil {
+		return skipErr
+	}
+	return err
+}
+
+// Decode and return the next top-level object: a *Header, or one of the
+// repeated elements of a Database (*NameFormat, *Tag, *Event, *Person,
+// *Family, *Citation, *Source, *PlaceObj, *Object, *Repository, *Note,
+// *Bookmark or *NameMap). The attributes of the people element are returned
+// as a *People without Persons before the first Person. Returns io.EOF after
+// the last object. As with Parse, an *UnparsedError is returned for any portion
+// of the XML that is not parsed. If the Decoder is not Strict, the object is
+// returned along with that error and its unparsed elements are kept; unknown
+// elements that are not within an object are skipped, and returned as an
+// *UnparsedError with a nil object.
+func (dec *Decoder) Next() (interface{}, error) {
+	for {
+		tok, err := dec.d.Token()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var v interface{}
+			path := "database"
+			if dec.collection != "" {
+				c := collections[dec.collection]
+				path += "." + dec.collection
+				if tok.Name.Local != c.elem {
+					return nil, dec.skip(path, tok)
+				}
+				v = c.new()
+			} else if tok.Name.Local == "header" {
+				v = new(Header)
+			} else if _, ok := collections[tok.Name.Local]; ok {
+				dec.collection = tok.Name.Local
+				if tok.Name.Local != "people" {
+					continue
+				}
+				people := &People{XMLName: xml.Name{Local: tok.Name.Local}}
+				for _, a := range tok.Attr {
+					if a.Name.Local == "home" {
+						people.Home = a.Value
+					}
+				}
+				return people, nil
+			} else {
+				return nil, dec.skip(path, tok)
+			}
+
+			// Only the object is kept to read its unparsed elements again.
+			dec.rec.buf = dec.rec.buf[:0]
+			base := dec.d.InputOffset()
+			if err := dec.d.DecodeElement(v, &tok); err != nil {
+				return nil, err
+			}
+			if err := placeUnparsed(reflect.ValueOf(v), dec.rec.buf, base); err != nil {
+				return nil, err
+			}
+			clearNamespaces(reflect.ValueOf(v))
+			if err := unparsedError(v, path); err != nil {
+				if dec.Strict {
+					return nil, err
+				}
+				return v, err
+			}
+			return v, nil
+		case xml.EndElement:
+			if dec.collection == "" {
+				return nil, io.EOF
+			}
+			dec.collection = ""
+		}
+	}
+}
+
+// Stream a .gramps XML file, calling visit with each top-level object as it is
+// decoded (see Decoder.Next for the types). Decoding stops at the first error
+// returned by visit, which is returned by Stream.
+func Stream(r io.Reader, visit func(obj interface{}) error) error {
+	dec, err := NewDecoder(r)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+	for {
+		obj, err := dec.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := visit(obj); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package xml
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamExample(t *testing.T) {
+	db := parseExample(t, "example-1.5.0.gramps")
+
+	f, err := os.Open(filepath.Join(*testDir, "example-1.5.0.gramps"))
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer f.Close()
+	var header *Header
+	var people *People
+	var persons, families, notes int
+	err = Stream(f, func(obj interface{}) error {
+		switch obj := obj.(type) {
+		case *Header:
+			header = obj
+		case *People:
+			people = obj
+		case *Person:
+			persons++
+		case *Family:
+			families++
+		case *Note:
+			notes++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream example: %s", err)
+	}
+	if header == nil || header.Created.Version != db.Header.Created.Version {
+		t.Errorf("Header not streamed: %+v", header)
+	}
+	if people == nil || people.Home != db.People.Home {
+		t.Errorf("People not streamed: %+v", people)
+	}
+	if persons != len(db.People.Persons) || families != len(db.Families) ||
+		notes != len(db.Notes) {
+		t.Errorf("Expected %d/%d/%d persons/families/notes, got %d/%d/%d",
+			len(db.People.Persons), len(db.Families), len(db.Notes),
+			persons, families, notes)
+	}
+}
+
+func TestStreamStops(t *testing.T) {
+	f, err := os.Open(filepath.Join(*testDir, "example-1.5.0.gramps"))
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	defer f.Close()
+	stop := errors.New("stop")
+	n := 0
+	err = Stream(f, func(obj interface{}) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("Expected to stop after 1 object, got %d: %v", n, err)
+	}
+}
+
+func TestStreamUnparsed(t *testing.T) {
+	cases := []string{
+		`<people><person handle="_a" change="1"><gender>M</gender><foo/></person></people>`,
+		`<people><foo/></people>`,
+		`<foos/>`,
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		zipped := gzip.NewWriter(&buf)
+		zipped.Write([]byte(`<database xmlns="` + XMLNamespace + `">` + c + `</database>`))
+		zipped.Close()
+		err := Stream(&buf, func(obj interface{}) error { return nil })
+		if err == nil {
+			t.Errorf("Expected an error streaming %s", c)
+		}
+	}
+}
+
+func TestDecoderLenient(t *testing.T) {
+	dec, err := NewDecoder(gzipped(addonXML))
+	if err != nil {
+		t.Fatalf("Failed to create decoder: %s", err)
+	}
+	defer dec.Close()
+	dec.Strict = false
+
+	var persons, unknown int
+	for {
+		obj, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*UnparsedError); err != nil && !ok {
+			t.Fatalf("Failed to decode: %s", err)
+		}
+		switch obj.(type) {
+		case *Person:
+			persons++
+			if err == nil || len(obj.(*Person).Unparsed) != 1 {
+				t.Errorf("Expected the unparsed element to be kept and reported")
+			}
+		case nil:
+			unknown++
+		}
+	}
+	if persons != 1 || unknown != 1 {
+		t.Errorf("Expected 1 person and 1 unknown element, got %d and %d",
+			persons, unknown)
+	}
+}
@@ -0,0 +1,144 @@
+package xml
+
+import (
+	"sort"
+
+	"code.google.com/p/gogramps/phonetic"
+)
+
+// The type of the name maps that group surnames.
+const groupAs = "group_as"
+
+// Get the group of surname: the value of its group_as name map, or else the
+// surname itself.
+func (db *Database) GroupName(surname string) string {
+	for _, m := range db.NameMaps {
+		if m.Type == groupAs && m.Key == surname {
+			return m.Value
+		}
+	}
+	return surname
+}
+
+// Group surname with group, or with itself if group is "", by its group_as
+// name map.
+func (db *Database) SetGroupName(surname, group string) {
+	for i, m := range db.NameMaps {
+		if m.Type == groupAs && m.Key == surname {
+			if group == "" || group == surname {
+				db.NameMaps = append(db.NameMaps[:i], db.NameMaps[i+1:]...)
+			} else {
+				m.Value = group
+			}
+			return
+		}
+	}
+	if group != "" && group != surname {
+		db.NameMaps = append(db.NameMaps, &NameMap{Type: groupAs, Key: surname, Value: group})
+	}
+}
+
+// Get the surname group of n: its own group if it has one, or else the group
+// of its primary surname.
+func (db *Database) NameGroup(n *Name) string {
+	if n.Group != nil && *n.Group != "" {
+		return *n.Group
+	}
+	if s := n.PrimarySurname(); s != nil {
+		return db.GroupName(s.Value)
+	}
+	return db.GroupName("")
+}
+
+// A SurnameGroup is the people whose preferred names are in a surname group.
+type SurnameGroup struct {
+	Name   string
+	People []*Person
+}
+
+// Get the surname groups of the people of db, sorted by name. People without
+// a surname are in the group "".
+func (db *Database) SurnameGroups() []*SurnameGroup {
+	byName := map[string]*SurnameGroup{}
+	var names []string
+	for _, p := range db.People.Persons {
+		n := p.GetPreferredName()
+		if n == nil {
+			continue
+		}
+		name := db.NameGroup(n)
+		g := byName[name]
+		if g == nil {
+			g = &SurnameGroup{Name: name}
+			byName[name] = g
+			names = append(names, name)
+		}
+		g.People = append(g.People, p)
+	}
+	sort.Strings(names)
+	groups := make([]*SurnameGroup, len(names))
+	for i, name := range names {
+		groups[i] = byName[name]
+	}
+	return groups
+}
+
+// Get the Soundex key of the surname, as Gramps computes it.
+func (s *Surname) Soundex() string { return phonetic.Soundex(s.Value) }
+
+// Get the Daitch-Mokotoff Soundex keys of the surname.
+func (s *Surname) DaitchMokotoff() []string { return phonetic.DaitchMokotoff(s.Value) }
+
+// Get the primary and alternate Double Metaphone keys of the surname.
+func (s *Surname) DoubleMetaphone() (primary, alternate string) {
+	return phonetic.DoubleMetaphone(s.Value)
+}
+
+// A PhoneticKey gets the keys of a surname. Surnames sound alike when they
+// have a key in common.
+type PhoneticKey func(surname string) []string
+
+// Phonetic keys for SoundsLike.
+var (
+	SoundexKey PhoneticKey = func(s string) []string {
+		return []string{phonetic.Soundex(s)}
+	}
+	DaitchMokotoffKey  PhoneticKey = phonetic.DaitchMokotoff
+	DoubleMetaphoneKey PhoneticKey = func(s string) []string {
+		primary, alternate := phonetic.DoubleMetaphone(s)
+		if alternate == primary {
+			return []string{primary}
+		}
+		return []string{primary, alternate}
+	}
+)
+
+// Get the people with a surname in any of their names that sounds like
+// surname by key.
+func (db *Database) SoundsLike(surname string, key PhoneticKey) []*Person {
+	keys := map[string]bool{}
+	for _, k := range key(surname) {
+		keys[k] = true
+	}
+	var people []*Person
+	for _, p := range db.People.Persons {
+		if p.soundsLike(keys, key) {
+			people = append(people, p)
+		}
+	}
+	return people
+}
+
+// Whether a surname of p has one of keys.
+func (p *Person) soundsLike(keys map[string]bool, key PhoneticKey) bool {
+	for _, n := range p.Names {
+		for _, s := range n.Surnames {
+			for _, k := range key(s.Value) {
+				if keys[k] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Add a person with a preferred name with surnames to db.
+func addNamed(t *testing.T, db *Database, surnames ...string) *Person {
+	p := addPerson(t, db)
+	n := &Name{}
+	for _, s := range surnames {
+		n.Surnames = append(n.Surnames, &Surname{Value: s})
+	}
+	p.Names = append(p.Names, n)
+	return p
+}
+
+func TestSurnameGroups(t *testing.T) {
+	db := &Database{}
+	fernandez := addNamed(t, db, "Fernandez")
+	fernandezAccent := addNamed(t, db, "Fernández")
+	garcia := addNamed(t, db, "García", "Lopez")
+	nobody := addNamed(t, db)
+
+	db.SetGroupName("Fernández", "Fernandez")
+	if g := db.GroupName("Fernández"); g != "Fernandez" {
+		t.Errorf("Got group %q", g)
+	}
+	if g := db.GroupName("García"); g != "García" {
+		t.Errorf("Got group %q", g)
+	}
+
+	groups := db.SurnameGroups()
+	if len(groups) != 3 {
+		t.Fatalf("Got %d groups", len(groups))
+	}
+	for i, want := range []struct {
+		name   string
+		people []*Person
+	}{
+		{"", []*Person{nobody}},
+		{"Fernandez", []*Person{fernandez, fernandezAccent}},
+		{"García", []*Person{garcia}},
+	} {
+		g := groups[i]
+		if g.Name != want.name || len(g.People) != len(want.people) {
+			t.Errorf("Group %d: got %q with %d people", i, g.Name, len(g.People))
+			continue
+		}
+		for j, p := range want.people {
+			if g.People[j] != p {
+				t.Errorf("Group %q: person %d differs", g.Name, j)
+			}
+		}
+	}
+
+	// A name's own group overrides the map.
+	garcia.Names[0].Group = strp("Garcia")
+	if g := db.NameGroup(garcia.Names[0]); g != "Garcia" {
+		t.Errorf("Got group %q", g)
+	}
+
+	db.SetGroupName("Fernández", "")
+	if len(db.NameMaps) != 0 {
+		t.Errorf("Got %d name maps after ungrouping", len(db.NameMaps))
+	}
+}
+
+func TestNameGroupRoundTrip(t *testing.T) {
+	db := parseExample(t, "example-1.7.1.gramps")
+	p := db.People.Persons[0]
+	p.GetPreferredName().Group = strp("Grouped")
+	db.SetGroupName("Garner", "Gardner")
+	var buf bytes.Buffer
+	if err := db.Write(&buf, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<group>Grouped</group>") ||
+		!strings.Contains(buf.String(), `<map type="group_as" key="Garner" value="Gardner"/>`) {
+		t.Errorf("Group not written")
+	}
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := parsed.NameGroup(parsed.People.Persons[0].GetPreferredName()); g != "Grouped" {
+		t.Errorf("Got group %q", g)
+	}
+	if g := parsed.GroupName("Garner"); g != "Gardner" {
+		t.Errorf("Got group %q", g)
+	}
+}
+
+func TestSoundsLike(t *testing.T) {
+	db := &Database{}
+	smith := addNamed(t, db, "Smith")
+	schmidt := addNamed(t, db, "Schmidt")
+	smyth := addNamed(t, db, "Smyth")
+	addNamed(t, db, "Jones")
+	alt := addNamed(t, db, "Brown")
+	alt.Names = append(alt.Names, &Name{Alt: 1, Surnames: []*Surname{{Value: "Schmitt"}}})
+
+	for _, test := range []struct {
+		key  PhoneticKey
+		want []*Person
+	}{
+		{SoundexKey, []*Person{smith, schmidt, smyth, alt}},
+		{DoubleMetaphoneKey, []*Person{smith, schmidt, smyth, alt}},
+		{DaitchMokotoffKey, []*Person{smith, schmidt, smyth, alt}},
+	} {
+		got := db.SoundsLike("Schmidt", test.key)
+		if len(got) != len(test.want) {
+			t.Errorf("Got %d people, want %d", len(got), len(test.want))
+			continue
+		}
+		for i, p := range test.want {
+			if got[i] != p {
+				t.Errorf("Person %d differs", i)
+			}
+		}
+	}
+
+	s := &Surname{Value: "Smith"}
+	if k := s.Soundex(); k != "S530" {
+		t.Errorf("Got Soundex %s", k)
+	}
+	if primary, alternate := s.DoubleMetaphone(); primary != "SM0" || alternate != "XMT" {
+		t.Errorf("Got Double Metaphone %s %s", primary, alternate)
+	}
+	if k := s.DaitchMokotoff(); len(k) != 1 || k[0] != "463000" {
+		t.Errorf("Got Daitch-Mokotoff %v", k)
+	}
+}
@@ -0,0 +1,214 @@
+package xml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The most generations of ancestors, whose Ahnentafel numbers fit in a
+// uint64.
+const maxAncestorGenerations = 64
+
+// An Ancestor is a person found by WalkAncestors.
+type Ancestor struct {
+	Person *Person
+	// The Ahnentafel or Sosa-Stradonitz number: 1 for the person the walk
+	// starts from, then 2n for the father and 2n+1 for the mother of n.
+	Number uint64
+	// 1 for the person the walk starts from, 2 for parents and so on.
+	Generation int
+	// The family in which this is a parent of the ancestor below, or nil for
+	// the person the walk starts from.
+	Family *Family
+	// Whether the person was found before under a lower number, by pedigree
+	// collapse or a loop. Its ancestors are only walked the first time.
+	Duplicate bool
+}
+
+// Get the family of the parents of p: the first family p was born into, or
+// else its first family.
+func (p *Person) mainParents(db *Database) *Family {
+	families := p.families(db, p.ChildOfs)
+	for _, f := range families {
+		if isBirthChild(f, p, true) && isBirthChild(f, p, false) {
+			return f
+		}
+	}
+	if len(families) > 0 {
+		return families[0]
+	}
+	return nil
+}
+
+// Call visit with p and its ancestors in the order of their Ahnentafel
+// numbers, generation by generation, up to generations generations with p as
+// the first, or all of them if generations is 0. Parents are those of the
+// family p was born into. Stops when visit returns false.
+func (p *Person) WalkAncestors(db *Database, generations int, visit func(a *Ancestor) bool) {
+	if generations <= 0 || generations > maxAncestorGenerations {
+		generations = maxAncestorGenerations
+	}
+	seen := map[*Person]bool{}
+	queue := []*Ancestor{{Person: p, Number: 1, Generation: 1}}
+	for len(queue) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		a.Duplicate = seen[a.Person]
+		seen[a.Person] = true
+		if !visit(a) {
+			return
+		}
+		if a.Duplicate || a.Generation >= generations {
+			continue
+		}
+		f := a.Person.mainParents(db)
+		if f == nil {
+			continue
+		}
+		for i, l := range []*GenericLink{f.Father, f.Mother} {
+			if l == nil {
+				continue
+			}
+			if parent := db.PersonByHandle(l.HLink); parent != nil {
+				queue = append(queue, &Ancestor{Person: parent, Number: 2*a.Number + uint64(i),
+					Generation: a.Generation + 1, Family: f})
+			}
+		}
+	}
+}
+
+// Get p and its ancestors up to generations generations, as WalkAncestors
+// finds them.
+func (p *Person) Ancestors(db *Database, generations int) []*Ancestor {
+	var ancestors []*Ancestor
+	p.WalkAncestors(db, generations, func(a *Ancestor) bool {
+		ancestors = append(ancestors, a)
+		return true
+	})
+	return ancestors
+}
+
+// A Descendant is a person found by WalkDescendants, with the numbers of the
+// schemes of descendant reports.
+type Descendant struct {
+	Person *Person
+	// 1 for the person the walk starts from, 2 for children and so on.
+	Generation int
+	// The descendant this is a child of and the family of them, or nil for
+	// the person the walk starts from.
+	Parent *Descendant
+	Family *Family
+	// The position of the person among the children of Parent in all of its
+	// families, from 1.
+	Child int
+	// The position of the person in its generation, from 1.
+	Index int
+	// The record or modified register number: the position of the person in
+	// the walk, from 1.
+	Record int
+	// Whether the person was found before, by pedigree collapse or a loop.
+	// Its descendants are only walked the first time.
+	Duplicate bool
+}
+
+// Get the d'Aboville number of d: 1 for the person the walk starts from, 1.1
+// for its first child, 1.1.2 for the second child of that child.
+func (d *Descendant) DAboville() string {
+	if d.Parent == nil {
+		return "1"
+	}
+	return d.Parent.DAboville() + "." + strconv.Itoa(d.Child)
+}
+
+// The digits of Henry numbers, as Gramps writes them.
+const henryDigits = "123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Get the Henry number of d: 1 for the person the walk starts from, 11 for
+// its first child, 112 for the second child of that child. The tenth child is
+// A, and children after the 35th are written in parentheses, e.g. 1(36).
+func (d *Descendant) Henry() string {
+	if d.Parent == nil {
+		return "1"
+	}
+	if d.Child <= len(henryDigits) {
+		return d.Parent.Henry() + henryDigits[d.Child-1:d.Child]
+	}
+	return fmt.Sprintf("%s(%d)", d.Parent.Henry(), d.Child)
+}
+
+// Get the Meurgey de Tupigny number of d: the generation in Roman numerals and
+// the position in it, e.g. II-3 for the third person of the second
+// generation. The person the walk starts from is I.
+func (d *Descendant) MeurgeyDeTupigny() string {
+	if d.Parent == nil {
+		return roman(d.Generation)
+	}
+	return fmt.Sprintf("%s-%d", roman(d.Generation), d.Index)
+}
+
+var romanNumerals = []struct {
+	value  int
+	symbol string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+	{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+// Get n in Roman numerals.
+func roman(n int) string {
+	var s []string
+	for _, r := range romanNumerals {
+		for ; n >= r.value; n -= r.value {
+			s = append(s, r.symbol)
+		}
+	}
+	return strings.Join(s, "")
+}
+
+// Call visit with p and its descendants generation by generation, up to
+// generations generations with p as the first, or all of them if generations
+// is 0. The children of each person are in the order of its families and of
+// their children. Stops when visit returns false.
+func (p *Person) WalkDescendants(db *Database, generations int, visit func(d *Descendant) bool) {
+	seen := map[*Person]bool{}
+	record := 0
+	gen := []*Descendant{{Person: p, Generation: 1}}
+	for len(gen) > 0 {
+		var next []*Descendant
+		for i, d := range gen {
+			record++
+			d.Index, d.Record = i+1, record
+			d.Duplicate = seen[d.Person]
+			seen[d.Person] = true
+			if !visit(d) {
+				return
+			}
+			if d.Duplicate || (generations > 0 && d.Generation >= generations) {
+				continue
+			}
+			child := 0
+			for _, f := range d.Person.families(db, d.Person.ParentIns) {
+				for _, ref := range f.ChildRefs {
+					if c := db.PersonByHandle(ref.HLink); c != nil {
+						child++
+						next = append(next, &Descendant{Person: c, Generation: d.Generation + 1,
+							Parent: d, Family: f, Child: child})
+					}
+				}
+			}
+		}
+		gen = next
+	}
+}
+
+// Get p and its descendants up to generations generations, as
+// WalkDescendants finds them.
+func (p *Person) Descendants(db *Database, generations int) []*Descendant {
+	var descendants []*Descendant
+	p.WalkDescendants(db, generations, func(d *Descendant) bool {
+		descendants = append(descendants, d)
+		return true
+	})
+	return descendants
+}
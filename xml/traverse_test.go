@@ -0,0 +1,146 @@
+package xml
+
+import (
+	"testing"
+)
+
+// Build a family tree of four generations, with a half-brother, an adopted
+// child and a marriage of second cousins.
+func familyTree(t *testing.T) (*Database, map[string]*Person) {
+	db := &Database{}
+	people := map[string]*Person{}
+	for _, p := range []struct{ name, gender string }{
+		{"ggf", "M"}, {"ggm", "F"}, {"gf", "M"}, {"gm", "F"}, {"ga", "F"}, {"gah", "M"},
+		{"father", "M"}, {"mother", "F"}, {"mother2", "F"}, {"uncle", "M"}, {"uncleWife", "F"},
+		{"x", "M"}, {"sister", "F"}, {"halfBrother", "M"}, {"adopted", "F"},
+		{"cousin", "F"}, {"gaChild", "M"}, {"cousinChild", "M"}, {"stranger", "U"},
+	} {
+		people[p.name] = addPerson(t, db)
+		people[p.name].Gender = p.gender
+	}
+	family := func(father, mother string, children ...string) *Family {
+		var c []*Person
+		for _, child := range children {
+			c = append(c, people[child])
+		}
+		addFamily(t, db, people[father], people[mother], c...)
+		return db.FamilyByHandle(people[father].ParentIns[len(people[father].ParentIns)-1].HLink)
+	}
+	family("ggf", "ggm", "gf", "ga")
+	family("gf", "gm", "father", "uncle")
+	family("ga", "gah", "gaChild")
+	parents := family("father", "mother", "x", "sister", "adopted")
+	family("father", "mother2", "halfBrother")
+	family("uncle", "uncleWife", "cousin")
+	family("gaChild", "cousin", "cousinChild")
+
+	parents.Rel = &Rel{Type: "Married"}
+	for _, ref := range parents.ChildRefs {
+		if ref.HLink == people["adopted"].Handle {
+			ref.FRel, ref.MRel = "Adopted", "Adopted"
+		}
+	}
+	return db, people
+}
+
+func TestAncestors(t *testing.T) {
+	db, people := familyTree(t)
+	var got []string
+	byPerson := map[*Person]string{}
+	for name, p := range people {
+		byPerson[p] = name
+	}
+	for _, a := range people["cousinChild"].Ancestors(db, 0) {
+		s := byPerson[a.Person]
+		if a.Duplicate {
+			s += "*"
+		}
+		got = append(got, s)
+		if a.Number == 24 && (a.Person != people["ggf"] || a.Generation != 5) {
+			t.Errorf("Got %s in generation %d for 24", byPerson[a.Person], a.Generation)
+		}
+	}
+	want := []string{"cousinChild", "gaChild", "cousin", "ga", "gah", "uncle", "uncleWife",
+		"ggf", "ggm", "gf", "gm", "ggf*", "ggm*"}
+	if len(got) != len(want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Ancestor %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	ancestors := people["x"].Ancestors(db, 2)
+	if len(ancestors) != 3 || ancestors[1].Number != 2 || ancestors[2].Number != 3 ||
+		ancestors[2].Person != people["mother"] || ancestors[2].Family == nil {
+		t.Errorf("Got %d ancestors in 2 generations", len(ancestors))
+	}
+
+	n := 0
+	people["x"].WalkAncestors(db, 0, func(a *Ancestor) bool {
+		n++
+		return a.Number < 3
+	})
+	if n != 3 {
+		t.Errorf("Walked %d ancestors after stopping", n)
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	db, people := familyTree(t)
+	descendants := people["ggf"].Descendants(db, 0)
+	want := []struct {
+		name                      string
+		daboville, henry, meurgey string
+		record                    int
+		duplicate                 bool
+	}{
+		{"ggf", "1", "1", "I", 1, false},
+		{"gf", "1.1", "11", "II-1", 2, false},
+		{"ga", "1.2", "12", "II-2", 3, false},
+		{"father", "1.1.1", "111", "III-1", 4, false},
+		{"uncle", "1.1.2", "112", "III-2", 5, false},
+		{"gaChild", "1.2.1", "121", "III-3", 6, false},
+		{"x", "1.1.1.1", "1111", "IV-1", 7, false},
+		{"sister", "1.1.1.2", "1112", "IV-2", 8, false},
+		{"adopted", "1.1.1.3", "1113", "IV-3", 9, false},
+		{"halfBrother", "1.1.1.4", "1114", "IV-4", 10, false},
+		{"cousin", "1.1.2.1", "1121", "IV-5", 11, false},
+		{"cousinChild", "1.2.1.1", "1211", "IV-6", 12, false},
+		{"cousinChild", "1.1.2.1.1", "11211", "V-1", 13, true},
+	}
+	if len(descendants) != len(want) {
+		t.Fatalf("Got %d descendants, want %d", len(descendants), len(want))
+	}
+	for i, w := range want {
+		d := descendants[i]
+		if d.Person != people[w.name] || d.DAboville() != w.daboville || d.Henry() != w.henry ||
+			d.MeurgeyDeTupigny() != w.meurgey || d.Record != w.record || d.Duplicate != w.duplicate {
+			t.Errorf("Descendant %d: got %s %s %s %d %v, want %+v", i, d.DAboville(), d.Henry(),
+				d.MeurgeyDeTupigny(), d.Record, d.Duplicate, w)
+		}
+	}
+
+	if n := len(people["ggf"].Descendants(db, 3)); n != 6 {
+		t.Errorf("Got %d descendants in 3 generations", n)
+	}
+}
+
+func TestDescendantNumbers(t *testing.T) {
+	root := &Descendant{Generation: 1}
+	d := &Descendant{Parent: root, Child: 10, Generation: 2, Index: 14}
+	d = &Descendant{Parent: d, Child: 36, Generation: 3, Index: 1}
+	if s := d.DAboville(); s != "1.10.36" {
+		t.Errorf("Got d'Aboville %s", s)
+	}
+	if s := d.Henry(); s != "1A(36)" {
+		t.Errorf("Got Henry %s", s)
+	}
+	if s := d.Parent.MeurgeyDeTupigny(); s != "II-14" {
+		t.Errorf("Got Meurgey de Tupigny %s", s)
+	}
+	if s := roman(1994); s != "MCMXCIV" {
+		t.Errorf("Got %s", s)
+	}
+}
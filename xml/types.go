@@ -7,19 +7,64 @@ import (
 )
 
 const (
-	XMLNamespace = `http://gramps-project.org/xml/1.5.0/`
+	namespacePrefix = `http://gramps-project.org/xml/`
+
+	// The most recent version of the XML format. A Database that was not
+	// parsed from a file is serialized in this version.
+	LatestVersion = "1.7.1"
+	XMLNamespace  = namespacePrefix + LatestVersion + "/"
 )
 
+// The versions of the XML format that can be parsed, oldest first.
+var Versions = []string{"1.5.0", "1.6.0", "1.7.0", "1.7.1"}
+
+// Get the XML namespace used by files of the given version.
+func Namespace(version string) string {
+	return namespacePrefix + version + "/"
+}
+
+// Get the version of the XML format that uses namespace ns. Returns false if
+// ns is not the namespace of a supported version.
+func VersionOf(ns string) (string, bool) {
+	for _, v := range Versions {
+		if Namespace(v) == ns {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// An element that is not parsed into a field, kept so that it can be written
+// back out: its name with its prefix, its attributes and its contents
+// verbatim, where it was among the elements of its parent.
 type raw struct {
 	XMLName  xml.Name
-	Contents string `xml:",innerxml"`
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Contents string     `xml:",innerxml"`
+
+	line   int
+	prefix string
+	// The offset of the end of the start tag in the input, until the prefix
+	// and position are found by placeUnparsed.
+	offset int64
+	// The number of parsed elements of the parent after this one. Counting
+	// from the end keeps elements that are added to the parent after the
+	// unparsed ones that were last.
+	following int
+}
+
+func (r *raw) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain raw
+	r.line, _ = d.InputPos()
+	r.offset = d.InputOffset()
+	return d.DecodeElement((*plain)(r), &start)
 }
 
 type Created struct {
 	Date    string `xml:"date,attr"`
 	Version string `xml:"version,attr"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ created"`
+	XMLName  xml.Name `xml:"created"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -34,7 +79,7 @@ type Researcher struct {
 	ResPhone    *string `xml:"resphone"`
 	ResEMail    *string `xml:"resemail"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ researcher"`
+	XMLName  xml.Name `xml:"researcher"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -43,7 +88,7 @@ type Header struct {
 	Researcher *Researcher `xml:"researcher"`
 	MediaPath  *string     `xml:"mediapath"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ header"`
+	XMLName  xml.Name `xml:"header"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -53,7 +98,7 @@ type NameFormat struct {
 	FmtStr string `xml:"fmt_str,attr"`
 	Active int    `xml:"active,attr,omitempty"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ format"`
+	XMLName  xml.Name `xml:"format"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -69,7 +114,7 @@ type Tag struct {
 	Color    string `xml:"color,attr"`
 	Priority string `xml:"priority,attr"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ tag"`
+	XMLName  xml.Name `xml:"tag"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -86,6 +131,8 @@ type dbObj struct {
 }
 
 func (o *dbObj) GetHandle() string { return o.Handle }
+func (o *dbObj) GetID() string     { return o.ID }
+func (o *dbObj) base() *dbObj      { return o }
 
 type dateCommon struct {
 	Quality   string `xml:"quality,attr,omitempty"`
@@ -98,7 +145,7 @@ type dateCommon struct {
 
 type DateStr struct {
 	Val      string   `xml:"val,attr"`
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ datestr"`
+	XMLName  xml.Name `xml:"datestr"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -107,7 +154,7 @@ type DateVal struct {
 	Type string `xml:"type,attr,omitempty"`
 	dateCommon
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ dateval"`
+	XMLName xml.Name `xml:"dateval"`
 }
 
 type DateRange struct {
@@ -125,23 +172,32 @@ type hasDate struct {
 	DateStr   *DateStr   `xml:"datestr"`
 }
 
-// Get a string representing the date (value, range or span).
+// Get a string representing the date (value, range or span), written by
+// DefaultDateDisplayer. Dates that cannot be interpreted are written as they
+// are in the file.
 func (v *hasDate) GetDateString() string {
+	if d, err := v.GetDate(); err == nil {
+		return DefaultDateDisplayer.Display(d)
+	}
+	lang := DefaultDateDisplayer.Lang
 	if v.DateVal != nil {
 		return v.DateVal.Val
 	}
 	if v.DateSpan != nil {
-		return fmt.Sprintf("from %s to %s", v.DateSpan.Start, v.DateSpan.Stop)
+		return fmt.Sprintf(lang.Span, v.DateSpan.Start, v.DateSpan.Stop)
 	}
 	if v.DateRange != nil {
-		return fmt.Sprintf("between %s and %s", v.DateRange.Start, v.DateRange.Stop)
-	}
-	if v.DateStr != nil {
-		return v.DateStr.Val
+		return fmt.Sprintf(lang.Range, v.DateRange.Start, v.DateRange.Stop)
 	}
 	return ""
 }
 
+// Set the date elements to the date in text, read by DefaultDateParser. Text
+// that is not a date is kept in a datestr element, as Gramps does.
+func (v *hasDate) SetDateString(text string) {
+	v.SetDate(DefaultDateParser.Parse(text))
+}
+
 // A link is a reference to a DBObj.  It is any element with an hlink attr.
 type Link interface {
 	GetHLink() string
@@ -165,7 +221,7 @@ type Attribute struct {
 	CitationRefs []*GenericLink `xml:"citationref"`
 	NoteRefs     []*GenericLink `xml:"noteref"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ attribute"`
+	XMLName  xml.Name `xml:"attribute"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -179,7 +235,8 @@ type Event struct {
 	NoteRefs     []*GenericLink `xml:"noteref"`
 	CitationRefs []*GenericLink `xml:"citationref"`
 	ObjRefs      []*ObjRef      `xml:"objref"`
-	XMLName      xml.Name       `xml:"http://gramps-project.org/xml/1.5.0/ event"`
+	TagRefs      []*GenericLink `xml:"tagref"`
+	XMLName      xml.Name       `xml:"event"`
 }
 
 type EventRef struct {
@@ -189,7 +246,7 @@ type EventRef struct {
 	Attributes []*Attribute   `xml:"attribute"`
 	NoteRefs   []*GenericLink `xml:"noteref"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ eventref"`
+	XMLName  xml.Name `xml:"eventref"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -208,7 +265,7 @@ type Region struct {
 	Corner1Y int      `xml:"corner1_y,attr"`
 	Corner2X int      `xml:"corner2_x,attr"`
 	Corner2Y int      `xml:"corner2_y,attr"`
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ region"`
+	XMLName  xml.Name `xml:"region"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -220,18 +277,19 @@ type ObjRef struct {
 	CitationRefs []*GenericLink `xml:"citationref"`
 	NoteRefs     []*GenericLink `xml:"noteref"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ objref"`
+	XMLName  xml.Name `xml:"objref"`
 	Unparsed []*raw   `xml:",any"`
 }
 
 type Surname struct {
-	Prefix     string `xml:"prefix,attr,omitempty"`
-	Prim       int    `xml:"prim,attr,omitempty"`
+	Prefix string `xml:"prefix,attr,omitempty"`
+	// "0" for a secondary surname. The default, "", means primary.
+	Prim       string `xml:"prim,attr,omitempty"`
 	Derivation string `xml:"derivation,attr,omitempty"`
 	Connector  string `xml:"connector,attr,omitempty"`
 	Value      string `xml:",chardata"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ surname"`
+	XMLName  xml.Name `xml:"surname"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -243,21 +301,27 @@ type Name struct {
 	Sort    int `xml:"sort,attr,omitempty"`
 	Display int `xml:"display,attr,omitempty"`
 
-	First        *string        `xml:"first"`
-	Call         *string        `xml:"call"`
-	Surnames     []*Surname     `xml:"surname"`
-	Suffix       *string        `xml:"suffix"`
-	Title        *string        `xml:"title"`
-	Nick         *string        `xml:"nick"`
-	FamilyNick   *string        `xml:"familynick"`
+	First      *string    `xml:"first"`
+	Call       *string    `xml:"call"`
+	Surnames   []*Surname `xml:"surname"`
+	Suffix     *string    `xml:"suffix"`
+	Title      *string    `xml:"title"`
+	Nick       *string    `xml:"nick"`
+	FamilyNick *string    `xml:"familynick"`
+	// The surname group of the name, if it is not the group of its primary
+	// surname.
+	Group *string `xml:"group"`
 	hasDate
 	NoteRefs     []*GenericLink `xml:"noteref"`
 	CitationRefs []*GenericLink `xml:"citationref"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ name"`
+	XMLName  xml.Name `xml:"name"`
 	Unparsed []*raw   `xml:",any"`
 }
 
+// Whether this is the primary surname of a name.
+func (s *Surname) IsPrimary() bool { return s.Prim != "0" }
+
 func (n *Name) GetSurname() string {
 	if len(n.Surnames) > 0 {
 		return n.Surnames[0].Value
@@ -272,7 +336,8 @@ func (n *Name) GetFirstName() string {
 	return *n.First
 }
 
-func (n *Name) String() string { return n.GetSurname() + ", " + n.GetFirstName() }
+// Format the name by its display format, with the formats built into Gramps.
+func (n *Name) String() string { return DefaultNameDisplayer.Display(n) }
 
 // A short form of the name: surname plus the first part of the firstname.
 func (n *Name) Short() string {
@@ -282,13 +347,13 @@ func (n *Name) Short() string {
 type Temple struct {
 	Val      string   `xml:"val,attr"`
 	Unparsed []*raw   `xml:",any"`
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ temple"`
+	XMLName  xml.Name `xml:"temple"`
 }
 
 type Status struct {
 	Val      string   `xml:"val,attr"`
 	Unparsed []*raw   `xml:",any"`
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ status"`
+	XMLName  xml.Name `xml:"status"`
 }
 
 type LDSOrd struct {
@@ -303,7 +368,7 @@ type LDSOrd struct {
 	NoteRefs     []*GenericLink `xml:"noteref"`
 	CitationRefs []*GenericLink `xml:"citationref"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ lds_ord"`
+	XMLName  xml.Name `xml:"lds_ord"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -322,7 +387,7 @@ type Address struct {
 	NoteRefs     []*GenericLink `xml:"noteref"`
 	CitationRefs []*GenericLink `xml:"citationref"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ address"`
+	XMLName  xml.Name `xml:"address"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -334,7 +399,7 @@ type PersonRef struct {
 	NoteRefs     []*GenericLink `xml:"noteref"`
 	CitationRefs []*GenericLink `xml:"citationref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ personref"`
+	XMLName xml.Name `xml:"personref"`
 }
 
 type Person struct {
@@ -355,7 +420,7 @@ type Person struct {
 	CitationRefs []*GenericLink `xml:"citationref"`
 	TagRefs      []*GenericLink `xml:"tagref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ person"`
+	XMLName xml.Name `xml:"person"`
 }
 
 // The preferred name is the first name that's not marked as an alternate.
@@ -381,7 +446,7 @@ func (p *Person) FindEventRef(e Event) *EventRef {
 type People struct {
 	Home     string    `xml:"home,attr"`
 	Persons  []*Person `xml:"person"`
-	XMLName  xml.Name  `xml:"http://gramps-project.org/xml/1.5.0/ people"`
+	XMLName  xml.Name  `xml:"people"`
 	Unparsed []*raw    `xml:",any"`
 }
 
@@ -395,7 +460,7 @@ type ChildRef struct {
 	CitationRefs []*GenericLink `xml:"citationref"`
 	NoteRefs     []*GenericLink `xml:"noteref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ childref"`
+	XMLName xml.Name `xml:"childref"`
 }
 
 type Rel struct {
@@ -417,7 +482,7 @@ type Family struct {
 	CitationRefs []*GenericLink `xml:"citationref"`
 	TagRefs      []*GenericLink `xml:"tagref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ family"`
+	XMLName xml.Name `xml:"family"`
 }
 
 type Citation struct {
@@ -429,16 +494,30 @@ type Citation struct {
 
 	NoteRefs  []*GenericLink `xml:"noteref"`
 	ObjRefs   []*ObjRef      `xml:"objref"`
-	SourceRef GenericLink    `xml:"sourceref"`
+	DataItems []*DataItem    `xml:"data_item"`
+	// SrcAttributes replaced DataItems in 1.6.0.
+	SrcAttributes []*SrcAttribute `xml:"srcattribute"`
+	Attributes    []*Attribute    `xml:"attribute"`
+	SourceRef     GenericLink     `xml:"sourceref"`
+	TagRefs       []*GenericLink  `xml:"tagref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ citation"`
+	XMLName xml.Name `xml:"citation"`
 }
 
 type DataItem struct {
 	Key   string `xml:"key,attr"`
 	Value string `xml:"value,attr"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ data_item"`
+	XMLName  xml.Name `xml:"data_item"`
+	Unparsed []*raw   `xml:",any"`
+}
+
+type SrcAttribute struct {
+	Priv  int    `xml:"priv,attr,omitempty"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+
+	XMLName  xml.Name `xml:"srcattribute"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -448,30 +527,35 @@ type RepoRef struct {
 	CallNo string `xml:"callno,attr,omitempty"`
 	Medium string `xml:"medium,attr,omitempty"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ reporef"`
+	XMLName  xml.Name `xml:"reporef"`
 	Unparsed []*raw   `xml:",any"`
 }
 
 type Source struct {
 	dbObj
 
-	STitle    *string        `xml:"stitle"`
-	SAuthor   *string        `xml:"sauthor"`
-	SPubInfo  *string        `xml:"spubinfo"`
-	SAbbrev   *string        `xml:"sabbrev"`
-	NoteRefs  []*GenericLink `xml:"noteref"`
-	ObjRefs   []*ObjRef      `xml:"objref"`
-	DataItems []*DataItem    `xml:"data_item"`
-	RepoRefs  []*RepoRef     `xml:"reporef"`
+	STitle     *string        `xml:"stitle"`
+	SAuthor    *string        `xml:"sauthor"`
+	SPubInfo   *string        `xml:"spubinfo"`
+	SAbbrev    *string        `xml:"sabbrev"`
+	SourceText *string        `xml:"sourcetext"`
+	NoteRefs   []*GenericLink `xml:"noteref"`
+	ObjRefs    []*ObjRef      `xml:"objref"`
+	DataItems  []*DataItem    `xml:"data_item"`
+	// SrcAttributes replaced DataItems in 1.6.0.
+	SrcAttributes []*SrcAttribute `xml:"srcattribute"`
+	Attributes    []*Attribute    `xml:"attribute"`
+	RepoRefs      []*RepoRef      `xml:"reporef"`
+	TagRefs       []*GenericLink  `xml:"tagref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ source"`
+	XMLName xml.Name `xml:"source"`
 }
 
 type Coord struct {
 	Long string `xml:"long,attr"`
 	Lat  string `xml:"lat,attr"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ coord"`
+	XMLName  xml.Name `xml:"coord"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -486,7 +570,7 @@ type Location struct {
 	Postal   string `xml:"postal,attr,omitempty"`
 	Phone    string `xml:"phone,attr,omitempty"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ location"`
+	XMLName  xml.Name `xml:"location"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -496,22 +580,56 @@ type URL struct {
 	Type        string `xml:"type,attr,omitempty"`
 	Description string `xml:"description,attr,omitempty"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ url"`
+	XMLName  xml.Name `xml:"url"`
 	Unparsed []*raw   `xml:",any"`
 }
 
+// A PName is one of the names of a place (1.6.0 and later).
+type PName struct {
+	Lang  string `xml:"lang,attr,omitempty"`
+	Value string `xml:"value,attr"`
+	hasDate
+
+	XMLName  xml.Name `xml:"pname"`
+	Unparsed []*raw   `xml:",any"`
+}
+
+// A PlaceRef links a place to the place enclosing it (1.6.0 and later).
+type PlaceRef struct {
+	GenericLink
+	Priv int `xml:"priv,attr,omitempty"`
+
+	hasDate
+	CitationRefs []*GenericLink `xml:"citationref"`
+	NoteRefs     []*GenericLink `xml:"noteref"`
+
+	XMLName xml.Name `xml:"placeref"`
+}
+
+// Before 1.6.0 the parts of a place's address are attributes of its
+// Locations. Later versions give places a Type and Names and describe the
+// address by a hierarchy of PlaceRefs to enclosing places.
 type PlaceObj struct {
 	dbObj
+	Type string `xml:"type,attr,omitempty"`
 
 	PTitle       *string        `xml:"ptitle"`
+	PNames       []*PName       `xml:"pname"`
+	Code         *string        `xml:"code"`
 	Coord        *Coord         `xml:"coord"`
+	PlaceRefs    []*PlaceRef    `xml:"placeref"`
 	Locations    []*Location    `xml:"location"`
 	ObjRefs      []*ObjRef      `xml:"objref"`
 	URLs         []*URL         `xml:"url"`
 	NoteRefs     []*GenericLink `xml:"noteref"`
 	CitationRefs []*GenericLink `xml:"citationref"`
+	TagRefs      []*GenericLink `xml:"tagref"`
+
+	XMLName xml.Name `xml:"placeobj"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ placeobj"`
+	// Whether Migrate created the place as an enclosing place when upgrading
+	// from 1.5.0.
+	generated bool
 }
 
 type File struct {
@@ -519,7 +637,7 @@ type File struct {
 	Mime        string `xml:"mime,attr"`
 	Description string `xml:"description,attr"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ file"`
+	XMLName  xml.Name `xml:"file"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -533,7 +651,7 @@ type Object struct {
 	CitationRefs []*GenericLink `xml:"citationref"`
 	TagRefs      []*GenericLink `xml:"tagref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ object"`
+	XMLName xml.Name `xml:"object"`
 }
 
 type Repository struct {
@@ -544,15 +662,16 @@ type Repository struct {
 	Addresses []*Address     `xml:"address"`
 	URL       *URL           `xml:"url"`
 	NoteRefs  []*GenericLink `xml:"noteref"`
+	TagRefs   []*GenericLink `xml:"tagref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ repository"`
+	XMLName xml.Name `xml:"repository"`
 }
 
 type Range struct {
 	Start string `xml:"start,attr"`
 	End   string `xml:"end,attr"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ range"`
+	XMLName  xml.Name `xml:"range"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -561,7 +680,7 @@ type Style struct {
 	Value  string   `xml:"value,attr,omitempty"`
 	Ranges []*Range `xml:"range"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ style"`
+	XMLName  xml.Name `xml:"style"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -575,14 +694,14 @@ type Note struct {
 	Styles  []*Style       `xml:"style"`
 	TagRefs []*GenericLink `xml:"tagref"`
 
-	XMLName xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ note"`
+	XMLName xml.Name `xml:"note"`
 }
 
 type Bookmark struct {
 	Target string `xml:"target,attr"`
 	GenericLink
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ bookmark"`
+	XMLName  xml.Name `xml:"bookmark"`
 	Unparsed []*raw   `xml:",any"`
 }
 
@@ -591,11 +710,14 @@ type NameMap struct {
 	Key   string `xml:"key,attr"`
 	Value string `xml:"value,attr"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ map"`
+	XMLName  xml.Name `xml:"map"`
 	Unparsed []*raw   `xml:",any"`
 }
 
-// A Database represents an entire Gramps XML file.  
+// A Database represents an entire Gramps XML file. Methods that only read
+// the Database, including the lookups by handle and ID and Referrers, may be
+// called from several goroutines at once; changing it, including by Add and
+// Remove, may not.
 type Database struct {
 	Header       Header        `xml:"header"`
 	NameFormats  []*NameFormat `xml:"name-formats>format"`
@@ -612,6 +734,16 @@ type Database struct {
 	Bookmarks    []*Bookmark   `xml:"bookmarks>bookmark"`
 	NameMaps     []*NameMap    `xml:"namemaps>map"`
 
-	XMLName  xml.Name `xml:"http://gramps-project.org/xml/1.5.0/ database"`
+	XMLName  xml.Name `xml:"database"`
 	Unparsed []*raw   `xml:",any"`
+
+	index *index
+}
+
+// Get the version of the XML format the Database was parsed from.
+func (db *Database) Version() string {
+	if v, ok := VersionOf(db.XMLName.Space); ok {
+		return v
+	}
+	return LatestVersion
 }
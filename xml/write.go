@@ -0,0 +1,260 @@
+package xml
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// The order of the top-level elements of a Database.
+var topLevelOrder = []string{"header", "name-formats", "tags", "events",
+	"people", "families", "citations", "sources", "places", "objects",
+	"repositories", "notes", "bookmarks", "namemaps"}
+
+// Options controlling how a Database is written.
+type WriteOptions struct {
+	// The version of the XML format to write. Defaults to the version of the
+	// Database. No migration is done; see Migrate.
+	Version string
+	// Write plain XML (e.g. for .xml files) instead of gzipping it.
+	Uncompressed bool
+	// The gzip compression level, from gzip.HuffmanOnly to
+	// gzip.BestCompression. Zero, which is gzip.NoCompression to package
+	// gzip, means gzip.DefaultCompression here; there is no way to store
+	// without compression inside gzip, so use Uncompressed to write plain XML.
+	Level int
+}
+
+// An Encoder writes the top-level objects of a .gramps XML file one at a time.
+// It is the counterpart of Decoder. The output is formatted exactly as Gramps
+// formats its exports.
+type Encoder struct {
+	w          io.Writer
+	zipped     *gzip.Writer
+	cw         canonicalWriter
+	collection string
+	order      int
+}
+
+// Create an Encoder writing to w. The XML declaration, DOCTYPE and the start
+// of the root element are written immediately. Nothing is written to w after
+// Close; it is not closed.
+func NewEncoder(w io.Writer, opts WriteOptions) (*Encoder, error) {
+	version := opts.Version
+	if version == "" {
+		version = LatestVersion
+	}
+	if _, ok := VersionOf(Namespace(version)); !ok {
+		return nil, fmt.Errorf("Unsupported version: %s", version)
+	}
+
+	if opts.Level < gzip.HuffmanOnly || opts.Level > gzip.BestCompression {
+		return nil, fmt.Errorf("Invalid compression level: %d", opts.Level)
+	}
+
+	enc := &Encoder{w: w}
+	if !opts.Uncompressed {
+		level := opts.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		zipped, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		enc.zipped = zipped
+		enc.w = zipped
+	}
+
+	fmt.Fprintf(&enc.cw.buf, "%s\n<!DOCTYPE database PUBLIC \"-//Gramps//DTD Gramps XML %s//EN\"\n\"%sgrampsxml.dtd\">\n",
+		xml.Header[:len(xml.Header)-1], version, Namespace(version))
+	enc.cw.open("database", []string{`xmlns="` + Namespace(version) + `"`}, 0)
+	return enc, enc.flush()
+}
+
+func (enc *Encoder) flush() error {
+	_, err := enc.w.Write(enc.cw.buf.Bytes())
+	enc.cw.buf.Reset()
+	return err
+}
+
+// Move to the top-level element name, closing the current collection and
+// opening the collection name if needed. Elements must be written in order.
+func (enc *Encoder) moveTo(name string, attrs []string) error {
+	if name == enc.collection {
+		return nil
+	}
+	i := enc.order
+	for i < len(topLevelOrder) && topLevelOrder[i] != name {
+		i++
+	}
+	if i == len(topLevelOrder) {
+		return fmt.Errorf("%s written out of order", name)
+	}
+	if enc.collection != "" {
+		enc.cw.close(enc.collection, 1)
+	}
+	enc.order, enc.collection = i+1, ""
+	if name == "header" {
+		return nil
+	}
+	enc.collection = name
+	enc.cw.open(name, attrs, 1)
+	return nil
+}
+
+// Write obj, which must be one of the types returned by Decoder.Next, and
+// must come after any previously written objects in a Database's order. A
+// *People starts the people element and writes any Persons it has.
+func (enc *Encoder) Encode(obj interface{}) error {
+	if people, ok := obj.(*People); ok {
+		var attrs []string
+		if people.Home != "" {
+			attrs = []string{`home="` + escape(people.Home) + `"`}
+		}
+		if err := enc.moveTo("people", attrs); err != nil {
+			return err
+		}
+		n := len(people.Persons)
+		for i, p := range people.Persons {
+			enc.cw.rawsBefore(people.Unparsed, i, n, 4)
+			enc.cw.element("person", reflect.ValueOf(p), 2)
+		}
+		enc.cw.rawsBefore(people.Unparsed, n, n, 4)
+		return enc.flush()
+	}
+
+	name := topLevelName(obj)
+	if name == "" {
+		return fmt.Errorf("Cannot encode %T", obj)
+	}
+	if err := enc.moveTo(name, nil); err != nil {
+		return err
+	}
+	if name == "header" {
+		enc.cw.element(name, reflect.ValueOf(obj), 1)
+	} else {
+		enc.cw.element(collections[name].elem, reflect.ValueOf(obj), 2)
+	}
+	return enc.flush()
+}
+
+func topLevelName(obj interface{}) string {
+	switch obj.(type) {
+	case *Header:
+		return "header"
+	case *NameFormat:
+		return "name-formats"
+	case *Tag:
+		return "tags"
+	case *Event:
+		return "events"
+	case *Person:
+		return "people"
+	case *Family:
+		return "families"
+	case *Citation:
+		return "citations"
+	case *Source:
+		return "sources"
+	case *PlaceObj:
+		return "places"
+	case *Object:
+		return "objects"
+	case *Repository:
+		return "repositories"
+	case *Note:
+		return "notes"
+	case *Bookmark:
+		return "bookmarks"
+	case *NameMap:
+		return "namemaps"
+	}
+	return ""
+}
+
+// Write the unparsed elements of a Database that were before top-level
+// element i of n, or after the last if i is n.
+func (enc *Encoder) unparsed(raws []*raw, i, n int) {
+	var w canonicalWriter
+	w.rawsBefore(raws, i, n, 2)
+	if w.buf.Len() == 0 {
+		return
+	}
+	if enc.collection != "" {
+		enc.cw.close(enc.collection, 1)
+		enc.collection = ""
+	}
+	enc.cw.buf.Write(w.buf.Bytes())
+}
+
+// Close finishes the root element and flushes the output.
+func (enc *Encoder) Close() error {
+	if enc.collection != "" {
+		enc.cw.close(enc.collection, 1)
+		enc.collection = ""
+	}
+	enc.cw.close("database", 0)
+	if err := enc.flush(); err != nil {
+		return err
+	}
+	if enc.zipped != nil {
+		return enc.zipped.Close()
+	}
+	return nil
+}
+
+// Write the Database to w as a .gramps XML file, one element at a time.
+func (db *Database) Write(w io.Writer, opts WriteOptions) error {
+	if opts.Version == "" {
+		opts.Version = db.Version()
+	}
+	enc, err := NewEncoder(w, opts)
+	if err != nil {
+		return err
+	}
+
+	encode := func(obj interface{}) {
+		if err == nil {
+			err = enc.Encode(obj)
+		}
+	}
+	// The top-level elements, one function each, so that the unparsed
+	// elements between them are written where they were.
+	groups := []func(){func() { encode(&db.Header) }}
+	group := func(n int, obj func(i int) interface{}) {
+		if n > 0 {
+			groups = append(groups, func() {
+				for i := 0; i < n; i++ {
+					encode(obj(i))
+				}
+			})
+		}
+	}
+	group(len(db.NameFormats), func(i int) interface{} { return db.NameFormats[i] })
+	group(len(db.Tags), func(i int) interface{} { return db.Tags[i] })
+	group(len(db.Events), func(i int) interface{} { return db.Events[i] })
+	if db.People.Home != "" || len(db.People.Persons) > 0 || len(db.People.Unparsed) > 0 {
+		groups = append(groups, func() { encode(&db.People) })
+	}
+	group(len(db.Families), func(i int) interface{} { return db.Families[i] })
+	group(len(db.Citations), func(i int) interface{} { return db.Citations[i] })
+	group(len(db.Sources), func(i int) interface{} { return db.Sources[i] })
+	group(len(db.Places), func(i int) interface{} { return db.Places[i] })
+	group(len(db.Objects), func(i int) interface{} { return db.Objects[i] })
+	group(len(db.Repositories), func(i int) interface{} { return db.Repositories[i] })
+	group(len(db.Notes), func(i int) interface{} { return db.Notes[i] })
+	group(len(db.Bookmarks), func(i int) interface{} { return db.Bookmarks[i] })
+	group(len(db.NameMaps), func(i int) interface{} { return db.NameMaps[i] })
+	for i, g := range groups {
+		enc.unparsed(db.Unparsed, i, len(groups))
+		g()
+	}
+	if err != nil {
+		return err
+	}
+	enc.unparsed(db.Unparsed, len(groups), len(groups))
+	return enc.Close()
+}
@@ -0,0 +1,84 @@
+package xml
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestWriteUncompressed(t *testing.T) {
+	db := parseExample(t, "example-1.7.1.gramps")
+	var buf bytes.Buffer
+	if err := db.Write(&buf, WriteOptions{Uncompressed: true}); err != nil {
+		t.Fatalf("Failed to write db: %s", err)
+	}
+	prolog := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE database PUBLIC "-//Gramps//DTD Gramps XML 1.7.1//EN"
+"http://gramps-project.org/xml/1.7.1/grampsxml.dtd">
+<database xmlns="http://gramps-project.org/xml/1.7.1/">
+`
+	if !strings.HasPrefix(buf.String(), prolog) {
+		t.Errorf("Expected prolog %s, got %s", prolog, buf.String()[:len(prolog)])
+	}
+	if strings.Count(buf.String(), "xmlns") != 1 {
+		t.Errorf("Expected the namespace only on the root element")
+	}
+}
+
+func TestWriteCompressed(t *testing.T) {
+	db := parseExample(t, "example-1.7.1.gramps")
+	var buf bytes.Buffer
+	err := db.Write(&buf, WriteOptions{Level: gzip.BestSpeed, Version: "1.6.0"})
+	if err != nil {
+		t.Fatalf("Failed to write db: %s", err)
+	}
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Failed to parse written db: %s", err)
+	}
+	if parsed.Version() != "1.6.0" || len(parsed.Places) != len(db.Places) {
+		t.Errorf("Written db differs: version %s, %d places",
+			parsed.Version(), len(parsed.Places))
+	}
+}
+
+// Zero is the default level, and levels gzip does not have are rejected.
+func TestWriteLevel(t *testing.T) {
+	db := &Database{}
+	var def, zero bytes.Buffer
+	if err := db.Write(&def, WriteOptions{Level: gzip.DefaultCompression}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Write(&zero, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(def.Bytes(), zero.Bytes()) {
+		t.Error("Level 0 is not the default compression")
+	}
+	for _, level := range []int{-3, 10} {
+		if err := db.Write(&bytes.Buffer{}, WriteOptions{Level: level}); err == nil {
+			t.Errorf("Wrote with level %d", level)
+		}
+		if err := db.Write(&bytes.Buffer{}, WriteOptions{Level: level, Uncompressed: true}); err == nil {
+			t.Errorf("Wrote uncompressed with level %d", level)
+		}
+	}
+}
+
+func TestEncoderOrder(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, WriteOptions{Uncompressed: true})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %s", err)
+	}
+	if err := enc.Encode(&Family{}); err != nil {
+		t.Fatalf("Failed to encode family: %s", err)
+	}
+	if err := enc.Encode(&Person{}); err == nil {
+		t.Errorf("Expected an error encoding a person after a family")
+	}
+	if err := enc.Encode(42); err == nil {
+		t.Errorf("Expected an error encoding an int")
+	}
+}